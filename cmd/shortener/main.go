@@ -7,7 +7,7 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"io"
 	"net"
 	"net/http"
 	"os/signal"
@@ -15,18 +15,31 @@ import (
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/golangTroshin/shorturl/internal/app/broker"
 	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
 	grpcServer "github.com/golangTroshin/shorturl/internal/app/grpc/handlers"
 	interceptor "github.com/golangTroshin/shorturl/internal/app/grpc/interceptor"
 	shortener "github.com/golangTroshin/shorturl/internal/app/grpc/proto"
+	"github.com/golangTroshin/shorturl/internal/app/health"
+	"github.com/golangTroshin/shorturl/internal/app/lifecycle"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
 	"github.com/golangTroshin/shorturl/internal/app/service"
+	"go.uber.org/zap"
 
 	"github.com/golangTroshin/shorturl/internal/app/helpers"
 	"github.com/golangTroshin/shorturl/internal/app/http/handlers"
 	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/linkcheck"
 	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/metrics"
 	storageSvc "github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/golangTroshin/shorturl/internal/app/tracing"
+	"github.com/golangTroshin/shorturl/internal/app/webhooks"
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 var (
@@ -39,9 +52,18 @@ var (
 //
 // It performs the following tasks:
 //   - Parses configuration values from flags and environment variables using `config.ParseFlags`.
+//   - Builds the application logger using `logger.New`.
+//   - Sets up the span exporter using `tracing.Setup`.
 //   - Initializes the storage system based on the provided configuration using `storageSvc.GetStorageByConfig`.
-//   - Sets up a background worker for URL deletions using `service.StartDeleteWorker`.
+//   - Sets up the deletion job queue using `DeleteQueue` and its worker using `deletes.NewWorker`.
+//   - Sets up the link event broker using `broker.New`, fed by the service and the deletion worker.
+//   - Sets up the webhook subscriptions using `WebhookStore` and their delivery worker using `webhooks.NewWorker`.
+//   - Starts the destination page fetch workers using `service.StartFetchWorkers`.
+//   - Starts the periodic dead-link checker using `service.StartLinkChecker`.
 //   - Starts the HTTP server with routes defined in the `Router` function.
+//   - Starts the admin server with routes defined in the `AdminRouter` function.
+//   - Shuts down in stages using `lifecycle.Manager`: ends the watch streams, stops the servers,
+//     drains the delete queue, stops the webhook worker, then flushes and closes storage.
 //
 // Logs errors if configuration parsing, storage initialization, or server startup fails.
 func main() {
@@ -50,48 +72,128 @@ func main() {
 	fmt.Printf("Build date: %s\n", buildDate)
 	fmt.Printf("Build commit: %s\n", buildCommit)
 
-	if err := config.ParseFlags(); err != nil {
-		log.Printf("error occurred while parsing flags: %v", err)
+	flagsErr := config.ParseFlags()
+
+	log, err := logger.New(logger.Options{
+		Level:    config.Options.LogLevel,
+		Format:   config.Options.LogFormat,
+		Sampling: config.Options.LogSampling,
+	})
+	if err != nil {
+		logger.Default().Fatal("failed to initialize logger", zap.Error(err))
+	}
+	defer log.Sync()
+	logger.SetDefault(log)
+	defer zap.RedirectStdLog(log)()
+
+	if flagsErr != nil {
+		log.Error("error occurred while parsing flags", zap.Error(flagsErr))
 	}
 
-	storage, err := storageSvc.GetStorageByConfig()
-	svc := service.NewURLService(storage)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter: config.Options.TracingExporter,
+		Endpoint: config.Options.TracingEndpoint,
+	})
+	if err != nil {
+		log.Fatal("failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to flush traces", zap.Error(err))
+		}
+	}()
 
+	storage, err := storageSvc.GetStorageByConfig(metrics.ObserveStorageOperation)
+	if err != nil {
+		log.Fatal("failed to initialize storage", zap.Error(err))
+	}
+	deleteQueue, err := DeleteQueue(context.Background(), storage)
 	if err != nil {
-		log.Printf("failed to initialize storage: %v", err)
+		log.Fatal("failed to initialize delete queue", zap.Error(err))
+	}
+	events := broker.New(broker.DefaultBuffer)
+	urlService := service.NewURLService(storage).WithDeleteQueue(deleteQueue).WithBroker(events)
+	deleteWorker := deletes.NewWorker(deleteQueue, storage, deletes.Options{Deleted: urlService.LinksDeleted})
+	if err := metrics.RegisterDeleteQueue(deleteWorker.Depth); err != nil {
+		log.Error("failed to register delete queue metrics", zap.Error(err))
+	}
+	var svc service.Service = urlService
+	if config.Options.TracingExporter != "" {
+		svc = service.NewTracedService(svc)
 	}
-	defer storageSvc.CloseDB()
 
-	go service.StartDeleteWorker(storage)
+	// The worker outlives the signal context: it stops once the delete queue is drained.
+	go deleteWorker.Run(context.Background())
+
+	hooks, err := WebhookStore(context.Background(), storage)
+	if err != nil {
+		log.Fatal("failed to initialize webhook store", zap.Error(err))
+	}
+	var webhookWorker *webhooks.Worker
+	if outbox, ok := storageSvc.FindOutbox(storage); ok {
+		webhookWorker = webhooks.NewWorker(outbox, hooks, webhooks.Options{BaseURL: config.Options.FlagBaseURL})
+		go webhookWorker.Run(context.Background())
+	}
 
 	// Create context with cancellation
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer stop()
 
+	go service.StartFetchWorkers(ctx, storage, fetcher.New(fetcher.Options{}), config.Options.FetchWorkers)
+	go service.StartLinkChecker(ctx, storage, linkcheck.New(linkcheck.Options{}), config.Options.LinkCheckInterval)
+	if cached, ok := storage.(*storageSvc.CachedStore); ok {
+		go cached.StartInvalidationListener(ctx)
+		if err := metrics.RegisterCache(cached); err != nil {
+			log.Error("failed to register cache metrics", zap.Error(err))
+		}
+	}
+
 	// Start gRPC server
 	grpcListener, err := net.Listen("tcp", ":50051")
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		log.Fatal("failed to listen", zap.Error(err))
 	}
 	// Create a gRPC server with interceptors
 	grpcSrv := grpc.NewServer(
 		grpc.ChainUnaryInterceptor(
+			requestid.UnaryServerInterceptor,
+			tracing.UnaryServerInterceptor,
+			metrics.UnaryServerInterceptor,
+			logger.UnaryServerInterceptor,
 			interceptor.GiveAuthTokenToUserInterceptor, // Generates the token
 			interceptor.CheckAuthTokenInterceptor,      // Validates the token
 		),
+		grpc.ChainStreamInterceptor(
+			requestid.StreamServerInterceptor,
+			tracing.StreamServerInterceptor,
+			metrics.StreamServerInterceptor,
+			logger.StreamServerInterceptor,
+			interceptor.GiveAuthTokenToUserStreamInterceptor,
+			interceptor.CheckAuthTokenStreamInterceptor,
+		),
 	)
 	shortener.RegisterShortenerServer(grpcSrv, grpcServer.NewShortenerServer(svc))
+
+	lc := lifecycle.New()
+	checks := HealthChecks(storage, deleteWorker, webhookWorker)
+	checks.AddReadinessCheck("shutdown", lc)
+	healthSrv := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, healthSrv)
+	go checks.WatchGRPC(ctx, healthSrv, 10*time.Second, shortener.Shortener_ServiceDesc.ServiceName)
+
 	go func() {
-		log.Println("gRPC server is running on :50051")
+		log.Info("gRPC server is running", zap.String("address", ":50051"))
 		if err := grpcSrv.Serve(grpcListener); err != nil {
-			log.Fatalf("failed to serve gRPC: %v", err)
+			log.Fatal("failed to serve gRPC", zap.Error(err))
 		}
 	}()
 
 	// Start HTTP server
 	srv := &http.Server{
 		Addr:    config.Options.FlagServiceAddress,
-		Handler: Router(svc),
+		Handler: Router(svc, checks, hooks),
 	}
 
 	go func() {
@@ -106,26 +208,70 @@ func main() {
 		}
 
 		if err != nil && err != http.ErrServerClosed {
-			log.Printf("server error: %v", err)
+			log.Error("server error", zap.Error(err))
 		}
 	}()
 
-	log.Println("Server is running...")
+	// Start admin server
+	var adminSrv *http.Server
+	if config.Options.AdminAddress != "" {
+		adminSrv = &http.Server{
+			Addr:    config.Options.AdminAddress,
+			Handler: AdminRouter(),
+		}
+		go func() {
+			log.Info("admin server is running", zap.String("address", config.Options.AdminAddress))
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("admin server error", zap.Error(err))
+			}
+		}()
+	}
+
+	// Stop accepting work first, then drain what was accepted, and persist it last.
+	lc.Add("readiness", func(context.Context) error {
+		healthSrv.Shutdown()
+		return nil
+	})
+	// Watch streams never end on their own, so they are ended before the servers wait for them.
+	lc.Add("watch", func(context.Context) error {
+		events.Close()
+		return nil
+	})
+	lc.Add("http", srv.Shutdown)
+	lc.Add("grpc", lifecycle.GRPCServer(grpcSrv))
+	lc.Add("deletes", deleteWorker.Shutdown)
+	if webhookWorker != nil {
+		lc.Add("webhooks", webhookWorker.Shutdown)
+	}
+	lc.Add("flush", func(ctx context.Context) error {
+		return storageSvc.Flush(ctx, storage)
+	})
+	if closer, ok := storage.(io.Closer); ok {
+		lc.Add("storage", func(context.Context) error {
+			return closer.Close()
+		})
+	}
+	if adminSrv != nil {
+		lc.Add("admin", adminSrv.Shutdown)
+	}
+
+	log.Info("server is running", zap.String("address", config.Options.FlagServiceAddress))
 
 	// Wait for termination signal
 	<-ctx.Done()
-	log.Println("Shutdown signal received")
+	log.Info("shutdown signal received")
 
 	// Create context for server shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Options.ShutdownTimeout)
 	defer cancel()
 
 	// Gracefully shutdown server
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Printf("server shutdown failed: %v", err)
+	if err := lc.Shutdown(shutdownCtx); err != nil {
+		log.Error("graceful shutdown failed", zap.Error(err))
+		return
 	}
 
-	log.Println("Server gracefully stopped")
+	log.Info("server gracefully stopped")
 }
 
 // Router sets up and returns a Chi router instance for the application.
@@ -135,24 +281,46 @@ func main() {
 //   - POST "/api/shorten"   : Shortens a URL via API using `handlers.APIPostHandler`.
 //   - POST "/api/shorten/batch" : Shortens multiple URLs in a batch via API using `handlers.APIPostBatchHandler`.
 //   - GET "/{id}"           : Retrieves the original URL by its short ID using `handlers.GetRequestHandler`.
+//   - HEAD "/{id}"          : Same as GET "/{id}" without counting a click.
 //   - GET "/ping"           : Performs a database health check using `handlers.DatabasePing`.
+//   - GET "/healthz"        : Reports whether the process is alive using the liveness checks of `checks`.
+//   - GET "/readyz"         : Reports whether the process is ready to serve using all checks of `checks`.
 //   - GET "/api/user/urls"  : Retrieves URLs created by the authenticated user using `handlers.GetURLsByUserHandler`.
 //   - DELETE "/api/user/urls": Deletes multiple URLs created by the authenticated user using `handlers.APIDeleteUrlsHandler`.
+//   - GET "/api/user/deletions/{id}": Reports the state of a deletion job of the authenticated user using `handlers.APIGetDeletionHandler`.
+//   - PATCH "/api/user/urls/{id}": Updates the settings of a URL owned by the authenticated user using `handlers.APIUpdateURLHandler`.
+//   - GET "/api/user/urls/{id}/rules": Lists the conditional redirect rules of a user's URL using `handlers.APIGetURLRulesHandler`.
+//   - PUT "/api/user/urls/{id}/rules": Replaces the conditional redirect rules of a user's URL using `handlers.APISetURLRulesHandler`.
+//   - POST "/api/user/webhooks": Subscribes a URL to events of the authenticated user's links using `handlers.APICreateWebhookHandler`.
+//   - GET "/api/user/webhooks": Lists the webhook subscriptions of the authenticated user using `handlers.APIListWebhooksHandler`.
+//   - DELETE "/api/user/webhooks/{id}": Removes a webhook subscription using `handlers.APIDeleteWebhookHandler`.
+//   - GET "/api/user/webhooks/{id}/deliveries": Serves the delivery log of a webhook subscription using `handlers.APIListWebhookDeliveriesHandler`.
+//   - GET "/api/user/events": Streams the events of the authenticated user's links as server-sent events using `handlers.APIWatchLinksHandler`.
+//
+// Errors of the /api/* routes, including unknown routes and methods, are answered with RFC 7807
+// problem details, see package problem.
 //
 // Middleware:
+//   - Accepts or generates the X-Request-ID of every request using `requestid.Middleware`.
+//   - Continues incoming traces and starts a span per request using `tracing.HTTPMiddleware`.
+//   - Counts and times requests per route using `metrics.HTTPMiddleware`.
 //   - Applies gzip compression using `middleware.GzipMiddleware`.
 //   - Logs incoming requests using `logger.LoggingWrapper`.
 //   - Validates and provides authentication tokens for certain routes using `middleware.GiveAuthTokenToUser` and `middleware.CheckAuthToken`.
 //
 // Parameters:
-//   - storage: An implementation of the `storageSvc.Storage` interface used for storing and retrieving URL data.
+//   - svc: The URL service handling the requests.
+//   - checks: The health checks served on /healthz and /readyz, see HealthChecks.
+//   - hooks: The webhook subscriptions of the users, see WebhookStore.
 //
 // Returns:
 //   - A configured `chi.Router` instance.
-func Router(svc service.Service) chi.Router {
+func Router(svc service.Service, checks *health.Health, hooks webhooks.Store) chi.Router {
 	r := chi.NewRouter()
 
-	r.Use(middleware.GzipMiddleware, logger.LoggingWrapper)
+	r.Use(requestid.Middleware, tracing.HTTPMiddleware, metrics.HTTPMiddleware, middleware.GzipMiddleware, logger.LoggingWrapper)
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
 
 	r.With(middleware.GiveAuthTokenToUser).Post("/", handlers.ShortenURL(svc))
 	r.With(middleware.GiveAuthTokenToUser).Post("/api/shorten", handlers.APIShortenURL(svc))
@@ -160,9 +328,84 @@ func Router(svc service.Service) chi.Router {
 	r.With(middleware.IPTrustedMiddleware).Get("/api/internal/stats", handlers.APIInternalGetStatsHandler(svc))
 
 	r.Get("/{id}", handlers.GetOriginalURL(svc))
+	r.Head("/{id}", handlers.GetOriginalURL(svc))
+	r.Get("/{id}/qr", handlers.GetQRCode(svc))
 	r.Get("/ping", handlers.Ping(svc))
+	r.Method(http.MethodGet, "/healthz", checks.LivenessHandler())
+	r.Method(http.MethodGet, "/readyz", checks.ReadinessHandler())
 	r.With(middleware.CheckAuthToken).Get("/api/user/urls", handlers.GetUserURLs(svc))
+	r.With(middleware.CheckAuthToken).Post("/api/user/urls/import", handlers.APIImportURLsHandler(svc))
+	r.With(middleware.CheckAuthToken).Get("/api/user/urls/export", handlers.APIExportURLsHandler(svc))
 	r.With(middleware.CheckAuthToken).Delete("/api/user/urls", handlers.APIDeleteUrlsHandler(svc))
+	r.With(middleware.CheckAuthToken).Get("/api/user/deletions/{id}", handlers.APIGetDeletionHandler(svc))
+	r.With(middleware.CheckAuthToken).Patch("/api/user/urls/{id}", handlers.APIUpdateURLHandler(svc))
+	r.With(middleware.CheckAuthToken).Get("/api/user/urls/{id}/rules", handlers.APIGetURLRulesHandler(svc))
+	r.With(middleware.CheckAuthToken).Put("/api/user/urls/{id}/rules", handlers.APISetURLRulesHandler(svc))
+	r.With(middleware.CheckAuthToken).Post("/api/user/webhooks", handlers.APICreateWebhookHandler(hooks))
+	r.With(middleware.CheckAuthToken).Get("/api/user/webhooks", handlers.APIListWebhooksHandler(hooks))
+	r.With(middleware.CheckAuthToken).Delete("/api/user/webhooks/{id}", handlers.APIDeleteWebhookHandler(hooks))
+	r.With(middleware.CheckAuthToken).Get("/api/user/webhooks/{id}/deliveries", handlers.APIListWebhookDeliveriesHandler(hooks))
+	r.With(middleware.CheckAuthToken).Get("/api/user/events", handlers.APIWatchLinksHandler(svc))
 
 	return r
 }
+
+// AdminRouter sets up and returns the router of the admin listener, which is kept apart from
+// the public API.
+//
+// Routes:
+//   - GET "/metrics" : Serves runtime metrics in the Prometheus text format using `metrics.Handler`.
+func AdminRouter() chi.Router {
+	r := chi.NewRouter()
+
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
+
+	return r
+}
+
+// DeleteQueue returns the queue of deletion jobs: a table next to the links when they are stored
+// in PostgreSQL, so that jobs survive restarts and are shared by all instances, and memory
+// otherwise.
+func DeleteQueue(ctx context.Context, store storageSvc.Storage) (deletes.Queue, error) {
+	if db, ok := storageSvc.Database(store); ok {
+		return deletes.NewPostgresQueue(ctx, db.DB())
+	}
+	return deletes.NewMemoryQueue(deletes.DefaultMemoryLimit), nil
+}
+
+// WebhookStore returns the store of webhook subscriptions and deliveries, as durable as the links:
+// tables next to them when they are stored in PostgreSQL, a file next to the storage file (with the
+// ".webhooks" suffix) when they are stored in a file, and memory otherwise.
+func WebhookStore(ctx context.Context, store storageSvc.Storage) (webhooks.Store, error) {
+	if db, ok := storageSvc.Database(store); ok {
+		return webhooks.NewPostgresStore(ctx, db.DB())
+	}
+	if config.Options.StoragePath != "" {
+		return webhooks.NewFileStore(config.Options.StoragePath + ".webhooks")
+	}
+	return webhooks.NewMemoryStore(), nil
+}
+
+// HealthChecks registers the health checks of the service:
+//   - Liveness: the delete worker is running, and so is the webhook worker when the storage has an
+//     outbox (webhookWorker is nil otherwise).
+//   - Readiness: the storage is reachable, the storage file is writable when storing links in a
+//     file, and the cache invalidation listener is subscribed when caching links of a database.
+func HealthChecks(store storageSvc.Storage, deleteWorker health.Checker, webhookWorker *webhooks.Worker) *health.Health {
+	checks := health.New()
+
+	checks.AddLivenessCheck("delete_worker", deleteWorker)
+	if webhookWorker != nil {
+		checks.AddLivenessCheck("webhook_worker", webhookWorker)
+	}
+
+	checks.AddReadinessCheck("storage", health.CheckFunc(store.Ping))
+	if config.Options.DatabaseDsn == "" && config.Options.StoragePath != "" {
+		checks.AddReadinessCheck("file", health.FileWritable(config.Options.StoragePath))
+	}
+	if cached, ok := store.(*storageSvc.CachedStore); ok {
+		checks.AddReadinessCheck("cache", health.CheckFunc(cached.CheckInvalidation))
+	}
+
+	return checks
+}
@@ -8,10 +8,15 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
+	"github.com/golangTroshin/shorturl/internal/app/health"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
 	"github.com/golangTroshin/shorturl/internal/app/service"
 	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/golangTroshin/shorturl/internal/app/webhooks"
 	"github.com/stretchr/testify/require"
 )
 
@@ -53,7 +58,7 @@ func TestShortenURL(t *testing.T) {
 	for _, tt := range tests {
 		store := storage.NewMemoryStore()
 		svc := service.NewURLService(store)
-		router := Router(svc)
+		router := Router(svc, health.New(), webhooks.NewMemoryStore())
 
 		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
 		r.Header.Set("Content-Type", "text/plain")
@@ -123,8 +128,8 @@ func TestAPIShortenURL(t *testing.T) {
 			body: "",
 			want: want{
 				code:        http.StatusBadRequest,
-				contentType: "text/plain; charset=utf-8",
-				content:     "Wrong request body\n",
+				contentType: problem.ContentType,
+				content:     "Wrong request body",
 			},
 		},
 	}
@@ -138,7 +143,7 @@ func TestAPIShortenURL(t *testing.T) {
 	for _, tt := range tests {
 		store := storage.NewMemoryStore()
 		svc := service.NewURLService(store)
-		router := Router(svc)
+		router := Router(svc, health.New(), webhooks.NewMemoryStore())
 
 		r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
 		r.Header.Set("Content-Type", "application/json")
@@ -172,8 +177,10 @@ func TestAPIShortenURL(t *testing.T) {
 				t.Errorf("[%s] URLs are not equal: expected: %s, result: %s ", tt.name, expectedURL, stringResultURL)
 			}
 		} else {
-			if tt.want.content != stringResultURL {
-				t.Errorf("[%s] URLs are not equal: expected: %s, result: %s ", tt.name, tt.want.content, stringResultURL)
+			var details problem.Details
+			require.NoError(t, json.Unmarshal(resultURL, &details))
+			if tt.want.content != details.Detail {
+				t.Errorf("[%s] error details are not equal: expected: %s, result: %s ", tt.name, tt.want.content, details.Detail)
 			}
 		}
 	}
@@ -230,7 +237,7 @@ func TestGetOriginalURL(t *testing.T) {
 			// Pre-populate store with test data
 			store.Set(context.Background(), "https://practicum.yandex.ru/")
 			svc := service.NewURLService(store)
-			router := Router(svc)
+			router := Router(svc, health.New(), webhooks.NewMemoryStore())
 
 			r := httptest.NewRequest(http.MethodGet, tt.requestURI, nil)
 			r.Header.Set("Content-Type", "text/plain")
@@ -259,3 +266,91 @@ func TestGetOriginalURL(t *testing.T) {
 		})
 	}
 }
+
+func TestAdminRouter_Metrics(t *testing.T) {
+	svc := service.NewURLService(storage.NewMemoryStore())
+	Router(svc, health.New(), webhooks.NewMemoryStore()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	w := httptest.NewRecorder()
+	AdminRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `shorturl_http_requests_total{code="200",method="GET",route="/ping"} 1`)
+}
+
+func TestRouter_APIProblems(t *testing.T) {
+	router := Router(service.NewURLService(storage.NewMemoryStore()), health.New(), webhooks.NewMemoryStore())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"missing auth token", http.MethodDelete, "/api/user/urls", http.StatusUnauthorized, "UNAUTHENTICATED"},
+		{"unknown endpoint", http.MethodGet, "/api/unknown/endpoint", http.StatusNotFound, "NOT_FOUND"},
+		{"method not allowed", http.MethodPut, "/api/shorten", http.StatusMethodNotAllowed, "UNIMPLEMENTED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+			var details problem.Details
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&details))
+			require.Equal(t, tt.code, details.Code)
+			require.Equal(t, tt.path, details.Instance)
+			require.Equal(t, w.Header().Get("X-Request-ID"), details.RequestID)
+			require.NotEmpty(t, details.RequestID)
+		})
+	}
+
+	t.Run("non-API routes keep plain errors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/b/c", nil))
+
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.NotEqual(t, problem.ContentType, w.Header().Get("Content-Type"))
+	})
+}
+
+func TestRouter_Health(t *testing.T) {
+	store := storage.NewMemoryStore()
+	queue := deletes.NewMemoryQueue(0)
+	worker := deletes.NewWorker(queue, store, deletes.Options{})
+	hooks := webhooks.NewMemoryStore()
+	webhookWorker := webhooks.NewWorker(store, hooks, webhooks.Options{PollInterval: time.Millisecond})
+	router := Router(service.NewURLService(store).WithDeleteQueue(queue), HealthChecks(store, worker, webhookWorker), hooks)
+
+	probe := func(path string) (int, health.Report) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var report health.Report
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+		return w.Code, report
+	}
+
+	code, report := probe("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, []string{"delete_worker", "webhook_worker"}, report.Failed())
+	require.Equal(t, health.StatusOK, report.Checks["storage"].Status)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Run(ctx)
+	go webhookWorker.Run(ctx)
+	require.Eventually(t, func() bool {
+		code, _ := probe("/healthz")
+		return code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	code, report = probe("/readyz")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, report.Checks, 3)
+}
@@ -0,0 +1,193 @@
+// Package broker fans out link events to the watchers of a user within the process.
+//
+// The service publishes an Event for every change of a link and every click. Each Subscription
+// receives the events of a single user through a buffered channel. Publishing never blocks: a
+// subscriber whose buffer is full is too slow to keep up, so its subscription is ended with
+// ErrSlowSubscriber instead of delaying the requests that publish events or the other
+// subscribers. Such a subscriber may subscribe again and reload the links it missed.
+package broker
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuffer is the number of events a subscription holds unless told otherwise.
+const DefaultBuffer = 64
+
+// Types of link events.
+const (
+	EventCreated = "created" // A link was shortened
+	EventUpdated = "updated" // The settings of a link changed
+	EventDeleted = "deleted" // A link was deleted by its user
+	EventClicked = "clicked" // A link was followed
+)
+
+// Errors ending subscriptions.
+var (
+	// ErrSlowSubscriber ends subscriptions whose buffer was full when an event was published.
+	ErrSlowSubscriber = errors.New("subscriber is too slow, events were dropped")
+	// ErrClosed ends subscriptions when the broker is closed.
+	ErrClosed = errors.New("broker is closed")
+)
+
+// Event is a change of a link of a user.
+type Event struct {
+	ID          uint64    `json:"id"`                     // Sequence number of the event, increasing within the process
+	Type        string    `json:"type"`                   // One of the Event* constants
+	UserID      string    `json:"-"`                      // Owner of the link
+	ShortURL    string    `json:"short_url"`              // Short URL key of the link
+	OriginalURL string    `json:"original_url,omitempty"` // Destination of the link; empty for deletions
+	OccurredAt  time.Time `json:"occurred_at"`            // Moment of the change
+}
+
+// Broker delivers published events to the subscriptions of their user. The zero value is not
+// usable; create brokers with New.
+type Broker struct {
+	mu     sync.RWMutex
+	subs   map[string]map[*Subscription]struct{} // Subscriptions per user
+	closed bool
+	buffer int
+	seq    atomic.Uint64
+	count  atomic.Int64
+}
+
+// New creates a broker whose subscriptions buffer up to buffer events; DefaultBuffer is used
+// when buffer is not positive.
+func New(buffer int) *Broker {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Broker{subs: make(map[string]map[*Subscription]struct{}), buffer: buffer}
+}
+
+// Subscribe starts receiving the events of the user. The subscription must be closed when no
+// longer read. Subscriptions to a closed broker end immediately with ErrClosed.
+func (b *Broker) Subscribe(userID string) *Subscription {
+	sub := &Subscription{broker: b, userID: userID, events: make(chan Event, b.buffer)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		sub.end(ErrClosed)
+		return sub
+	}
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[*Subscription]struct{})
+	}
+	b.subs[userID][sub] = struct{}{}
+	b.count.Add(1)
+	return sub
+}
+
+// Publish sends the event to the subscriptions of its user without blocking, setting its ID and,
+// when zero, its time. Subscriptions that cannot take the event are ended with ErrSlowSubscriber.
+func (b *Broker) Publish(event Event) {
+	if !b.Watched(event.UserID) {
+		return
+	}
+
+	event.ID = b.seq.Add(1)
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	var slow []*Subscription
+	b.mu.RLock()
+	for sub := range b.subs[event.UserID] {
+		select {
+		case sub.events <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range slow {
+		b.remove(sub, ErrSlowSubscriber)
+	}
+}
+
+// Watched reports whether the user has subscriptions, so that publishers can skip building
+// events nobody receives.
+func (b *Broker) Watched(userID string) bool {
+	if b.count.Load() == 0 {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs[userID]) > 0
+}
+
+// Subscribers returns the number of open subscriptions.
+func (b *Broker) Subscribers() int {
+	return int(b.count.Load())
+}
+
+// Close ends every subscription with ErrClosed and makes later subscriptions end immediately.
+// It is meant to run on shutdown, before the servers wait for open streams.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for userID, subs := range b.subs {
+		for sub := range subs {
+			sub.end(ErrClosed)
+		}
+		delete(b.subs, userID)
+	}
+	b.count.Store(0)
+}
+
+// remove ends the subscription with err, unless it already ended.
+func (b *Broker) remove(sub *Subscription, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subs[sub.userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subs, sub.userID)
+	}
+	b.count.Add(-1)
+	sub.end(err)
+}
+
+// Subscription receives the events of a user, see Broker.Subscribe.
+type Subscription struct {
+	broker *Broker
+	userID string
+	events chan Event
+	err    error // Set before events is closed
+}
+
+// Events returns the channel of events. It is closed when the subscription ends, after the
+// events buffered so far; Err then tells why.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns the reason the subscription ended: nil after Close, ErrSlowSubscriber or ErrClosed.
+// It must only be called once Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Close ends the subscription. It may be called more than once.
+func (s *Subscription) Close() {
+	s.broker.remove(s, nil)
+}
+
+// end records err and closes the channel of events. It is called once, with the broker locked.
+func (s *Subscription) end(err error) {
+	s.err = err
+	close(s.events)
+}
@@ -0,0 +1,88 @@
+package broker_test
+
+import (
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drain returns the events buffered by the ended subscription.
+func drain(sub *broker.Subscription) []broker.Event {
+	var events []broker.Event
+	for event := range sub.Events() {
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestBroker_FanOutPerUser(t *testing.T) {
+	b := broker.New(0)
+	first := b.Subscribe("user1")
+	second := b.Subscribe("user1")
+	other := b.Subscribe("user2")
+	assert.Equal(t, 3, b.Subscribers())
+
+	b.Publish(broker.Event{Type: broker.EventCreated, UserID: "user1", ShortURL: "abc"})
+	b.Publish(broker.Event{Type: broker.EventClicked, UserID: "user1", ShortURL: "abc"})
+	b.Publish(broker.Event{Type: broker.EventCreated, UserID: "user3", ShortURL: "def"})
+
+	first.Close()
+	second.Close()
+	other.Close()
+	assert.Equal(t, 0, b.Subscribers())
+
+	for _, sub := range []*broker.Subscription{first, second} {
+		events := drain(sub)
+		require.Len(t, events, 2)
+		assert.Equal(t, broker.EventCreated, events[0].Type)
+		assert.Equal(t, broker.EventClicked, events[1].Type)
+		assert.Less(t, events[0].ID, events[1].ID)
+		assert.False(t, events[0].OccurredAt.IsZero())
+		assert.NoError(t, sub.Err())
+	}
+	assert.Empty(t, drain(other), "events of other users are not received")
+}
+
+func TestBroker_SlowSubscriber(t *testing.T) {
+	b := broker.New(2)
+	slow := b.Subscribe("user1")
+	fast := b.Subscribe("user1")
+	publish := func() {
+		b.Publish(broker.Event{Type: broker.EventClicked, UserID: "user1", ShortURL: "abc"})
+	}
+
+	// The fast subscriber reads every event while the slow one reads none.
+	publish()
+	publish()
+	<-fast.Events()
+	<-fast.Events()
+	publish()
+
+	// The slow subscriber keeps what was buffered and is told why it ended.
+	assert.Len(t, drain(slow), 2)
+	assert.ErrorIs(t, slow.Err(), broker.ErrSlowSubscriber)
+
+	// The others are not affected.
+	assert.True(t, b.Watched("user1"))
+	fast.Close()
+	assert.Len(t, drain(fast), 1)
+	assert.NoError(t, fast.Err())
+	assert.False(t, b.Watched("user1"))
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := broker.New(0)
+	sub := b.Subscribe("user1")
+
+	b.Close()
+	assert.Empty(t, drain(sub))
+	assert.ErrorIs(t, sub.Err(), broker.ErrClosed)
+	sub.Close()
+
+	late := b.Subscribe("user1")
+	assert.Empty(t, drain(late))
+	assert.ErrorIs(t, late.Err(), broker.ErrClosed)
+	b.Publish(broker.Event{Type: broker.EventCreated, UserID: "user1"})
+}
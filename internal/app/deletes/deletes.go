@@ -0,0 +1,67 @@
+// Package deletes processes requests to delete links in the background.
+//
+// Every request is stored as a Job in a Queue, either in memory (MemoryQueue) or in a
+// PostgreSQL table shared by all instances (PostgresQueue), where it survives restarts. A Worker
+// claims ready jobs in batches, merges the jobs of each user into a single storage update and
+// retries failed jobs with exponential backoff. Jobs failing Options.MaxAttempts times are kept
+// with status dead, as dead letters, and the status of every job can be looked up by its ID.
+package deletes
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Status is the state of a deletion job.
+type Status string
+
+// Job statuses.
+const (
+	StatusPending Status = "pending" // Waiting for a worker, possibly for a retry
+	StatusDone    Status = "done"    // The links were deleted
+	StatusDead    Status = "dead"    // Failed too often and gave up; see LastError
+)
+
+// Errors returned by queues.
+var (
+	// ErrJobNotFound is returned for unknown jobs and jobs of other users.
+	ErrJobNotFound = errors.New("deletion job not found")
+	// ErrQueueFull is returned when a memory queue holds its limit of pending jobs.
+	ErrQueueFull = errors.New("deletion queue is full")
+	// ErrClosed is returned for jobs enqueued after the queue was closed.
+	ErrClosed = errors.New("deletion queue is closed")
+)
+
+// Job is a request of a user to delete some of their links.
+type Job struct {
+	ID            string    `json:"id"`                   // ID of the job
+	UserID        string    `json:"-"`                    // User who requested the deletion
+	URLs          []string  `json:"urls"`                 // Short URLs to delete
+	Status        Status    `json:"status"`               // pending, done or dead
+	Attempts      int       `json:"attempts"`             // Number of times a worker claimed the job
+	LastError     string    `json:"last_error,omitempty"` // Error of the last failed attempt
+	NextAttemptAt time.Time `json:"next_attempt_at"`      // Earliest time a worker may claim the job
+	CreatedAt     time.Time `json:"created_at"`           // Moment the deletion was requested
+	UpdatedAt     time.Time `json:"updated_at"`           // Moment the job last changed
+}
+
+// Stats counts the jobs of a queue per status.
+type Stats struct {
+	Pending int // Jobs waiting for a worker
+	Dead    int // Jobs that gave up
+}
+
+// Queue stores deletion jobs until a Worker processes them.
+type Queue interface {
+	Enqueue(ctx context.Context, userID string, urls []string) (Job, error)   // Enqueue stores a new pending job.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Job, error) // Claim leases up to limit ready jobs, counting an attempt; jobs not completed within lease are claimed again.
+	Complete(ctx context.Context, ids []string) error                         // Complete marks the jobs as done.
+	Retry(ctx context.Context, id string, cause error, at time.Time) error    // Retry makes the job ready again at the given time.
+	Bury(ctx context.Context, id string, cause error) error                   // Bury gives up on the job and keeps it as a dead letter.
+	Get(ctx context.Context, userID string, id string) (Job, error)           // Get returns the job of the user with the given ID.
+	Purge(ctx context.Context, before time.Time) (int, error)                 // Purge removes done jobs last updated before the given time.
+	Stats(ctx context.Context) (Stats, error)                                 // Stats counts pending and dead jobs.
+	Close() error                                                             // Close stops accepting new jobs if they would not survive a restart.
+	Durable() bool                                                            // Durable reports whether jobs survive a restart.
+}
@@ -0,0 +1,269 @@
+package deletes_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
+	"github.com/golangTroshin/shorturl/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastOptions makes workers poll and retry quickly.
+var fastOptions = deletes.Options{
+	PollInterval: time.Millisecond,
+	MinBackoff:   time.Millisecond,
+	MaxBackoff:   2 * time.Millisecond,
+}
+
+// run starts the worker until the test ends.
+func run(t *testing.T, worker *deletes.Worker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go worker.Run(ctx)
+}
+
+// waitFor waits until the job of the user has the given status and returns it.
+func waitFor(t *testing.T, queue deletes.Queue, userID, id string, status deletes.Status) deletes.Job {
+	var job deletes.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = queue.Get(context.Background(), userID, id)
+		return err == nil && job.Status == status
+	}, 2*time.Second, time.Millisecond)
+	return job
+}
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+	queue := deletes.NewMemoryQueue(2)
+
+	first, err := queue.Enqueue(ctx, "user1", []string{"short1"})
+	require.NoError(t, err)
+	_, err = queue.Enqueue(ctx, "user1", []string{"short2"})
+	require.NoError(t, err)
+
+	t.Run("full queue does not block", func(t *testing.T) {
+		_, err := queue.Enqueue(ctx, "user1", []string{"short3"})
+		assert.ErrorIs(t, err, deletes.ErrQueueFull)
+	})
+
+	t.Run("jobs of other users are not found", func(t *testing.T) {
+		_, err := queue.Get(ctx, "user2", first.ID)
+		assert.ErrorIs(t, err, deletes.ErrJobNotFound)
+	})
+
+	t.Run("claimed jobs are leased", func(t *testing.T) {
+		jobs, err := queue.Claim(ctx, 1, time.Hour)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, first.ID, jobs[0].ID)
+		assert.Equal(t, 1, jobs[0].Attempts)
+
+		jobs, err = queue.Claim(ctx, 10, time.Hour)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1, "the leased job is not claimed again")
+		assert.NotEqual(t, first.ID, jobs[0].ID)
+	})
+
+	t.Run("done jobs are purged", func(t *testing.T) {
+		require.NoError(t, queue.Complete(ctx, []string{first.ID}))
+		stats, err := queue.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, deletes.Stats{Pending: 1}, stats)
+
+		purged, err := queue.Purge(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		_, err = queue.Get(ctx, "user1", first.ID)
+		assert.ErrorIs(t, err, deletes.ErrJobNotFound)
+	})
+
+	t.Run("closed queue rejects jobs", func(t *testing.T) {
+		require.NoError(t, queue.Close())
+		_, err := queue.Enqueue(ctx, "user1", []string{"short4"})
+		assert.ErrorIs(t, err, deletes.ErrClosed)
+	})
+}
+
+func TestWorker_BatchesJobsPerUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	queue := deletes.NewMemoryQueue(0)
+	a, _ := queue.Enqueue(ctx, "user1", []string{"short1", "short2"})
+	b, _ := queue.Enqueue(ctx, "user2", []string{"short3"})
+	c, _ := queue.Enqueue(ctx, "user1", []string{"short2", "short4"})
+
+	// Three requests cost a single storage update per user.
+	mockStorage := mocks.NewMockStorage(ctrl)
+	mockStorage.EXPECT().BatchDeleteURLs("user1", []string{"short1", "short2", "short4"}).Return([]string{"short1", "short2", "short4"}, nil)
+	mockStorage.EXPECT().BatchDeleteURLs("user2", []string{"short3"}).Return([]string{"short3"}, nil)
+
+	run(t, deletes.NewWorker(queue, mockStorage, fastOptions))
+
+	waitFor(t, queue, "user1", a.ID, deletes.StatusDone)
+	waitFor(t, queue, "user2", b.ID, deletes.StatusDone)
+	waitFor(t, queue, "user1", c.ID, deletes.StatusDone)
+}
+
+func TestWorker_ReportsOnlyDeletedURLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	queue := deletes.NewMemoryQueue(0)
+	job, _ := queue.Enqueue(context.Background(), "user1", []string{"short1", "foreign", "deleted"})
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	mockStorage.EXPECT().BatchDeleteURLs("user1", []string{"short1", "foreign", "deleted"}).Return([]string{"short1"}, nil)
+
+	reported := make(chan []string, 1)
+	opts := fastOptions
+	opts.Deleted = func(userID string, urls []string) {
+		assert.Equal(t, "user1", userID)
+		reported <- urls
+	}
+	run(t, deletes.NewWorker(queue, mockStorage, opts))
+
+	waitFor(t, queue, "user1", job.ID, deletes.StatusDone)
+	assert.Equal(t, []string{"short1"}, <-reported)
+}
+
+func TestWorker_Retries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	queue := deletes.NewMemoryQueue(0)
+	job, _ := queue.Enqueue(context.Background(), "user1", []string{"short1"})
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	gomock.InOrder(
+		mockStorage.EXPECT().BatchDeleteURLs("user1", []string{"short1"}).Return(nil, errors.New("db down")),
+		mockStorage.EXPECT().BatchDeleteURLs("user1", []string{"short1"}).Return([]string{"short1"}, nil),
+	)
+
+	run(t, deletes.NewWorker(queue, mockStorage, fastOptions))
+
+	job = waitFor(t, queue, "user1", job.ID, deletes.StatusDone)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Empty(t, job.LastError)
+}
+
+func TestWorker_DeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	queue := deletes.NewMemoryQueue(0)
+	job, _ := queue.Enqueue(context.Background(), "user1", []string{"short1"})
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	mockStorage.EXPECT().BatchDeleteURLs("user1", []string{"short1"}).Return(nil, errors.New("db down")).Times(3)
+
+	opts := fastOptions
+	opts.MaxAttempts = 3
+	run(t, deletes.NewWorker(queue, mockStorage, opts))
+
+	job = waitFor(t, queue, "user1", job.ID, deletes.StatusDead)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Equal(t, "db down", job.LastError)
+
+	stats, err := queue.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, deletes.Stats{Dead: 1}, stats)
+}
+
+func TestWorker_ShutdownDrainsQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var (
+		mu      sync.Mutex
+		deleted = make(map[string]bool)
+	)
+	mockStorage := mocks.NewMockStorage(ctrl)
+	mockStorage.EXPECT().BatchDeleteURLs("user1", gomock.Any()).DoAndReturn(func(_ string, batch []string) ([]string, error) {
+		time.Sleep(time.Millisecond) // A slow storage keeps jobs waiting
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range batch {
+			deleted[key] = true
+		}
+		return batch, nil
+	}).AnyTimes()
+
+	queue := deletes.NewMemoryQueue(0)
+	worker := deletes.NewWorker(queue, mockStorage, deletes.Options{BatchSize: 10})
+	run(t, worker)
+
+	// Requests are still being sent when the shutdown starts.
+	accepted := make(chan string, 200)
+	var senders sync.WaitGroup
+	for i := range 200 {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			key := fmt.Sprintf("short%d", i)
+			if _, err := queue.Enqueue(context.Background(), "user1", []string{key}); err != nil {
+				assert.ErrorIs(t, err, deletes.ErrClosed)
+				return
+			}
+			accepted <- key
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, worker.Shutdown(ctx))
+	senders.Wait()
+	close(accepted)
+
+	// Every accepted deletion was processed before Shutdown returned.
+	mu.Lock()
+	defer mu.Unlock()
+	count := 0
+	for key := range accepted {
+		assert.True(t, deleted[key], "queued deletion of %s was dropped", key)
+		count++
+	}
+	assert.Positive(t, count)
+	assert.Len(t, deleted, count)
+	assert.Error(t, worker.Check(context.Background()), "worker stopped")
+}
+
+func TestWorker_ShutdownReportsLeftJobs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	queue := deletes.NewMemoryQueue(0)
+	_, _ = queue.Enqueue(context.Background(), "user1", []string{"short1"})
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	mockStorage.EXPECT().BatchDeleteURLs("user1", []string{"short1"}).Return(nil, errors.New("db down"))
+
+	// The failed job waits for a retry after the deadline.
+	worker := deletes.NewWorker(queue, mockStorage, deletes.Options{MinBackoff: time.Hour})
+	run(t, worker)
+	require.Eventually(t, func() bool { return worker.Check(context.Background()) == nil }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.EqualError(t, worker.Shutdown(ctx), "1 deletion jobs left waiting for a retry")
+}
+
+func TestOptions_Backoff(t *testing.T) {
+	opts := deletes.Options{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, opts.Backoff(1))
+	assert.Equal(t, 2*time.Second, opts.Backoff(2))
+	assert.Equal(t, 8*time.Second, opts.Backoff(4))
+	assert.Equal(t, 10*time.Second, opts.Backoff(5))
+	assert.Equal(t, 10*time.Second, opts.Backoff(100))
+}
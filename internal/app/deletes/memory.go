@@ -0,0 +1,217 @@
+package deletes
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMemoryLimit is the number of pending jobs a memory queue holds unless told otherwise.
+const DefaultMemoryLimit = 10000
+
+var _ Queue = (*MemoryQueue)(nil) // Ensures MemoryQueue implements Queue
+
+// MemoryQueue keeps jobs in memory. They are lost when the process stops, so a Worker drains
+// the queue on shutdown.
+type MemoryQueue struct {
+	mu      sync.Mutex
+	limit   int
+	jobs    map[string]*Job
+	pending []string // IDs of pending jobs in the order they were enqueued
+	closed  bool
+	ready   chan struct{}
+	now     func() time.Time
+}
+
+// NewMemoryQueue creates a queue holding up to limit pending jobs; DefaultMemoryLimit is used
+// when limit is not positive.
+func NewMemoryQueue(limit int) *MemoryQueue {
+	if limit <= 0 {
+		limit = DefaultMemoryLimit
+	}
+	return &MemoryQueue{
+		limit: limit,
+		jobs:  make(map[string]*Job),
+		ready: make(chan struct{}, 1),
+		now:   time.Now,
+	}
+}
+
+// Enqueue stores a new pending job. It returns ErrQueueFull instead of blocking when the queue
+// holds its limit of pending jobs, and ErrClosed once the queue is closed.
+func (q *MemoryQueue) Enqueue(_ context.Context, userID string, urls []string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return Job{}, ErrClosed
+	}
+	if len(q.pending) >= q.limit {
+		return Job{}, ErrQueueFull
+	}
+
+	now := q.now()
+	job := &Job{
+		ID:            uuid.NewString(),
+		UserID:        userID,
+		URLs:          slices.Clone(urls),
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	q.jobs[job.ID] = job
+	q.pending = append(q.pending, job.ID)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+
+	return *job, nil
+}
+
+// Claim leases up to limit ready jobs, oldest first.
+func (q *MemoryQueue) Claim(_ context.Context, limit int, lease time.Duration) ([]Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	var claimed []Job
+	for _, id := range q.pending {
+		if len(claimed) == limit {
+			break
+		}
+
+		job := q.jobs[id]
+		if job.NextAttemptAt.After(now) {
+			continue
+		}
+		job.Attempts++
+		job.NextAttemptAt = now.Add(lease)
+		job.UpdatedAt = now
+		claimed = append(claimed, q.copy(job))
+	}
+
+	return claimed, nil
+}
+
+// Complete marks the jobs as done.
+func (q *MemoryQueue) Complete(_ context.Context, ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, id := range ids {
+		q.finish(id, StatusDone, "")
+	}
+	return nil
+}
+
+// Retry makes the job ready again at the given time.
+func (q *MemoryQueue) Retry(_ context.Context, id string, cause error, at time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.LastError = cause.Error()
+	job.NextAttemptAt = at
+	job.UpdatedAt = q.now()
+	return nil
+}
+
+// Bury gives up on the job and keeps it as a dead letter.
+func (q *MemoryQueue) Bury(_ context.Context, id string, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.jobs[id]; !ok {
+		return ErrJobNotFound
+	}
+	q.finish(id, StatusDead, cause.Error())
+	return nil
+}
+
+// Get returns the job of the user with the given ID.
+func (q *MemoryQueue) Get(_ context.Context, userID string, id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok || job.UserID != userID {
+		return Job{}, ErrJobNotFound
+	}
+	return q.copy(job), nil
+}
+
+// Purge removes done jobs last updated before the given time.
+func (q *MemoryQueue) Purge(_ context.Context, before time.Time) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	purged := 0
+	for id, job := range q.jobs {
+		if job.Status == StatusDone && job.UpdatedAt.Before(before) {
+			delete(q.jobs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Stats counts pending and dead jobs.
+func (q *MemoryQueue) Stats(_ context.Context) (Stats, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := Stats{Pending: len(q.pending)}
+	for _, job := range q.jobs {
+		if job.Status == StatusDead {
+			stats.Dead++
+		}
+	}
+	return stats, nil
+}
+
+// Close makes Enqueue fail with ErrClosed. Jobs already queued can still be claimed.
+func (q *MemoryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	return nil
+}
+
+// Durable reports false: jobs are lost when the process stops.
+func (q *MemoryQueue) Durable() bool {
+	return false
+}
+
+// Ready is signalled when a job is enqueued, so that a waiting Worker claims it immediately.
+func (q *MemoryQueue) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// finish sets the final status of a job and removes it from the pending jobs.
+func (q *MemoryQueue) finish(id string, status Status, lastError string) {
+	job, ok := q.jobs[id]
+	if !ok || job.Status != StatusPending {
+		return
+	}
+	job.Status = status
+	job.LastError = lastError
+	job.UpdatedAt = q.now()
+	q.pending = slices.DeleteFunc(q.pending, func(pending string) bool { return pending == id })
+}
+
+// copy returns a copy of the job that does not share the URLs with the queue.
+func (q *MemoryQueue) copy(job *Job) Job {
+	c := *job
+	c.URLs = slices.Clone(job.URLs)
+	return c
+}
@@ -0,0 +1,177 @@
+package deletes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var _ Queue = (*PostgresQueue)(nil) // Ensures PostgresQueue implements Queue
+
+// PostgresQueue keeps jobs in the delete_jobs table, shared by all instances using the database.
+// Workers of several instances claim jobs concurrently: SELECT ... FOR UPDATE SKIP LOCKED
+// hands each ready job to a single worker without waiting for the others.
+type PostgresQueue struct {
+	db *sql.DB
+}
+
+// deleteJobsSchema creates the delete_jobs table. Every statement is idempotent because it runs
+// on each start.
+var deleteJobsSchema = []string{
+	"CREATE TABLE IF NOT EXISTS delete_jobs (" +
+		" id UUID PRIMARY KEY," +
+		" user_id VARCHAR(250) NOT NULL," +
+		" urls TEXT[] NOT NULL," +
+		" status VARCHAR(16) NOT NULL DEFAULT 'pending'," +
+		" attempts INT NOT NULL DEFAULT 0," +
+		" last_error TEXT NOT NULL DEFAULT ''," +
+		" next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now()," +
+		" created_at TIMESTAMPTZ NOT NULL DEFAULT now()," +
+		" updated_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+	"CREATE INDEX IF NOT EXISTS delete_jobs_ready_idx ON delete_jobs (next_attempt_at) WHERE status = 'pending'",
+}
+
+// jobColumns lists the columns read by scanJob, in order.
+const jobColumns = `id, user_id, urls, status, attempts, last_error, next_attempt_at, created_at, updated_at`
+
+// NewPostgresQueue creates the delete_jobs table if needed and returns a queue using it. The
+// connection pool is owned by the caller.
+func NewPostgresQueue(ctx context.Context, db *sql.DB) (*PostgresQueue, error) {
+	for _, statement := range deleteJobsSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, err
+		}
+	}
+	return &PostgresQueue{db: db}, nil
+}
+
+// Enqueue stores a new pending job.
+func (q *PostgresQueue) Enqueue(ctx context.Context, userID string, urls []string) (Job, error) {
+	query := `INSERT INTO delete_jobs (id, user_id, urls) VALUES ($1, $2, $3) RETURNING ` + jobColumns
+	return scanJob(q.db.QueryRowContext(ctx, query, uuid.NewString(), userID, pq.Array(urls)))
+}
+
+// Claim leases up to limit ready jobs, oldest first, skipping jobs locked by other workers.
+func (q *PostgresQueue) Claim(ctx context.Context, limit int, lease time.Duration) ([]Job, error) {
+	query := `UPDATE delete_jobs
+		SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+		WHERE id IN (
+			SELECT id FROM delete_jobs
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING ` + jobColumns
+
+	rows, err := q.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Complete marks the jobs as done.
+func (q *PostgresQueue) Complete(ctx context.Context, ids []string) error {
+	query := `UPDATE delete_jobs SET status = 'done', last_error = '', updated_at = now()
+		WHERE id = ANY($1) AND status = 'pending'`
+	_, err := q.db.ExecContext(ctx, query, pq.Array(ids))
+	return err
+}
+
+// Retry makes the job ready again at the given time.
+func (q *PostgresQueue) Retry(ctx context.Context, id string, cause error, at time.Time) error {
+	query := `UPDATE delete_jobs SET last_error = $2, next_attempt_at = $3, updated_at = now() WHERE id = $1`
+	return q.exec(ctx, query, id, cause.Error(), at)
+}
+
+// Bury gives up on the job and keeps it as a dead letter.
+func (q *PostgresQueue) Bury(ctx context.Context, id string, cause error) error {
+	query := `UPDATE delete_jobs SET status = 'dead', last_error = $2, updated_at = now() WHERE id = $1`
+	return q.exec(ctx, query, id, cause.Error())
+}
+
+// Get returns the job of the user with the given ID.
+func (q *PostgresQueue) Get(ctx context.Context, userID string, id string) (Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return Job{}, ErrJobNotFound
+	}
+
+	query := `SELECT ` + jobColumns + ` FROM delete_jobs WHERE id = $1 AND user_id = $2`
+	job, err := scanJob(q.db.QueryRowContext(ctx, query, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, ErrJobNotFound
+	}
+	return job, err
+}
+
+// Purge removes done jobs last updated before the given time.
+func (q *PostgresQueue) Purge(ctx context.Context, before time.Time) (int, error) {
+	result, err := q.db.ExecContext(ctx, `DELETE FROM delete_jobs WHERE status = 'done' AND updated_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// Stats counts pending and dead jobs.
+func (q *PostgresQueue) Stats(ctx context.Context) (Stats, error) {
+	query := `SELECT count(*) FILTER (WHERE status = 'pending'), count(*) FILTER (WHERE status = 'dead') FROM delete_jobs`
+
+	var stats Stats
+	err := q.db.QueryRowContext(ctx, query).Scan(&stats.Pending, &stats.Dead)
+	return stats, err
+}
+
+// Close does nothing: jobs enqueued while the instance stops are kept for the next worker.
+func (q *PostgresQueue) Close() error {
+	return nil
+}
+
+// Durable reports true: jobs survive restarts.
+func (q *PostgresQueue) Durable() bool {
+	return true
+}
+
+// exec runs a statement changing a single job and returns ErrJobNotFound if there is no such job.
+func (q *PostgresQueue) exec(ctx context.Context, query string, args ...any) error {
+	result, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// scanJob reads a job from a row of jobColumns.
+func scanJob(row interface{ Scan(dest ...any) error }) (Job, error) {
+	var (
+		job    Job
+		status string
+	)
+	err := row.Scan(&job.ID, &job.UserID, pq.Array(&job.URLs), &status, &job.Attempts, &job.LastError,
+		&job.NextAttemptAt, &job.CreatedAt, &job.UpdatedAt)
+	job.Status = Status(status)
+	return job, err
+}
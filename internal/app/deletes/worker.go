@@ -0,0 +1,267 @@
+package deletes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// Options tune a Worker. Zero fields select the defaults.
+type Options struct {
+	BatchSize    int           // Most jobs claimed at once; 100 by default
+	PollInterval time.Duration // Time between claims while the queue is idle; 1s by default
+	Lease        time.Duration // Time after which a claimed job not completed is claimed again; 1m by default
+	MaxAttempts  int           // Attempts before a job is buried as a dead letter; 5 by default
+	MinBackoff   time.Duration // Delay before the first retry, doubled on every further one; 1s by default
+	MaxBackoff   time.Duration // Longest delay between retries; 5m by default
+	Retention    time.Duration // Time done jobs are kept for status lookups; 24h by default
+
+	// Deleted, if set, is called with the short URLs of a user once the storage deleted them,
+	// leaving out those that were unknown, owned by another user or already deleted.
+	Deleted func(userID string, urls []string)
+}
+
+// withDefaults returns the options with zero fields replaced by the defaults.
+func (o Options) withDefaults() Options {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.Lease <= 0 {
+		o.Lease = time.Minute
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 5 * time.Minute
+	}
+	if o.Retention <= 0 {
+		o.Retention = 24 * time.Hour
+	}
+	return o
+}
+
+// Backoff returns the delay before retrying a job that failed its given attempt.
+func (o Options) Backoff(attempt int) time.Duration {
+	o = o.withDefaults()
+
+	delay := o.MinBackoff
+	for i := 1; i < attempt && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, o.MaxBackoff)
+}
+
+// Worker deletes the links of the jobs of a queue.
+type Worker struct {
+	queue Queue
+	store storage.Storage
+	opts  Options
+
+	running   atomic.Bool
+	drainOnce sync.Once
+	drain     chan struct{} // Closed by Shutdown
+	stopped   chan struct{} // Closed when Run returns
+}
+
+// NewWorker creates a worker processing the jobs of queue with store.
+func NewWorker(queue Queue, store storage.Storage, opts Options) *Worker {
+	return &Worker{
+		queue:   queue,
+		store:   store,
+		opts:    opts.withDefaults(),
+		drain:   make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Run claims and processes jobs until ctx is done, or until Shutdown is called and no job is
+// ready. It must be called once, typically as a goroutine.
+func (w *Worker) Run(ctx context.Context) {
+	w.running.Store(true)
+	defer func() {
+		w.running.Store(false)
+		close(w.stopped)
+	}()
+
+	var ready <-chan struct{}
+	if notifier, ok := w.queue.(interface{ Ready() <-chan struct{} }); ok {
+		ready = notifier.Ready()
+	}
+
+	poll := time.NewTicker(w.opts.PollInterval)
+	defer poll.Stop()
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+
+	draining := false
+	for {
+		claimed, err := w.processBatch(ctx)
+		if err != nil {
+			logger.Default().Error("unable to claim deletion jobs", zap.Error(err))
+		}
+		if claimed == w.opts.BatchSize && ctx.Err() == nil {
+			continue
+		}
+		if draining && (claimed == 0 || err != nil) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.drain:
+			draining = true
+		case <-ready:
+		case <-poll.C:
+		case <-purge.C:
+			w.purge(ctx)
+		}
+	}
+}
+
+// Shutdown stops accepting jobs on queues that are not durable, and waits until Run has
+// processed every ready job and returned. Jobs of durable queues left pending are processed
+// after the next start; for other queues they are lost and reported in the returned error.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	if err := w.queue.Close(); err != nil {
+		return err
+	}
+	w.drainOnce.Do(func() { close(w.drain) })
+
+	select {
+	case <-w.stopped:
+	case <-ctx.Done():
+		return fmt.Errorf("deletion jobs not drained: %w", ctx.Err())
+	}
+
+	stats, err := w.queue.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	if stats.Pending > 0 {
+		if !w.queue.Durable() {
+			return fmt.Errorf("%d deletion jobs left waiting for a retry", stats.Pending)
+		}
+		logger.Default().Info("deletion jobs left for the next start", zap.Int("count", stats.Pending))
+	}
+	return nil
+}
+
+// Check reports an error when the worker is not running. It is meant to be registered as a
+// liveness check.
+func (w *Worker) Check(_ context.Context) error {
+	if !w.running.Load() {
+		return errors.New("delete worker is not running")
+	}
+	return nil
+}
+
+// Depth returns the number of pending jobs, or zero when the queue cannot be reached.
+func (w *Worker) Depth() int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stats, err := w.queue.Stats(ctx)
+	if err != nil {
+		return 0
+	}
+	return stats.Pending
+}
+
+// processBatch claims a batch of jobs and processes it. It returns the number of claimed jobs.
+//
+// The jobs of each user are merged into a single storage update, so many small requests cost a
+// single UPDATE. When it fails, every job of the user is retried or buried on its own.
+func (w *Worker) processBatch(ctx context.Context) (int, error) {
+	jobs, err := w.queue.Claim(ctx, w.opts.BatchSize, w.opts.Lease)
+	if err != nil || len(jobs) == 0 {
+		return 0, err
+	}
+
+	var users []string
+	byUser := make(map[string][]Job)
+	for _, job := range jobs {
+		if _, ok := byUser[job.UserID]; !ok {
+			users = append(users, job.UserID)
+		}
+		byUser[job.UserID] = append(byUser[job.UserID], job)
+	}
+
+	for _, userID := range users {
+		w.process(ctx, userID, byUser[userID])
+	}
+
+	return len(jobs), nil
+}
+
+// process deletes the links of the jobs of a user in one storage call.
+func (w *Worker) process(ctx context.Context, userID string, jobs []Job) {
+	var (
+		urls []string
+		ids  []string
+		seen = make(map[string]bool)
+	)
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+		for _, url := range job.URLs {
+			if !seen[url] {
+				seen[url] = true
+				urls = append(urls, url)
+			}
+		}
+	}
+
+	deleted, err := w.store.BatchDeleteURLs(userID, urls)
+	if err == nil {
+		if w.opts.Deleted != nil && len(deleted) > 0 {
+			w.opts.Deleted(userID, deleted)
+		}
+		if err := w.queue.Complete(ctx, ids); err != nil {
+			logger.Default().Error("unable to complete deletion jobs", zap.Int("count", len(ids)), zap.Error(err))
+		}
+		return
+	}
+
+	for _, job := range jobs {
+		if job.Attempts >= w.opts.MaxAttempts {
+			logger.Default().Error("deletion job gave up", zap.String("job_id", job.ID), zap.Int("attempts", job.Attempts), zap.Error(err))
+			if err := w.queue.Bury(ctx, job.ID, err); err != nil {
+				logger.Default().Error("unable to bury deletion job", zap.String("job_id", job.ID), zap.Error(err))
+			}
+			continue
+		}
+
+		delay := w.opts.Backoff(job.Attempts)
+		logger.Default().Warn("deletion job failed", zap.String("job_id", job.ID), zap.Int("attempts", job.Attempts),
+			zap.Duration("retry_in", delay), zap.Error(err))
+		if err := w.queue.Retry(ctx, job.ID, err, time.Now().Add(delay)); err != nil {
+			logger.Default().Error("unable to retry deletion job", zap.String("job_id", job.ID), zap.Error(err))
+		}
+	}
+}
+
+// purge removes done jobs older than the retention.
+func (w *Worker) purge(ctx context.Context) {
+	purged, err := w.queue.Purge(ctx, time.Now().Add(-w.opts.Retention))
+	if err != nil {
+		logger.Default().Error("unable to purge deletion jobs", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		logger.Default().Debug("purged deletion jobs", zap.Int("count", purged))
+	}
+}
@@ -0,0 +1,324 @@
+// Package fetcher downloads the destination page of a short link and extracts
+// its title, Open Graph title and description, and favicon.
+//
+// Requests are bounded by a timeout, a cap on the number of bytes read and a
+// redirect limit. To protect against server-side request forgery, connections
+// to loopback, private, link-local and other non-public addresses are refused
+// after DNS resolution, so a public host name pointing at an internal address
+// is rejected as well.
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"golang.org/x/net/html"
+)
+
+// Default limits of a Fetcher.
+const (
+	DefaultTimeout      = 5 * time.Second
+	DefaultMaxBytes     = 512 << 10
+	DefaultMaxRedirects = 5
+)
+
+// maxTextLength caps the length of extracted texts in bytes.
+const maxTextLength = 512
+
+// Errors returned by Fetch.
+var (
+	// ErrForbiddenAddress is returned when the destination resolves to a non-public address.
+	ErrForbiddenAddress = errors.New("destination address is not public")
+	// ErrTooManyRedirects is returned when the destination redirects more often than allowed.
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrUnsupportedScheme is returned for destinations that are not http or https.
+	ErrUnsupportedScheme = errors.New("unsupported scheme")
+	// ErrNotHTML is returned when the destination does not serve an HTML page.
+	ErrNotHTML = errors.New("destination is not an HTML page")
+)
+
+// Options configures a Fetcher. Zero values select the defaults.
+type Options struct {
+	Timeout      time.Duration // Limit for the whole request including redirects
+	MaxBytes     int64         // Maximum number of body bytes read from the destination
+	MaxRedirects int           // Maximum number of redirects followed
+	AllowPrivate bool          // Permit non-public addresses; meant for tests only
+}
+
+// Fetcher loads destination pages and extracts their metadata.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// New creates a Fetcher with the given options.
+func New(opts Options) *Fetcher {
+	opts = opts.withDefaults()
+
+	return &Fetcher{client: NewHTTPClient(opts), maxBytes: opts.MaxBytes}
+}
+
+// NewHTTPClient returns an HTTP client enforcing the timeout, redirect limit and
+// address restrictions of the options. It is shared by all outgoing requests
+// made on behalf of users.
+func NewHTTPClient(opts Options) *http.Client {
+	opts = opts.withDefaults()
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = rejectNonPublic
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedScheme
+			}
+			return nil
+		},
+	}
+}
+
+// withDefaults replaces zero limits with the defaults.
+func (opts Options) withDefaults() Options {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+
+	return opts
+}
+
+// Fetch downloads the page at rawURL and extracts its metadata.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (storage.PageInfo, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return storage.PageInfo{}, err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return storage.PageInfo{}, ErrUnsupportedScheme
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return storage.PageInfo{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return storage.PageInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return storage.PageInfo{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return storage.PageInfo{}, ErrNotHTML
+	}
+
+	page := parse(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	fetchedAt := time.Now().UTC()
+	page.FetchedAt = &fetchedAt
+
+	return page, nil
+}
+
+// parse extracts page metadata from the head of an HTML document.
+// Relative favicon links are resolved against base; without one, /favicon.ico is assumed.
+func parse(r io.Reader, base *url.URL) storage.PageInfo {
+	var page storage.PageInfo
+	var title strings.Builder
+	inTitle := false
+	icon := ""
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				property := strings.ToLower(attr(token, "property"))
+				if property == "" {
+					property = strings.ToLower(attr(token, "name"))
+				}
+				switch property {
+				case "og:title":
+					page.OGTitle = clean(attr(token, "content"))
+				case "og:description":
+					page.OGDescription = clean(attr(token, "content"))
+				}
+			case "link":
+				if icon == "" && isIconRel(attr(token, "rel")) {
+					icon = attr(token, "href")
+				}
+			case "body":
+				return finish(page, title.String(), icon, base)
+			}
+		case html.TextToken:
+			if inTitle {
+				title.WriteString(token.Data)
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "title":
+				inTitle = false
+			case "head":
+				return finish(page, title.String(), icon, base)
+			}
+		}
+	}
+
+	return finish(page, title.String(), icon, base)
+}
+
+// finish fills the title and the absolute favicon URL of the page.
+func finish(page storage.PageInfo, title string, icon string, base *url.URL) storage.PageInfo {
+	page.Title = clean(title)
+
+	if icon == "" {
+		icon = "/favicon.ico"
+	}
+	if ref, err := url.Parse(strings.TrimSpace(icon)); err == nil {
+		if favicon := base.ResolveReference(ref); favicon.Scheme == "http" || favicon.Scheme == "https" {
+			page.Favicon = favicon.String()
+		}
+	}
+
+	return page
+}
+
+// attr returns the value of the named attribute of the token.
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// isIconRel reports whether a link rel attribute names a favicon.
+func isIconRel(rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		if value == "icon" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clean collapses whitespace and caps the length of an extracted text.
+func clean(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= maxTextLength {
+		return text
+	}
+
+	text = text[:maxTextLength]
+	// Do not cut a multi-byte character in half
+	for !utf8.ValidString(text) {
+		text = text[:len(text)-1]
+	}
+
+	return text
+}
+
+// rejectNonPublic is a net.Dialer control function refusing connections to non-public addresses.
+func rejectNonPublic(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// reservedNets are the ranges that are not globally routable but not covered by the net.IP
+// predicates either.
+var reservedNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"), // Carrier-grade NAT, RFC 6598
+	mustParseCIDR("192.0.0.0/24"),  // IETF protocol assignments, RFC 6890
+	mustParseCIDR("198.18.0.0/15"), // Benchmarking, RFC 2544
+	mustParseCIDR("240.0.0.0/4"),   // Reserved, RFC 1112, including the broadcast address
+	mustParseCIDR("64:ff9b::/96"),  // NAT64, RFC 6052, which reaches any IPv4 address through a gateway
+}
+
+// mustParseCIDR parses a CIDR range of reservedNets.
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// IsPublicIP reports whether ip is a globally routable unicast address.
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		if ip4[0] == 0 {
+			return false
+		}
+		ip = ip4
+	}
+
+	for _, reserved := range reservedNets {
+		if reserved.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,151 @@
+package fetcher
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+	<title>
+		Example   Domain
+	</title>
+	<meta property="og:title" content="Example on social">
+	<meta name="og:description" content="An example page">
+	<link rel="shortcut icon" href="/static/icon.png">
+</head>
+<body><title>Not the title</title></body>
+</html>`
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/bare", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Bare</title></head></html>`))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><!--" + strings.Repeat("x", 4096) + "--><title>Too far</title></head></html>"))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := New(Options{AllowPrivate: true, MaxBytes: 1024, MaxRedirects: 2})
+	ctx := context.Background()
+
+	t.Run("Extracts title, Open Graph data and favicon", func(t *testing.T) {
+		info, err := f.Fetch(ctx, server.URL+"/page")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Example Domain", info.Title)
+		assert.Equal(t, "Example on social", info.OGTitle)
+		assert.Equal(t, "An example page", info.OGDescription)
+		assert.Equal(t, server.URL+"/static/icon.png", info.Favicon)
+		assert.NotNil(t, info.FetchedAt)
+	})
+
+	t.Run("Follows redirects and resolves against the final URL", func(t *testing.T) {
+		info, err := f.Fetch(ctx, server.URL+"/moved")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Example Domain", info.Title)
+	})
+
+	t.Run("Default favicon", func(t *testing.T) {
+		info, err := f.Fetch(ctx, server.URL+"/bare")
+
+		assert.NoError(t, err)
+		assert.Equal(t, server.URL+"/favicon.ico", info.Favicon)
+	})
+
+	t.Run("Redirect limit", func(t *testing.T) {
+		_, err := f.Fetch(ctx, server.URL+"/loop")
+
+		assert.ErrorIs(t, err, ErrTooManyRedirects)
+	})
+
+	t.Run("Size cap", func(t *testing.T) {
+		info, err := f.Fetch(ctx, server.URL+"/big")
+
+		assert.NoError(t, err)
+		assert.Empty(t, info.Title)
+	})
+
+	t.Run("Not an HTML page", func(t *testing.T) {
+		_, err := f.Fetch(ctx, server.URL+"/image")
+
+		assert.ErrorIs(t, err, ErrNotHTML)
+	})
+
+	t.Run("Unsupported scheme", func(t *testing.T) {
+		_, err := f.Fetch(ctx, "file:///etc/passwd")
+
+		assert.ErrorIs(t, err, ErrUnsupportedScheme)
+	})
+
+	t.Run("Private addresses are refused by default", func(t *testing.T) {
+		_, err := New(Options{}).Fetch(ctx, server.URL+"/page")
+
+		assert.ErrorIs(t, err, ErrForbiddenAddress)
+	})
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"0.0.0.0", false},
+		{"255.255.255.255", false},
+		{"240.0.0.1", false},
+		{"250.1.2.3", false},
+		{"198.18.0.1", false},
+		{"198.19.255.254", false},
+		{"198.20.0.1", true},
+		{"192.0.0.8", false},
+		{"192.0.1.1", true},
+		{"::1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:198.18.0.1", false},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b::5db8:d822", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.public, IsPublicIP(net.ParseIP(tt.ip)), tt.ip)
+	}
+}
@@ -2,15 +2,27 @@ package grpc
 
 import (
 	"context"
-	"log"
+	"errors"
+	"io"
+	"time"
 
+	"github.com/golangTroshin/shorturl/internal/app/broker"
 	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
 	shortener "github.com/golangTroshin/shorturl/internal/app/grpc/proto"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/qrcode"
 	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// MaxShortenURLsItems is the largest number of items of a ShortenURLs call. The response holds
+// a result per item, so the limit keeps it well below the 4 MiB that gRPC clients accept by default.
+const MaxShortenURLsItems = 10000
+
 // ShortenerServer implements the gRPC service for URL shortening.
 //
 // This server provides methods for shortening URLs, retrieving original URLs,
@@ -28,12 +40,27 @@ func NewShortenerServer(svc service.Service) *ShortenerServer {
 
 // ShortenURL creates a shortened URL for the given original URL.
 //
-// This method processes a `ShortenURLRequest` containing the original URL,
-// stores the URL mapping in the underlying storage, and returns the shortened URL.
+// This method processes a `ShortenURLRequest` containing the original URL and optional
+// link metadata, stores the URL mapping in the underlying storage, and returns the shortened URL.
 func (s *ShortenerServer) ShortenURL(ctx context.Context, req *shortener.ShortenURLRequest) (*shortener.ShortenURLResponse, error) {
-	URL, err := s.svc.ShortenURL(ctx, req.Url)
+	URL, err := s.svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+		URL: req.Url,
+		LinkOptions: storage.LinkOptions{
+			Title: req.Title,
+			Note:  req.Note,
+			Tags:  req.Tags,
+		},
+	})
 	if err != nil {
-		return nil, err
+		var conflict *storage.InsertConflictError
+		switch {
+		case errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrInvalidOptions):
+			return nil, status.Errorf(codes.InvalidArgument, "%s", err.Error())
+		case errors.As(err, &conflict):
+			return nil, status.Errorf(codes.AlreadyExists, "URL is already shortened as %s", URL.ShortURL)
+		}
+		logger.FromContext(ctx).Error("unable to shorten url", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Failed to shorten URL")
 	}
 	return &shortener.ShortenURLResponse{ShortUrl: URL.ShortURL}, nil
 }
@@ -51,38 +78,92 @@ func (s *ShortenerServer) GetOriginalURL(ctx context.Context, req *shortener.Get
 }
 
 // GetURLsByUser retrieves URLs associated with a given user ID.
+//
+// The request may filter the links by tag or search text, choose the sort order and
+// ask for a page of a given size; `next_cursor` of the response continues the list.
 func (s *ShortenerServer) GetUserURLs(ctx context.Context, req *shortener.GetUserURLsRequest) (*shortener.GetUserURLsResponse, error) {
-	urls, err := s.svc.GetUserURLs(ctx)
+	page, err := s.svc.FindUserURLs(ctx, storage.URLQuery{
+		Tag:       req.Tag,
+		Search:    req.Search,
+		Prefix:    req.Prefix,
+		Sort:      req.Sort,
+		Ascending: req.Ascending,
+		Limit:     int(req.Limit),
+		Cursor:    req.Cursor,
+		Health:    req.Health,
+	})
 	if err != nil {
-		log.Printf("getURLsByUser: Error fetching URLs for user %s", err)
-		return nil, err
+		if errors.Is(err, service.ErrInvalidQuery) {
+			return nil, status.Errorf(codes.InvalidArgument, "%s", err.Error())
+		}
+		logger.FromContext(ctx).Error("unable to fetch user URLs", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Failed to fetch URLs")
 	}
 
 	// Convert storage URLs to gRPC response format
 	var responseURLs []*shortener.URL
-	for _, url := range urls {
-		responseURLs = append(responseURLs, &shortener.URL{
+	for _, url := range page.URLs {
+		pbURL := &shortener.URL{
 			ShortUrl:    config.Options.FlagBaseURL + "/" + url.ShortURL,
 			OriginalUrl: url.OriginalURL,
-		})
+			Title:       url.Title,
+			Note:        url.Note,
+			Tags:        url.Tags,
+		}
+		if url.Health != nil {
+			pbURL.LastStatus = int32(url.Health.Status)
+			pbURL.LastCheckedAt = url.Health.CheckedAt.Format(time.RFC3339)
+			pbURL.Broken = url.Health.Broken()
+		}
+		responseURLs = append(responseURLs, pbURL)
 	}
 
 	return &shortener.GetUserURLsResponse{
-		Urls: responseURLs,
+		Urls:       responseURLs,
+		NextCursor: page.NextCursor,
 	}, nil
 }
 
 // DeleteUserURLs handles a gRPC request to delete a batch of URLs for a user.
 //
 // This method processes a `DeleteUserURLsRequest` containing a list of URL IDs to delete.
-// The URLs are added to a deletion queue for asynchronous processing.
+// The URLs are added to a deletion queue for asynchronous processing; the returned job ID
+// can be passed to GetDeletion.
 func (s *ShortenerServer) DeleteUserURLs(ctx context.Context, req *shortener.DeleteUserURLsRequest) (*shortener.DeleteUserURLsResponse, error) {
-	err := s.svc.DeleteUserURLs(ctx, req.ShortUrls)
+	job, err := s.svc.DeleteUserURLs(ctx, req.ShortUrls)
+	if errors.Is(err, deletes.ErrQueueFull) || errors.Is(err, deletes.ErrClosed) {
+		return nil, status.Errorf(codes.Unavailable, "%s", err.Error())
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "%s", err.Error())
 	}
 
-	return &shortener.DeleteUserURLsResponse{Success: true}, nil
+	return &shortener.DeleteUserURLsResponse{Success: true, JobId: job.ID}, nil
+}
+
+// GetDeletion handles a gRPC request for the state of a deletion job of the user.
+func (s *ShortenerServer) GetDeletion(ctx context.Context, req *shortener.GetDeletionRequest) (*shortener.GetDeletionResponse, error) {
+	job, err := s.svc.GetDeletion(ctx, req.JobId)
+	if errors.Is(err, deletes.ErrJobNotFound) {
+		return nil, status.Errorf(codes.NotFound, "Deletion job not found")
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("unable to get deletion job", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Internal Server Error")
+	}
+
+	response := &shortener.GetDeletionResponse{
+		JobId:     job.ID,
+		Status:    string(job.Status),
+		ShortUrls: job.URLs,
+		Attempts:  int32(job.Attempts),
+		LastError: job.LastError,
+	}
+	if job.Status == deletes.StatusPending {
+		response.NextAttemptAt = job.NextAttemptAt.Format(time.RFC3339)
+	}
+
+	return response, nil
 }
 
 // GetStats handles a gRPC request to retrieve URL and user statistics.
@@ -92,11 +173,10 @@ func (s *ShortenerServer) DeleteUserURLs(ctx context.Context, req *shortener.Del
 func (s *ShortenerServer) GetStats(ctx context.Context, req *shortener.GetStatsRequest) (*shortener.GetStatsResponse, error) {
 	stats, err := s.svc.GetStats(ctx)
 	if err != nil {
-		log.Printf("Error fetching stats: %v", err)
+		logger.FromContext(ctx).Error("unable to fetch stats", zap.Error(err))
 		return nil, status.Errorf(codes.Internal, "Unable to fetch statistics")
 	}
 
-	log.Printf("Stats retrieved: URLs: %d, Users: %d", stats.Urls, stats.Users)
 	return &shortener.GetStatsResponse{
 		Urls:  int32(stats.Urls),
 		Users: int32(stats.Users),
@@ -111,10 +191,263 @@ func (s *ShortenerServer) Ping(ctx context.Context, req *shortener.PingRequest)
 	err := s.svc.PingDatabase(ctx)
 
 	if err != nil {
-		log.Printf("Database ping error: %v", err)
+		logger.FromContext(ctx).Warn("storage ping failed", zap.Error(err))
 		return nil, status.Errorf(codes.Unavailable, "%s", err.Error())
 	}
 
-	log.Println("Database is healthy")
 	return &shortener.PingResponse{Status: "OK"}, nil
 }
+
+// GetRules handles a gRPC request to list the conditional redirect rules of a user's URL.
+func (s *ShortenerServer) GetRules(ctx context.Context, req *shortener.GetRulesRequest) (*shortener.GetRulesResponse, error) {
+	url, err := s.svc.GetUserURL(ctx, req.ShortUrl)
+	if err != nil {
+		return nil, linkStatusError(ctx, err)
+	}
+
+	return &shortener.GetRulesResponse{Rules: rulesToProto(url.Rules)}, nil
+}
+
+// SetRules handles a gRPC request to replace the conditional redirect rules of a user's URL.
+//
+// Rules are evaluated in order on every redirect and the first matching rule wins.
+// An empty list removes all rules.
+func (s *ShortenerServer) SetRules(ctx context.Context, req *shortener.SetRulesRequest) (*shortener.SetRulesResponse, error) {
+	url, err := s.svc.SetURLRules(ctx, req.ShortUrl, rulesFromProto(req.Rules))
+	if err != nil {
+		return nil, linkStatusError(ctx, err)
+	}
+
+	return &shortener.SetRulesResponse{Rules: rulesToProto(url.Rules)}, nil
+}
+
+// GetQRCode handles a gRPC request for a QR code image of a short link.
+//
+// The image encodes the public short URL and is rendered like `GET /{id}/qr`;
+// an unset margin selects the quiet zone of 4 modules required by the QR specification.
+func (s *ShortenerServer) GetQRCode(ctx context.Context, req *shortener.GetQRCodeRequest) (*shortener.GetQRCodeResponse, error) {
+	opts := qrcode.ImageOptions{Format: req.Format, Size: int(req.Size), Margin: qrcode.DefaultMargin}
+	if req.Margin != nil {
+		opts.Margin = int(*req.Margin)
+	}
+
+	image, err := s.svc.QRCode(ctx, req.ShortUrl, req.Level, opts)
+	if err != nil {
+		return nil, linkStatusError(ctx, err)
+	}
+
+	return &shortener.GetQRCodeResponse{Image: image, ContentType: qrcode.ContentType(opts.Format)}, nil
+}
+
+// ShortenURLs handles a client-streaming gRPC request that shortens a batch of up to
+// MaxShortenURLsItems URLs.
+//
+// Items are stored in chunks of at most service.MaxStreamChunk while they are received, with the
+// semantics of `POST /api/shorten/batch`: invalid URLs and URLs that were already shortened are
+// reported per item and do not fail the batch. The response lists the result of every item in
+// request order together with the totals. A stream sending more items fails with
+// InvalidArgument once the limit is exceeded; the chunks stored until then are kept.
+func (s *ShortenerServer) ShortenURLs(stream shortener.Shortener_ShortenURLsServer) error {
+	ctx := stream.Context()
+	response := &shortener.ShortenURLsResponse{}
+	chunk := make([]storage.RequestBodyBanch, 0, service.MaxStreamChunk)
+	received := 0
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		results, err := s.svc.BatchShortenURLs(ctx, chunk)
+		if err != nil {
+			logger.FromContext(ctx).Error("streamed batch failed", zap.Error(err))
+			return status.Errorf(codes.Internal, "Internal server error")
+		}
+
+		for _, result := range results {
+			pbResult := &shortener.ShortenURLsResult{
+				CorrelationId: result.CorrelationID,
+				OriginalUrl:   result.URL.OriginalURL,
+				Status:        string(result.Status),
+				Existing:      result.Status == service.BatchExisting,
+			}
+
+			switch result.Status {
+			case service.BatchCreated:
+				response.Created++
+			case service.BatchExisting:
+				response.Existing++
+			case service.BatchInvalid:
+				response.Invalid++
+			case service.BatchFailed:
+				response.Failed++
+			}
+
+			if result.Err != nil {
+				pbResult.Error = result.Err.Error()
+			} else {
+				pbResult.ShortUrl = config.Options.FlagBaseURL + "/" + result.URL.ShortURL
+			}
+
+			response.Results = append(response.Results, pbResult)
+		}
+
+		chunk = chunk[:0]
+
+		return nil
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if received++; received > MaxShortenURLsItems {
+			return status.Errorf(codes.InvalidArgument, "a call shortens at most %d URLs", MaxShortenURLsItems)
+		}
+		chunk = append(chunk, storage.RequestBodyBanch{CorrelationID: req.CorrelationId, OriginalURL: req.Url})
+		if len(chunk) == service.MaxStreamChunk {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(response)
+}
+
+// WatchLinks handles a server-streaming gRPC request that pushes the events of the caller's links
+// as they happen: creations, updates of their settings, deletions and clicks.
+//
+// The stream lasts until the client cancels it. A client reading too slowly to keep up is
+// disconnected with ResourceExhausted and the stream ends with Unavailable on shutdown; in both
+// cases the client may watch again and reload its links to catch up.
+func (s *ShortenerServer) WatchLinks(req *shortener.WatchLinksRequest, stream shortener.Shortener_WatchLinksServer) error {
+	ctx := stream.Context()
+	sub, err := s.svc.WatchLinks(ctx)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "%s", err.Error())
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				return watchStatusError(sub.Err())
+			}
+
+			err := stream.Send(&shortener.LinkEvent{
+				Id:          event.ID,
+				Type:        event.Type,
+				ShortUrl:    config.Options.FlagBaseURL + "/" + event.ShortURL,
+				OriginalUrl: event.OriginalURL,
+				OccurredAt:  event.OccurredAt.Format(time.RFC3339),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watchStatusError maps the reason a link event subscription ended to a gRPC status error.
+func watchStatusError(err error) error {
+	switch {
+	case errors.Is(err, broker.ErrSlowSubscriber):
+		return status.Errorf(codes.ResourceExhausted, "%s", err.Error())
+	case errors.Is(err, broker.ErrClosed):
+		return status.Errorf(codes.Unavailable, "%s", err.Error())
+	}
+
+	return nil
+}
+
+// linkStatusError maps errors of link management operations to gRPC status errors.
+func linkStatusError(ctx context.Context, err error) error {
+	var deleted *storage.DeletedURLError
+
+	switch {
+	case errors.Is(err, service.ErrInvalidOptions), errors.Is(err, service.ErrInvalidQRCode):
+		return status.Errorf(codes.InvalidArgument, "%s", err.Error())
+	case errors.Is(err, storage.ErrURLNotFound), errors.As(err, &deleted), errors.Is(err, service.ErrURLExpired):
+		return status.Errorf(codes.NotFound, "URL not found")
+	}
+
+	logger.FromContext(ctx).Error("link operation failed", zap.Error(err))
+	return status.Errorf(codes.Internal, "Internal server error")
+}
+
+// rulesToProto converts storage rules to their gRPC representation.
+func rulesToProto(rules []storage.Rule) []*shortener.RedirectRule {
+	result := make([]*shortener.RedirectRule, 0, len(rules))
+	for _, rule := range rules {
+		pbRule := &shortener.RedirectRule{
+			Device:      rule.Device,
+			Language:    rule.Language,
+			Query:       rule.Query,
+			Destination: rule.Destination,
+		}
+
+		if rule.TimeWindow != nil {
+			pbRule.TimeWindow = &shortener.TimeWindow{
+				Start:    rule.TimeWindow.Start,
+				End:      rule.TimeWindow.End,
+				Location: rule.TimeWindow.Location,
+			}
+		}
+
+		for _, variant := range rule.Split {
+			pbRule.Split = append(pbRule.Split, &shortener.SplitVariant{
+				Destination: variant.Destination,
+				Weight:      int32(variant.Weight),
+			})
+		}
+
+		result = append(result, pbRule)
+	}
+
+	return result
+}
+
+// rulesFromProto converts gRPC rules to the storage representation.
+func rulesFromProto(rules []*shortener.RedirectRule) []storage.Rule {
+	result := make([]storage.Rule, 0, len(rules))
+	for _, pbRule := range rules {
+		rule := storage.Rule{
+			Device:      pbRule.Device,
+			Language:    pbRule.Language,
+			Query:       pbRule.Query,
+			Destination: pbRule.Destination,
+		}
+
+		if pbRule.TimeWindow != nil {
+			rule.TimeWindow = &storage.TimeWindow{
+				Start:    pbRule.TimeWindow.Start,
+				End:      pbRule.TimeWindow.End,
+				Location: pbRule.TimeWindow.Location,
+			}
+		}
+
+		for _, variant := range pbRule.Split {
+			rule.Split = append(rule.Split, storage.SplitVariant{
+				Destination: variant.Destination,
+				Weight:      int(variant.Weight),
+			})
+		}
+
+		result = append(result, rule)
+	}
+
+	return result
+}
@@ -3,14 +3,21 @@ package grpc_test
 import (
 	"context"
 	"errors"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
 	grpc "github.com/golangTroshin/shorturl/internal/app/grpc/handlers"
 	shortener "github.com/golangTroshin/shorturl/internal/app/grpc/proto"
+	"github.com/golangTroshin/shorturl/internal/app/qrcode"
+	"github.com/golangTroshin/shorturl/internal/app/service"
 	"github.com/golangTroshin/shorturl/internal/app/storage"
 	"github.com/golangTroshin/shorturl/internal/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -23,7 +30,7 @@ func TestShortenerServer_ShortenURL(t *testing.T) {
 	server := grpc.NewShortenerServer(mockService)
 
 	t.Run("Successful URL shortening", func(t *testing.T) {
-		mockService.EXPECT().ShortenURL(gomock.Any(), "http://example.com").Return(
+		mockService.EXPECT().ShortenURLWithOptions(gomock.Any(), storage.RequestURL{URL: "http://example.com"}).Return(
 			storage.URL{ShortURL: "short123"}, nil,
 		)
 
@@ -35,13 +42,39 @@ func TestShortenerServer_ShortenURL(t *testing.T) {
 		assert.Equal(t, "short123", resp.ShortUrl)
 	})
 
+	t.Run("URL shortening with metadata", func(t *testing.T) {
+		mockService.EXPECT().ShortenURLWithOptions(gomock.Any(), storage.RequestURL{
+			URL:         "http://example.com",
+			LinkOptions: storage.LinkOptions{Title: "Example", Note: "docs", Tags: []string{"work"}},
+		}).Return(storage.URL{ShortURL: "short123"}, nil)
+
+		req := &shortener.ShortenURLRequest{Url: "http://example.com", Title: "Example", Note: "docs", Tags: []string{"work"}}
+		resp, err := server.ShortenURL(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "short123", resp.ShortUrl)
+	})
+
 	t.Run("Error during URL shortening", func(t *testing.T) {
-		mockService.EXPECT().ShortenURL(gomock.Any(), "http://example.com").Return(storage.URL{}, errors.New("internal error"))
+		mockService.EXPECT().ShortenURLWithOptions(gomock.Any(), storage.RequestURL{URL: "http://example.com"}).Return(storage.URL{}, errors.New("internal error"))
 
 		req := &shortener.ShortenURLRequest{Url: "http://example.com"}
 		resp, err := server.ShortenURL(context.Background(), req)
 
-		assert.Error(t, err)
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Nil(t, resp)
+	})
+
+	t.Run("URL already shortened", func(t *testing.T) {
+		mockService.EXPECT().ShortenURLWithOptions(gomock.Any(), storage.RequestURL{URL: "http://example.com"}).Return(
+			storage.URL{ShortURL: "short123"}, storage.NewInsertConflictError(),
+		)
+
+		req := &shortener.ShortenURLRequest{Url: "http://example.com"}
+		resp, err := server.ShortenURL(context.Background(), req)
+
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+		assert.Contains(t, status.Convert(err).Message(), "short123")
 		assert.Nil(t, resp)
 	})
 }
@@ -83,10 +116,10 @@ func TestShortenerServer_GetUserURLs(t *testing.T) {
 	server := grpc.NewShortenerServer(mockService)
 
 	t.Run("Successful retrieval of user URLs", func(t *testing.T) {
-		mockService.EXPECT().GetUserURLs(gomock.Any()).Return([]storage.URL{
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{}).Return(storage.URLPage{URLs: []storage.URL{
 			{ShortURL: "short1", OriginalURL: "http://example.com/1"},
 			{ShortURL: "short2", OriginalURL: "http://example.com/2"},
-		}, nil)
+		}}, nil)
 
 		req := &shortener.GetUserURLsRequest{}
 		resp, err := server.GetUserURLs(context.Background(), req)
@@ -98,8 +131,59 @@ func TestShortenerServer_GetUserURLs(t *testing.T) {
 		assert.Equal(t, "http://example.com/1", resp.Urls[0].OriginalUrl)
 	})
 
+	t.Run("Filter by tag", func(t *testing.T) {
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{Tag: "work"}).Return(storage.URLPage{URLs: []storage.URL{
+			{ShortURL: "short1", OriginalURL: "http://example.com/1", LinkOptions: storage.LinkOptions{
+				Title: "Example", Note: "docs", Tags: []string{"work"},
+			}},
+		}}, nil)
+
+		resp, err := server.GetUserURLs(context.Background(), &shortener.GetUserURLsRequest{Tag: "work"})
+
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+		assert.Equal(t, "Example", resp.Urls[0].Title)
+		assert.Equal(t, "docs", resp.Urls[0].Note)
+		assert.Equal(t, []string{"work"}, resp.Urls[0].Tags)
+	})
+
+	t.Run("Paged request", func(t *testing.T) {
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{Sort: storage.SortClicks, Limit: 1, Cursor: "c1"}).Return(
+			storage.URLPage{URLs: []storage.URL{{ShortURL: "short1"}}, NextCursor: "c2"}, nil,
+		)
+
+		resp, err := server.GetUserURLs(context.Background(), &shortener.GetUserURLsRequest{Sort: "clicks", Limit: 1, Cursor: "c1"})
+
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+		assert.Equal(t, "c2", resp.NextCursor)
+	})
+
+	t.Run("Broken links", func(t *testing.T) {
+		checkedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{Health: storage.HealthBroken}).Return(storage.URLPage{
+			URLs: []storage.URL{{ShortURL: "short1", Health: &storage.LinkHealth{Status: 404, CheckedAt: checkedAt}}},
+		}, nil)
+
+		resp, err := server.GetUserURLs(context.Background(), &shortener.GetUserURLsRequest{Health: "broken"})
+
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+		assert.Equal(t, int32(404), resp.Urls[0].LastStatus)
+		assert.Equal(t, "2024-05-01T12:00:00Z", resp.Urls[0].LastCheckedAt)
+		assert.True(t, resp.Urls[0].Broken)
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{Sort: "name"}).Return(storage.URLPage{}, service.ErrInvalidQuery)
+
+		_, err := server.GetUserURLs(context.Background(), &shortener.GetUserURLsRequest{Sort: "name"})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
 	t.Run("Error retrieving user URLs", func(t *testing.T) {
-		mockService.EXPECT().GetUserURLs(gomock.Any()).Return(nil, errors.New("internal error"))
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{}).Return(storage.URLPage{}, errors.New("internal error"))
 
 		req := &shortener.GetUserURLsRequest{}
 		resp, err := server.GetUserURLs(context.Background(), req)
@@ -117,8 +201,8 @@ func TestShortenerServer_DeleteUserURLs(t *testing.T) {
 	server := grpc.NewShortenerServer(mockService)
 
 	t.Run("Successful deletion of user URLs", func(t *testing.T) {
-		// Mock the service to return nil for a successful deletion
-		mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1", "short2"}).Return(nil)
+		// Mock the service to return the queued job for a successful deletion
+		mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1", "short2"}).Return(deletes.Job{ID: "job1"}, nil)
 
 		// Call the method
 		req := &shortener.DeleteUserURLsRequest{ShortUrls: []string{"short1", "short2"}}
@@ -128,11 +212,12 @@ func TestShortenerServer_DeleteUserURLs(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, resp)
 		assert.True(t, resp.Success)
+		assert.Equal(t, "job1", resp.JobId)
 	})
 
 	t.Run("Error during URL deletion", func(t *testing.T) {
 		// Mock the service to return an error
-		mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1", "short2"}).Return(errors.New("failed to delete URLs"))
+		mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1", "short2"}).Return(deletes.Job{}, errors.New("failed to delete URLs"))
 
 		// Call the method
 		req := &shortener.DeleteUserURLsRequest{ShortUrls: []string{"short1", "short2"}}
@@ -148,6 +233,46 @@ func TestShortenerServer_DeleteUserURLs(t *testing.T) {
 		assert.Equal(t, codes.InvalidArgument, st.Code())
 		assert.Equal(t, "failed to delete URLs", st.Message())
 	})
+
+	t.Run("Queue full", func(t *testing.T) {
+		mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1"}).Return(deletes.Job{}, deletes.ErrQueueFull)
+
+		_, err := server.DeleteUserURLs(context.Background(), &shortener.DeleteUserURLsRequest{ShortUrls: []string{"short1"}})
+
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestShortenerServer_GetDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	server := grpc.NewShortenerServer(mockService)
+
+	t.Run("Pending job", func(t *testing.T) {
+		next := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		job := deletes.Job{ID: "job1", URLs: []string{"short1"}, Status: deletes.StatusPending, Attempts: 2,
+			LastError: "db down", NextAttemptAt: next}
+		mockService.EXPECT().GetDeletion(gomock.Any(), "job1").Return(job, nil)
+
+		resp, err := server.GetDeletion(context.Background(), &shortener.GetDeletionRequest{JobId: "job1"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "pending", resp.Status)
+		assert.Equal(t, []string{"short1"}, resp.ShortUrls)
+		assert.Equal(t, int32(2), resp.Attempts)
+		assert.Equal(t, "db down", resp.LastError)
+		assert.Equal(t, "2026-10-18T12:00:00Z", resp.NextAttemptAt)
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		mockService.EXPECT().GetDeletion(gomock.Any(), "other").Return(deletes.Job{}, deletes.ErrJobNotFound)
+
+		_, err := server.GetDeletion(context.Background(), &shortener.GetDeletionRequest{JobId: "other"})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
 }
 
 func TestShortenerServer_GetStats(t *testing.T) {
@@ -179,3 +304,277 @@ func TestShortenerServer_GetStats(t *testing.T) {
 		assert.Nil(t, resp)
 	})
 }
+
+func TestShortenerServer_Rules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	server := grpc.NewShortenerServer(mockService)
+
+	rules := []storage.Rule{{
+		Query: map[string]string{"utm_source": "mail"},
+		Split: []storage.SplitVariant{{Destination: "http://example.com/a", Weight: 3}, {Destination: "http://example.com/b", Weight: 1}},
+	}}
+
+	t.Run("Set rules", func(t *testing.T) {
+		mockService.EXPECT().SetURLRules(gomock.Any(), "short1", rules).Return(
+			storage.URL{ShortURL: "short1", LinkOptions: storage.LinkOptions{Rules: rules}}, nil,
+		)
+
+		req := &shortener.SetRulesRequest{ShortUrl: "short1", Rules: []*shortener.RedirectRule{{
+			Query: map[string]string{"utm_source": "mail"},
+			Split: []*shortener.SplitVariant{{Destination: "http://example.com/a", Weight: 3}, {Destination: "http://example.com/b", Weight: 1}},
+		}}}
+		resp, err := server.SetRules(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.Len(t, resp.Rules, 1)
+		assert.Len(t, resp.Rules[0].Split, 2)
+		assert.Equal(t, int32(3), resp.Rules[0].Split[0].Weight)
+	})
+
+	t.Run("Invalid rules", func(t *testing.T) {
+		mockService.EXPECT().SetURLRules(gomock.Any(), "short1", gomock.Any()).Return(storage.URL{}, service.ErrInvalidOptions)
+
+		resp, err := server.SetRules(context.Background(), &shortener.SetRulesRequest{ShortUrl: "short1"})
+
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Get rules of unknown URL", func(t *testing.T) {
+		mockService.EXPECT().GetUserURL(gomock.Any(), "missing").Return(storage.URL{}, storage.ErrURLNotFound)
+
+		resp, err := server.GetRules(context.Background(), &shortener.GetRulesRequest{ShortUrl: "missing"})
+
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestShortenerServer_GetQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	server := grpc.NewShortenerServer(mockService)
+
+	t.Run("Default margin", func(t *testing.T) {
+		mockService.EXPECT().QRCode(gomock.Any(), "short1", "H", qrcode.ImageOptions{Format: "svg", Size: 128, Margin: qrcode.DefaultMargin}).
+			Return([]byte("<svg/>"), nil)
+
+		resp, err := server.GetQRCode(context.Background(), &shortener.GetQRCodeRequest{ShortUrl: "short1", Format: "svg", Size: 128, Level: "H"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("<svg/>"), resp.Image)
+		assert.Equal(t, "image/svg+xml", resp.ContentType)
+	})
+
+	t.Run("Explicit margin", func(t *testing.T) {
+		margin := int32(0)
+		mockService.EXPECT().QRCode(gomock.Any(), "short1", "", qrcode.ImageOptions{}).Return([]byte("png"), nil)
+
+		resp, err := server.GetQRCode(context.Background(), &shortener.GetQRCodeRequest{ShortUrl: "short1", Margin: &margin})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", resp.ContentType)
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		mockService.EXPECT().QRCode(gomock.Any(), "short1", "Z", gomock.Any()).Return(nil, service.ErrInvalidQRCode)
+
+		_, err := server.GetQRCode(context.Background(), &shortener.GetQRCodeRequest{ShortUrl: "short1", Level: "Z"})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Expired link", func(t *testing.T) {
+		mockService.EXPECT().QRCode(gomock.Any(), "short1", "", gomock.Any()).Return(nil, service.ErrURLExpired)
+
+		_, err := server.GetQRCode(context.Background(), &shortener.GetQRCodeRequest{ShortUrl: "short1"})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+// shortenURLsStream is a client stream of ShortenURLs requests served from a slice.
+type shortenURLsStream struct {
+	shortener.Shortener_ShortenURLsServer
+	requests []*shortener.ShortenURLsRequest
+	response *shortener.ShortenURLsResponse
+}
+
+func (s *shortenURLsStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *shortenURLsStream) Recv() (*shortener.ShortenURLsRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *shortenURLsStream) SendAndClose(resp *shortener.ShortenURLsResponse) error {
+	s.response = resp
+	return nil
+}
+
+func TestShortenerServer_ShortenURLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	server := grpc.NewShortenerServer(mockService)
+
+	// chunk answers a chunk as if every URL was already shortened.
+	chunk := func(items []storage.RequestBodyBanch) []service.BatchResult {
+		results := make([]service.BatchResult, len(items))
+		for i, item := range items {
+			results[i] = service.BatchResult{
+				CorrelationID: item.CorrelationID,
+				URL:           storage.URL{ShortURL: "abc", OriginalURL: item.OriginalURL},
+				Status:        service.BatchExisting,
+			}
+		}
+		return results
+	}
+
+	t.Run("Per-item results in chunks", func(t *testing.T) {
+		stream := &shortenURLsStream{}
+		for i := 0; i < service.MaxStreamChunk+1; i++ {
+			stream.requests = append(stream.requests, &shortener.ShortenURLsRequest{CorrelationId: "id", Url: "https://example.com"})
+		}
+
+		gomock.InOrder(
+			mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Len(service.MaxStreamChunk)).DoAndReturn(
+				func(_ context.Context, items []storage.RequestBodyBanch) ([]service.BatchResult, error) {
+					return chunk(items), nil
+				}),
+			mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Len(1)).Return([]service.BatchResult{
+				{CorrelationID: "id", URL: storage.URL{OriginalURL: "bad"}, Status: service.BatchInvalid, Err: service.ErrInvalidURL},
+			}, nil),
+		)
+
+		assert.NoError(t, server.ShortenURLs(stream))
+
+		require.NotNil(t, stream.response)
+		assert.Len(t, stream.response.Results, service.MaxStreamChunk+1)
+		assert.Equal(t, int32(service.MaxStreamChunk), stream.response.Existing)
+		assert.Equal(t, int32(1), stream.response.Invalid)
+		assert.Equal(t, "existing", stream.response.Results[0].Status)
+		assert.Contains(t, stream.response.Results[0].ShortUrl, "/abc")
+
+		last := stream.response.Results[service.MaxStreamChunk]
+		assert.Equal(t, "invalid", last.Status)
+		assert.Empty(t, last.ShortUrl)
+		assert.Equal(t, service.ErrInvalidURL.Error(), last.Error)
+	})
+
+	t.Run("Too many items", func(t *testing.T) {
+		stream := &shortenURLsStream{}
+		for i := 0; i < grpc.MaxShortenURLsItems+1; i++ {
+			stream.requests = append(stream.requests, &shortener.ShortenURLsRequest{CorrelationId: "id", Url: "https://example.com"})
+		}
+		mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Len(service.MaxStreamChunk)).DoAndReturn(
+			func(_ context.Context, items []storage.RequestBodyBanch) ([]service.BatchResult, error) {
+				return chunk(items), nil
+			}).Times(grpc.MaxShortenURLsItems / service.MaxStreamChunk)
+
+		err := server.ShortenURLs(stream)
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Nil(t, stream.response)
+	})
+
+	t.Run("Storage failure", func(t *testing.T) {
+		stream := &shortenURLsStream{requests: []*shortener.ShortenURLsRequest{{Url: "https://example.com"}}}
+		mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down"))
+
+		err := server.ShortenURLs(stream)
+
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Nil(t, stream.response)
+	})
+}
+
+// watchLinksStream is a server stream of link events recording what is sent.
+type watchLinksStream struct {
+	shortener.Shortener_WatchLinksServer
+	ctx    context.Context
+	events chan *shortener.LinkEvent
+}
+
+func (s *watchLinksStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchLinksStream) Send(event *shortener.LinkEvent) error {
+	s.events <- event
+	return nil
+}
+
+func TestShortenerServer_WatchLinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	server := grpc.NewShortenerServer(mockService)
+
+	t.Run("Events until the client cancels", func(t *testing.T) {
+		events := broker.New(0)
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &watchLinksStream{ctx: ctx, events: make(chan *shortener.LinkEvent)}
+		mockService.EXPECT().WatchLinks(gomock.Any()).Return(events.Subscribe("user1"), nil)
+
+		done := make(chan error)
+		go func() { done <- server.WatchLinks(&shortener.WatchLinksRequest{}, stream) }()
+
+		events.Publish(broker.Event{Type: broker.EventCreated, UserID: "user1", ShortURL: "abc", OriginalURL: "https://example.com"})
+		event := <-stream.events
+		assert.Equal(t, broker.EventCreated, event.Type)
+		assert.Contains(t, event.ShortUrl, "/abc")
+		assert.Equal(t, "https://example.com", event.OriginalUrl)
+		assert.NotEmpty(t, event.OccurredAt)
+
+		cancel()
+		assert.NoError(t, <-done)
+		assert.Equal(t, 0, events.Subscribers(), "the subscription is closed")
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		events := broker.New(0)
+		stream := &watchLinksStream{ctx: context.Background()}
+		mockService.EXPECT().WatchLinks(gomock.Any()).Return(events.Subscribe("user1"), nil)
+		events.Close()
+
+		err := server.WatchLinks(&shortener.WatchLinksRequest{}, stream)
+
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("Slow client", func(t *testing.T) {
+		events := broker.New(1)
+		stream := &watchLinksStream{ctx: context.Background(), events: make(chan *shortener.LinkEvent, 1)}
+		sub := events.Subscribe("user1")
+		events.Publish(broker.Event{Type: broker.EventClicked, UserID: "user1", ShortURL: "abc"})
+		events.Publish(broker.Event{Type: broker.EventClicked, UserID: "user1", ShortURL: "abc"})
+		mockService.EXPECT().WatchLinks(gomock.Any()).Return(sub, nil)
+
+		err := server.WatchLinks(&shortener.WatchLinksRequest{}, stream)
+
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Len(t, stream.events, 1, "buffered events are sent before the error")
+	})
+
+	t.Run("Without user", func(t *testing.T) {
+		mockService.EXPECT().WatchLinks(gomock.Any()).Return(nil, errors.New("wrong userID"))
+
+		err := server.WatchLinks(&shortener.WatchLinksRequest{}, &watchLinksStream{ctx: context.Background()})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -2,12 +2,16 @@ package grpc
 
 import (
 	"context"
-	"log"
+	"slices"
+	"strings"
 
 	"github.com/golangTroshin/shorturl/internal/app/helpers"
 	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
@@ -30,6 +34,10 @@ func GiveAuthTokenToUserInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (interface{}, error) {
+	if isPublic(info) {
+		return handler(ctx, req)
+	}
+
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		md = metadata.New(nil)
@@ -38,19 +46,19 @@ func GiveAuthTokenToUserInterceptor(
 	var authToken string
 	if tokens := md[middleware.CookieAuthToken]; len(tokens) > 0 {
 		authToken = tokens[0]
-		log.Println("Token found in metadata")
 	} else {
 		var err error
 		authToken, err = helpers.BuildJWTString()
 		if err != nil {
-			log.Printf("BuildJWTString error: %v", err)
+			logger.FromContext(ctx).Error("unable to build auth token", zap.Error(err))
 			return nil, status.Errorf(codes.Internal, "Internal Server Error")
 		}
-		log.Println("Generated new auth token")
+		logger.FromContext(ctx).Debug("generated new auth token")
 	}
 
 	// Add token to the context
 	ctx = context.WithValue(ctx, middleware.UserIDKey, authToken)
+	ctx = middleware.WithUserLogFields(ctx, authToken)
 	outgoingMD := metadata.Pairs(middleware.CookieAuthToken, authToken)
 	grpc.SetHeader(ctx, outgoingMD)
 
@@ -81,6 +89,10 @@ func CheckAuthTokenInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (interface{}, error) {
+	if isPublic(info) {
+		return handler(ctx, req)
+	}
+
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return nil, status.Errorf(codes.Unauthenticated, "No metadata found")
@@ -89,16 +101,79 @@ func CheckAuthTokenInterceptor(
 	// Check for auth_token in metadata
 	tokens := md[middleware.CookieAuthToken]
 	if len(tokens) == 0 || tokens[0] == "" {
-		log.Println("Missing or empty auth_token")
 		return nil, status.Errorf(codes.Unauthenticated, "Invalid or missing auth token")
 	}
 
 	authToken := tokens[0]
-	log.Printf("Valid auth token: %s", authToken)
 
 	// Add the token to the context for downstream handlers
 	ctx = context.WithValue(ctx, middleware.UserIDKey, authToken)
+	ctx = middleware.WithUserLogFields(ctx, authToken)
 
 	// Call the next handler
 	return handler(ctx, req)
 }
+
+// GiveAuthTokenToUserStreamInterceptor is the streaming counterpart of GiveAuthTokenToUserInterceptor.
+//
+// The handler receives a stream whose context carries the user ID, like unary handlers do.
+func GiveAuthTokenToUserStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	_, err := GiveAuthTokenToUserInterceptor(ss.Context(), nil, unaryInfo(info), streamHandler(srv, ss, handler))
+	return err
+}
+
+// CheckAuthTokenStreamInterceptor is the streaming counterpart of CheckAuthTokenInterceptor.
+func CheckAuthTokenStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	_, err := CheckAuthTokenInterceptor(ss.Context(), nil, unaryInfo(info), streamHandler(srv, ss, handler))
+	return err
+}
+
+// publicServices lists the gRPC services called without an auth token, such as health probes.
+var publicServices = []string{healthpb.Health_ServiceDesc.ServiceName}
+
+// isPublic reports whether the called method belongs to one of publicServices.
+func isPublic(info *grpc.UnaryServerInfo) bool {
+	if info == nil {
+		return false
+	}
+
+	service, _, _ := strings.Cut(strings.TrimPrefix(info.FullMethod, "/"), "/")
+	return slices.Contains(publicServices, service)
+}
+
+// unaryInfo describes a streaming call to the unary interceptors.
+func unaryInfo(info *grpc.StreamServerInfo) *grpc.UnaryServerInfo {
+	if info == nil {
+		return nil
+	}
+	return &grpc.UnaryServerInfo{FullMethod: info.FullMethod}
+}
+
+// streamHandler adapts a stream handler to a unary one, so that the unary interceptors can
+// prepare the context of the stream.
+func streamHandler(srv interface{}, ss grpc.ServerStream, handler grpc.StreamHandler) grpc.UnaryHandler {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return nil, handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// contextServerStream is a server stream with a replaced context.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
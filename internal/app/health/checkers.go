@@ -0,0 +1,17 @@
+package health
+
+import (
+	"context"
+	"os"
+)
+
+// FileWritable checks that the file at path can be opened for appending, creating it if needed.
+func FileWritable(path string) Checker {
+	return CheckFunc(func(context.Context) error {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	})
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"go.uber.org/zap"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// WatchGRPC publishes readiness through a grpc.health.v1 server: the overall status ("") and
+// the given services are SERVING while every readiness check passes and NOT_SERVING otherwise.
+// The checks run every interval until ctx is done.
+func (h *Health) WatchGRPC(ctx context.Context, server *grpchealth.Server, interval time.Duration, services ...string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		report := h.Ready(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		status := healthpb.HealthCheckResponse_SERVING
+		if !report.OK() {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if status != last {
+			logger.Default().Info("readiness changed",
+				zap.String("status", status.String()), zap.Strings("failed", report.Failed()))
+			last = status
+		}
+
+		server.SetServingStatus("", status)
+		for _, service := range services {
+			server.SetServingStatus(service, status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package health reports whether the URL shortener service is alive and ready to serve.
+//
+// Checks are pluggable: anything implementing Checker, or a plain function wrapped in
+// CheckFunc, is registered under a name either as a liveness check, whose failure means the
+// process should be restarted, or as a readiness check, whose failure means the process should
+// not receive traffic for now. LivenessHandler and ReadinessHandler serve the results as JSON,
+// typically on /healthz and /readyz, and WatchGRPC publishes readiness through the standard
+// grpc.health.v1 service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout limits the duration of a single check.
+const DefaultTimeout = 2 * time.Second
+
+// Check statuses.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker checks one dependency or component of the service.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckFunc adapts a function to Checker.
+type CheckFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status   string `json:"status"`          // StatusOK or StatusFail
+	Error    string `json:"error,omitempty"` // Reason of a failure
+	Duration string `json:"duration"`        // Time the check took, e.g. "1.2ms"
+}
+
+// Report is the outcome of all liveness or readiness checks.
+type Report struct {
+	Status string            `json:"status"` // StatusOK when every check passed, StatusFail otherwise
+	Checks map[string]Result `json:"checks"` // Results by check name
+}
+
+// OK reports whether every check passed.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Failed returns the names of the failed checks of a report in alphabetical order.
+func (r Report) Failed() []string {
+	var names []string
+	for name, result := range r.Checks {
+		if result.Status != StatusOK {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Health holds the registered liveness and readiness checks.
+type Health struct {
+	// Timeout limits the duration of a single check; DefaultTimeout when zero.
+	Timeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  map[string]Checker
+	readiness map[string]Checker
+}
+
+// New returns a Health without checks, which reports the service as alive and ready.
+func New() *Health {
+	return &Health{
+		liveness:  make(map[string]Checker),
+		readiness: make(map[string]Checker),
+	}
+}
+
+// AddLivenessCheck registers a check whose failure means the process is broken beyond repair
+// and should be restarted. A check registered under the same name before is replaced.
+func (h *Health) AddLivenessCheck(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness[name] = checker
+}
+
+// AddReadinessCheck registers a check whose failure means the process cannot serve requests
+// for now. A check registered under the same name before is replaced.
+func (h *Health) AddReadinessCheck(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness[name] = checker
+}
+
+// Live runs the liveness checks.
+func (h *Health) Live(ctx context.Context) Report {
+	return h.run(ctx, h.checks(h.liveness))
+}
+
+// Ready runs the liveness and readiness checks, since a process that is not alive is not
+// ready either.
+func (h *Health) Ready(ctx context.Context) Report {
+	checks := h.checks(h.liveness)
+	for name, checker := range h.checks(h.readiness) {
+		checks[name] = checker
+	}
+	return h.run(ctx, checks)
+}
+
+// checks returns a copy of the given checks taken under the lock.
+func (h *Health) checks(registered map[string]Checker) map[string]Checker {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	checks := make(map[string]Checker, len(registered))
+	for name, checker := range registered {
+		checks[name] = checker
+	}
+	return checks
+}
+
+// run runs the checks concurrently, each limited by the timeout.
+func (h *Health) run(ctx context.Context, checks map[string]Checker) Report {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checks {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+
+			result := check(ctx, checker, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// check runs a single check. A check that does not return in time fails even if it ignores
+// the cancellation of its context.
+func check(ctx context.Context, checker Checker, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.New("check timed out")
+	}
+
+	result := Result{Status: StatusOK, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler serves the liveness report as JSON, with status 200 when every check passed
+// and 503 otherwise.
+func (h *Health) LivenessHandler() http.Handler {
+	return reportHandler(h.Live)
+}
+
+// ReadinessHandler serves the readiness report as JSON, with status 200 when every check passed
+// and 503 otherwise.
+func (h *Health) ReadinessHandler() http.Handler {
+	return reportHandler(h.Ready)
+}
+
+// reportHandler serves the report of a set of checks.
+func reportHandler(run func(context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+
+		status := http.StatusOK
+		if !report.OK() {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,157 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func passing(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("storage is unreachable") }
+
+func TestHealth_Live(t *testing.T) {
+	checks := health.New()
+	checks.AddLivenessCheck("worker", health.CheckFunc(passing))
+	checks.AddReadinessCheck("storage", health.CheckFunc(failing))
+
+	// Readiness checks do not affect liveness
+	report := checks.Live(context.Background())
+	assert.True(t, report.OK())
+	assert.Len(t, report.Checks, 1)
+	assert.Equal(t, health.StatusOK, report.Checks["worker"].Status)
+}
+
+func TestHealth_Ready(t *testing.T) {
+	checks := health.New()
+	checks.AddLivenessCheck("worker", health.CheckFunc(passing))
+	checks.AddReadinessCheck("storage", health.CheckFunc(failing))
+	checks.AddReadinessCheck("cache", health.CheckFunc(passing))
+
+	report := checks.Ready(context.Background())
+	assert.False(t, report.OK())
+	assert.Equal(t, []string{"storage"}, report.Failed())
+	assert.Len(t, report.Checks, 3)
+	assert.Equal(t, "storage is unreachable", report.Checks["storage"].Error)
+	assert.NotEmpty(t, report.Checks["cache"].Duration)
+
+	// Replacing the failing check makes the service ready
+	checks.AddReadinessCheck("storage", health.CheckFunc(passing))
+	assert.True(t, checks.Ready(context.Background()).OK())
+}
+
+func TestHealth_Timeout(t *testing.T) {
+	checks := health.New()
+	checks.Timeout = 20 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+	checks.AddReadinessCheck("stuck", health.CheckFunc(func(context.Context) error {
+		<-block // Ignores the cancellation of its context
+		return nil
+	}))
+
+	start := time.Now()
+	report := checks.Ready(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, "check timed out", report.Checks["stuck"].Error)
+}
+
+func TestHealth_Handlers(t *testing.T) {
+	checks := health.New()
+	checks.AddLivenessCheck("worker", health.CheckFunc(passing))
+	checks.AddReadinessCheck("storage", health.CheckFunc(failing))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		status  int
+		report  string
+	}{
+		{"liveness", checks.LivenessHandler(), http.StatusOK, health.StatusOK},
+		{"readiness", checks.ReadinessHandler(), http.StatusServiceUnavailable, health.StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+			var report health.Report
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Equal(t, tt.report, report.Status)
+			assert.Equal(t, health.StatusOK, report.Checks["worker"].Status)
+		})
+	}
+}
+
+func TestFileWritable(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, health.FileWritable(filepath.Join(dir, "links.json")).Check(context.Background()))
+	assert.FileExists(t, filepath.Join(dir, "links.json"))
+	assert.Error(t, health.FileWritable(filepath.Join(dir, "missing", "links.json")).Check(context.Background()))
+}
+
+func TestHealth_WatchGRPC(t *testing.T) {
+	var ready error = errors.New("not ready yet")
+	readiness := make(chan error, 1)
+	readiness <- ready
+
+	checks := health.New()
+	checks.AddReadinessCheck("storage", health.CheckFunc(func(context.Context) error {
+		select {
+		case ready = <-readiness:
+		default:
+		}
+		return ready
+	}))
+
+	server := grpchealth.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		checks.WatchGRPC(ctx, server, 10*time.Millisecond, "shortener.Shortener")
+		close(done)
+	}()
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.Status
+	}
+
+	require.Eventually(t, func() bool {
+		return status("shortener.Shortener") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	readiness <- nil
+	require.Eventually(t, func() bool {
+		return status("") == healthpb.HealthCheckResponse_SERVING &&
+			status("shortener.Shortener") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not stop")
+	}
+}
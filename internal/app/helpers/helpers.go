@@ -83,11 +83,9 @@ func GetUserIDByToken(tokenString string) string {
 	}
 
 	if !token.Valid {
-		log.Println("Token is not valid")
 		return ""
 	}
 
-	log.Println("Token is valid")
 	return claims.UserID
 }
 
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// ContentTypeEventStream defines the Content-Type of server-sent event streams.
+const ContentTypeEventStream = "text/event-stream"
+
+// watchHeartbeat is the interval of the comments keeping idle event streams open through proxies.
+const watchHeartbeat = 15 * time.Second
+
+// APIWatchLinksHandler returns an HTTP handler streaming the events of the user's links as
+// server-sent events.
+//
+// This handler processes a GET request to `/api/user/events` and keeps the response open,
+// writing an event named after its type (`created`, `updated`, `deleted` or `clicked`) whose
+// data is the JSON event with the full short URL, and whose ID is the sequence number of the
+// event. A comment is written every 15 seconds while nothing happens.
+//
+// A client reading too slowly to keep up, and every client on shutdown, receives a final `error`
+// event with the reason; it may reconnect and reload its links to catch up.
+//
+// Parameters:
+//   - svc: The service whose link events are watched.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the watch request.
+func APIWatchLinksHandler(svc service.Service) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		sub, err := svc.WatchLinks(r.Context())
+		if err != nil {
+			problem.Write(w, r, codes.Unauthenticated, err.Error())
+			return
+		}
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", ContentTypeEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		controller := http.NewResponseController(w)
+		_ = controller.Flush()
+
+		heartbeat := time.NewTicker(watchHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(w, ": ping\n\n")
+			case event, ok := <-sub.Events():
+				if !ok {
+					if sub.Err() != nil {
+						_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", sub.Err())
+						_ = controller.Flush()
+					}
+					return
+				}
+				err = writeLinkEvent(w, event)
+			}
+			if err != nil {
+				logger.FromContext(r.Context()).Debug("event stream closed", zap.Error(err))
+				return
+			}
+			_ = controller.Flush()
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// writeLinkEvent writes the event in the server-sent event format.
+func writeLinkEvent(w http.ResponseWriter, event broker.Event) error {
+	event.ShortURL = config.Options.FlagBaseURL + "/" + event.ShortURL
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
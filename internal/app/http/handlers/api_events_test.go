@@ -0,0 +1,111 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/http/handlers"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is an event read from a server-sent event stream.
+type sseEvent struct {
+	ID   string
+	Name string
+	Data string
+}
+
+// readSSEEvent reads the next event of the stream, skipping comments.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && event.Name != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestAPIWatchLinksHandler(t *testing.T) {
+	events := broker.New(broker.DefaultBuffer)
+	svc := service.NewURLService(storage.NewMemoryStore()).WithBroker(events)
+	watch := handlers.APIWatchLinksHandler(svc)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		watch(w, r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, r.URL.Query().Get("user"))))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/user/events?user=user1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, handlers.ContentTypeEventStream, resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	reader := bufio.NewReader(resp.Body)
+
+	// The stream is open once the subscription exists.
+	require.Eventually(t, func() bool { return events.Watched("user1") }, time.Second, 10*time.Millisecond)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user2")
+	_, err = svc.ShortenURL(ctx, "https://other.example.com")
+	require.NoError(t, err)
+
+	ctx = context.WithValue(context.Background(), middleware.UserIDKey, "user1")
+	url, err := svc.ShortenURL(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	t.Run("Events of the user", func(t *testing.T) {
+		event := readSSEEvent(t, reader)
+		assert.Equal(t, broker.EventCreated, event.Name)
+		assert.NotEmpty(t, event.ID)
+
+		var data map[string]any
+		require.NoError(t, json.Unmarshal([]byte(event.Data), &data))
+		assert.Equal(t, "created", data["type"])
+		assert.True(t, strings.HasSuffix(data["short_url"].(string), "/"+url.ShortURL))
+		assert.Equal(t, "https://example.com", data["original_url"])
+		assert.NotContains(t, data, "user_id")
+	})
+
+	t.Run("Error on shutdown", func(t *testing.T) {
+		events.Close()
+
+		event := readSSEEvent(t, reader)
+		assert.Equal(t, "error", event.Name)
+		assert.Equal(t, broker.ErrClosed.Error(), event.Data)
+	})
+}
+
+func TestAPIWatchLinksHandler_WithoutUser(t *testing.T) {
+	svc := service.NewURLService(storage.NewMemoryStore())
+	rec := httptest.NewRecorder()
+
+	handlers.APIWatchLinksHandler(svc)(rec, httptest.NewRequest(http.MethodGet, "/api/user/events", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
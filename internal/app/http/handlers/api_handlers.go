@@ -3,12 +3,19 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"mime"
 	"net/http"
+	"strings"
 
+	"github.com/go-chi/chi"
 	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
 	"github.com/golangTroshin/shorturl/internal/app/service"
 	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 // ContentTypeJSON defines the Content-Type for JSON responses.
@@ -16,7 +23,8 @@ const ContentTypeJSON = "application/json"
 
 // APIShortenURL returns an HTTP handler for creating a shortened URL.
 //
-// This handler processes a POST request with a JSON payload containing the original URL.
+// This handler processes a POST request with a JSON payload containing the original URL
+// and optional per-link settings such as `redirect_type` and `expires_at`.
 // It generates a shortened URL and returns it in the response using the provided service.
 //
 // Parameters:
@@ -29,18 +37,26 @@ func APIShortenURL(svc service.Service) http.HandlerFunc {
 		var url storage.RequestURL
 
 		if err := json.NewDecoder(r.Body).Decode(&url); err != nil {
-			http.Error(w, "Wrong request body", http.StatusBadRequest)
+			problem.Write(w, r, codes.InvalidArgument, "Wrong request body")
 			return
 		}
 
 		status := http.StatusCreated
 
-		urlObj, err := svc.ShortenURL(r.Context(), url.URL)
+		urlObj, err := svc.ShortenURLWithOptions(r.Context(), url)
 		if err != nil {
 			var target *storage.InsertConflictError
 
-			if errors.As(err, &target) {
+			switch {
+			case errors.As(err, &target):
 				status = http.StatusConflict
+			case errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrInvalidOptions):
+				problem.Write(w, r, codes.InvalidArgument, err.Error())
+				return
+			default:
+				logger.FromContext(r.Context()).Error("unable to shorten url", zap.Error(err))
+				problem.Write(w, r, codes.Internal, "Failed to shorten URL")
+				return
 			}
 		}
 
@@ -51,9 +67,7 @@ func APIShortenURL(svc service.Service) http.HandlerFunc {
 		result.ShortURL = config.Options.FlagBaseURL + "/" + urlObj.ShortURL
 
 		if err := json.NewEncoder(w).Encode(&result); err != nil {
-			log.Printf("Unable to write reponse: %v", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
 		}
 	}
 
@@ -65,6 +79,16 @@ func APIShortenURL(svc service.Service) http.HandlerFunc {
 // This handler processes a POST request with a JSON array payload containing multiple original URLs.
 // It generates shortened URLs for each input and returns them in the response using the provided service.
 //
+// Every item gets an entry in the response, in request order. URLs that were already shortened
+// return their existing short URL marked with `"existing": true`, and invalid URLs an `error`
+// instead of a short URL; the remaining items are still stored. The status reflects the outcome:
+// 201 Created when every item was created, 409 Conflict when every item already existed,
+// 400 Bad Request when every item was invalid and 207 Multi-Status for mixed outcomes.
+//
+// Requests with the `application/x-ndjson` content type are streamed instead: the body holds one
+// item object per line and the response one result line per item with its status (`created`,
+// `existing` or `invalid`), so batches of any size are handled with bounded memory.
+//
 // Parameters:
 //   - svc: The URL service for handling business logic.
 //
@@ -72,51 +96,78 @@ func APIShortenURL(svc service.Service) http.HandlerFunc {
 //   - An `http.HandlerFunc` that handles the batch URL shortening request.
 func APIPostBatchHandler(svc service.Service) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == ContentTypeNDJSON {
+			streamBatch(svc, w, r)
+			return
+		}
+
 		var requestBodies []storage.RequestBodyBanch
 		err := json.NewDecoder(r.Body).Decode(&requestBodies)
 		if err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			problem.Write(w, r, codes.InvalidArgument, "Invalid request body")
 			return
 		}
 
-		urlObjs, err := svc.BatchShortenURLs(r.Context(), requestBodies)
-		log.Printf("urlObjs %v", urlObjs)
+		results, err := svc.BatchShortenURLs(r.Context(), requestBodies)
 		if err != nil {
-			log.Println(err)
+			logger.FromContext(r.Context()).Error("batch shortening failed", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Failed to shorten URLs")
+			return
 		}
 
-		w.Header().Set("Content-Type", ContentTypeJSON)
-		w.WriteHeader(http.StatusCreated)
-
 		type responseBodyBatch struct {
 			CorrelationID string `json:"correlation_id"`
-			ShortURL      string `json:"short_url"`
+			ShortURL      string `json:"short_url,omitempty"`
+			Existing      bool   `json:"existing,omitempty"`
+			Error         string `json:"error,omitempty"`
 		}
-		var responseBodies []responseBodyBatch
-		for _, url := range urlObjs {
+		responseBodies := make([]responseBodyBatch, 0, len(results))
+		counts := make(map[service.BatchStatus]int)
+		for _, result := range results {
+			counts[result.Status]++
+
 			responseBody := responseBodyBatch{
-				CorrelationID: url.UUID,
-				ShortURL:      config.Options.FlagBaseURL + "/" + url.ShortURL,
+				CorrelationID: result.CorrelationID,
+				Existing:      result.Status == service.BatchExisting,
+			}
+			if result.Err != nil {
+				responseBody.Error = result.Err.Error()
+			} else {
+				responseBody.ShortURL = config.Options.FlagBaseURL + "/" + result.URL.ShortURL
 			}
 			responseBodies = append(responseBodies, responseBody)
 		}
 
-		log.Printf("responseBodies %v", responseBodies)
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		w.WriteHeader(batchStatus(counts, len(results)))
 
 		if err := json.NewEncoder(w).Encode(&responseBodies); err != nil {
-			log.Printf("Unable to write reponse: %v", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
 		}
 	}
 
 	return http.HandlerFunc(fn)
 }
 
+// batchStatus returns the HTTP status of a batch from the number of items per outcome.
+func batchStatus(counts map[service.BatchStatus]int, total int) int {
+	switch {
+	case counts[service.BatchCreated] == total:
+		return http.StatusCreated
+	case counts[service.BatchExisting] == total:
+		return http.StatusConflict
+	case counts[service.BatchInvalid] == total:
+		return http.StatusBadRequest
+	default:
+		return http.StatusMultiStatus
+	}
+}
+
 // APIDeleteUrlsHandler returns an HTTP handler for deleting a batch of URLs for a user.
 //
 // This handler processes a DELETE request with a JSON array payload containing URL IDs to delete.
-// The URLs are removed using the provided service.
+// The URLs are queued for deletion using the provided service; the response is 202 Accepted with
+// the deletion job as JSON and its status URL in the Location header, see APIGetDeletionHandler.
 //
 // Parameters:
 //   - svc: The URL service for handling business logic.
@@ -128,18 +179,61 @@ func APIDeleteUrlsHandler(svc service.Service) http.HandlerFunc {
 		var urlIDs []string
 		err := json.NewDecoder(r.Body).Decode(&urlIDs)
 		if err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			problem.Write(w, r, codes.InvalidArgument, "Invalid request body")
 			return
 		}
 
-		err = svc.DeleteUserURLs(r.Context(), urlIDs)
+		job, err := svc.DeleteUserURLs(r.Context(), urlIDs)
 
+		if errors.Is(err, deletes.ErrQueueFull) || errors.Is(err, deletes.ErrClosed) {
+			w.Header().Set("Retry-After", "1")
+			problem.Write(w, r, codes.Unavailable, err.Error())
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			problem.Write(w, r, codes.InvalidArgument, err.Error())
 			return
 		}
 
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		w.Header().Set("Location", "/api/user/deletions/"+job.ID)
 		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIGetDeletionHandler returns an HTTP handler reporting the state of a deletion job of the user.
+//
+// This handler processes a GET request to `/api/user/deletions/{id}` and answers with the job as
+// JSON; its status is `pending` until the URLs are deleted, `done` afterwards and `dead` when
+// the deletion failed too often. Jobs of other users are not found.
+//
+// Parameters:
+//   - svc: The URL service for handling business logic.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the deletion status request.
+func APIGetDeletionHandler(svc service.Service) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.GetDeletion(r.Context(), chi.URLParam(r, "id"))
+		if errors.Is(err, deletes.ErrJobNotFound) {
+			problem.Write(w, r, codes.NotFound, "Deletion job not found")
+			return
+		}
+		if err != nil {
+			logger.FromContext(r.Context()).Error("unable to get deletion job", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Failed to get deletion job")
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
 	}
 
 	return http.HandlerFunc(fn)
@@ -159,20 +253,169 @@ func APIInternalGetStatsHandler(svc service.Service) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		stats, err := svc.GetStats(r.Context())
 		if err != nil {
-			w.WriteHeader(http.StatusNoContent)
+			logger.FromContext(r.Context()).Error("unable to fetch stats", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Unable to fetch statistics")
 			return
 		}
 
 		w.Header().Set("Content-Type", ContentTypeJSON)
 
-		log.Printf("responseBodies %v", stats)
-
 		if err := json.NewEncoder(w).Encode(&stats); err != nil {
-			log.Printf("Unable to write reponse: %v", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIUpdateURLHandler returns an HTTP handler for changing the settings of a user's shortened URL.
+//
+// This handler processes a PATCH request to `/api/user/urls/{id}` with a JSON payload of
+// per-link settings (`redirect_type`, `expires_at`, `rules`, `utm`, `pass_query`, `title`,
+// `description`). Fields present in the payload replace the current values, other settings
+// are kept. The updated link is returned in the response.
+//
+// Parameters:
+//   - svc: The URL service for handling business logic.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the URL update request.
+func APIUpdateURLHandler(svc service.Service) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		url, err := svc.GetUserURL(r.Context(), id)
+		if err != nil {
+			writeLinkError(w, r, err)
 			return
 		}
+
+		opts := url.LinkOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			problem.Write(w, r, codes.InvalidArgument, "Invalid request body")
+			return
+		}
+
+		url, err = svc.UpdateURLOptions(r.Context(), id, opts)
+		if err != nil {
+			writeLinkError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(&url); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIGetURLRulesHandler returns an HTTP handler that lists the conditional redirect rules of a user's URL.
+//
+// This handler processes a GET request to `/api/user/urls/{id}/rules` and responds with
+// the ordered JSON array of rules.
+//
+// Parameters:
+//   - svc: The URL service for handling business logic.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the rules request.
+func APIGetURLRulesHandler(svc service.Service) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		url, err := svc.GetUserURL(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeLinkError(w, r, err)
+			return
+		}
+
+		rules := url.Rules
+		if rules == nil {
+			rules = []storage.Rule{}
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(rules); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APISetURLRulesHandler returns an HTTP handler that replaces the conditional redirect rules of a user's URL.
+//
+// This handler processes a PUT request to `/api/user/urls/{id}/rules` with an ordered JSON array
+// of rules. Rules are evaluated in order on every redirect and the first matching rule wins.
+// An empty array removes all rules.
+//
+// Parameters:
+//   - svc: The URL service for handling business logic.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the rules update request.
+func APISetURLRulesHandler(svc service.Service) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		var rules []storage.Rule
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			problem.Write(w, r, codes.InvalidArgument, "Invalid request body")
+			return
+		}
+
+		url, err := svc.SetURLRules(r.Context(), chi.URLParam(r, "id"), rules)
+		if err != nil {
+			writeLinkError(w, r, err)
+			return
+		}
+
+		rules = url.Rules
+		if rules == nil {
+			rules = []storage.Rule{}
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(rules); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
 	}
 
 	return http.HandlerFunc(fn)
 }
+
+// writeLinkError maps errors of link management operations to problem details, using the
+// codes the gRPC API returns for the same errors.
+func writeLinkError(w http.ResponseWriter, r *http.Request, err error) {
+	var deleted *storage.DeletedURLError
+
+	switch {
+	case errors.Is(err, service.ErrInvalidOptions), errors.Is(err, service.ErrInvalidQRCode):
+		problem.Write(w, r, codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrURLNotFound), errors.As(err, &deleted), errors.Is(err, service.ErrURLExpired):
+		problem.Write(w, r, codes.NotFound, "URL not found")
+	default:
+		logger.FromContext(r.Context()).Error("link operation failed", zap.Error(err))
+		problem.Write(w, r, codes.Internal, "Internal server error")
+	}
+}
+
+// NotFound answers requests to unknown routes, with a problem for /api/* paths and a plain text
+// 404 page otherwise.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	problem.Write(w, r, codes.NotFound, "Unknown endpoint")
+}
+
+// MethodNotAllowed answers requests with a method a route does not support, with a problem for
+// /api/* paths and an empty 405 response otherwise.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	problem.WriteStatus(w, r, http.StatusMethodNotAllowed, codes.Unimplemented, "Method "+r.Method+" is not allowed")
+}
@@ -8,11 +8,18 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
+	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
 	"github.com/golangTroshin/shorturl/internal/app/http/handlers"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
+	"github.com/golangTroshin/shorturl/internal/app/service"
 	"github.com/golangTroshin/shorturl/internal/app/storage"
 	"github.com/golangTroshin/shorturl/internal/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAPIShortenURL(t *testing.T) {
@@ -23,7 +30,7 @@ func TestAPIShortenURL(t *testing.T) {
 	handler := handlers.APIShortenURL(mockService)
 
 	t.Run("Successful URL shortening", func(t *testing.T) {
-		mockService.EXPECT().ShortenURL(gomock.Any(), "http://example.com").Return(
+		mockService.EXPECT().ShortenURLWithOptions(gomock.Any(), storage.RequestURL{URL: "http://example.com"}).Return(
 			storage.URL{ShortURL: "short123"}, nil,
 		)
 
@@ -48,6 +55,153 @@ func TestAPIShortenURL(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
+
+	t.Run("Problem details", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader([]byte("invalid body")))
+		req.Header.Set(requestid.Header, "req-1")
+		rec := httptest.NewRecorder()
+
+		requestid.Middleware(handler).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+		var details problem.Details
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&details))
+		assert.Equal(t, problem.Details{
+			Type:      "about:blank",
+			Title:     "Bad Request",
+			Status:    http.StatusBadRequest,
+			Detail:    "Wrong request body",
+			Instance:  "/api/shorten",
+			Code:      "INVALID_ARGUMENT",
+			RequestID: "req-1",
+		}, details)
+	})
+
+	t.Run("Storage failure", func(t *testing.T) {
+		mockService.EXPECT().ShortenURLWithOptions(gomock.Any(), gomock.Any()).Return(
+			storage.URL{}, errors.New("storage is down"),
+		)
+
+		body := `{"url": "http://example.com"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader([]byte(body)))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("Invalid link options", func(t *testing.T) {
+		mockService.EXPECT().ShortenURLWithOptions(gomock.Any(), gomock.Any()).Return(
+			storage.URL{}, service.ErrInvalidOptions,
+		)
+
+		body := `{"url": "http://example.com", "redirect_type": 200}`
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader([]byte(body)))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestAPIUpdateURLHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	router := chi.NewRouter()
+	router.Patch("/api/user/urls/{id}", handlers.APIUpdateURLHandler(mockService))
+
+	t.Run("Successful update", func(t *testing.T) {
+		rules := []storage.Rule{{Device: "mobile", Destination: "http://m.example.com"}}
+		opts := storage.LinkOptions{RedirectType: http.StatusMovedPermanently, Rules: rules}
+		mockService.EXPECT().GetUserURL(gomock.Any(), "short1").Return(
+			storage.URL{ShortURL: "short1", LinkOptions: storage.LinkOptions{Rules: rules}}, nil,
+		)
+		mockService.EXPECT().UpdateURLOptions(gomock.Any(), "short1", opts).Return(
+			storage.URL{ShortURL: "short1", OriginalURL: "http://example.com", LinkOptions: opts}, nil,
+		)
+
+		body := `{"redirect_type": 301}`
+		req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/short1", bytes.NewReader([]byte(body)))
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response storage.URL
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		assert.Equal(t, http.StatusMovedPermanently, response.RedirectType)
+	})
+
+	t.Run("Unknown URL", func(t *testing.T) {
+		mockService.EXPECT().GetUserURL(gomock.Any(), "missing").Return(storage.URL{}, storage.ErrURLNotFound)
+
+		req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/missing", bytes.NewReader([]byte(`{}`)))
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestAPIURLRulesHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	router := chi.NewRouter()
+	router.Get("/api/user/urls/{id}/rules", handlers.APIGetURLRulesHandler(mockService))
+	router.Put("/api/user/urls/{id}/rules", handlers.APISetURLRulesHandler(mockService))
+
+	rules := []storage.Rule{{Language: "de", Destination: "http://example.de"}}
+
+	t.Run("Set rules", func(t *testing.T) {
+		mockService.EXPECT().SetURLRules(gomock.Any(), "short1", rules).Return(
+			storage.URL{ShortURL: "short1", LinkOptions: storage.LinkOptions{Rules: rules}}, nil,
+		)
+
+		body := `[{"language": "de", "destination": "http://example.de"}]`
+		req := httptest.NewRequest(http.MethodPut, "/api/user/urls/short1/rules", bytes.NewReader([]byte(body)))
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response []storage.Rule
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		assert.Equal(t, rules, response)
+	})
+
+	t.Run("Invalid rules", func(t *testing.T) {
+		mockService.EXPECT().SetURLRules(gomock.Any(), "short1", gomock.Any()).Return(storage.URL{}, service.ErrInvalidOptions)
+
+		body := `[{"language": "de"}]`
+		req := httptest.NewRequest(http.MethodPut, "/api/user/urls/short1/rules", bytes.NewReader([]byte(body)))
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Get rules of a link without rules", func(t *testing.T) {
+		mockService.EXPECT().GetUserURL(gomock.Any(), "short1").Return(storage.URL{ShortURL: "short1"}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/short1/rules", nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
 }
 
 func TestAPIPostBatchHandler(t *testing.T) {
@@ -59,9 +213,9 @@ func TestAPIPostBatchHandler(t *testing.T) {
 
 	t.Run("Successful batch shortening", func(t *testing.T) {
 		mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Any()).Return(
-			[]storage.URL{
-				{UUID: "id1", ShortURL: "short1", OriginalURL: "http://example.com/1"},
-				{UUID: "id2", ShortURL: "short2", OriginalURL: "http://example.com/2"},
+			[]service.BatchResult{
+				{CorrelationID: "id1", URL: storage.URL{ShortURL: "short1", OriginalURL: "http://example.com/1"}, Status: service.BatchCreated},
+				{CorrelationID: "id2", URL: storage.URL{ShortURL: "short2", OriginalURL: "http://example.com/2"}, Status: service.BatchCreated},
 			}, nil,
 		)
 
@@ -82,6 +236,58 @@ func TestAPIPostBatchHandler(t *testing.T) {
 		assert.Equal(t, "id2", response[1]["correlation_id"])
 	})
 
+	t.Run("Mixed outcomes", func(t *testing.T) {
+		mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Any()).Return(
+			[]service.BatchResult{
+				{CorrelationID: "id1", URL: storage.URL{ShortURL: "short1"}, Status: service.BatchCreated},
+				{CorrelationID: "id2", URL: storage.URL{ShortURL: "short2"}, Status: service.BatchExisting},
+				{CorrelationID: "id3", URL: storage.URL{OriginalURL: "bad"}, Status: service.BatchInvalid, Err: service.ErrInvalidURL},
+			}, nil,
+		)
+
+		body := `[{"correlation_id": "id1", "original_url": "http://example.com/1"}]`
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader([]byte(body)))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.JSONEq(t, `[
+			{"correlation_id": "id1", "short_url": "`+config.Options.FlagBaseURL+`/short1"},
+			{"correlation_id": "id2", "short_url": "`+config.Options.FlagBaseURL+`/short2", "existing": true},
+			{"correlation_id": "id3", "error": "invalid URL"}
+		]`, rec.Body.String())
+	})
+
+	t.Run("Status of uniform outcomes", func(t *testing.T) {
+		for status, code := range map[service.BatchStatus]int{
+			service.BatchExisting: http.StatusConflict,
+			service.BatchInvalid:  http.StatusBadRequest,
+		} {
+			mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Any()).Return(
+				[]service.BatchResult{{CorrelationID: "id1", Status: status}}, nil,
+			)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader([]byte(`[]`)))
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, code, rec.Code, status)
+		}
+	})
+
+	t.Run("Storage failure", func(t *testing.T) {
+		mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down"))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader([]byte(`[]`)))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
 	t.Run("Invalid request body", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader([]byte("invalid body")))
 		rec := httptest.NewRecorder()
@@ -100,7 +306,8 @@ func TestAPIDeleteUrlsHandler(t *testing.T) {
 	handler := handlers.APIDeleteUrlsHandler(mockService)
 
 	t.Run("Successful deletion", func(t *testing.T) {
-		mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1", "short2"}).Return(nil)
+		job := deletes.Job{ID: "job1", URLs: []string{"short1", "short2"}, Status: deletes.StatusPending}
+		mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1", "short2"}).Return(job, nil)
 
 		body := `["short1", "short2"]`
 		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewReader([]byte(body)))
@@ -109,6 +316,12 @@ func TestAPIDeleteUrlsHandler(t *testing.T) {
 		handler.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, "/api/user/deletions/job1", rec.Header().Get("Location"))
+
+		var response deletes.Job
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		assert.Equal(t, "job1", response.ID)
+		assert.Equal(t, deletes.StatusPending, response.Status)
 	})
 
 	t.Run("Invalid request body", func(t *testing.T) {
@@ -119,6 +332,53 @@ func TestAPIDeleteUrlsHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
+
+	for _, err := range []error{deletes.ErrClosed, deletes.ErrQueueFull} {
+		t.Run(err.Error(), func(t *testing.T) {
+			mockService.EXPECT().DeleteUserURLs(gomock.Any(), []string{"short1"}).Return(deletes.Job{}, err)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewReader([]byte(`["short1"]`)))
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestAPIGetDeletionHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	r := chi.NewRouter()
+	r.Get("/api/user/deletions/{id}", handlers.APIGetDeletionHandler(mockService))
+
+	t.Run("Done job", func(t *testing.T) {
+		job := deletes.Job{ID: "job1", UserID: "user1", URLs: []string{"short1"}, Status: deletes.StatusDone, Attempts: 1}
+		mockService.EXPECT().GetDeletion(gomock.Any(), "job1").Return(job, nil)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/deletions/job1", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response map[string]any
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		assert.Equal(t, "done", response["status"])
+		assert.NotContains(t, response, "UserID")
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		mockService.EXPECT().GetDeletion(gomock.Any(), "other").Return(deletes.Job{}, deletes.ErrJobNotFound)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/deletions/other", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+	})
 }
 
 func TestAPIInternalGetStatsHandler(t *testing.T) {
@@ -155,6 +415,7 @@ func TestAPIInternalGetStatsHandler(t *testing.T) {
 
 		handler.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
 	})
 }
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// maxStreamLineLength is the longest accepted line of a streamed batch. Longer lines are
+// skipped and reported as invalid.
+const maxStreamLineLength = 64 << 10
+
+// errLineTooLong is reported for streamed batch lines longer than maxStreamLineLength.
+var errLineTooLong = errors.New("line is too long")
+
+// streamResult is a line of the streamed batch response.
+type streamResult struct {
+	Line          int    `json:"line"`                     // Line of the item in the request body
+	CorrelationID string `json:"correlation_id,omitempty"` // Correlation ID of the item
+	ShortURL      string `json:"short_url,omitempty"`      // Created or existing short link
+	Existing      bool   `json:"existing,omitempty"`       // The URL was already shortened
+	OriginalURL   string `json:"original_url,omitempty"`   // URL of the item
+	Status        string `json:"status"`                   // created, existing, invalid or error
+	Error         string `json:"error,omitempty"`          // Reason the item was rejected
+	RequestID     string `json:"request_id,omitempty"`     // ID of the request, set on lines of status error
+}
+
+// streamBatch shortens a batch sent as NDJSON, one `{"correlation_id": ..., "original_url": ...}`
+// object per line, and streams one result line per item back in request order.
+//
+// Items are read and stored in chunks of at most service.MaxStreamChunk, so memory use does not
+// depend on the size of the body. A chunk is also processed as soon as no more input is buffered,
+// which lets interactive clients see results while they are still sending.
+// Storage failures end the response with a line of status `error`.
+func streamBatch(svc service.Service, w http.ResponseWriter, r *http.Request) {
+	reader := bufio.NewReaderSize(r.Body, maxStreamLineLength)
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+
+	started := false
+	start := func() {
+		started = true
+		w.Header().Set("Content-Type", ContentTypeNDJSON)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	var (
+		items   []storage.RequestBodyBanch
+		lines   []int
+		invalid []streamResult
+	)
+
+	// process stores the pending chunk and writes the results of its lines in request order.
+	process := func() error {
+		if !started {
+			start()
+		}
+
+		var results []service.BatchResult
+		if len(items) > 0 {
+			var err error
+			if results, err = svc.BatchShortenURLs(r.Context(), items); err != nil {
+				return err
+			}
+		}
+
+		for i, result := range results {
+			for len(invalid) > 0 && invalid[0].Line < lines[i] {
+				if err := encoder.Encode(invalid[0]); err != nil {
+					return err
+				}
+				invalid = invalid[1:]
+			}
+
+			if err := encoder.Encode(newStreamResult(lines[i], result)); err != nil {
+				return err
+			}
+		}
+
+		for _, result := range invalid {
+			if err := encoder.Encode(result); err != nil {
+				return err
+			}
+		}
+
+		items, lines, invalid = items[:0], lines[:0], invalid[:0]
+		_ = controller.Flush()
+
+		return nil
+	}
+
+	fail := func(err error) {
+		logger.FromContext(r.Context()).Error("streamed batch failed", zap.Error(err))
+		if !started {
+			problem.Write(w, r, codes.Internal, "Failed to shorten URLs")
+			return
+		}
+		_ = encoder.Encode(streamResult{Status: "error", Error: "internal error", RequestID: requestid.FromContext(r.Context())})
+	}
+
+	for line := 1; ; line++ {
+		data, err := readStreamLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, errLineTooLong) {
+			if !started {
+				problem.Write(w, r, codes.InvalidArgument, "Unable to read request body")
+				return
+			}
+			fail(err)
+			return
+		}
+
+		if err == nil && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		var item storage.RequestBodyBanch
+		switch {
+		case err != nil:
+			invalid = append(invalid, streamResult{Line: line, Status: string(service.BatchInvalid), Error: err.Error()})
+		case json.Unmarshal(data, &item) != nil:
+			invalid = append(invalid, streamResult{Line: line, Status: string(service.BatchInvalid), Error: "invalid JSON"})
+		default:
+			items = append(items, item)
+			lines = append(lines, line)
+		}
+
+		if len(items)+len(invalid) >= service.MaxStreamChunk || reader.Buffered() == 0 {
+			if err := process(); err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+
+	if err := process(); err != nil {
+		fail(err)
+	}
+}
+
+// newStreamResult converts the outcome of a batch item to a response line.
+func newStreamResult(line int, result service.BatchResult) streamResult {
+	response := streamResult{
+		Line:          line,
+		CorrelationID: result.CorrelationID,
+		OriginalURL:   result.URL.OriginalURL,
+		Status:        string(result.Status),
+		Existing:      result.Status == service.BatchExisting,
+	}
+
+	if result.Err != nil {
+		response.Error = result.Err.Error()
+	} else {
+		response.ShortURL = config.Options.FlagBaseURL + "/" + result.URL.ShortURL
+	}
+
+	return response
+}
+
+// readStreamLine reads the next line without its line ending. Lines longer than the reader's
+// buffer are discarded and reported with errLineTooLong. A last line without a newline is
+// returned as is; io.EOF is only returned once the input is exhausted.
+func readStreamLine(reader *bufio.Reader) ([]byte, error) {
+	data, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = reader.ReadSlice('\n')
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return nil, errLineTooLong
+	}
+
+	if err == io.EOF && len(data) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(data, "\r\n"), nil
+}
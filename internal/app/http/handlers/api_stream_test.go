@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golangTroshin/shorturl/internal/app/http/handlers"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/golangTroshin/shorturl/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type streamLine struct {
+	Line          int    `json:"line"`
+	CorrelationID string `json:"correlation_id"`
+	ShortURL      string `json:"short_url"`
+	Status        string `json:"status"`
+	Error         string `json:"error"`
+	RequestID     string `json:"request_id"`
+}
+
+func TestAPIPostBatchHandler_Stream(t *testing.T) {
+	svc := service.NewURLService(storage.NewMemoryStore())
+	handler := handlers.APIPostBatchHandler(svc)
+
+	stream := func(body string) (*httptest.ResponseRecorder, []streamLine) {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", handlers.ContentTypeNDJSON+"; charset=utf-8")
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user123"))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		var lines []streamLine
+		scanner := bufio.NewScanner(rec.Body)
+		for scanner.Scan() {
+			var line streamLine
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+
+		return rec, lines
+	}
+
+	t.Run("Per-item results", func(t *testing.T) {
+		body := `{"correlation_id":"a","original_url":"https://example.com"}` + "\n" +
+			"\n" +
+			`{"correlation_id":"b","original_url":"ftp://example.com"}` + "\r\n" +
+			"{broken\n" +
+			`{"correlation_id":"c","original_url":"https://example.com"}` + "\n" +
+			`{"correlation_id":"d","original_url":"https://example.org"}`
+
+		rec, lines := stream(body)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, handlers.ContentTypeNDJSON, rec.Header().Get("Content-Type"))
+		require.Len(t, lines, 5)
+
+		assert.Equal(t, streamLine{Line: 1, CorrelationID: "a", ShortURL: lines[0].ShortURL, Status: "created"}, lines[0])
+		assert.NotEmpty(t, lines[0].ShortURL)
+		assert.Equal(t, 3, lines[1].Line)
+		assert.Equal(t, "invalid", lines[1].Status)
+		assert.Contains(t, lines[1].Error, service.ErrInvalidURL.Error())
+		assert.Equal(t, 4, lines[2].Line)
+		assert.Equal(t, "invalid", lines[2].Status)
+		assert.Equal(t, "existing", lines[3].Status)
+		assert.Equal(t, lines[0].ShortURL, lines[3].ShortURL)
+		assert.Equal(t, "d", lines[4].CorrelationID)
+		assert.Equal(t, "created", lines[4].Status)
+	})
+
+	t.Run("More items than a chunk", func(t *testing.T) {
+		var body strings.Builder
+		for i := 0; i < service.MaxStreamChunk*2+10; i++ {
+			body.WriteString(`{"correlation_id":"` + strings.Repeat("x", i%3) + `","original_url":"https://example.net/` + strings.Repeat("p", i) + `"}` + "\n")
+			if i == 150 {
+				body.WriteString(`{"original_url":"` + strings.Repeat("y", 70<<10) + `"}` + "\n")
+			}
+		}
+
+		_, lines := stream(body.String())
+
+		require.Len(t, lines, service.MaxStreamChunk*2+11)
+		for i, line := range lines {
+			assert.Equal(t, i+1, line.Line)
+		}
+		assert.Equal(t, "invalid", lines[151].Status)
+		assert.Equal(t, "line is too long", lines[151].Error)
+		assert.Equal(t, "created", lines[len(lines)-1].Status)
+	})
+}
+
+func TestAPIPostBatchHandler_StreamFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	mockService.EXPECT().BatchShortenURLs(gomock.Any(), gomock.Any()).Return(nil, errors.New("storage is down"))
+	handler := requestid.Middleware(handlers.APIPostBatchHandler(mockService))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch",
+		strings.NewReader(`{"correlation_id":"a","original_url":"https://example.com"}`))
+	req.Header.Set("Content-Type", handlers.ContentTypeNDJSON)
+	req.Header.Set(requestid.Header, "req-1")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-1", rec.Header().Get(requestid.Header))
+
+	var line streamLine
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &line))
+	assert.Equal(t, "error", line.Status)
+	assert.Equal(t, "req-1", line.RequestID)
+}
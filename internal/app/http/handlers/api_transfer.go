@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// Content types of exported link lists.
+const (
+	ContentTypeCSV    = "text/csv"
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// maxImportErrors caps the number of row errors reported by an import, keeping the
+// response bounded for large files full of bad rows.
+const maxImportErrors = 1000
+
+// exportFlushInterval is the number of exported links after which the response is flushed.
+const exportFlushInterval = 100
+
+// importColumns maps the accepted CSV header names to the import fields.
+var importColumns = map[string]string{
+	"url":          "url",
+	"original_url": "url",
+	"alias":        "alias",
+	"title":        "title",
+	"note":         "note",
+	"tags":         "tags",
+}
+
+// exportColumns is the CSV header of exported link lists. The file can be imported again.
+var exportColumns = []string{"alias", "short_url", "original_url", "title", "note", "tags", "clicks", "created_at"}
+
+// importRowError describes a CSV row that could not be imported.
+type importRowError struct {
+	Line  int    `json:"line"`            // Line of the row in the CSV file
+	URL   string `json:"url,omitempty"`   // Original URL of the row
+	Alias string `json:"alias,omitempty"` // Requested short URL of the row
+	Error string `json:"error"`           // Reason the row was rejected
+}
+
+// importResult summarizes an import.
+type importResult struct {
+	Created         int              `json:"created"`                    // Rows stored as new links
+	Existing        int              `json:"existing"`                   // Rows whose URL was already shortened
+	Failed          int              `json:"failed"`                     // Rows that were rejected
+	Errors          []importRowError `json:"errors"`                     // Rejected rows, at most maxImportErrors
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"` // More rows failed than are listed
+}
+
+// addError records a rejected row.
+func (result *importResult) addError(rowErr importRowError) {
+	result.Failed++
+	if len(result.Errors) < maxImportErrors {
+		result.Errors = append(result.Errors, rowErr)
+	} else {
+		result.ErrorsTruncated = true
+	}
+}
+
+// APIImportURLsHandler returns an HTTP handler for importing links of the user from a CSV file.
+//
+// The request body is read and imported row by row, so files of any size are handled with
+// constant memory. When the first row names a `url` (or `original_url`) column it is taken as
+// the header, and the optional `alias`, `title`, `note` and `tags` (comma-separated) columns
+// are read as well; unknown columns are ignored. Without a header, the first column holds
+// the URL and the optional second one the alias.
+//
+// Rows are validated individually; invalid rows, taken aliases and malformed CSV lines are
+// reported with their line number while the remaining rows are still imported. URLs that were
+// already shortened are counted as existing and left unchanged.
+//
+// The handler responds with a 200 OK status and a JSON summary of created, existing and failed
+// rows, or with a 400 Bad Request status when the body cannot be read at all.
+//
+// Parameters:
+//   - svc: The URL service for handling business logic.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the import request.
+func APIImportURLsHandler(svc service.Service) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		reader := csv.NewReader(r.Body)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		reader.ReuseRecord = true
+
+		result := importResult{Errors: []importRowError{}}
+		columns := map[string]int{"url": 0, "alias": 1}
+		first := true
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.addError(importRowError{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+				first = false
+				continue
+			}
+			if err != nil {
+				problem.Write(w, r, codes.InvalidArgument, "Unable to read request body")
+				return
+			}
+
+			line, _ := reader.FieldPos(0)
+
+			if first {
+				first = false
+				if header, ok := parseImportHeader(record); ok {
+					columns = header
+					continue
+				}
+			}
+
+			field := func(name string) string {
+				if i, ok := columns[name]; ok && i < len(record) {
+					return strings.TrimSpace(record[i])
+				}
+				return ""
+			}
+
+			req := storage.RequestURL{
+				URL: field("url"),
+				LinkOptions: storage.LinkOptions{
+					Title: field("title"),
+					Note:  field("note"),
+				},
+			}
+			if tags := field("tags"); tags != "" {
+				req.Tags = strings.Split(tags, ",")
+			}
+			alias := field("alias")
+
+			_, err = svc.ImportURL(r.Context(), req, alias)
+
+			var conflict *storage.InsertConflictError
+			switch {
+			case err == nil:
+				result.Created++
+			case errors.As(err, &conflict):
+				result.Existing++
+			case errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrInvalidAlias),
+				errors.Is(err, service.ErrInvalidOptions), errors.Is(err, storage.ErrAliasTaken):
+				result.addError(importRowError{Line: line, URL: req.URL, Alias: alias, Error: err.Error()})
+			default:
+				logger.FromContext(r.Context()).Error("import failed", zap.Int("line", line), zap.Error(err))
+				result.addError(importRowError{Line: line, URL: req.URL, Alias: alias, Error: "internal error"})
+			}
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(&result); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// parseImportHeader returns the column positions named by a CSV header row.
+// The row is a header only if it names the URL column.
+func parseImportHeader(record []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, name := range record {
+		if field, ok := importColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+
+	_, ok := columns["url"]
+
+	return columns, ok
+}
+
+// APIExportURLsHandler returns an HTTP handler for exporting all active links of the user.
+//
+// The `format` query parameter selects `csv` (default), `json` (an array of link objects as
+// returned by `GET /api/user/urls`) or `ndjson` (one link object per line). The CSV columns are
+// alias, short_url, original_url, title, note, tags, clicks and created_at, and the file can be
+// imported again. Links are streamed oldest first while they are read from the storage, so the
+// export never holds the whole list in memory.
+//
+// The handler responds with a 400 Bad Request status for an unknown format and with a
+// 500 Internal Server Error status when reading the links fails before anything was sent;
+// later failures truncate the response.
+//
+// Parameters:
+//   - svc: The URL service for handling business logic.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the export request.
+func APIExportURLsHandler(svc service.Service) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "csv"
+		}
+
+		var encoder linkEncoder
+		switch format {
+		case "csv":
+			encoder = &csvLinkEncoder{w: csv.NewWriter(w)}
+		case "json":
+			encoder = &jsonLinkEncoder{w: w}
+		case "ndjson":
+			encoder = &ndjsonLinkEncoder{enc: json.NewEncoder(w)}
+		default:
+			problem.Write(w, r, codes.InvalidArgument, "format must be csv, json or ndjson")
+			return
+		}
+
+		controller := http.NewResponseController(w)
+		started := false
+		count := 0
+
+		start := func() error {
+			started = true
+			w.Header().Set("Content-Type", encoder.contentType())
+			w.Header().Set("Content-Disposition", `attachment; filename="links.`+format+`"`)
+			return encoder.begin()
+		}
+
+		err := svc.ExportUserURLs(r.Context(), func(link storage.URL) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+
+			if err := encoder.encode(link); err != nil {
+				return err
+			}
+
+			if count++; count%exportFlushInterval == 0 {
+				if err := encoder.flush(); err != nil {
+					return err
+				}
+				_ = controller.Flush()
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			logger.FromContext(r.Context()).Error("export failed", zap.Int("exported", count), zap.Error(err))
+			if !started {
+				problem.Write(w, r, codes.Internal, "Failed to export URLs")
+			}
+			return
+		}
+
+		if !started {
+			if err := start(); err != nil {
+				logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+				return
+			}
+		}
+
+		if err := encoder.end(); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// linkEncoder writes a stream of links in one export format.
+type linkEncoder interface {
+	contentType() string
+	begin() error
+	encode(link storage.URL) error
+	flush() error
+	end() error
+}
+
+// csvLinkEncoder writes links as CSV rows with a header.
+type csvLinkEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvLinkEncoder) contentType() string {
+	return ContentTypeCSV
+}
+
+func (e *csvLinkEncoder) begin() error {
+	return e.w.Write(exportColumns)
+}
+
+func (e *csvLinkEncoder) encode(link storage.URL) error {
+	return e.w.Write([]string{
+		link.ShortURL,
+		config.Options.FlagBaseURL + "/" + link.ShortURL,
+		link.OriginalURL,
+		link.Title,
+		link.Note,
+		strings.Join(link.Tags, ","),
+		strconv.FormatInt(link.Clicks, 10),
+		link.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (e *csvLinkEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvLinkEncoder) end() error {
+	return e.flush()
+}
+
+// jsonLinkEncoder writes links as a single JSON array.
+type jsonLinkEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonLinkEncoder) contentType() string {
+	return ContentTypeJSON
+}
+
+func (e *jsonLinkEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonLinkEncoder) encode(link storage.URL) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	if e.count > 0 {
+		data = append([]byte(",\n"), data...)
+	}
+	e.count++
+
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonLinkEncoder) flush() error {
+	return nil
+}
+
+func (e *jsonLinkEncoder) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// ndjsonLinkEncoder writes one JSON link object per line.
+type ndjsonLinkEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonLinkEncoder) contentType() string {
+	return ContentTypeNDJSON
+}
+
+func (e *ndjsonLinkEncoder) begin() error {
+	return nil
+}
+
+func (e *ndjsonLinkEncoder) encode(link storage.URL) error {
+	return e.enc.Encode(link)
+}
+
+func (e *ndjsonLinkEncoder) flush() error {
+	return nil
+}
+
+func (e *ndjsonLinkEncoder) end() error {
+	return nil
+}
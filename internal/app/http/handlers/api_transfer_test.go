@@ -0,0 +1,181 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/handlers"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type importResponse struct {
+	Created  int `json:"created"`
+	Existing int `json:"existing"`
+	Failed   int `json:"failed"`
+	Errors   []struct {
+		Line  int    `json:"line"`
+		URL   string `json:"url"`
+		Alias string `json:"alias"`
+		Error string `json:"error"`
+	} `json:"errors"`
+}
+
+func importCSV(t *testing.T, svc service.Service, body string) importResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/urls/import", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user123"))
+	rec := httptest.NewRecorder()
+
+	handlers.APIImportURLsHandler(svc).ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response importResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+
+	return response
+}
+
+func TestAPIImportURLsHandler(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+
+	t.Run("With header", func(t *testing.T) {
+		body := "Title,URL,Alias,Tags,Clicks\n" +
+			"Docs,https://example.com/docs,docs,\"work,docs\",12\n" +
+			"Home,https://example.com,,,3\n" +
+			"Bad,not a url,,,0\n" +
+			"Taken,https://example.org,docs,,0\n" +
+			"Again,https://example.com/docs,,,0\n"
+
+		response := importCSV(t, svc, body)
+
+		assert.Equal(t, 2, response.Created)
+		assert.Equal(t, 1, response.Existing)
+		assert.Equal(t, 2, response.Failed)
+		require.Len(t, response.Errors, 2)
+		assert.Equal(t, 4, response.Errors[0].Line)
+		assert.Equal(t, "not a url", response.Errors[0].URL)
+		assert.Equal(t, 5, response.Errors[1].Line)
+		assert.Contains(t, response.Errors[1].Error, storage.ErrAliasTaken.Error())
+
+		link, err := store.GetURL(context.Background(), "docs")
+		assert.NoError(t, err)
+		assert.Equal(t, "Docs", link.Title)
+		assert.Equal(t, []string{"docs", "work"}, link.Tags)
+	})
+
+	t.Run("Without header", func(t *testing.T) {
+		body := "https://example.net,net\nhttps://example.net/a\n\"broken\n"
+
+		response := importCSV(t, svc, body)
+
+		assert.Equal(t, 2, response.Created)
+		assert.Equal(t, 1, response.Failed)
+		assert.Equal(t, 3, response.Errors[0].Line)
+
+		original, err := store.Get(context.Background(), "net")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.net", original)
+	})
+}
+
+func TestAPIExportURLsHandler(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	_, err := svc.ImportURL(ctx, storage.RequestURL{
+		URL:         "https://example.com/docs",
+		LinkOptions: storage.LinkOptions{Title: "Docs", Tags: []string{"work", "docs"}},
+	}, "docs")
+	require.NoError(t, err)
+	_, err = svc.ImportURL(ctx, storage.RequestURL{URL: "https://example.com"}, "home")
+	require.NoError(t, err)
+
+	export := func(format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export?format="+format, nil)
+		req = req.WithContext(ctx)
+		rec := httptest.NewRecorder()
+
+		handlers.APIExportURLsHandler(svc).ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		rec := export("")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, handlers.ContentTypeCSV, rec.Header().Get("Content-Type"))
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, []string{"alias", "short_url", "original_url", "title", "note", "tags", "clicks", "created_at"}, records[0])
+		assert.Equal(t, "docs", records[1][0])
+		assert.Equal(t, "https://example.com/docs", records[1][2])
+		assert.Equal(t, "docs,work", records[1][5])
+	})
+
+	t.Run("CSV can be imported again", func(t *testing.T) {
+		rec := export("csv")
+
+		response := importCSV(t, service.NewURLService(storage.NewMemoryStore()), rec.Body.String())
+
+		assert.Equal(t, 2, response.Created)
+		assert.Zero(t, response.Failed)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		rec := export("json")
+
+		assert.Equal(t, handlers.ContentTypeJSON, rec.Header().Get("Content-Type"))
+
+		var links []storage.URL
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&links))
+		require.Len(t, links, 2)
+		assert.Equal(t, "home", links[1].ShortURL)
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		rec := export("ndjson")
+
+		assert.Equal(t, handlers.ContentTypeNDJSON, rec.Header().Get("Content-Type"))
+
+		var shortURLs []string
+		scanner := bufio.NewScanner(rec.Body)
+		for scanner.Scan() {
+			var link storage.URL
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &link))
+			shortURLs = append(shortURLs, link.ShortURL)
+		}
+		assert.Equal(t, []string{"docs", "home"}, shortURLs)
+	})
+
+	t.Run("Empty list", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export?format=json", nil)
+		req = req.WithContext(context.WithValue(context.Background(), middleware.UserIDKey, "nobody"))
+		rec := httptest.NewRecorder()
+
+		handlers.APIExportURLsHandler(svc).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, "[]", rec.Body.String())
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, export("xml").Code)
+	})
+}
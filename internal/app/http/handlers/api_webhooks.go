@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/golangTroshin/shorturl/internal/app/webhooks"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// webhookDeliveriesLimit is the number of deliveries listed by APIListWebhookDeliveriesHandler.
+const webhookDeliveriesLimit = 100
+
+// webhookRequest is the body of a request creating a webhook subscription.
+type webhookRequest struct {
+	URL    string   `json:"url"`    // HTTP(S) URL receiving the deliveries
+	Events []string `json:"events"` // Event types to deliver; all of them when empty
+	Secret string   `json:"secret"` // Key of the signatures; generated when empty
+}
+
+// APICreateWebhookHandler returns an HTTP handler subscribing a URL to events of the user's links.
+//
+// This handler processes a POST request to `/api/user/webhooks` with a JSON body holding the
+// `url`, the `events` to deliver (all of `link.created`, `link.deleted` and `link.clicked` when
+// omitted) and an optional `secret`. It answers `201 Created` with the subscription, including
+// its secret, which is not shown again; a secret is generated when none is given.
+//
+// Parameters:
+//   - hooks: The store of webhook subscriptions.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the subscription request.
+func APICreateWebhookHandler(hooks webhooks.Store) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		var request webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			problem.Write(w, r, codes.InvalidArgument, "Invalid request body")
+			return
+		}
+
+		sub := webhooks.Subscription{
+			UserID: userID(r),
+			URL:    request.URL,
+			Events: request.Events,
+			Secret: request.Secret,
+		}
+		if len(sub.Events) == 0 {
+			sub.Events = slices.Clone(storage.EventTypes)
+		}
+		if sub.Secret == "" {
+			secret, err := webhooks.NewSecret()
+			if err != nil {
+				logger.FromContext(r.Context()).Error("unable to generate webhook secret", zap.Error(err))
+				problem.Write(w, r, codes.Internal, "Failed to create webhook")
+				return
+			}
+			sub.Secret = secret
+		}
+		if err := sub.Validate(); err != nil {
+			problem.Write(w, r, codes.InvalidArgument, err.Error())
+			return
+		}
+
+		sub, err := hooks.CreateSubscription(r.Context(), sub)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("unable to create webhook", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Failed to create webhook")
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		w.Header().Set("Location", "/api/user/webhooks/"+sub.ID)
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(sub); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIListWebhooksHandler returns an HTTP handler listing the webhook subscriptions of the user.
+//
+// This handler processes a GET request to `/api/user/webhooks` and answers with a JSON array of
+// subscriptions, oldest first, without their secrets.
+//
+// Parameters:
+//   - hooks: The store of webhook subscriptions.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the listing request.
+func APIListWebhooksHandler(hooks webhooks.Store) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		subs, err := hooks.ListSubscriptions(r.Context(), userID(r))
+		if err != nil {
+			logger.FromContext(r.Context()).Error("unable to list webhooks", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Failed to list webhooks")
+			return
+		}
+		for i := range subs {
+			subs[i].Secret = ""
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(subs); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIDeleteWebhookHandler returns an HTTP handler removing a webhook subscription of the user.
+//
+// This handler processes a DELETE request to `/api/user/webhooks/{id}` and answers
+// `204 No Content`. Pending deliveries and the delivery log of the subscription are removed too.
+//
+// Parameters:
+//   - hooks: The store of webhook subscriptions.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the removal request.
+func APIDeleteWebhookHandler(hooks webhooks.Store) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		err := hooks.DeleteSubscription(r.Context(), userID(r), chi.URLParam(r, "id"))
+		if errors.Is(err, webhooks.ErrSubscriptionNotFound) {
+			problem.Write(w, r, codes.NotFound, "Webhook not found")
+			return
+		}
+		if err != nil {
+			logger.FromContext(r.Context()).Error("unable to delete webhook", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Failed to delete webhook")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIListWebhookDeliveriesHandler returns an HTTP handler serving the delivery log of a webhook
+// subscription of the user.
+//
+// This handler processes a GET request to `/api/user/webhooks/{id}/deliveries` and answers with
+// a JSON array of the latest deliveries, newest first, each with its payload, status, number of
+// attempts and the response status and error of the last attempt.
+//
+// Parameters:
+//   - hooks: The store of webhook subscriptions.
+//
+// Returns:
+//   - An `http.HandlerFunc` that handles the delivery log request.
+func APIListWebhookDeliveriesHandler(hooks webhooks.Store) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		deliveries, err := hooks.Deliveries(r.Context(), userID(r), chi.URLParam(r, "id"), webhookDeliveriesLimit)
+		if errors.Is(err, webhooks.ErrSubscriptionNotFound) {
+			problem.Write(w, r, codes.NotFound, "Webhook not found")
+			return
+		}
+		if err != nil {
+			logger.FromContext(r.Context()).Error("unable to list webhook deliveries", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Failed to list webhook deliveries")
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// userID returns the ID of the user authenticated by middleware.CheckAuthToken.
+func userID(r *http.Request) string {
+	id, _ := r.Context().Value(middleware.UserIDKey).(string)
+	return id
+}
@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/golangTroshin/shorturl/internal/app/http/handlers"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/golangTroshin/shorturl/internal/app/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIWebhookHandlers(t *testing.T) {
+	hooks := webhooks.NewMemoryStore()
+	r := chi.NewRouter()
+	r.Post("/api/user/webhooks", handlers.APICreateWebhookHandler(hooks))
+	r.Get("/api/user/webhooks", handlers.APIListWebhooksHandler(hooks))
+	r.Delete("/api/user/webhooks/{id}", handlers.APIDeleteWebhookHandler(hooks))
+	r.Get("/api/user/webhooks/{id}/deliveries", handlers.APIListWebhookDeliveriesHandler(hooks))
+
+	serve := func(method, target, body, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, user))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var created webhooks.Subscription
+	t.Run("Create with generated secret and all events", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/api/user/webhooks", `{"url":"https://receiver.test/hook"}`, "user1")
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+		assert.Equal(t, "/api/user/webhooks/"+created.ID, rec.Header().Get("Location"))
+		assert.Equal(t, storage.EventTypes, created.Events)
+		assert.True(t, strings.HasPrefix(created.Secret, "whsec_"))
+	})
+
+	t.Run("Invalid subscriptions", func(t *testing.T) {
+		for _, body := range []string{
+			`{"url":"ftp://receiver.test"}`,
+			`{"url":"https://receiver.test","events":["link.renamed"]}`,
+			`not json`,
+		} {
+			rec := serve(http.MethodPost, "/api/user/webhooks", body, "user1")
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+			assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("List hides secrets", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/api/user/webhooks", "", "user1")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var subs []map[string]any
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&subs))
+		require.Len(t, subs, 1)
+		assert.Equal(t, created.ID, subs[0]["id"])
+		assert.NotContains(t, subs[0], "secret")
+
+		rec = serve(http.MethodGet, "/api/user/webhooks", "", "user2")
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
+
+	t.Run("Delivery log", func(t *testing.T) {
+		event := storage.Event{ID: "event1", Type: storage.EventLinkCreated, UserID: "user1"}
+		_, err := hooks.Dispatch(context.Background(), event, []byte(`{"id":"event1"}`))
+		require.NoError(t, err)
+
+		rec := serve(http.MethodGet, "/api/user/webhooks/"+created.ID+"/deliveries", "", "user1")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var deliveries []map[string]any
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&deliveries))
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, "pending", deliveries[0]["status"])
+		assert.Equal(t, map[string]any{"id": "event1"}, deliveries[0]["payload"])
+
+		rec = serve(http.MethodGet, "/api/user/webhooks/"+created.ID+"/deliveries", "", "user2")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		rec := serve(http.MethodDelete, "/api/user/webhooks/"+created.ID, "", "user2")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = serve(http.MethodDelete, "/api/user/webhooks/"+created.ID, "", "user1")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = serve(http.MethodDelete, "/api/user/webhooks/"+created.ID, "", "user1")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
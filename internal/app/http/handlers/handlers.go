@@ -2,13 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/http/templates"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/qrcode"
+	"github.com/golangTroshin/shorturl/internal/app/redirect"
 	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 // ContentTypePlainText const for content type
@@ -35,29 +48,48 @@ func ShortenURL(svc service.Service) http.HandlerFunc {
 			return
 		}
 
+		status := http.StatusCreated
+
 		URL, err := svc.ShortenURL(r.Context(), string(body))
-		if err != nil {
+		var conflict *storage.InsertConflictError
+		if errors.As(err, &conflict) {
+			status = http.StatusConflict
+		} else if errors.Is(err, service.ErrInvalidURL) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
 			http.Error(w, "Failed to shorten URL", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", ContentTypePlainText)
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(status)
 		_, _ = w.Write([]byte(config.Options.FlagBaseURL + "/" + URL.ShortURL))
 	}
 }
 
-// GetRequestHandler handles HTTP GET requests to retrieve the original URL
+// GetRequestHandler handles HTTP GET and HEAD requests to retrieve the original URL
 // corresponding to a given shortened URL ID.
 //
 // It extracts the "id" parameter from the URL path, queries the storage for the
-// original URL, and performs the following actions:
-//   - If the shortened URL exists and is active, it responds with a 307 Temporary Redirect status,
-//     setting the "Location" header to the original URL.
-//   - If the shortened URL has been deleted, it responds with a 410 Gone status.
+// link, and performs the following actions:
+//   - If the shortened URL exists and is active, it responds with the link's redirect status
+//     (301, 302, 307 or 308, falling back to `config.Options.RedirectType`), setting the
+//     "Location" header to the original URL, or to the destination of the first matching
+//     conditional rule, and caching headers derived from the redirect type and the link expiry.
+//     The link's UTM template and, when enabled, the query parameters of the short URL are
+//     merged into the destination without overwriting its own parameters.
+//   - If the shortened URL has been deleted or has expired, it responds with a 410 Gone status.
 //   - If the shortened URL does not exist, it responds with a 404 Not Found status.
 //   - If the "id" parameter is missing or invalid, it responds with a 400 Bad Request status.
 //
+// Only GET requests are counted as clicks, so link checkers issuing HEAD requests
+// do not affect the statistics.
+//
+// Appending "+" to the ID ("/{id}+") or adding "?preview=1" renders an HTML preview page
+// with the destination URL, the owner-supplied title and description, and a "continue"
+// button instead of redirecting. Previews are not counted as clicks.
+//
 // Parameters:
 //   - store: The storage interface for managing URL data.
 //
@@ -66,20 +98,166 @@ func ShortenURL(svc service.Service) http.HandlerFunc {
 func GetOriginalURL(svc service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
+		query := r.URL.Query()
+		preview := query.Get(previewParam) == "1"
+		query.Del(previewParam)
+
+		if key, ok := strings.CutSuffix(id, "+"); ok {
+			id = key
+			preview = true
+		}
+
 		if id == "" {
 			http.Error(w, "Invalid URL", http.StatusBadRequest)
 			return
 		}
 
-		originalURL, err := svc.GetOriginalURL(r.Context(), id)
+		link, err := svc.ResolveURL(r.Context(), id)
 		if err != nil {
+			var deleted *storage.DeletedURLError
+			if errors.As(err, &deleted) || errors.Is(err, service.ErrURLExpired) {
+				http.Error(w, "URL is gone", http.StatusGone)
+				return
+			}
+
 			http.Error(w, "URL not found", http.StatusNotFound)
 			return
 		}
 
+		now := time.Now()
+		decision := redirect.Evaluate(r, link, now)
+		destination := redirect.MergeQuery(decision.Destination, link, query)
+
+		// The split variant is pinned before the preview too, so "continue" leads to the
+		// destination it shows.
+		if decision.Cookie != nil {
+			http.SetCookie(w, decision.Cookie)
+		}
+
+		if preview {
+			renderPreview(w, r, link, destination, query)
+			return
+		}
+
+		if r.Method != http.MethodHead {
+			if err := svc.RecordClick(r.Context(), id); err != nil {
+				logger.FromContext(r.Context()).Error("unable to record click", zap.String("short_url", id), zap.Error(err))
+			}
+		}
+
+		status := redirect.Status(link, config.Options.RedirectType)
+
 		w.Header().Set("Content-Type", "text/plain")
-		w.Header().Set("Location", originalURL)
-		w.WriteHeader(http.StatusTemporaryRedirect)
+		w.Header().Set("Location", destination)
+		redirect.SetCacheHeaders(w.Header(), status, link, now)
+		w.WriteHeader(status)
+	}
+}
+
+// previewParam is the query parameter that requests the preview page instead of a redirect.
+const previewParam = "preview"
+
+// renderPreview writes the interstitial page showing where the short link leads.
+//
+// The "continue" button points back to the short link without the preview marker,
+// so following it is redirected and counted as a regular click.
+func renderPreview(w http.ResponseWriter, r *http.Request, link storage.URL, destination string, query url.Values) {
+	continueURL := "/" + link.ShortURL
+	if len(query) > 0 {
+		continueURL += "?" + query.Encode()
+	}
+
+	w.Header().Set("Content-Type", templates.ContentTypeHTML)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	title, description := link.Title, link.Description
+	if link.Page != nil {
+		// Fall back to the metadata fetched from the destination page
+		title = firstNonEmpty(title, link.Page.OGTitle, link.Page.Title)
+		description = firstNonEmpty(description, link.Page.OGDescription)
+	}
+
+	err := templates.Render(w, templates.PagePreview, templates.PreviewData{
+		Title:       title,
+		Description: description,
+		Destination: destination,
+		ContinueURL: continueURL,
+	})
+	if err != nil {
+		logger.FromContext(r.Context()).Error("unable to render preview", zap.String("short_url", link.ShortURL), zap.Error(err))
+		http.Error(w, "Failed to render preview", http.StatusInternalServerError)
+	}
+}
+
+// firstNonEmpty returns the first non-empty value.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// GetQRCode handles HTTP GET requests for a QR code image of a short link,
+// encoding `config.Options.FlagBaseURL + "/" + id`.
+//
+// Optional query parameters:
+//   - `format` is `png` (default) or `svg`.
+//   - `size` is the image width and height in pixels, 256 by default. PNG images use a whole
+//     number of pixels per module, so they may come out slightly smaller.
+//   - `margin` is the quiet zone around the code in modules, 4 by default.
+//   - `level` is the error correction level `L`, `M` (default), `Q` or `H`.
+//
+// The function performs the following actions:
+//   - If the link exists and is active, it responds with the image and a 200 OK status.
+//   - If the query parameters are invalid, it responds with a 400 Bad Request status.
+//   - If the link has been deleted or has expired, it responds with a 410 Gone status.
+//   - If the link does not exist, it responds with a 404 Not Found status.
+//
+// Returns:
+//   - http.HandlerFunc: A handler function to process the request.
+func GetQRCode(svc service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		query := r.URL.Query()
+
+		opts := qrcode.ImageOptions{Format: query.Get("format"), Margin: qrcode.DefaultMargin}
+		for param, target := range map[string]*int{"size": &opts.Size, "margin": &opts.Margin} {
+			value := query.Get(param)
+			if value == "" {
+				continue
+			}
+
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, param+" must be a number", http.StatusBadRequest)
+				return
+			}
+			*target = n
+		}
+
+		image, err := svc.QRCode(r.Context(), id, query.Get("level"), opts)
+		if err != nil {
+			var deleted *storage.DeletedURLError
+			switch {
+			case errors.Is(err, service.ErrInvalidQRCode):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.As(err, &deleted), errors.Is(err, service.ErrURLExpired):
+				http.Error(w, "URL is gone", http.StatusGone)
+			case errors.Is(err, storage.ErrURLNotFound):
+				http.Error(w, "URL not found", http.StatusNotFound)
+			default:
+				logger.FromContext(r.Context()).Error("unable to render QR code", zap.String("short_url", id), zap.Error(err))
+				http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", qrcode.ContentType(opts.Format))
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_, _ = w.Write(image)
 	}
 }
 
@@ -87,14 +265,25 @@ func GetOriginalURL(svc service.Service) http.HandlerFunc {
 // associated with the currently authenticated user.
 //
 // It extracts the user ID from the request context and queries the storage for
-// all URLs associated with that user. The response includes the original URL and
-// its corresponding shortened URL.
+// all URLs associated with that user. The response includes the original URL,
+// its corresponding shortened URL and the link metadata (title, note, tags).
+//
+// Optional query parameters:
+//   - `tag` limits the list to links carrying that tag.
+//   - `health=broken` lists links whose destination failed the last dead-link check,
+//     `health=ok` those that passed it.
+//   - `q` searches the original URL and the title; `match=prefix` matches it as a prefix
+//     instead of a substring.
+//   - `sort` orders by `created` (default) or `clicks`; `order` is `desc` (default) or `asc`.
+//   - `limit` sets the page size and `cursor` continues from a previous page. The URL of
+//     the next page is returned in a `Link` header with `rel="next"`.
 //
 // The function performs the following actions:
 //   - If URLs are found for the user, it responds with a JSON-encoded list of URLs
 //     and a 200 OK status.
+//   - If the query parameters are invalid, it responds with a 400 Bad Request problem.
 //   - If no URLs are found, it responds with a 204 No Content status.
-//   - If an error occurs during retrieval, it responds with the appropriate HTTP status.
+//   - If an error occurs during retrieval, it responds with a 500 Internal Server Error problem.
 //
 // Parameters:
 //   - store: The storage interface for managing URL data.
@@ -103,28 +292,84 @@ func GetOriginalURL(svc service.Service) http.HandlerFunc {
 //   - http.HandlerFunc: A handler function to process the request.
 func GetUserURLs(svc service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		urls, err := svc.GetUserURLs(r.Context())
-		if err != nil || len(urls) == 0 {
+		query, err := parseURLQuery(r.URL.Query())
+		if err != nil {
+			problem.Write(w, r, codes.InvalidArgument, err.Error())
+			return
+		}
+
+		page, err := svc.FindUserURLs(r.Context(), query)
+		if errors.Is(err, service.ErrInvalidQuery) {
+			problem.Write(w, r, codes.InvalidArgument, err.Error())
+			return
+		}
+		if err != nil {
+			logger.FromContext(r.Context()).Error("unable to fetch user URLs", zap.Error(err))
+			problem.Write(w, r, codes.Internal, "Failed to fetch URLs")
+			return
+		}
+		if len(page.URLs) == 0 {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
+		if page.NextCursor != "" {
+			next := r.URL.Query()
+			next.Set("cursor", page.NextCursor)
+			w.Header().Set("Link", "<"+config.Options.FlagBaseURL+r.URL.Path+"?"+next.Encode()+`>; rel="next"`)
+		}
+
 		w.Header().Set("Content-Type", ContentTypeJSON)
-		if err := json.NewEncoder(w).Encode(urls); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-			return
+		if err := json.NewEncoder(w).Encode(page.URLs); err != nil {
+			logger.FromContext(r.Context()).Error("unable to write response", zap.Error(err))
 		}
 	}
 }
 
-// DatabasePing handles HTTP GET requests to check the health of the database connection.
+// parseURLQuery reads the filter, sort and pagination parameters of the link list.
+func parseURLQuery(values url.Values) (storage.URLQuery, error) {
+	query := storage.URLQuery{
+		Tag:    values.Get("tag"),
+		Health: values.Get("health"),
+		Search: values.Get("q"),
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+
+	switch values.Get("match") {
+	case "", "contains":
+	case "prefix":
+		query.Prefix = true
+	default:
+		return query, errors.New("match must be contains or prefix")
+	}
+
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, errors.New("limit must be a positive number")
+		}
+		query.Limit = n
+	}
+
+	return query, nil
+}
+
+// Ping handles HTTP GET requests to check that the storage backend is reachable.
 //
 // It performs the following actions:
-//   - Attempts to establish a connection to the database using the configured DSN.
-//   - If the connection is successful and the database is reachable, it responds with
-//     a 200 OK status.
-//   - If the connection fails or the database is unreachable, it responds with a
-//     500 Internal Server Error status.
+//   - Pings the configured storage through the service, reusing its connections: the database,
+//     the storage file, or nothing for memory storage.
+//   - If the storage is reachable, it responds with a 200 OK status.
+//   - Otherwise, it responds with a 500 Internal Server Error status.
 //
 // Returns:
 //   - http.HandlerFunc: A handler function to process the request.
@@ -4,18 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
 	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
 	"github.com/golangTroshin/shorturl/internal/app/service"
 	"github.com/golangTroshin/shorturl/internal/app/storage"
 	"github.com/golangTroshin/shorturl/internal/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShortenURL(t *testing.T) {
@@ -40,6 +45,21 @@ func TestShortenURL(t *testing.T) {
 		assert.Contains(t, string(body), "/")
 	})
 
+	t.Run("Already shortened", func(t *testing.T) {
+		first, err := http.Post(server.URL, "text/plain", strings.NewReader("https://example.org"))
+		require.NoError(t, err)
+		created, _ := io.ReadAll(first.Body)
+		first.Body.Close()
+
+		resp, err := http.Post(server.URL, "text/plain", strings.NewReader("https://example.org"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, string(created), string(body))
+	})
+
 	t.Run("Empty Body", func(t *testing.T) {
 		resp, err := http.Post(server.URL, "text/plain", nil)
 
@@ -74,6 +94,169 @@ func TestGetOriginalURL(t *testing.T) {
 	// Assert the response
 	assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
 	assert.Equal(t, "https://example.com", recorder.Header().Get("Location"))
+	assert.Equal(t, "private, no-cache", recorder.Header().Get("Cache-Control"))
+}
+
+func TestGetOriginalURL_RedirectTypes(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	router := chi.NewRouter()
+	router.Get("/{id}", GetOriginalURL(svc))
+	router.Head("/{id}", GetOriginalURL(svc))
+
+	t.Run("Permanent redirect is cacheable", func(t *testing.T) {
+		url, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL:         "https://example.com/permanent",
+			LinkOptions: storage.LinkOptions{RedirectType: http.StatusPermanentRedirect},
+		})
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+url.ShortURL, nil))
+
+		assert.Equal(t, http.StatusPermanentRedirect, recorder.Code)
+		assert.Equal(t, "public, max-age=86400", recorder.Header().Get("Cache-Control"))
+		assert.NotEmpty(t, recorder.Header().Get("Expires"))
+	})
+
+	t.Run("Expired link is gone", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Minute)
+		url, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL:         "https://example.com/expired",
+			LinkOptions: storage.LinkOptions{ExpiresAt: &expiresAt},
+		})
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+url.ShortURL, nil))
+
+		assert.Equal(t, http.StatusGone, recorder.Code)
+	})
+
+	t.Run("Conditional rule overrides destination", func(t *testing.T) {
+		url, err := store.Set(ctx, "https://example.com/rules")
+		assert.NoError(t, err)
+
+		_, err = svc.SetURLRules(ctx, url.ShortURL, []storage.Rule{
+			{Device: "mobile", Destination: "https://m.example.com/rules"},
+		})
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/"+url.ShortURL, nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		assert.Equal(t, "https://m.example.com/rules", recorder.Header().Get("Location"))
+		assert.Equal(t, "private, no-cache", recorder.Header().Get("Cache-Control"))
+	})
+
+	t.Run("UTM template and pass-through query", func(t *testing.T) {
+		url, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL: "https://example.com/utm?id=1",
+			LinkOptions: storage.LinkOptions{
+				UTM:       &storage.UTM{Source: "poster", Campaign: "launch"},
+				PassQuery: true,
+			},
+		})
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+url.ShortURL+"?id=2&ref=qr", nil))
+
+		assert.Equal(t, "https://example.com/utm?id=1&ref=qr&utm_campaign=launch&utm_source=poster", recorder.Header().Get("Location"))
+	})
+
+	t.Run("Preview page instead of redirect", func(t *testing.T) {
+		url, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL:         "https://example.com/preview?a=1&b=2",
+			LinkOptions: storage.LinkOptions{Title: "Spring <sale>", Description: "All the deals"},
+		})
+		assert.NoError(t, err)
+
+		for _, target := range []string{"/" + url.ShortURL + "+", "/" + url.ShortURL + "?preview=1"} {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+			assert.Empty(t, recorder.Header().Get("Location"))
+
+			body := recorder.Body.String()
+			assert.Contains(t, body, "Spring &lt;sale&gt;")
+			assert.Contains(t, body, "All the deals")
+			assert.Contains(t, body, "https://example.com/preview?a=1&amp;b=2")
+			assert.Contains(t, body, `href="/`+url.ShortURL+`"`)
+		}
+
+		link, err := store.GetURL(ctx, url.ShortURL)
+		assert.NoError(t, err)
+		assert.Zero(t, link.Clicks)
+	})
+
+	t.Run("Preview pins the split variant", func(t *testing.T) {
+		url, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL: "https://example.com/split",
+			LinkOptions: storage.LinkOptions{Rules: []storage.Rule{{Split: []storage.SplitVariant{
+				{Destination: "https://a.example.com/", Weight: 1},
+				{Destination: "https://b.example.com/", Weight: 1},
+			}}}},
+		})
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+url.ShortURL+"+", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		cookies := recorder.Result().Cookies()
+		require.Len(t, cookies, 1)
+
+		// Continuing from the preview leads to the variant it showed.
+		req := httptest.NewRequest(http.MethodGet, "/"+url.ShortURL, nil)
+		req.AddCookie(cookies[0])
+		redirected := httptest.NewRecorder()
+		router.ServeHTTP(redirected, req)
+
+		location := redirected.Header().Get("Location")
+		assert.Contains(t, []string{"https://a.example.com/", "https://b.example.com/"}, location)
+		assert.Contains(t, recorder.Body.String(), location)
+	})
+
+	t.Run("Preview falls back to fetched page metadata", func(t *testing.T) {
+		url, err := store.Set(ctx, "https://example.com/fetched")
+		assert.NoError(t, err)
+		assert.NoError(t, store.SetPageInfo(ctx, url.ShortURL, storage.PageInfo{
+			Title:         "Page title",
+			OGTitle:       "Shared title",
+			OGDescription: "Shared description",
+		}))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+url.ShortURL+"+", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "Shared title")
+		assert.Contains(t, recorder.Body.String(), "Shared description")
+	})
+
+	t.Run("HEAD does not count clicks", func(t *testing.T) {
+		url, err := store.Set(ctx, "https://example.com/head")
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodHead, "/"+url.ShortURL, nil))
+		assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+		assert.Equal(t, "https://example.com/head", recorder.Header().Get("Location"))
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+url.ShortURL, nil))
+		assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+
+		link, err := store.GetURL(ctx, url.ShortURL)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), link.Clicks)
+	})
 }
 func TestGetUserURLs(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -84,10 +267,10 @@ func TestGetUserURLs(t *testing.T) {
 
 	t.Run("Successful retrieval of user URLs", func(t *testing.T) {
 		// Mock service to return a list of URLs
-		mockService.EXPECT().GetUserURLs(gomock.Any()).Return([]storage.URL{
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{}).Return(storage.URLPage{URLs: []storage.URL{
 			{ShortURL: "short1", OriginalURL: "http://example.com/1"},
 			{ShortURL: "short2", OriginalURL: "http://example.com/2"},
-		}, nil)
+		}}, nil)
 
 		// Simulate a request
 		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
@@ -111,7 +294,7 @@ func TestGetUserURLs(t *testing.T) {
 
 	t.Run("No URLs for user", func(t *testing.T) {
 		// Mock service to return an empty list
-		mockService.EXPECT().GetUserURLs(gomock.Any()).Return([]storage.URL{}, nil)
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{}).Return(storage.URLPage{}, nil)
 
 		// Simulate a request
 		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
@@ -123,9 +306,90 @@ func TestGetUserURLs(t *testing.T) {
 		assert.Equal(t, http.StatusNoContent, rec.Code)
 	})
 
+	t.Run("Filter by tag", func(t *testing.T) {
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{Tag: "work"}).Return(storage.URLPage{URLs: []storage.URL{
+			{ShortURL: "short1", OriginalURL: "http://example.com/1", LinkOptions: storage.LinkOptions{
+				Title: "Example", Note: "for the team", Tags: []string{"work"},
+			}},
+		}}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls?tag=work", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response []storage.URL
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		assert.Len(t, response, 1)
+		assert.Equal(t, "Example", response[0].Title)
+		assert.Equal(t, "for the team", response[0].Note)
+		assert.Equal(t, []string{"work"}, response[0].Tags)
+	})
+
+	t.Run("Paged and sorted list", func(t *testing.T) {
+		want := storage.URLQuery{Search: "exa", Prefix: true, Sort: storage.SortClicks, Ascending: true, Limit: 1}
+		mockService.EXPECT().FindUserURLs(gomock.Any(), want).Return(storage.URLPage{
+			URLs:       []storage.URL{{ShortURL: "short1", OriginalURL: "http://example.com/1"}},
+			NextCursor: "next",
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls?q=exa&match=prefix&sort=clicks&order=asc&limit=1", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		link := rec.Header().Get("Link")
+		assert.Contains(t, link, "/api/user/urls?")
+		assert.Contains(t, link, "cursor=next")
+		assert.Contains(t, link, "limit=1")
+		assert.Contains(t, link, `rel="next"`)
+	})
+
+	t.Run("Broken links", func(t *testing.T) {
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{Health: storage.HealthBroken}).Return(storage.URLPage{
+			URLs: []storage.URL{{ShortURL: "short1", Health: &storage.LinkHealth{Status: http.StatusNotFound}}},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls?health=broken", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response []storage.URL
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		assert.Equal(t, http.StatusNotFound, response[0].Health.Status)
+	})
+
+	t.Run("Invalid page parameters", func(t *testing.T) {
+		for _, query := range []string{"limit=-1", "limit=abc", "order=up", "match=exact"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls?"+query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{Cursor: "bogus"}).Return(
+			storage.URLPage{}, service.ErrInvalidQuery,
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls?cursor=bogus", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
 	t.Run("Service error", func(t *testing.T) {
 		// Mock service to return an error
-		mockService.EXPECT().GetUserURLs(gomock.Any()).Return(nil, context.DeadlineExceeded)
+		mockService.EXPECT().FindUserURLs(gomock.Any(), storage.URLQuery{}).Return(storage.URLPage{}, context.DeadlineExceeded)
 
 		// Simulate a request
 		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
@@ -134,19 +398,90 @@ func TestGetUserURLs(t *testing.T) {
 		handler.ServeHTTP(rec, req)
 
 		// Validate response
-		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+		var details problem.Details
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&details))
+		assert.Equal(t, "INTERNAL", details.Code)
 	})
 }
 
-func TestPing(t *testing.T) {
+func TestGetQRCode(t *testing.T) {
 	store := storage.NewMemoryStore()
 	svc := service.NewURLService(store)
-	handler := Ping(svc)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
 
-	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
-	recorder := httptest.NewRecorder()
+	url, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{id}/qr", GetQRCode(svc))
+
+	tests := []struct {
+		name        string
+		target      string
+		status      int
+		contentType string
+	}{
+		{"PNG by default", "/" + url.ShortURL + "/qr", http.StatusOK, "image/png"},
+		{"SVG with size and margin", "/" + url.ShortURL + "/qr?format=svg&size=128&margin=0&level=Q", http.StatusOK, "image/svg+xml"},
+		{"Unknown link", "/missing/qr", http.StatusNotFound, ""},
+		{"Invalid size", "/" + url.ShortURL + "/qr?size=big", http.StatusBadRequest, ""},
+		{"Size out of range", "/" + url.ShortURL + "/qr?size=100000", http.StatusBadRequest, ""},
+		{"Invalid level", "/" + url.ShortURL + "/qr?level=Z", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+				assert.NotEmpty(t, rec.Body.Bytes())
+			}
+		})
+	}
+
+	t.Run("Deleted link", func(t *testing.T) {
+		_, err = store.BatchDeleteURLs("test-user", []string{url.ShortURL})
+		assert.NoError(t, err)
 
-	handler.ServeHTTP(recorder, req)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+url.ShortURL+"/qr", nil))
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+	})
+}
+
+func TestPing(t *testing.T) {
+	t.Run("Healthy storage", func(t *testing.T) {
+		svc := service.NewURLService(storage.NewMemoryStore())
+		handler := Ping(svc)
+
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
 
-	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("Unreachable storage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mocks.NewMockStorage(ctrl)
+		mockStorage.EXPECT().Ping(gomock.Any()).Return(errors.New("connection refused"))
+		handler := Ping(service.NewURLService(mockStorage))
+
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	})
 }
@@ -2,10 +2,14 @@ package middleware
 
 import (
 	"context"
-	"log"
 	"net/http"
+	"strings"
 
 	"github.com/golangTroshin/shorturl/internal/app/helpers"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 // ContextKey defines a custom type for keys in the context to avoid collisions.
@@ -31,7 +35,7 @@ const UserIDKey = ContextKey("userID")
 //
 // Behavior:
 //   - If the token generation fails, it responds with HTTP 500 (Internal Server Error).
-//   - Logs the token generation and cookie status.
+//   - Adds the user ID to the fields logged for the request.
 func GiveAuthTokenToUser(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var ctx context.Context
@@ -39,19 +43,23 @@ func GiveAuthTokenToUser(h http.Handler) http.Handler {
 		if err != nil {
 			token, err := helpers.BuildJWTString()
 			if err != nil {
-				log.Printf("BuildJWTString error: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
+				logger.FromContext(r.Context()).Error("unable to build auth token", zap.Error(err))
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					problem.Write(w, r, codes.Internal, "Unable to issue auth token")
+				} else {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
 				return
 			}
 			http.SetCookie(w, &http.Cookie{Name: CookieAuthToken, Value: token})
 			ctx = context.WithValue(r.Context(), UserIDKey, token)
-			log.Println("cookie is set")
+			ctx = WithUserLogFields(ctx, token)
+			logger.FromContext(ctx).Debug("auth cookie is set")
 		} else if authToken.Value != "" {
-			log.Println("cookie is already set")
 			ctx = context.WithValue(r.Context(), UserIDKey, authToken.Value)
+			ctx = WithUserLogFields(ctx, authToken.Value)
 		}
 
-		log.Println("end GiveAuthTokenToUser")
 		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -59,7 +67,7 @@ func GiveAuthTokenToUser(h http.Handler) http.Handler {
 // CheckAuthToken is middleware that validates the presence of an authentication token.
 //
 // This middleware checks if the `auth_token` cookie exists and is valid. If the token is missing
-// or invalid, the middleware responds with HTTP 401 (Unauthorized). Otherwise, it adds the token
+// or invalid, the middleware responds with a 401 (Unauthorized) problem. Otherwise, it adds the token
 // to the request context and proceeds to the next handler.
 //
 // Parameters:
@@ -69,22 +77,24 @@ func GiveAuthTokenToUser(h http.Handler) http.Handler {
 //   - An `http.Handler` that wraps the provided handler with token validation.
 //
 // Behavior:
-//   - If the token is missing or invalid, it responds with HTTP 401 (Unauthorized).
+//   - If the token is missing or invalid, it responds with a 401 (Unauthorized) problem.
 func CheckAuthToken(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var ctx context.Context
 		authToken, err := r.Cookie(CookieAuthToken)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-
-		if authToken.Value == "" {
-			w.WriteHeader(http.StatusUnauthorized)
+		if err != nil || authToken.Value == "" {
+			problem.Write(w, r, codes.Unauthenticated, "Missing auth token")
 			return
 		}
 
 		ctx = context.WithValue(r.Context(), UserIDKey, authToken.Value)
+		ctx = WithUserLogFields(ctx, authToken.Value)
 		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// WithUserLogFields adds the ID of the user holding the auth token to the fields logged for the
+// request. The token itself is never logged.
+func WithUserLogFields(ctx context.Context, token string) context.Context {
+	return logger.WithFields(ctx, zap.String("user_id", helpers.GetUserIDByToken(token)))
+}
@@ -14,9 +14,9 @@
 //     user's cookies. Requests without a valid token are rejected with a `401 Unauthorized`
 //     status.
 //
-//   - `GzipMiddleware`: Compresses HTTP responses using Gzip for clients that support it.
-//     It also decompresses incoming Gzip-encoded request bodies to make them accessible
-//     to handlers.
+//   - `GzipMiddleware`: Compresses JSON and HTML responses using Gzip for clients that
+//     support it. It also decompresses incoming Gzip-encoded request bodies to make them
+//     accessible to handlers.
 //
 // # Usage
 //
@@ -3,12 +3,16 @@ package middleware
 import (
 	"compress/gzip"
 	"io"
-	"log"
+	"mime"
 	"net"
 	"net/http"
 	"strings"
 
 	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -25,17 +29,23 @@ var (
 // compressWriter is a wrapper around `http.ResponseWriter` that compresses the
 // response body using gzip. It sets the `Content-Encoding` header when the response
 // status code is less than 300.
+//
+// When the writer is not forced to compress, the decision is made on the first write
+// based on the response `Content-Type`, so handlers rendering HTML or JSON get
+// compressed output regardless of the request headers.
 type compressWriter struct {
-	w  http.ResponseWriter // The original ResponseWriter.
-	zw *gzip.Writer        // The gzip writer for compression.
+	w           http.ResponseWriter // The original ResponseWriter.
+	zw          *gzip.Writer        // The gzip writer for compression, nil until compression is chosen.
+	force       bool                // Compress regardless of the response Content-Type.
+	wroteHeader bool                // Whether the status code has been written.
 }
 
 // newCompressWriter creates a new `compressWriter` that wraps an `http.ResponseWriter`
 // for gzip compression.
-func newCompressWriter(w http.ResponseWriter) *compressWriter {
+func newCompressWriter(w http.ResponseWriter, force bool) *compressWriter {
 	return &compressWriter{
-		w:  w,
-		zw: gzip.NewWriter(w),
+		w:     w,
+		force: force,
 	}
 }
 
@@ -47,20 +57,54 @@ func (c *compressWriter) Header() http.Header {
 
 // Write compresses the provided bytes and writes them to the underlying writer.
 func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.zw == nil {
+		return c.w.Write(p)
+	}
+
 	return c.zw.Write(p)
 }
 
 // WriteHeader writes the HTTP status code to the underlying writer, setting the
 // `Content-Encoding: gzip` header for successful responses.
 func (c *compressWriter) WriteHeader(statusCode int) {
-	if statusCode < 300 {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	if statusCode < 300 && (c.force || isResponseContentTypeAllowed(c.w.Header().Get("Content-Type"))) {
+		c.zw = gzip.NewWriter(c.w)
 		c.w.Header().Set("Content-Encoding", "gzip")
+		c.w.Header().Del("Content-Length")
 	}
 	c.w.WriteHeader(statusCode)
 }
 
+// Flush sends the data compressed so far to the client, so streaming responses
+// are delivered incrementally.
+func (c *compressWriter) Flush() {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.zw != nil {
+		if err := c.zw.Flush(); err != nil {
+			return
+		}
+	}
+
+	_ = http.NewResponseController(c.w).Flush()
+}
+
 // Close closes the gzip writer, flushing any remaining data to the underlying writer.
 func (c *compressWriter) Close() error {
+	if c.zw == nil {
+		return nil
+	}
 	return c.zw.Close()
 }
 
@@ -102,7 +146,8 @@ func (c *compressReader) Close() error {
 // is supported and enabled by the client.
 //
 //   - For responses: If the client indicates support for gzip via the `Accept-Encoding`
-//     header, the response is compressed.
+//     header, the response is compressed when either the request or the response
+//     `Content-Type` is JSON or HTML.
 //   - For requests: If the client sends gzip-encoded content via the `Content-Encoding`
 //     header, the middleware decompresses it.
 //
@@ -115,8 +160,8 @@ func GzipMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ow := w
 
-		if isContentTypeAllowed(r.Header.Get("Content-Type")) && isEncodingTypeAllowed(r.Header.Values("Accept-Encoding")) {
-			cw := newCompressWriter(w)
+		if isEncodingTypeAllowed(r.Header.Values("Accept-Encoding")) {
+			cw := newCompressWriter(w, isContentTypeAllowed(r.Header.Get("Content-Type")))
 			ow = cw
 			defer cw.Close()
 		}
@@ -142,22 +187,22 @@ func GzipMiddleware(h http.Handler) http.Handler {
 // defined by `config.Options.TrustedSubnet`.
 //
 // If the trusted subnet is not specified or the client's IP is not within the allowed range, the middleware
-// responds with HTTP 403 Forbidden.
+// responds with a 403 Forbidden problem.
 func IPTrustedMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if config.Options.TrustedSubnet == "" {
-			http.Error(w, "no specified TrustedSubnet", http.StatusForbidden)
+			problem.Write(w, r, codes.PermissionDenied, "no specified TrustedSubnet")
 			return
 		}
 
 		clientIP := r.Header.Get("X-Real-IP")
 		if clientIP == "" {
-			http.Error(w, "X-Real-IP header missing", http.StatusForbidden)
+			problem.Write(w, r, codes.PermissionDenied, "X-Real-IP header missing")
 			return
 		}
 
 		if !isIPTrusted(clientIP) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			problem.Write(w, r, codes.PermissionDenied, "Forbidden")
 			return
 		}
 
@@ -165,10 +210,18 @@ func IPTrustedMiddleware(h http.Handler) http.Handler {
 	})
 }
 
+// isEncodingTypeAllowed reports whether any coding listed in the Accept-Encoding
+// header values, such as "gzip, deflate, br", is supported.
 func isEncodingTypeAllowed(acceptEncoding []string) bool {
 	for _, value := range acceptEncoding {
-		if _, ok := allowedEncodingTypes[value]; ok {
-			return true
+		for _, coding := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+			if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+				continue
+			}
+			if _, ok := allowedEncodingTypes[strings.TrimSpace(coding)]; ok {
+				return true
+			}
 		}
 	}
 	return false
@@ -180,17 +233,28 @@ func isContentTypeAllowed(contentType string) bool {
 	return ok
 }
 
+// isResponseContentTypeAllowed reports whether a response Content-Type, which may
+// carry parameters such as charset, is worth compressing.
+func isResponseContentTypeAllowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return isContentTypeAllowed(mediaType)
+}
+
 // isIPTrusted checks if the given IP is within the trusted subnet
 func isIPTrusted(ip string) bool {
 	_, trustedNet, err := net.ParseCIDR(config.Options.TrustedSubnet)
 	if err != nil {
-		log.Printf("Error parsing trusted subnet: %v\n", err)
+		logger.Default().Error("invalid trusted subnet", zap.String("subnet", config.Options.TrustedSubnet), zap.Error(err))
 		return false
 	}
 
 	clientIP := net.ParseIP(ip)
 	if clientIP == nil {
-		log.Printf("Invalid IP address: %s\n", ip)
+		logger.Default().Warn("invalid client IP address", zap.String("ip", ip))
 		return false
 	}
 
@@ -75,3 +75,108 @@ func TestGzipMiddleware_SkipCompressionForUnsupportedContentType(t *testing.T) {
 		t.Fatalf("expected response body to be 'Hello, world!', got '%s'", resp.Body.String())
 	}
 }
+
+func TestGzipMiddleware_CompressesHTMLResponse(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte("<p>Hello, world!</p>"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/abc+", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected Content-Encoding to be 'gzip'")
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to create gzip reader: %v", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to decompress response body: %v", err)
+	}
+
+	if string(body) != "<p>Hello, world!</p>" {
+		t.Fatalf("expected response body '<p>Hello, world!</p>', got '%s'", body)
+	}
+}
+
+func TestIsEncodingTypeAllowed(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   bool
+	}{
+		{header: []string{"gzip"}, want: true},
+		{header: []string{"deflate, gzip;q=0.8"}, want: true},
+		{header: []string{"br", "gzip"}, want: true},
+		{header: []string{"gzip;q=0, br"}, want: false},
+		{header: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isEncodingTypeAllowed(tt.header); got != tt.want {
+			t.Errorf("isEncodingTypeAllowed(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipMiddleware_Flush(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"part":1}`))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush failed: %v", err)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if !resp.Flushed {
+		t.Fatalf("expected response to be flushed")
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to create gzip reader: %v", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to decompress response body: %v", err)
+	}
+
+	if string(body) != `{"part":1}` {
+		t.Fatalf("unexpected response body '%s'", body)
+	}
+}
+
+func TestGzipMiddleware_SkipCompressionForErrors(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":400}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Header().Get("Content-Encoding") == "gzip" {
+		t.Fatalf("expected Content-Encoding to not be 'gzip'")
+	}
+
+	if resp.Body.String() != `{"status":400}` {
+		t.Fatalf("expected the error body uncompressed, got '%s'", resp.Body.String())
+	}
+}
@@ -0,0 +1,115 @@
+// Package problem writes the error responses of the HTTP API as RFC 7807 problem details.
+//
+// Every failed /api/* request is answered with an `application/problem+json` body such as:
+//
+//	{
+//	    "type": "about:blank",
+//	    "title": "Not Found",
+//	    "status": 404,
+//	    "detail": "URL not found",
+//	    "instance": "/api/user/urls/abc/rules",
+//	    "code": "NOT_FOUND",
+//	    "request_id": "0f8fad5bd9cb469fa16570867728950e"
+//	}
+//
+// The `code` member is a stable, machine-readable error code: the name of the gRPC status code
+// the gRPC API returns for the same failure, so clients can handle errors of both APIs alike.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Details is the body of an error response.
+type Details struct {
+	Type      string `json:"type"`                 // URI identifying the problem type; "about:blank" means the HTTP status says it all
+	Title     string `json:"title"`                // Short summary of the problem type, the HTTP status text
+	Status    int    `json:"status"`               // HTTP status code of the response
+	Detail    string `json:"detail,omitempty"`     // Explanation specific to this occurrence
+	Instance  string `json:"instance,omitempty"`   // Path of the failed request
+	Code      string `json:"code"`                 // Machine-readable error code, e.g. "INVALID_ARGUMENT"
+	RequestID string `json:"request_id,omitempty"` // ID of the request, also sent in the X-Request-ID header
+}
+
+// httpStatuses maps gRPC status codes to the HTTP status codes of the same meaning.
+var httpStatuses = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499, // Client Closed Request
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+}
+
+// HTTPStatus returns the HTTP status code matching a gRPC status code.
+func HTTPStatus(code codes.Code) int {
+	if status, ok := httpStatuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Code returns the error code of a gRPC status code, its name in upper snake case such as
+// "INVALID_ARGUMENT".
+func Code(code codes.Code) string {
+	name := code.String()
+
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(name[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// Write answers the request with the problem details of a failure described by a gRPC status
+// code and a human-readable detail. The HTTP status is derived from the code.
+func Write(w http.ResponseWriter, r *http.Request, code codes.Code, detail string) {
+	WriteStatus(w, r, HTTPStatus(code), code, detail)
+}
+
+// WriteStatus is like Write but sets the given HTTP status, for failures that HTTP describes
+// more precisely than gRPC, such as 405 Method Not Allowed.
+func WriteStatus(w http.ResponseWriter, r *http.Request, status int, code codes.Code, detail string) {
+	details := Details{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      Code(code),
+		RequestID: requestid.FromContext(r.Context()),
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(&details); err != nil {
+		logger.FromContext(r.Context()).Error("unable to write error response", zap.Error(err))
+	}
+}
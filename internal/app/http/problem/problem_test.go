@@ -0,0 +1,87 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/problem"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCode(t *testing.T) {
+	tests := map[codes.Code]string{
+		codes.OK:                 "OK",
+		codes.InvalidArgument:    "INVALID_ARGUMENT",
+		codes.NotFound:           "NOT_FOUND",
+		codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+		codes.FailedPrecondition: "FAILED_PRECONDITION",
+		codes.Unauthenticated:    "UNAUTHENTICATED",
+		codes.Internal:           "INTERNAL",
+	}
+
+	for code, want := range tests {
+		assert.Equal(t, want, problem.Code(code))
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := map[codes.Code]int{
+		codes.InvalidArgument:  http.StatusBadRequest,
+		codes.NotFound:         http.StatusNotFound,
+		codes.AlreadyExists:    http.StatusConflict,
+		codes.PermissionDenied: http.StatusForbidden,
+		codes.Unauthenticated:  http.StatusUnauthorized,
+		codes.Unavailable:      http.StatusServiceUnavailable,
+		codes.Internal:         http.StatusInternalServerError,
+		codes.Code(99):         http.StatusInternalServerError,
+	}
+
+	for code, want := range tests {
+		assert.Equal(t, want, problem.HTTPStatus(code), "code %v", code)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls/abc/rules", nil)
+	req = req.WithContext(requestid.NewContext(req.Context(), "req-1"))
+	rec := httptest.NewRecorder()
+
+	problem.Write(rec, req, codes.NotFound, "URL not found")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+
+	var details problem.Details
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&details))
+	assert.Equal(t, problem.Details{
+		Type:      "about:blank",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "URL not found",
+		Instance:  "/api/user/urls/abc/rules",
+		Code:      "NOT_FOUND",
+		RequestID: "req-1",
+	}, details)
+}
+
+func TestWriteStatus(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/api/shorten", nil)
+	rec := httptest.NewRecorder()
+
+	problem.WriteStatus(rec, req, http.StatusMethodNotAllowed, codes.Unimplemented, "")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "Method Not Allowed", body["title"])
+	assert.Equal(t, "UNIMPLEMENTED", body["code"])
+	assert.NotContains(t, body, "detail")
+	assert.NotContains(t, body, "request_id")
+}
@@ -0,0 +1,83 @@
+// Package templates renders the HTML pages served by the URL shortener.
+//
+// Page templates are embedded into the binary and parsed once at startup. Every page
+// is combined with the shared "layout" template, which defines the document skeleton
+// and the "title" and "content" blocks that pages override. Rendering uses html/template,
+// so all values are contextually escaped and unsafe URLs are neutralised.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ContentTypeHTML defines the Content-Type for HTML responses.
+const ContentTypeHTML = "text/html; charset=utf-8"
+
+// Page names that can be passed to Render.
+const (
+	PagePreview = "preview"
+)
+
+//go:embed html/*.html
+var files embed.FS
+
+// layoutFile holds the shared document skeleton combined with every page.
+const layoutFile = "html/layout.html"
+
+// pages maps a page name to its parsed template set.
+var pages = mustParsePages()
+
+// PreviewData is the data rendered by the link preview page.
+type PreviewData struct {
+	Title       string // Owner-supplied link title
+	Description string // Owner-supplied link description
+	Destination string // URL the visitor will be redirected to
+	ContinueURL string // URL of the "continue" button
+}
+
+// Render executes the named page template and writes the result to w.
+//
+// The page is rendered into a buffer first, so nothing is written when rendering fails.
+func Render(w io.Writer, name string, data any) error {
+	page, ok := pages[name]
+	if !ok {
+		return fmt.Errorf("unknown page %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := page.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return err
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// mustParsePages parses every embedded page together with the layout.
+func mustParsePages() map[string]*template.Template {
+	layout := template.Must(template.ParseFS(files, layoutFile))
+
+	names, err := fs.Glob(files, "html/*.html")
+	if err != nil {
+		panic(err)
+	}
+
+	result := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		if name == layoutFile {
+			continue
+		}
+
+		page := template.Must(template.Must(layout.Clone()).ParseFS(files, name))
+		result[strings.TrimSuffix(path.Base(name), ".html")] = page
+	}
+
+	return result
+}
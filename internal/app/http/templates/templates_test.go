@@ -0,0 +1,46 @@
+package templates_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderPreview(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := templates.Render(&buf, templates.PagePreview, templates.PreviewData{
+		Title:       "Spring <sale>",
+		Description: "Everything & more",
+		Destination: "https://example.com/?a=1&b=2",
+		ContinueURL: "/abc",
+	})
+
+	assert.NoError(t, err)
+	body := buf.String()
+	assert.Contains(t, body, "<title>Spring &lt;sale&gt;</title>")
+	assert.Contains(t, body, "Everything &amp; more")
+	assert.Contains(t, body, "https://example.com/?a=1&amp;b=2")
+	assert.Contains(t, body, `href="/abc"`)
+}
+
+func TestRenderPreview_UnsafeURL(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := templates.Render(&buf, templates.PagePreview, templates.PreviewData{
+		Destination: "javascript:alert(1)",
+		ContinueURL: "javascript:alert(1)",
+	})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, buf.String(), `href="javascript:`)
+}
+
+func TestRenderUnknownPage(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.Error(t, templates.Render(&buf, "missing", nil))
+	assert.Zero(t, buf.Len())
+}
@@ -0,0 +1,29 @@
+package lifecycle
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// GRPCServer returns a stage stopping server gracefully: it stops accepting connections and
+// waits for in-flight RPCs to finish. RPCs still running when ctx is done are cancelled and
+// ctx's error is returned.
+func GRPCServer(server *grpc.Server) StopFunc {
+	return func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			server.Stop()
+			<-stopped
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Package lifecycle shuts the URL shortener service down in stages.
+//
+// Stages are registered on a Manager in the order they must run: typically the servers stop
+// accepting work and drain in-flight requests first, then background queues are drained, and
+// storage is flushed and closed last. Shutdown runs every stage once under a shared deadline, so
+// that a slow or failing stage does not keep later stages, such as closing storage, from running.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"go.uber.org/zap"
+)
+
+// ErrShuttingDown is reported by Manager.Check once the shutdown has started.
+var ErrShuttingDown = errors.New("shutting down")
+
+// StopFunc stops one component. It should return once the component is stopped or ctx is done.
+type StopFunc func(ctx context.Context) error
+
+// stage is a named step of the shutdown.
+type stage struct {
+	name string
+	stop StopFunc
+}
+
+// Manager runs the shutdown stages in the order they were added.
+type Manager struct {
+	mu       sync.Mutex
+	stages   []stage
+	stopping atomic.Bool
+	once     sync.Once
+	err      error
+}
+
+// New creates a manager without stages.
+func New() *Manager {
+	return &Manager{}
+}
+
+// Add appends a stage to the shutdown.
+func (m *Manager) Add(name string, stop StopFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stages = append(m.stages, stage{name: name, stop: stop})
+}
+
+// Shutdown runs the stages in order and returns the errors of the failed ones. A failed stage
+// does not keep later stages from running. Stages share ctx, so its deadline bounds the whole
+// shutdown. Only the first call runs the stages; later calls return its result.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.once.Do(func() {
+		m.stopping.Store(true)
+
+		m.mu.Lock()
+		stages := m.stages
+		m.mu.Unlock()
+
+		var errs []error
+		for _, s := range stages {
+			start := time.Now()
+			if err := s.stop(ctx); err != nil {
+				logger.Default().Error("shutdown stage failed", zap.String("stage", s.name), zap.Error(err))
+				errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+				continue
+			}
+			logger.Default().Info("shutdown stage done", zap.String("stage", s.name), zap.Duration("duration", time.Since(start)))
+		}
+		m.err = errors.Join(errs...)
+	})
+
+	return m.err
+}
+
+// Check returns ErrShuttingDown once the shutdown has started. It is meant to be registered as
+// a readiness check, so that load balancers stop sending traffic while the service drains.
+func (m *Manager) Check(_ context.Context) error {
+	if m.stopping.Load() {
+		return ErrShuttingDown
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	m := lifecycle.New()
+
+	var order []string
+	stage := func(name string, err error) lifecycle.StopFunc {
+		return func(context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	m.Add("http", stage("http", nil))
+	m.Add("deletes", stage("deletes", errors.New("2 requests left")))
+	m.Add("storage", stage("storage", nil))
+
+	assert.NoError(t, m.Check(context.Background()))
+
+	err := m.Shutdown(context.Background())
+
+	// A failed stage does not keep later stages from running
+	assert.Equal(t, []string{"http", "deletes", "storage"}, order)
+	assert.EqualError(t, err, "deletes: 2 requests left")
+	assert.ErrorIs(t, m.Check(context.Background()), lifecycle.ErrShuttingDown)
+
+	// Stages run once
+	assert.Equal(t, err, m.Shutdown(context.Background()))
+	assert.Len(t, order, 3)
+}
+
+func TestGRPCServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, grpchealth.NewServer())
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Watch keeps an RPC in flight until the server stops.
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = lifecycle.GRPCServer(server)(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = stream.Recv()
+	assert.Error(t, err, "in-flight RPC is cancelled once the deadline passes")
+}
+
+func TestGRPCServer_Idle(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	go server.Serve(listener)
+
+	assert.NoError(t, lifecycle.GRPCServer(server)(context.Background()))
+}
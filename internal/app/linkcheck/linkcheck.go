@@ -0,0 +1,158 @@
+// Package linkcheck checks whether the destinations of short links are still reachable.
+//
+// A Checker issues a HEAD request to each destination and falls back to GET when
+// the server does not support HEAD. Checks run concurrently up to a global limit,
+// while requests to the same host are sent one at a time with a pause in between,
+// so that a user with many links to one site does not flood it. Requests go
+// through the same SSRF-safe client as the page fetcher.
+package linkcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+// Default limits of a Checker.
+const (
+	DefaultConcurrency = 8
+	DefaultHostDelay   = time.Second
+)
+
+// Options configures a Checker. Zero values select the defaults.
+type Options struct {
+	Concurrency int             // Maximum number of checks in flight
+	HostDelay   time.Duration   // Pause between two requests to the same host
+	Client      fetcher.Options // Timeout, redirect limit and address restrictions of requests
+}
+
+// Result is the outcome of checking one link.
+type Result struct {
+	ShortURL string             // Short URL of the checked link
+	Health   storage.LinkHealth // Outcome of the check
+}
+
+// Checker checks destinations of links.
+type Checker struct {
+	client      *http.Client
+	concurrency int
+	hostDelay   time.Duration
+}
+
+// New creates a Checker with the given options.
+func New(opts Options) *Checker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.HostDelay <= 0 {
+		opts.HostDelay = DefaultHostDelay
+	}
+
+	return &Checker{
+		client:      fetcher.NewHTTPClient(opts.Client),
+		concurrency: opts.Concurrency,
+		hostDelay:   opts.HostDelay,
+	}
+}
+
+// CheckAll checks the destinations of the links and calls report for each result.
+// report may be called concurrently. CheckAll returns when all links are checked
+// or ctx is done.
+func (c *Checker) CheckAll(ctx context.Context, urls []storage.URL, report func(Result)) {
+	byHost := make(map[string][]storage.URL)
+	for _, u := range urls {
+		host := ""
+		if parsed, err := url.Parse(u.OriginalURL); err == nil {
+			host = strings.ToLower(parsed.Hostname())
+		}
+		byHost[host] = append(byHost[host], u)
+	}
+
+	slots := make(chan struct{}, c.concurrency)
+	var wg sync.WaitGroup
+
+	for _, links := range byHost {
+		wg.Add(1)
+
+		go func(links []storage.URL) {
+			defer wg.Done()
+
+			for i, link := range links {
+				if i > 0 && !sleep(ctx, c.hostDelay) {
+					return
+				}
+
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				health := c.Check(ctx, link.OriginalURL)
+				<-slots
+
+				if ctx.Err() != nil {
+					return
+				}
+				report(Result{ShortURL: link.ShortURL, Health: health})
+			}
+		}(links)
+	}
+
+	wg.Wait()
+}
+
+// Check checks a single destination.
+func (c *Checker) Check(ctx context.Context, destination string) storage.LinkHealth {
+	status, err := c.request(ctx, http.MethodHead, destination)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.request(ctx, http.MethodGet, destination)
+	}
+
+	health := storage.LinkHealth{Status: status, CheckedAt: time.Now().UTC()}
+	if err != nil {
+		health.Status = 0
+		health.Error = err.Error()
+	}
+
+	return health
+}
+
+// request sends a single request and returns the final status code.
+func (c *Checker) request(ctx context.Context, method string, destination string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Read a little of the body so that the connection can be reused
+	_, _ = io.CopyN(io.Discard, resp.Body, 4<<10)
+
+	return resp.StatusCode, nil
+}
+
+// sleep waits for d and reports whether ctx is still active.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,114 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/missing", http.StatusMovedPermanently)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	checker := New(Options{Client: fetcher.Options{AllowPrivate: true}})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		broken bool
+	}{
+		{"Reachable", server.URL + "/ok", http.StatusOK, false},
+		{"Not found", server.URL + "/missing", http.StatusNotFound, true},
+		{"GET fallback when HEAD is not allowed", server.URL + "/nohead", http.StatusOK, false},
+		{"Redirects are followed", server.URL + "/moved", http.StatusNotFound, true},
+		{"Unreachable", closed.URL + "/ok", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := checker.Check(ctx, tt.url)
+
+			assert.Equal(t, tt.status, health.Status)
+			assert.Equal(t, tt.broken, health.Broken())
+			assert.False(t, health.CheckedAt.IsZero())
+			if tt.status == 0 {
+				assert.NotEmpty(t, health.Error)
+			}
+		})
+	}
+
+	t.Run("Private addresses are refused by default", func(t *testing.T) {
+		health := New(Options{}).Check(ctx, server.URL+"/ok")
+
+		assert.True(t, health.Broken())
+		assert.Contains(t, health.Error, fetcher.ErrForbiddenAddress.Error())
+	})
+}
+
+func TestCheckAllHostPoliteness(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	delay := 30 * time.Millisecond
+	checker := New(Options{Concurrency: 4, HostDelay: delay, Client: fetcher.Options{AllowPrivate: true}})
+
+	urls := []storage.URL{
+		{ShortURL: "a", OriginalURL: server.URL + "/a"},
+		{ShortURL: "b", OriginalURL: server.URL + "/b"},
+		{ShortURL: "c", OriginalURL: server.URL + "/c"},
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]storage.LinkHealth)
+
+	start := time.Now()
+	checker.CheckAll(context.Background(), urls, func(result Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.ShortURL] = result.Health
+	})
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, int32(1), maxInFlight.Load(), "one request at a time per host")
+	assert.GreaterOrEqual(t, time.Since(start), 2*delay, "pause between requests to the same host")
+	for _, health := range results {
+		assert.Equal(t, http.StatusOK, health.Status)
+	}
+}
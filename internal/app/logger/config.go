@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Supported values of Options.Format.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Options configures the application logger built by New.
+type Options struct {
+	Level    string    // Lowest logged level: "debug", "info", "warn" or "error"; empty selects "info"
+	Format   string    // FormatJSON or FormatConsole; empty selects FormatJSON
+	Sampling int       // Identical entries logged per second before only every Sampling-th is; zero or less disables sampling
+	Output   io.Writer // Destination of the entries; standard error when nil
+}
+
+// stderr writes to the standard error the process has at the time of writing, so that loggers
+// built before it is redirected follow the redirect.
+type stderr struct{}
+
+// Write writes p to os.Stderr.
+func (stderr) Write(p []byte) (int, error) {
+	return os.Stderr.Write(p)
+}
+
+// New builds a logger with the given options.
+func New(opts Options) (*zap.Logger, error) {
+	level := zapcore.InfoLevel
+	if opts.Level != "" {
+		var err error
+		if level, err = zapcore.ParseLevel(opts.Level); err != nil {
+			return nil, err
+		}
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch opts.Format {
+	case "", FormatJSON:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case FormatConsole:
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	var output io.Writer = stderr{}
+	if opts.Output != nil {
+		output = opts.Output
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(output)), level)
+	if opts.Sampling > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, opts.Sampling, opts.Sampling)
+	}
+
+	return zap.New(core, zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(zapcore.AddSync(stderr{})))), nil
+}
+
+// defaultLogger is the logger returned by Default.
+var defaultLogger atomic.Pointer[zap.Logger]
+
+func init() {
+	logger, _ := New(Options{Level: "debug", Format: FormatConsole})
+	defaultLogger.Store(logger)
+}
+
+// Default returns the application logger. Until SetDefault is called, it is a console logger
+// writing every level to standard error.
+func Default() *zap.Logger {
+	return defaultLogger.Load()
+}
+
+// SetDefault replaces the application logger.
+func SetDefault(logger *zap.Logger) {
+	defaultLogger.Store(logger)
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
+	"go.uber.org/zap"
+)
+
+// scope holds the fields of a request, shared by all contexts derived from the one it was
+// added to, so that fields added by inner middleware also appear in the final request log.
+type scope struct {
+	mu     sync.Mutex
+	fields []zap.Field
+}
+
+// scopeKey is the context key of the request scope.
+type scopeKey struct{}
+
+// WithFields returns a context whose logger carries the given fields in addition to those
+// of the request. It starts a new request scope when ctx has none.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.mu.Lock()
+		s.fields = append(s.fields, fields...)
+		s.mu.Unlock()
+		return ctx
+	}
+
+	return context.WithValue(ctx, scopeKey{}, &scope{fields: fields})
+}
+
+// FromContext returns the application logger with the request ID and fields of the request
+// in ctx and, for HTTP requests, the chi route pattern.
+func FromContext(ctx context.Context) *zap.Logger {
+	logger := Default()
+
+	if id := requestid.FromContext(ctx); id != "" {
+		logger = logger.With(zap.String("request_id", id))
+	}
+
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.mu.Lock()
+		logger = logger.With(s.fields...)
+		s.mu.Unlock()
+	}
+
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		logger = logger.With(zap.String("route", rctx.RoutePattern()))
+	}
+
+	return logger
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor is the gRPC counterpart of LoggingWrapper for unary calls. It starts
+// the request scope with the method name and logs the method,
+// status code and duration of every call.
+func UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+	ctx = withCallFields(ctx, info.FullMethod)
+
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// loggingServerStream passes the context holding the request scope to stream handlers.
+type loggingServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context holding the request scope.
+func (s *loggingServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor is the gRPC counterpart of LoggingWrapper for streaming calls.
+func StreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	ctx := withCallFields(ss.Context(), info.FullMethod)
+
+	err := handler(srv, &loggingServerStream{ServerStream: ss, ctx: ctx})
+	logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// withCallFields starts the request scope of a gRPC call.
+func withCallFields(ctx context.Context, method string) context.Context {
+	return WithFields(ctx, zap.String("grpc_method", method))
+}
+
+// logCall logs a finished gRPC call.
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	FromContext(ctx).Sugar().Infoln(
+		"method", method,
+		"code", status.Code(err),
+		"duration", time.Since(start),
+	)
+}
@@ -1,15 +1,15 @@
-// Package logger provides middleware for HTTP logging.
+// Package logger provides the application logger and middleware for HTTP and gRPC logging.
 //
-// It includes functionality to log HTTP request and response data such as status codes,
-// sizes, methods, and durations using the zap structured logging library.
+// The application logger is built once at startup by New and installed with SetDefault. Request
+// handling code obtains it with FromContext, which adds the fields of the current request, such
+// as its request ID, user ID and route. It includes functionality to log HTTP request and
+// response data such as status codes, sizes, methods, and durations using the zap structured
+// logging library.
 package logger
 
 import (
-	"log"
 	"net/http"
 	"time"
-
-	"go.uber.org/zap"
 )
 
 type (
@@ -43,6 +43,9 @@ type (
 // Returns:
 //   - The number of bytes written and any error encountered.
 func (r *loggingResponseWriter) Write(b []byte) (int, error) {
+	if r.responseData.status == 0 {
+		r.responseData.status = http.StatusOK
+	}
 	size, err := r.ResponseWriter.Write(b)
 	r.responseData.size += size
 	return size, err
@@ -54,20 +57,26 @@ func (r *loggingResponseWriter) Write(b []byte) (int, error) {
 //   - statusCode: The HTTP status code to set in the response.
 func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.ResponseWriter.WriteHeader(statusCode)
-	log.Println("status", statusCode)
-	r.responseData.status = statusCode
+	if r.responseData.status == 0 {
+		r.responseData.status = statusCode
+	}
+}
+
+// Unwrap returns the original `ResponseWriter`, giving `http.ResponseController` access
+// to optional interfaces such as flushing.
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
 }
 
 // LoggingWrapper is middleware for logging HTTP requests and responses.
 //
-// It logs the following data for each request:
+// It starts the request scope of the default logger, so that everything logged while handling
+// the request through FromContext carries the same fields, and logs the following data once the request is handled:
 //   - The URI of the request.
 //   - The HTTP method used.
-//   - The duration of the request processing.
 //   - The status code returned.
 //   - The size of the response body.
-//
-// It uses the zap structured logging library to log this data.
+//   - The duration of the request processing.
 //
 // Parameters:
 //   - h: The `http.Handler` to wrap.
@@ -76,15 +85,8 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 //   - An `http.Handler` that logs HTTP request and response metadata.
 func LoggingWrapper(h http.Handler) http.Handler {
 	logFn := func(w http.ResponseWriter, r *http.Request) {
-		logger, err := zap.NewDevelopment()
-		if err != nil {
-			log.Printf("error ocured while creating zap logger: %v", err)
-		}
-		defer logger.Sync()
-
-		sugar := *logger.Sugar()
-
 		start := time.Now()
+		ctx := WithFields(r.Context())
 
 		responseData := &responseData{
 			status: 0,
@@ -95,15 +97,15 @@ func LoggingWrapper(h http.Handler) http.Handler {
 			responseData:   responseData,
 		}
 
-		sugar.Infoln(
-			"uri", r.RequestURI,
-			"method", r.Method,
-			"duration", time.Since(start),
-		)
+		h.ServeHTTP(&lw, r.WithContext(ctx))
 
-		h.ServeHTTP(&lw, r)
+		if responseData.status == 0 {
+			responseData.status = http.StatusOK
+		}
 
-		sugar.Infoln(
+		FromContext(ctx).Sugar().Infoln(
+			"uri", r.RequestURI,
+			"method", r.Method,
 			"status", responseData.status,
 			"size", responseData.size,
 			"duration", time.Since(start),
@@ -2,14 +2,24 @@ package logger_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/go-chi/chi"
 	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestLoggingWrapper(t *testing.T) {
@@ -47,3 +57,158 @@ func TestLoggingWrapper(t *testing.T) {
 	assert.Contains(t, logOutput, "size 2")
 	assert.Contains(t, logOutput, "duration")
 }
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     logger.Options
+		wantErr  bool
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "json",
+			opts:     logger.Options{Format: logger.FormatJSON},
+			contains: []string{`"level":"info"`, `"msg":"info entry"`, `"key":"value"`},
+			excludes: []string{"debug entry"},
+		},
+		{
+			name:     "console",
+			opts:     logger.Options{Format: logger.FormatConsole, Level: "debug"},
+			contains: []string{"INFO", "info entry", `{"key": "value"}`, "DEBUG", "debug entry"},
+		},
+		{
+			name:     "level",
+			opts:     logger.Options{Level: "error"},
+			excludes: []string{"info entry", "debug entry"},
+		},
+		{
+			name:    "unknown format",
+			opts:    logger.Options{Format: "xml"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown level",
+			opts:    logger.Options{Level: "loud"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.opts.Output = &buf
+
+			l, err := logger.New(tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			l.Info("info entry", zap.String("key", "value"))
+			l.Debug("debug entry")
+
+			for _, s := range tt.contains {
+				assert.Contains(t, buf.String(), s)
+			}
+			for _, s := range tt.excludes {
+				assert.NotContains(t, buf.String(), s)
+			}
+		})
+	}
+}
+
+func TestNew_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := logger.New(logger.Options{Sampling: 2, Output: &buf})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		l.Info("repeated entry")
+	}
+
+	// The first 2 entries are written, then every 2nd of the rest
+	assert.Equal(t, 6, strings.Count(buf.String(), "repeated entry"))
+}
+
+// useLogger makes a JSON logger writing to the returned buffer the default for the test.
+func useLogger(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	l, err := logger.New(logger.Options{Level: "debug", Output: &buf})
+	require.NoError(t, err)
+
+	previous := logger.Default()
+	logger.SetDefault(l)
+	t.Cleanup(func() { logger.SetDefault(previous) })
+
+	return &buf
+}
+
+func TestFromContext(t *testing.T) {
+	buf := useLogger(t)
+
+	r := chi.NewRouter()
+	r.Use(requestid.Middleware, logger.LoggingWrapper)
+	r.Get("/links/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.WithFields(r.Context(), zap.String("user_id", "user-1"))
+		logger.FromContext(r.Context()).Info("handler entry")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/links/abc", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var entry, access map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+
+	assert.Equal(t, "handler entry", entry["msg"])
+	assert.Equal(t, "/links/{id}", entry["route"])
+	assert.Equal(t, "user-1", entry["user_id"])
+	assert.Equal(t, "req-1", entry["request_id"])
+
+	// Fields added by the handler also appear in the access log of the request
+	assert.Equal(t, "req-1", access["request_id"])
+	assert.Equal(t, "user-1", access["user_id"])
+	assert.Equal(t, "/links/{id}", access["route"])
+}
+
+func TestFromContext_WithoutScope(t *testing.T) {
+	buf := useLogger(t)
+
+	logger.FromContext(context.Background()).Info("plain entry")
+
+	assert.Contains(t, buf.String(), `"msg":"plain entry"`)
+	assert.NotContains(t, buf.String(), "request_id")
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	buf := useLogger(t)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/shortener.Shortener/GetURL"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		logger.FromContext(ctx).Info("handler entry")
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	_, err := logger.UnaryServerInterceptor(ctx, nil, info, handler)
+	require.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "/shortener.Shortener/GetURL", entry["grpc_method"])
+	assert.Equal(t, "req-1", entry["request_id"])
+
+	assert.Contains(t, lines[1], "code NotFound")
+	assert.Contains(t, lines[1], `"request_id":"req-1"`)
+}
@@ -0,0 +1,149 @@
+// Package metrics collects runtime metrics of the URL shortener service and exposes them in the
+// Prometheus text exposition format.
+//
+// # Collected Metrics
+//
+//   - HTTP requests per chi route pattern, counted by status code and timed, see HTTPMiddleware.
+//   - gRPC calls per method, counted by status code and timed, see UnaryServerInterceptor and
+//     StreamServerInterceptor.
+//   - Latencies and failures of storage operations per backend, see ObserveStorageOperation.
+//   - Depth of the delete queue and cache counters, see RegisterDeleteQueue and RegisterCache.
+//   - Go runtime and process statistics.
+//
+// All metrics are registered in Registry and served by Handler, which is meant to be mounted
+// on an admin listener rather than on the public API.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of all metrics of the service.
+const namespace = "shorturl"
+
+// Registry holds all metrics of the service.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method and chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC calls by full method name and status code.",
+	}, []string{"method", "code"})
+
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of gRPC calls by full method name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of storage operations by backend and operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"backend", "operation"})
+
+	storageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_errors_total",
+		Help:      "Failed storage operations by backend and operation.",
+	}, []string{"backend", "operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		grpcRequests,
+		grpcDuration,
+		storageDuration,
+		storageErrors,
+	)
+}
+
+// Handler serves the metrics in Registry in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveStorageOperation records a storage call. It is a storage.OperationObserver.
+func ObserveStorageOperation(backend, operation string, duration time.Duration, failed bool) {
+	storageDuration.WithLabelValues(backend, operation).Observe(duration.Seconds())
+	if failed {
+		storageErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
+
+var _ storage.OperationObserver = ObserveStorageOperation
+
+// RegisterDeleteQueue exposes the number of deletion requests waiting in the queue.
+func RegisterDeleteQueue(depth func() int) error {
+	return Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "delete_queue_depth",
+		Help:      "Deletion requests waiting to be processed.",
+	}, func() float64 {
+		return float64(depth())
+	}))
+}
+
+// RegisterCache exposes the counters of the link cache.
+func RegisterCache(cache *storage.CachedStore) error {
+	return Registry.Register(cacheCollector{cache: cache})
+}
+
+var (
+	cacheHitsDesc = prometheus.NewDesc(namespace+"_cache_hits_total",
+		"Link lookups served from the cache.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc(namespace+"_cache_misses_total",
+		"Link lookups passed to the storage.", nil, nil)
+	cacheEntriesDesc = prometheus.NewDesc(namespace+"_cache_entries",
+		"Links and misses currently cached.", nil, nil)
+	cacheHitRatioDesc = prometheus.NewDesc(namespace+"_cache_hit_ratio",
+		"Share of link lookups served from the cache since the start.", nil, nil)
+)
+
+// cacheCollector reads all cache metrics from a single snapshot of the counters.
+type cacheCollector struct {
+	cache *storage.CachedStore
+}
+
+// Describe sends the descriptors of the cache metrics.
+func (c cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheEntriesDesc
+	ch <- cacheHitRatioDesc
+}
+
+// Collect sends the current values of the cache metrics.
+func (c cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(cacheHitRatioDesc, prometheus.GaugeValue, stats.HitRatio())
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPMiddleware_RoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(HTTPMiddleware)
+	r.Get("/{id}/qr", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	for _, path := range []string{"/abc/qr", "/def/qr", "/ok", "/missing/path"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/{id}/qr", "418")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/ok", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/shortener.Shortener/Test"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+
+	_, err := UnaryServerInterceptor(context.Background(), nil, info, handler)
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(grpcRequests.WithLabelValues(info.FullMethod, codes.NotFound.String())))
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/shortener.Shortener/TestStream"}
+	handler := func(srv interface{}, stream grpc.ServerStream) error { return nil }
+
+	require.NoError(t, StreamServerInterceptor(nil, nil, info, handler))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(grpcRequests.WithLabelValues(info.FullMethod, codes.OK.String())))
+}
+
+func TestObserveStorageOperation(t *testing.T) {
+	ObserveStorageOperation("test", "get_url", time.Millisecond, false)
+	ObserveStorageOperation("test", "get_url", time.Millisecond, true)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(storageErrors.WithLabelValues("test", "get_url")))
+	assert.Equal(t, 1, testutil.CollectAndCount(storageDuration, namespace+"_storage_operation_duration_seconds"))
+}
+
+func TestHandler(t *testing.T) {
+	cache := storage.NewCachedStore(storage.NewMemoryStore(), storage.CacheOptions{Size: 10})
+	_, _ = cache.GetURL(context.Background(), "unknown")
+	_, _ = cache.GetURL(context.Background(), "unknown")
+
+	require.NoError(t, RegisterCache(cache))
+	require.NoError(t, RegisterDeleteQueue(func() int { return 3 }))
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, line := range []string{
+		"shorturl_cache_hits_total 1",
+		"shorturl_cache_misses_total 1",
+		"shorturl_cache_hit_ratio 0.5",
+		"shorturl_delete_queue_depth 3",
+		"go_goroutines ",
+	} {
+		assert.True(t, strings.Contains(body, line), "missing %q", line)
+	}
+
+	// A second queue cannot be registered under the same name
+	assert.Error(t, RegisterDeleteQueue(func() int { return 0 }))
+}
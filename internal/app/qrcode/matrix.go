@@ -0,0 +1,294 @@
+package qrcode
+
+// Penalty weights of the mask evaluation rules.
+const (
+	penaltyRun     = 3  // Five or more same-coloured modules in a line
+	penaltyBlock   = 3  // Each 2x2 block of one colour
+	penaltyFinder  = 40 // Each finder-like 1:1:3:1:1 pattern next to four light modules
+	penaltyBalance = 10 // Each 5% deviation of the dark module share from 50%
+)
+
+// BCH code parameters of the format and version information.
+const (
+	formatGenerator  = 0x537
+	formatMask       = 0x5412
+	versionGenerator = 0x1F25
+)
+
+// newCode returns a symbol of the version with all function patterns drawn.
+func newCode(version int, level Level) *Code {
+	size := version*4 + 17
+	code := &Code{Version: version, Level: level, size: size}
+
+	code.modules = make([][]bool, size)
+	function := make([][]bool, size)
+	for y := range code.modules {
+		code.modules[y] = make([]bool, size)
+		function[y] = make([]bool, size)
+	}
+
+	set := func(x, y int, dark bool) {
+		code.modules[y][x] = dark
+		function[y][x] = true
+	}
+
+	// Timing patterns
+	for i := 0; i < size; i++ {
+		set(6, i, i%2 == 0)
+		set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their separators
+	for _, centre := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := centre[0]+dx, centre[1]+dy
+				if x >= 0 && y >= 0 && x < size && y < size {
+					dist := max(abs(dx), abs(dy))
+					set(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+
+	// Alignment patterns, except where they would overlap the finder patterns
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, cy := range positions {
+		for j, cx := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information area; the bits are written with the mask
+	code.drawFormat(0, set)
+
+	if version >= 7 {
+		bits := versionBits(version)
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 != 0
+			a, b := size-11+i%3, i/3
+			set(a, b, dark)
+			set(b, a, dark)
+		}
+	}
+
+	code.function = function
+
+	return code
+}
+
+// formatBits returns the 15 bit format information of the level and mask, BCH encoded and masked.
+func formatBits(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * formatGenerator)
+	}
+
+	return (data<<10 | rem) ^ formatMask
+}
+
+// versionBits returns the 18 bit BCH encoded version information.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * versionGenerator)
+	}
+
+	return version<<12 | rem
+}
+
+// drawFormat writes both copies of the format information for the mask, and the dark module.
+func (c *Code) drawFormat(mask int, set func(x, y int, dark bool)) {
+	bits := formatBits(c.Level, mask)
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	// Around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		set(8, i, bit(i))
+	}
+	set(8, 7, bit(6))
+	set(8, 8, bit(7))
+	set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		set(14-i, 8, bit(i))
+	}
+
+	// Split between the other two finder patterns
+	for i := 0; i < 8; i++ {
+		set(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		set(8, c.size-15+i, bit(i))
+	}
+	set(8, c.size-8, true)
+}
+
+// drawCodewords places the codewords in the two-module-wide zigzag columns,
+// starting at the bottom right corner and skipping function modules.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// Skip the vertical timing pattern
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.size; vert++ {
+			y := vert
+			if upward {
+				y = c.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] {
+					continue
+				}
+				// Remainder bits beyond the codewords stay light
+				if i < len(codewords)*8 {
+					c.modules[y][x] = (codewords[i/8]>>(7-i%8))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// masked reports whether the mask pattern inverts the module at column x and row y.
+func masked(mask int, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask inverts the data modules selected by the mask and writes its format information.
+// Applying the same mask twice restores the data modules.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.function[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+
+	c.drawFormat(mask, func(x, y int, dark bool) { c.modules[y][x] = dark })
+	c.Mask = mask
+}
+
+// applyBestMask applies the mask pattern with the lowest penalty score.
+func (c *Code) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.function = nil
+}
+
+// penalty scores the symbol by the four mask evaluation rules; lower is better.
+func (c *Code) penalty() int {
+	penalty := 0
+	dark := 0
+
+	for i := 0; i < c.size; i++ {
+		row := make([]bool, c.size)
+		column := make([]bool, c.size)
+		for j := 0; j < c.size; j++ {
+			row[j] = c.modules[i][j]
+			column[j] = c.modules[j][i]
+			if row[j] {
+				dark++
+			}
+		}
+		penalty += linePenalty(row) + linePenalty(column)
+	}
+
+	for y := 0; y < c.size-1; y++ {
+		for x := 0; x < c.size-1; x++ {
+			colour := c.modules[y][x]
+			if colour == c.modules[y][x+1] && colour == c.modules[y+1][x] && colour == c.modules[y+1][x+1] {
+				penalty += penaltyBlock
+			}
+		}
+	}
+
+	total := c.size * c.size
+	deviation := abs(dark*100/total - 50)
+	penalty += deviation / 5 * penaltyBalance
+
+	return penalty
+}
+
+// finderLike is the 1:1:3:1:1 dark-light ratio of a finder pattern followed by four light modules.
+var finderLike = []bool{true, false, true, true, true, false, true, false, false, false, false}
+
+// linePenalty scores a row or column for long runs and finder-like patterns.
+func linePenalty(line []bool) int {
+	penalty := 0
+
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += penaltyRun + run - 5
+		}
+		run = 1
+	}
+
+	for i := 0; i+len(finderLike) <= len(line); i++ {
+		forward, backward := true, true
+		for j, dark := range finderLike {
+			forward = forward && line[i+j] == dark
+			backward = backward && line[i+len(finderLike)-1-j] == dark
+		}
+		if forward {
+			penalty += penaltyFinder
+		}
+		if backward {
+			penalty += penaltyFinder
+		}
+	}
+
+	return penalty
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
@@ -0,0 +1,187 @@
+// Package qrcode encodes data as QR Code Model 2 symbols and renders them as PNG or SVG images.
+//
+// Data is always encoded in byte mode. The smallest version (symbol size) holding the
+// data at the requested error correction level is selected automatically, and the mask
+// pattern with the lowest penalty score is applied, as specified by ISO/IEC 18004.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is the error correction level of a symbol.
+type Level int
+
+// Error correction levels, recovering roughly 7%, 15%, 25% and 30% of the symbol.
+const (
+	Low Level = iota
+	Medium
+	Quartile
+	High
+)
+
+// ErrDataTooLong is returned when the data does not fit into the largest symbol at the requested level.
+var ErrDataTooLong = errors.New("data too long for a QR code")
+
+// ErrInvalidLevel is returned for an unknown error correction level.
+var ErrInvalidLevel = errors.New("invalid error correction level")
+
+// ParseLevel parses an error correction level given as "L", "M", "Q" or "H".
+// An empty string selects Medium.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "", "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+
+	return Medium, fmt.Errorf("%w: %q", ErrInvalidLevel, s)
+}
+
+// String returns the letter of the level.
+func (l Level) String() string {
+	switch l {
+	case Low:
+		return "L"
+	case Medium:
+		return "M"
+	case Quartile:
+		return "Q"
+	case High:
+		return "H"
+	}
+
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// formatBits returns the two bit level indicator of the format information.
+func (l Level) formatBits() int {
+	return [...]int{Low: 1, Medium: 0, Quartile: 3, High: 2}[l]
+}
+
+// Code is an encoded QR Code symbol.
+type Code struct {
+	Version int   // Symbol version between MinVersion and MaxVersion
+	Level   Level // Error correction level
+	Mask    int   // Applied mask pattern between 0 and 7
+
+	size     int      // Width and height in modules
+	modules  [][]bool // Dark modules, indexed by row and column
+	function [][]bool // Modules of function patterns while encoding, indexed by row and column
+}
+
+// Encode encodes the data in byte mode into the smallest symbol holding it at the level.
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidLevel, int(level))
+	}
+
+	version := MinVersion
+	for byteCapacity(version, level) < len(data) {
+		version++
+		if version > MaxVersion {
+			return nil, ErrDataTooLong
+		}
+	}
+
+	code := newCode(version, level)
+	code.drawCodewords(addErrorCorrection(encodeData(data, version, level), version, level))
+	code.applyBestMask()
+
+	return code, nil
+}
+
+// Size returns the width and height of the symbol in modules, without a quiet zone.
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark reports whether the module at column x and row y is dark.
+// Coordinates outside the symbol are light.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.size && y < c.size && c.modules[y][x]
+}
+
+// bitBuffer accumulates a sequence of bits.
+type bitBuffer []bool
+
+// append adds the lowest n bits of value, most significant bit first.
+func (b *bitBuffer) append(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 != 0)
+	}
+}
+
+// encodeData returns the data codewords of the symbol: the byte mode segment,
+// the terminator and the padding up to the capacity of the version.
+func encodeData(data []byte, version int, level Level) []byte {
+	capacity := dataCodewords(version, level) * 8
+
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	return codewords
+}
+
+// addErrorCorrection splits the data codewords into blocks, appends the error correction
+// codewords of each block and interleaves the blocks into the final codeword sequence.
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	blocks := eccBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	raw := rawCodewords(version)
+	shortBlocks := blocks - raw%blocks
+	shortDataLen := raw/blocks - eccLen
+
+	generator := rsGenerator(eccLen)
+	dataBlocks := make([][]byte, blocks)
+	corrections := make([][]byte, blocks)
+	for i, offset := 0, 0; i < blocks; i++ {
+		length := shortDataLen
+		if i >= shortBlocks {
+			length++
+		}
+		dataBlocks[i] = data[offset : offset+length]
+		corrections[i] = rsRemainder(dataBlocks[i], generator)
+		offset += length
+	}
+
+	result := make([]byte, 0, raw)
+	for i := 0; i <= shortDataLen; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for _, block := range corrections {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
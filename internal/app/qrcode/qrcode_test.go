@@ -0,0 +1,277 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteCapacity(t *testing.T) {
+	// Byte mode capacities from the specification
+	tests := []struct {
+		version  int
+		capacity [4]int
+	}{
+		{1, [4]int{17, 14, 11, 7}},
+		{2, [4]int{32, 26, 20, 14}},
+		{5, [4]int{106, 84, 60, 44}},
+		{10, [4]int{271, 213, 151, 119}},
+		{20, [4]int{858, 666, 482, 382}},
+		{40, [4]int{2953, 2331, 1663, 1273}},
+	}
+
+	for _, tt := range tests {
+		for level := Low; level <= High; level++ {
+			assert.Equal(t, tt.capacity[level], byteCapacity(tt.version, level), "version %d level %v", tt.version, level)
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	assert.Nil(t, alignmentPositions(1))
+	assert.Equal(t, []int{6, 18}, alignmentPositions(2))
+	assert.Equal(t, []int{6, 22, 38}, alignmentPositions(7))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPositions(32))
+	assert.Equal(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPositions(40))
+}
+
+func TestReedSolomon(t *testing.T) {
+	// Version 1-M "HELLO WORLD" example of the specification
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	assert.Equal(t, ecc, rsRemainder(data, rsGenerator(10)))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	assert.Equal(t, 0b111011111000100, formatBits(Low, 0))
+	assert.Equal(t, 0b101010000010010, formatBits(Medium, 0))
+	assert.Equal(t, 0b011010101011111, formatBits(Quartile, 0))
+	assert.Equal(t, 0b001011010001001, formatBits(High, 0))
+	assert.Equal(t, 0b100000011001110, formatBits(Medium, 5))
+
+	assert.Equal(t, 0x07C94, versionBits(7))
+	assert.Equal(t, 0x28C69, versionBits(40))
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		level   Level
+		version int
+	}{
+		{"Short link", "http://localhost:8080/abc123", Medium, 3},
+		{"Smallest symbol", "hello", High, 1},
+		{"Two blocks", strings.Repeat("a", 60), Quartile, 5},
+		{"Version information", strings.Repeat("b", 200), Low, 9},
+		{"Uneven blocks", strings.Repeat("c", 300), High, 18},
+		{"Long count indicator", strings.Repeat("d", 1000), Medium, 26},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode([]byte(tt.data), tt.level)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.version, code.Version)
+			assert.Equal(t, tt.version*4+17, code.Size())
+			assert.Equal(t, tt.data, string(readBack(t, code)))
+
+			// Finder pattern corners and the dark module
+			assert.True(t, code.Dark(0, 0))
+			assert.True(t, code.Dark(code.Size()-1, 0))
+			assert.True(t, code.Dark(0, code.Size()-1))
+			assert.False(t, code.Dark(7, 7))
+			assert.True(t, code.Dark(8, code.Size()-8))
+		})
+	}
+
+	t.Run("Too long", func(t *testing.T) {
+		_, err := Encode(make([]byte, 1274), High)
+		assert.ErrorIs(t, err, ErrDataTooLong)
+
+		code, err := Encode(make([]byte, 1273), High)
+		require.NoError(t, err)
+		assert.Equal(t, MaxVersion, code.Version)
+	})
+
+	t.Run("Invalid level", func(t *testing.T) {
+		_, err := Encode([]byte("x"), Level(7))
+		assert.ErrorIs(t, err, ErrInvalidLevel)
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, want := range map[string]Level{"": Medium, "l": Low, "M": Medium, "q": Quartile, "H": High} {
+		level, err := ParseLevel(input)
+		assert.NoError(t, err)
+		assert.Equal(t, want, level)
+	}
+
+	_, err := ParseLevel("X")
+	assert.ErrorIs(t, err, ErrInvalidLevel)
+}
+
+func TestRender(t *testing.T) {
+	code, err := Encode([]byte("http://localhost:8080/abc123"), Medium)
+	require.NoError(t, err)
+
+	t.Run("PNG", func(t *testing.T) {
+		data, err := code.Render(ImageOptions{Size: 300, Margin: DefaultMargin})
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		// 37 modules at 8 pixels each
+		assert.Equal(t, 296, img.Bounds().Dx())
+		assert.Equal(t, 296, img.Bounds().Dy())
+
+		dark := func(x, y int) bool {
+			r, _, _, _ := img.At(x, y).RGBA()
+			return r == 0
+		}
+		assert.False(t, dark(0, 0))
+		assert.True(t, dark(4*8, 4*8))
+		assert.Equal(t, code.Dark(10, 12), dark((4+10)*8+3, (4+12)*8+3))
+	})
+
+	t.Run("PNG without margin", func(t *testing.T) {
+		data, err := code.Render(ImageOptions{Format: FormatPNG, Size: 10})
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, code.Size(), img.Bounds().Dx())
+	})
+
+	t.Run("SVG", func(t *testing.T) {
+		data, err := code.Render(ImageOptions{Format: FormatSVG, Size: 512, Margin: 2})
+		require.NoError(t, err)
+
+		svg := string(data)
+		assert.Contains(t, svg, `width="512" height="512" viewBox="0 0 33 33"`)
+		assert.Contains(t, svg, "M2,2h1v1h-1z")
+		assert.Equal(t, darkModules(code), strings.Count(svg, "h1v1h-1z"))
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		for _, opts := range []ImageOptions{
+			{Format: "gif"},
+			{Size: MaxSize + 1},
+			{Size: -1},
+			{Margin: -1},
+			{Margin: MaxMargin + 1},
+		} {
+			_, err := code.Render(opts)
+			assert.ErrorIs(t, err, ErrInvalidImage, fmt.Sprintf("%+v", opts))
+		}
+	})
+}
+
+func darkModules(code *Code) int {
+	count := 0
+	for y := 0; y < code.Size(); y++ {
+		for x := 0; x < code.Size(); x++ {
+			if code.Dark(x, y) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// readBack decodes the byte mode data of an undamaged symbol, checking the format information
+// and the error correction codewords on the way.
+func readBack(t *testing.T, code *Code) []byte {
+	t.Helper()
+
+	// Format information next to the top left finder pattern, bits 14 to 0
+	var format int
+	for _, pos := range [][2]int{{0, 8}, {1, 8}, {2, 8}, {3, 8}, {4, 8}, {5, 8}, {7, 8}, {8, 8}, {8, 7}, {8, 5}, {8, 4}, {8, 3}, {8, 2}, {8, 1}, {8, 0}} {
+		format <<= 1
+		if code.Dark(pos[0], pos[1]) {
+			format |= 1
+		}
+	}
+	require.Equal(t, formatBits(code.Level, code.Mask), format)
+
+	// Read the modules in placement order, undoing the mask
+	function := newCode(code.Version, code.Level).function
+	var bits []bool
+	for right := code.Size() - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for i := 0; i < code.Size(); i++ {
+			y := i
+			if (right+1)&2 == 0 {
+				y = code.Size() - 1 - i
+			}
+			for _, x := range []int{right, right - 1} {
+				if !function[y][x] {
+					bits = append(bits, code.Dark(x, y) != masked(code.Mask, x, y))
+				}
+			}
+		}
+	}
+
+	raw := make([]byte, rawCodewords(code.Version))
+	for i := range raw {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				raw[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	// Deinterleave the blocks and verify their error correction codewords
+	blocks := eccBlocks[code.Level][code.Version]
+	eccLen := eccCodewordsPerBlock[code.Level][code.Version]
+	long := len(raw) % blocks
+	shortLen := len(raw)/blocks - eccLen
+	dataBlocks := make([][]byte, blocks)
+	offset := 0
+	for i := 0; i <= shortLen; i++ {
+		for b := range dataBlocks {
+			if i < shortLen || b >= blocks-long {
+				dataBlocks[b] = append(dataBlocks[b], raw[offset])
+				offset++
+			}
+		}
+	}
+
+	var data []byte
+	for b, block := range dataBlocks {
+		ecc := make([]byte, eccLen)
+		for i := range ecc {
+			ecc[i] = raw[offset+i*blocks+b]
+		}
+		require.Equal(t, rsRemainder(block, rsGenerator(eccLen)), ecc, "block %d", b)
+		data = append(data, block...)
+	}
+
+	// Byte mode segment
+	require.Equal(t, byte(0b0100), data[0]>>4)
+	var length, start int
+	if code.Version <= 9 {
+		length = int(data[0]&0x0F)<<4 | int(data[1]>>4)
+		start = 1
+	} else {
+		length = int(data[0]&0x0F)<<12 | int(data[1])<<4 | int(data[2]>>4)
+		start = 2
+	}
+
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = data[start+i]<<4 | data[start+i+1]>>4
+	}
+
+	return result
+}
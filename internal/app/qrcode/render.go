@@ -0,0 +1,140 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+)
+
+// Image formats.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Limits of rendered images.
+const (
+	DefaultSize   = 256  // Default width and height in pixels
+	MaxSize       = 4096 // Largest width and height in pixels
+	DefaultMargin = 4    // Quiet zone around the symbol in modules required by the specification
+	MaxMargin     = 32   // Largest quiet zone in modules
+)
+
+// ErrInvalidImage is returned when the image format, size or margin is not valid.
+var ErrInvalidImage = errors.New("invalid QR code image options")
+
+// ImageOptions describes how a symbol is rendered.
+type ImageOptions struct {
+	Format string // FormatPNG or FormatSVG; empty means FormatPNG
+	Size   int    // Width and height in pixels; zero means DefaultSize
+	Margin int    // Quiet zone in modules; callers pass DefaultMargin unless asked otherwise
+}
+
+// ContentType returns the media type of images in the format.
+func ContentType(format string) string {
+	if format == FormatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
+
+// Validate applies the defaults to the options and checks their limits.
+func (opts ImageOptions) Validate() (ImageOptions, error) {
+	if opts.Format == "" {
+		opts.Format = FormatPNG
+	}
+	if opts.Size == 0 {
+		opts.Size = DefaultSize
+	}
+
+	if opts.Format != FormatPNG && opts.Format != FormatSVG {
+		return opts, fmt.Errorf("%w: unknown format %q", ErrInvalidImage, opts.Format)
+	}
+	if opts.Size < 0 || opts.Size > MaxSize {
+		return opts, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidImage, MaxSize)
+	}
+	if opts.Margin < 0 || opts.Margin > MaxMargin {
+		return opts, fmt.Errorf("%w: margin must be between 0 and %d", ErrInvalidImage, MaxMargin)
+	}
+
+	return opts, nil
+}
+
+// Render renders the symbol as an image described by the options.
+func (c *Code) Render(opts ImageOptions) ([]byte, error) {
+	opts, err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Format == FormatSVG {
+		return c.SVG(opts.Size, opts.Margin), nil
+	}
+
+	return c.PNG(opts.Size, opts.Margin)
+}
+
+// scale returns the number of pixels per module fitting the symbol and its margin into size pixels.
+// Modules are never smaller than one pixel, so the image may exceed size for large symbols.
+func (c *Code) scale(size, margin int) int {
+	return max(1, size/(c.size+2*margin))
+}
+
+// PNG renders the symbol as a black-on-white PNG image at most size pixels wide,
+// using a whole number of pixels per module, with a quiet zone of margin modules.
+func (c *Code) PNG(size, margin int) ([]byte, error) {
+	scale := c.scale(size, margin)
+	width := (c.size + 2*margin) * scale
+
+	img := image.NewPaletted(image.Rect(0, 0, width, width), color.Palette{color.White, color.Black})
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				row := (margin+y)*scale + py
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex((margin+x)*scale+px, row, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG renders the symbol as an SVG image of size pixels with a quiet zone of margin modules.
+// Dark modules are drawn as a single path in a view box measured in modules, so the image scales freely.
+func (c *Code) SVG(size, margin int) []byte {
+	dimension := strconv.Itoa(c.size + 2*margin)
+	pixels := strconv.Itoa(size)
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="` + pixels + `" height="` + pixels +
+		`" viewBox="0 0 ` + dimension + ` ` + dimension + `" shape-rendering="crispEdges">` + "\n")
+	buf.WriteString(`<rect width="100%" height="100%" fill="#FFFFFF"/>` + "\n")
+	buf.WriteString(`<path fill="#000000" d="`)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&buf, "M%d,%dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+	buf.WriteString(`"/>` + "\n</svg>\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,69 @@
+package redirect
+
+import (
+	"net/url"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+// MergeQuery adds the link's query parameters to the destination URL.
+//
+// Parameters already present in the destination are never overwritten. Query parameters
+// given on the short URL are forwarded when the link enables pass-through, and take
+// precedence over the link's UTM template. The destination is returned unchanged if it
+// cannot be parsed or there is nothing to add.
+func MergeQuery(destination string, link storage.URL, requestQuery url.Values) string {
+	target, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	existing := target.Query()
+	extra := url.Values{}
+
+	add := func(key string, values ...string) {
+		if existing.Has(key) || extra.Has(key) {
+			return
+		}
+		for _, value := range values {
+			extra.Add(key, value)
+		}
+	}
+
+	if link.PassQuery {
+		for key, values := range requestQuery {
+			add(key, values...)
+		}
+	}
+
+	if link.UTM != nil {
+		for key, value := range utmParams(*link.UTM) {
+			if value != "" {
+				add(key, value)
+			}
+		}
+	}
+
+	if len(extra) == 0 {
+		return destination
+	}
+
+	if target.RawQuery == "" {
+		target.RawQuery = extra.Encode()
+	} else {
+		target.RawQuery += "&" + extra.Encode()
+	}
+
+	return target.String()
+}
+
+// utmParams maps the UTM template to query parameter names.
+func utmParams(utm storage.UTM) map[string]string {
+	return map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	}
+}
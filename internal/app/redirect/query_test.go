@@ -0,0 +1,61 @@
+package redirect_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/redirect"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeQuery(t *testing.T) {
+	utm := &storage.UTM{Source: "newsletter", Medium: "email", Campaign: "spring"}
+
+	tests := []struct {
+		name        string
+		destination string
+		options     storage.LinkOptions
+		query       url.Values
+		want        string
+	}{
+		{
+			name:        "no options",
+			destination: "https://example.com/page?b=2&a=1",
+			query:       url.Values{"x": {"1"}},
+			want:        "https://example.com/page?b=2&a=1",
+		},
+		{
+			name:        "utm template",
+			destination: "https://example.com/page",
+			options:     storage.LinkOptions{UTM: utm},
+			want:        "https://example.com/page?utm_campaign=spring&utm_medium=email&utm_source=newsletter",
+		},
+		{
+			name:        "existing params are kept",
+			destination: "https://example.com/page?utm_source=site&id=7",
+			options:     storage.LinkOptions{UTM: utm},
+			want:        "https://example.com/page?utm_source=site&id=7&utm_campaign=spring&utm_medium=email",
+		},
+		{
+			name:        "pass-through wins over template",
+			destination: "https://example.com/page",
+			options:     storage.LinkOptions{UTM: &storage.UTM{Source: "newsletter"}, PassQuery: true},
+			query:       url.Values{"utm_source": {"twitter"}, "tag": {"a", "b"}},
+			want:        "https://example.com/page?tag=a&tag=b&utm_source=twitter",
+		},
+		{
+			name:        "query is not forwarded without pass-through",
+			destination: "https://example.com/page",
+			query:       url.Values{"tag": {"a"}},
+			want:        "https://example.com/page",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := storage.URL{LinkOptions: tt.options}
+			assert.Equal(t, tt.want, redirect.MergeQuery(tt.destination, link, tt.query))
+		})
+	}
+}
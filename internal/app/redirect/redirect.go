@@ -0,0 +1,91 @@
+// Package redirect decides how a short link is served to the client.
+//
+// It picks the HTTP redirect status for a link, falling back to the
+// service-wide default, and derives caching headers from the redirect type
+// and the link expiry so that permanent redirects can be cached by browsers
+// and proxies while temporary ones are always revalidated.
+//
+// Links may carry ordered conditional rules that route a request to another
+// destination depending on the device class, preferred language, query
+// parameters or time of day, including weighted A/B splits pinned to a
+// visitor with a cookie. Evaluate applies these rules to a request, and
+// MergeQuery adds the link's UTM template and forwarded query parameters to
+// the chosen destination.
+package redirect
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+// DefaultStatus is used when neither the link nor the configuration specify a valid redirect type.
+const DefaultStatus = http.StatusTemporaryRedirect
+
+// PermanentMaxAge is the longest time a permanent redirect may be cached by clients.
+const PermanentMaxAge = 24 * time.Hour
+
+// IsValidStatus reports whether code is a supported redirect status.
+func IsValidStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// IsPermanent reports whether code is a permanent redirect status.
+func IsPermanent(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// Status returns the redirect status for the link.
+//
+// The per-link redirect type wins over defaultStatus; if neither is valid, DefaultStatus is used.
+func Status(link storage.URL, defaultStatus int) int {
+	if IsValidStatus(link.RedirectType) {
+		return link.RedirectType
+	}
+
+	if IsValidStatus(defaultStatus) {
+		return defaultStatus
+	}
+
+	return DefaultStatus
+}
+
+// SetCacheHeaders sets `Cache-Control` and `Expires` headers for a redirect response.
+//
+//   - Permanent redirects are public and cacheable for PermanentMaxAge, shortened to the
+//     remaining lifetime of the link when it has an expiry.
+//   - Temporary redirects, and links with conditional rules whose destination depends on
+//     the request, must be revalidated on every request, so clicks reach the service.
+func SetCacheHeaders(h http.Header, status int, link storage.URL, now time.Time) {
+	if len(link.Rules) > 0 {
+		h.Set("Vary", "User-Agent, Accept-Language, Cookie")
+	}
+
+	if !IsPermanent(status) || len(link.Rules) > 0 {
+		h.Set("Cache-Control", "private, no-cache")
+		h.Set("Expires", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		return
+	}
+
+	maxAge := PermanentMaxAge
+	if link.ExpiresAt != nil {
+		if remaining := link.ExpiresAt.Sub(now); remaining < maxAge {
+			maxAge = remaining
+		}
+	}
+
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	seconds := int64(maxAge / time.Second)
+	h.Set("Cache-Control", "public, max-age="+strconv.FormatInt(seconds, 10))
+	h.Set("Expires", now.Add(time.Duration(seconds)*time.Second).UTC().Format(http.TimeFormat))
+}
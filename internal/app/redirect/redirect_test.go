@@ -0,0 +1,63 @@
+package redirect_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/redirect"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		linkType      int
+		defaultStatus int
+		want          int
+	}{
+		{name: "link type wins", linkType: http.StatusMovedPermanently, defaultStatus: http.StatusFound, want: http.StatusMovedPermanently},
+		{name: "default is used", linkType: 0, defaultStatus: http.StatusPermanentRedirect, want: http.StatusPermanentRedirect},
+		{name: "invalid default falls back", linkType: 0, defaultStatus: http.StatusOK, want: http.StatusTemporaryRedirect},
+		{name: "invalid link type is ignored", linkType: http.StatusNotFound, defaultStatus: http.StatusFound, want: http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := storage.URL{LinkOptions: storage.LinkOptions{RedirectType: tt.linkType}}
+			assert.Equal(t, tt.want, redirect.Status(link, tt.defaultStatus))
+		})
+	}
+}
+
+func TestSetCacheHeaders(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Temporary redirect is not cached", func(t *testing.T) {
+		h := http.Header{}
+		redirect.SetCacheHeaders(h, http.StatusFound, storage.URL{}, now)
+
+		assert.Equal(t, "private, no-cache", h.Get("Cache-Control"))
+		assert.Equal(t, "Thu, 01 Jan 1970 00:00:00 GMT", h.Get("Expires"))
+	})
+
+	t.Run("Permanent redirect without expiry", func(t *testing.T) {
+		h := http.Header{}
+		redirect.SetCacheHeaders(h, http.StatusMovedPermanently, storage.URL{}, now)
+
+		assert.Equal(t, "public, max-age=86400", h.Get("Cache-Control"))
+		assert.Equal(t, "Tue, 02 Jan 2024 12:00:00 GMT", h.Get("Expires"))
+	})
+
+	t.Run("Permanent redirect is bounded by link expiry", func(t *testing.T) {
+		expiresAt := now.Add(time.Hour)
+		link := storage.URL{LinkOptions: storage.LinkOptions{ExpiresAt: &expiresAt}}
+
+		h := http.Header{}
+		redirect.SetCacheHeaders(h, http.StatusPermanentRedirect, link, now)
+
+		assert.Equal(t, "public, max-age=3600", h.Get("Cache-Control"))
+		assert.Equal(t, "Mon, 01 Jan 2024 13:00:00 GMT", h.Get("Expires"))
+	})
+}
@@ -0,0 +1,272 @@
+package redirect
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+// Device classes recognised by rules.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// SplitCookiePrefix is the prefix of the cookie that pins a visitor to an A/B split variant.
+// The full cookie name is the prefix followed by the short URL key.
+const SplitCookiePrefix = "split_"
+
+// splitCookieMaxAge keeps a visitor on the same variant for 30 days.
+const splitCookieMaxAge = 30 * 24 * 60 * 60
+
+// Decision is the outcome of evaluating the rules of a link for a request.
+type Decision struct {
+	Destination string       // URL the client is redirected to
+	Cookie      *http.Cookie // Sticky split cookie to set, if any
+}
+
+// Evaluate picks the destination of the link for the request.
+//
+// Rules are checked in order and the first rule whose conditions all match wins.
+// If it defines a split, a variant is chosen by weight, or taken from the sticky
+// cookie set on a previous visit. When no rule matches, the original URL is used.
+func Evaluate(r *http.Request, link storage.URL, now time.Time) Decision {
+	for i, rule := range link.Rules {
+		if !matches(r, rule, now) {
+			continue
+		}
+
+		if len(rule.Split) == 0 {
+			return Decision{Destination: rule.Destination}
+		}
+
+		return chooseVariant(r, link.ShortURL, i, rule.Split)
+	}
+
+	return Decision{Destination: link.OriginalURL}
+}
+
+// ValidateRules checks that rules are well-formed before they are stored.
+func ValidateRules(rules []storage.Rule) error {
+	for i, rule := range rules {
+		if err := validateRule(rule); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateRule(rule storage.Rule) error {
+	switch rule.Device {
+	case "", DeviceMobile, DeviceTablet, DeviceDesktop, DeviceBot:
+	default:
+		return fmt.Errorf("unknown device %q", rule.Device)
+	}
+
+	if rule.TimeWindow != nil {
+		start, err := parseClock(rule.TimeWindow.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(rule.TimeWindow.End)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return errors.New("time window must not start and end at the same time")
+		}
+		if _, err := time.LoadLocation(rule.TimeWindow.Location); err != nil {
+			return fmt.Errorf("unknown location %q", rule.TimeWindow.Location)
+		}
+	}
+
+	if len(rule.Split) == 0 {
+		if rule.Destination == "" {
+			return errors.New("destination or split is required")
+		}
+		return nil
+	}
+
+	for _, variant := range rule.Split {
+		if variant.Destination == "" {
+			return errors.New("split variant destination is required")
+		}
+		if variant.Weight <= 0 {
+			return errors.New("split variant weight must be positive")
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether all conditions of the rule hold for the request.
+func matches(r *http.Request, rule storage.Rule, now time.Time) bool {
+	if rule.Device != "" && DeviceClass(r.UserAgent()) != rule.Device {
+		return false
+	}
+
+	if rule.Language != "" && !languageMatches(PreferredLanguage(r.Header.Get("Accept-Language")), rule.Language) {
+		return false
+	}
+
+	query := r.URL.Query()
+	for key, value := range rule.Query {
+		if !query.Has(key) || (value != "" && query.Get(key) != value) {
+			return false
+		}
+	}
+
+	if rule.TimeWindow != nil && !inTimeWindow(*rule.TimeWindow, now) {
+		return false
+	}
+
+	return true
+}
+
+// DeviceClass classifies a User-Agent string as mobile, tablet, desktop or bot.
+func DeviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case ua == "" || strings.Contains(ua, "bot") || strings.Contains(ua, "crawler") || strings.Contains(ua, "spider"):
+		return DeviceBot
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return DeviceMobile
+	}
+
+	return DeviceDesktop
+}
+
+// PreferredLanguage returns the language tag with the highest quality value
+// from an Accept-Language header, or an empty string if there is none.
+func PreferredLanguage(header string) string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		if quality > 0 {
+			languages = append(languages, language{tag: tag, quality: quality})
+		}
+	}
+
+	if len(languages) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	return languages[0].tag
+}
+
+// languageMatches reports whether tag equals want or is a subtag of it, ignoring case:
+// "en" matches "en" and "en-US", while "en-US" matches only "en-US".
+func languageMatches(tag string, want string) bool {
+	tag = strings.ToLower(tag)
+	want = strings.ToLower(want)
+
+	return tag == want || strings.HasPrefix(tag, want+"-")
+}
+
+// inTimeWindow reports whether now falls into the daily time window.
+func inTimeWindow(window storage.TimeWindow, now time.Time) bool {
+	start, err := parseClock(window.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := parseClock(window.End)
+	if err != nil {
+		return false
+	}
+
+	location, err := time.LoadLocation(window.Location)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(location)
+	current := local.Hour()*60 + local.Minute()
+
+	if start <= end {
+		return current >= start && current < end
+	}
+
+	return current >= start || current < end
+}
+
+// parseClock converts "HH:MM" into minutes after midnight.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// chooseVariant picks a split variant for the visitor, reusing the sticky cookie when it is valid.
+func chooseVariant(r *http.Request, key string, ruleIndex int, split []storage.SplitVariant) Decision {
+	name := SplitCookiePrefix + key
+	prefix := strconv.Itoa(ruleIndex) + "."
+
+	if cookie, err := r.Cookie(name); err == nil {
+		if value, ok := strings.CutPrefix(cookie.Value, prefix); ok {
+			if index, err := strconv.Atoi(value); err == nil && index >= 0 && index < len(split) {
+				return Decision{Destination: split[index].Destination}
+			}
+		}
+	}
+
+	total := 0
+	for _, variant := range split {
+		total += variant.Weight
+	}
+
+	index := 0
+	for pick := rand.Intn(total); pick >= split[index].Weight; index++ {
+		pick -= split[index].Weight
+	}
+
+	return Decision{
+		Destination: split[index].Destination,
+		Cookie: &http.Cookie{
+			Name:     name,
+			Value:    prefix + strconv.Itoa(index),
+			Path:     "/" + key,
+			MaxAge:   splitCookieMaxAge,
+			HttpOnly: true,
+		},
+	}
+}
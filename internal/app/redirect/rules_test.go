@@ -0,0 +1,110 @@
+package redirect_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/redirect"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148"
+	iPadUA    = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)"
+	desktopUA = "Mozilla/5.0 (X11; Linux x86_64) Gecko/20100101 Firefox/120.0"
+)
+
+func TestDeviceClass(t *testing.T) {
+	assert.Equal(t, redirect.DeviceMobile, redirect.DeviceClass(iPhoneUA))
+	assert.Equal(t, redirect.DeviceTablet, redirect.DeviceClass(iPadUA))
+	assert.Equal(t, redirect.DeviceDesktop, redirect.DeviceClass(desktopUA))
+	assert.Equal(t, redirect.DeviceBot, redirect.DeviceClass("Googlebot/2.1"))
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	assert.Equal(t, "de-DE", redirect.PreferredLanguage("en;q=0.5, de-DE, fr;q=0.8"))
+	assert.Equal(t, "fr", redirect.PreferredLanguage("*, fr;q=0.9, en;q=0"))
+	assert.Equal(t, "", redirect.PreferredLanguage(""))
+}
+
+func TestEvaluate(t *testing.T) {
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	link := storage.URL{
+		ShortURL:    "abc",
+		OriginalURL: "https://example.com",
+		LinkOptions: storage.LinkOptions{Rules: []storage.Rule{
+			{Device: redirect.DeviceMobile, Destination: "https://m.example.com"},
+			{Language: "de", Destination: "https://example.de"},
+			{Query: map[string]string{"ref": "mail"}, Destination: "https://example.com/mail"},
+			{TimeWindow: &storage.TimeWindow{Start: "22:00", End: "06:00"}, Destination: "https://example.com/night"},
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		ua     string
+		lang   string
+		now    time.Time
+		want   string
+	}{
+		{name: "device", target: "/abc", ua: iPhoneUA, now: noon, want: "https://m.example.com"},
+		{name: "language", target: "/abc", ua: desktopUA, lang: "de-AT, en;q=0.5", now: noon, want: "https://example.de"},
+		{name: "query", target: "/abc?ref=mail", ua: desktopUA, now: noon, want: "https://example.com/mail"},
+		{name: "time window wraps midnight", target: "/abc", ua: desktopUA, now: noon.Add(11 * time.Hour), want: "https://example.com/night"},
+		{name: "no rule matches", target: "/abc?ref=web", ua: desktopUA, lang: "en", now: noon, want: "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.ua)
+			req.Header.Set("Accept-Language", tt.lang)
+
+			decision := redirect.Evaluate(req, link, tt.now)
+
+			assert.Equal(t, tt.want, decision.Destination)
+			assert.Nil(t, decision.Cookie)
+		})
+	}
+}
+
+func TestEvaluate_SplitIsSticky(t *testing.T) {
+	link := storage.URL{
+		ShortURL:    "abc",
+		OriginalURL: "https://example.com",
+		LinkOptions: storage.LinkOptions{Rules: []storage.Rule{
+			{Split: []storage.SplitVariant{
+				{Destination: "https://example.com/a", Weight: 1},
+				{Destination: "https://example.com/b", Weight: 1},
+			}},
+		}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	first := redirect.Evaluate(req, link, time.Now())
+	assert.NotNil(t, first.Cookie)
+	assert.Equal(t, redirect.SplitCookiePrefix+"abc", first.Cookie.Name)
+
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.AddCookie(first.Cookie)
+
+		decision := redirect.Evaluate(req, link, time.Now())
+
+		assert.Equal(t, first.Destination, decision.Destination)
+		assert.Nil(t, decision.Cookie)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	assert.NoError(t, redirect.ValidateRules([]storage.Rule{{Device: "mobile", Destination: "https://m.example.com"}}))
+	assert.Error(t, redirect.ValidateRules([]storage.Rule{{Device: "watch", Destination: "https://example.com"}}))
+	assert.Error(t, redirect.ValidateRules([]storage.Rule{{Language: "de"}}))
+	assert.Error(t, redirect.ValidateRules([]storage.Rule{{TimeWindow: &storage.TimeWindow{Start: "25:00", End: "06:00"}, Destination: "https://example.com"}}))
+	assert.Error(t, redirect.ValidateRules([]storage.Rule{{TimeWindow: &storage.TimeWindow{Start: "09:00", End: "09:00"}, Destination: "https://example.com"}}))
+	assert.Error(t, redirect.ValidateRules([]storage.Rule{{Split: []storage.SplitVariant{{Destination: "https://example.com", Weight: 0}}}}))
+}
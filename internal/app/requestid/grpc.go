@@ -0,0 +1,65 @@
+package requestid
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata key carrying the request ID.
+const MetadataKey = "x-request-id"
+
+// UnaryServerInterceptor is the gRPC counterpart of Middleware for unary calls. The request ID
+// is sent back both as a header and as a trailer, so that clients also find it on calls failing
+// before any header was sent.
+func UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	id := fromMetadata(ctx)
+
+	md := metadata.Pairs(MetadataKey, id)
+	_ = grpc.SetHeader(ctx, md)
+	_ = grpc.SetTrailer(ctx, md)
+
+	return handler(NewContext(ctx, id), req)
+}
+
+// serverStream passes the context holding the request ID to stream handlers.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context holding the request ID.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor is the gRPC counterpart of Middleware for streaming calls.
+func StreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	id := fromMetadata(ss.Context())
+
+	md := metadata.Pairs(MetadataKey, id)
+	_ = ss.SetHeader(md)
+	ss.SetTrailer(md)
+
+	return handler(srv, &serverStream{ServerStream: ss, ctx: NewContext(ss.Context(), id)})
+}
+
+// fromMetadata returns the valid request ID of the incoming metadata or a new one.
+func fromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(MetadataKey); len(values) > 0 {
+		return ensure(values[0])
+	}
+	return New()
+}
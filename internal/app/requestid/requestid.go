@@ -0,0 +1,82 @@
+// Package requestid correlates a request with its log entries and error responses.
+//
+// Every HTTP request and gRPC call gets a request ID: the one sent by the client in the
+// X-Request-ID header or the x-request-id metadata entry when it is valid, or a newly
+// generated one otherwise. The ID is stored in the request context, where the logger and the
+// error responses pick it up, and echoed to the client in the X-Request-ID response header or
+// in the x-request-id header and trailer of the gRPC call.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header is the HTTP header carrying the request ID.
+const Header = "X-Request-ID"
+
+// MaxLength is the length limit of request IDs accepted from clients.
+const MaxLength = 128
+
+// contextKey is the context key of the request ID.
+type contextKey struct{}
+
+// NewContext returns a context holding the request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID in ctx, or an empty string when there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// New generates a random request ID of 32 hexadecimal characters.
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether a request ID sent by a client may be used. IDs are limited to
+// MaxLength letters, digits and the characters "-", "_", ".", ":" and "/", which keeps them
+// safe to write to headers and logs.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ensure returns id when it is valid and a new request ID otherwise.
+func ensure(id string) string {
+	if Valid(id) {
+		return id
+	}
+	return New()
+}
+
+// Middleware accepts the X-Request-ID header of a request or generates a new ID, stores it in
+// the request context and sets it as the X-Request-ID header of the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := ensure(r.Header.Get(Header))
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
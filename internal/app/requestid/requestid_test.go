@@ -0,0 +1,156 @@
+package requestid_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"req-1", true},
+		{"0f8fad5b-d9cb-469f-a165-70867728950e", true},
+		{"service/a:b_c.d", true},
+		{"", false},
+		{"with space", false},
+		{"line\nbreak", false},
+		{"quote\"", false},
+		{strings.Repeat("a", requestid.MaxLength), true},
+		{strings.Repeat("a", requestid.MaxLength+1), false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, requestid.Valid(tt.id), "id %q", tt.id)
+	}
+}
+
+func TestNew(t *testing.T) {
+	id := requestid.New()
+
+	assert.Len(t, id, 32)
+	assert.True(t, requestid.Valid(id))
+	assert.NotEqual(t, id, requestid.New())
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{name: "accepts client ID", header: "client-42", wantSame: true},
+		{name: "generates missing ID"},
+		{name: "replaces invalid ID", header: "bad id\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestid.FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(requestid.Header, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.NotEmpty(t, seen)
+			assert.Equal(t, seen, rec.Header().Get(requestid.Header))
+			if tt.wantSame {
+				assert.Equal(t, tt.header, seen)
+			} else {
+				assert.NotEqual(t, tt.header, seen)
+				assert.True(t, requestid.Valid(seen))
+			}
+		})
+	}
+}
+
+// transportStream records the header and trailer set by unary interceptors.
+type transportStream struct {
+	grpc.ServerTransportStream
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	stream := &transportStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(requestid.MetadataKey, "client-42"))
+
+	var seen string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		seen = requestid.FromContext(ctx)
+		return nil, nil
+	}
+
+	_, err := requestid.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	assert.Equal(t, "client-42", seen)
+	assert.Equal(t, []string{"client-42"}, stream.header.Get(requestid.MetadataKey))
+	assert.Equal(t, []string{"client-42"}, stream.trailer.Get(requestid.MetadataKey))
+}
+
+// serverStream records the header and trailer set by stream interceptors.
+type serverStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *serverStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	ss := &serverStream{ctx: context.Background()}
+
+	var seen string
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		seen = requestid.FromContext(stream.Context())
+		return nil
+	}
+
+	err := requestid.StreamServerInterceptor(nil, ss, &grpc.StreamServerInfo{}, handler)
+	require.NoError(t, err)
+
+	// Without an ID in the metadata a new one is generated
+	assert.True(t, requestid.Valid(seen))
+	assert.Equal(t, []string{seen}, ss.header.Get(requestid.MetadataKey))
+	assert.Equal(t, []string{seen}, ss.trailer.Get(requestid.MetadataKey))
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+// MaxStreamChunk is the largest number of items shortened in one BatchShortenURLs call by the
+// streaming transports, which bounds the memory used per request.
+const MaxStreamChunk = 100
+
+// BatchStatus is the outcome of shortening a single item of a batch.
+type BatchStatus string
+
+// Outcomes of batch items.
+const (
+	BatchCreated  BatchStatus = "created"  // A new link was stored
+	BatchExisting BatchStatus = "existing" // The URL was already shortened; the existing link is returned
+	BatchInvalid  BatchStatus = "invalid"  // The item failed validation and was skipped
+	BatchFailed   BatchStatus = "failed"   // The generated short URL is held by another URL; nothing was stored for the item
+)
+
+// BatchResult reports the outcome of a single item of a batch.
+type BatchResult struct {
+	CorrelationID string      // Correlation ID of the request item
+	URL           storage.URL // Created or existing link; only OriginalURL is set for invalid items
+	Status        BatchStatus // Outcome of the item
+	Err           error       // Validation error of invalid items, storage.ErrAliasTaken for failed items
+}
+
+// BatchShortenURLs shortens the items for the user from the context and reports the outcome of
+// every item in request order.
+//
+// A batch never fails as a whole because of its content: invalid URLs are reported as BatchInvalid
+// and URLs that were already shortened, or appear earlier in the batch, as BatchExisting together
+// with the existing link, while the remaining items are stored in a single storage call. Items
+// whose generated short URL is held by another URL are reported as BatchFailed.
+// An error is only returned when the storage fails, and then nothing is stored.
+func (s *URLService) BatchShortenURLs(ctx context.Context, items []storage.RequestBodyBanch) ([]BatchResult, error) {
+	if userID, ok := ctx.Value(middleware.UserIDKey).(string); !ok || userID == "" {
+		return nil, errors.New("user ID is empty")
+	}
+
+	results := make([]BatchResult, len(items))
+	valid := make([]storage.RequestBodyBanch, 0, len(items))
+	positions := make([]int, 0, len(items))
+
+	for i, item := range items {
+		results[i] = BatchResult{
+			CorrelationID: item.CorrelationID,
+			URL:           storage.URL{OriginalURL: item.OriginalURL},
+			Status:        BatchInvalid,
+		}
+
+		if err := validateURL(item.OriginalURL); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		valid = append(valid, item)
+		positions = append(positions, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	links, err := s.store.SetBatch(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, link := range links {
+		result := &results[positions[j]]
+		result.URL = link.URL
+		if link.Err != nil {
+			result.Status = BatchFailed
+			result.Err = link.Err
+			continue
+		}
+		if link.Existing {
+			result.Status = BatchExisting
+			continue
+		}
+		result.Status = BatchCreated
+		enqueuePageFetch(link.URL)
+		s.publish(broker.EventCreated, link.URL)
+	}
+
+	return results, nil
+}
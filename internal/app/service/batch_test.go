@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchShortenURLs_MemoryStore(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	existing, err := svc.ImportURL(ctx, storage.RequestURL{URL: "https://example.com/docs"}, "docs")
+	require.NoError(t, err)
+
+	results, err := svc.BatchShortenURLs(ctx, []storage.RequestBodyBanch{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+		{CorrelationID: "2", OriginalURL: "not a url"},
+		{CorrelationID: "3", OriginalURL: "https://example.com/docs"},
+		{CorrelationID: "4", OriginalURL: "https://example.com"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, "1", results[0].CorrelationID)
+	assert.Equal(t, service.BatchCreated, results[0].Status)
+	assert.NoError(t, results[0].Err)
+
+	assert.Equal(t, service.BatchInvalid, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, service.ErrInvalidURL)
+	assert.Equal(t, "not a url", results[1].URL.OriginalURL)
+
+	assert.Equal(t, service.BatchExisting, results[2].Status)
+	assert.Equal(t, existing.ShortURL, results[2].URL.ShortURL)
+
+	// Duplicates within a batch resolve to the link created by the first occurrence
+	assert.Equal(t, service.BatchExisting, results[3].Status)
+	assert.Equal(t, results[0].URL.ShortURL, results[3].URL.ShortURL)
+
+	t.Run("Without user", func(t *testing.T) {
+		_, err := svc.BatchShortenURLs(context.Background(), []storage.RequestBodyBanch{{OriginalURL: "https://example.org"}})
+		assert.Error(t, err)
+	})
+}
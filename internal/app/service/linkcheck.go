@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/linkcheck"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// StartLinkChecker periodically checks the destinations of all links that are neither deleted
+// nor expired and records the last status and check time on each link.
+//
+// A run starts immediately and then once per interval. The first run skips links checked
+// less than an interval ago, so restarting the service does not re-check everything; later
+// runs check every link not checked since the previous run ended.
+//
+// Parameters:
+//   - ctx: Stops the checker when done.
+//   - store: The storage interface for managing URL persistence.
+//   - checker: The checker issuing the requests.
+//   - interval: Time between two runs; zero or negative disables checking.
+//
+// Usage:
+//
+//	This function blocks until ctx is done and is typically started as a goroutine.
+func StartLinkChecker(ctx context.Context, store storage.Storage, checker *linkcheck.Checker, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	runs := newLinkCheckRuns(store, checker, interval)
+	for {
+		runs.run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// linkCheckRuns runs the passes of the link checker, remembering which links are due.
+type linkCheckRuns struct {
+	store         storage.Storage
+	checker       *linkcheck.Checker
+	checkedBefore time.Time // Links checked before this time are due
+}
+
+// newLinkCheckRuns prepares the passes of a checker whose first pass skips links checked
+// within interval.
+func newLinkCheckRuns(store storage.Storage, checker *linkcheck.Checker, interval time.Duration) *linkCheckRuns {
+	return &linkCheckRuns{store: store, checker: checker, checkedBefore: time.Now().Add(-interval)}
+}
+
+// run checks the due links. Comparing check times to the end of the previous pass rather than
+// to the interval keeps links checked late in a pass from being skipped by the next one.
+func (r *linkCheckRuns) run(ctx context.Context) {
+	checkLinks(ctx, r.store, r.checker, r.checkedBefore)
+	r.checkedBefore = time.Now()
+}
+
+// linkCheckPageSize is the number of links loaded and checked at a time.
+const linkCheckPageSize = 500
+
+// checkLinks runs one pass of the link checker over the active links not checked since
+// checkedBefore. Links are loaded a page at a time, and expired links are not checked.
+func checkLinks(ctx context.Context, store storage.Storage, checker *linkcheck.Checker, checkedBefore time.Time) {
+	var total, checked int
+	for after := ""; ctx.Err() == nil; {
+		urls, err := store.ActiveURLs(ctx, after, linkCheckPageSize)
+		if err != nil {
+			logger.Default().Error("unable to list links to check", zap.Error(err))
+			return
+		}
+		if len(urls) == 0 {
+			break
+		}
+		after = urls[len(urls)-1].ShortURL
+		total += len(urls)
+
+		due := urls[:0]
+		for _, url := range urls {
+			if url.Health == nil || url.Health.CheckedAt.Before(checkedBefore) {
+				due = append(due, url)
+			}
+		}
+		checked += len(due)
+
+		checker.CheckAll(ctx, due, func(result linkcheck.Result) {
+			if err := store.SetHealth(ctx, result.ShortURL, result.Health); err != nil {
+				logger.Default().Error("unable to store link health", zap.String("short_url", result.ShortURL), zap.Error(err))
+			}
+		})
+	}
+
+	logger.Default().Info("checked links", zap.Int("due", checked), zap.Int("total", total))
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/linkcheck"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkCheckRuns_ConsecutivePasses(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits[r.URL.Path]++
+	}))
+	defer destination.Close()
+
+	store := storage.NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+	fresh, err := store.Set(ctx, destination.URL+"/fresh")
+	require.NoError(t, err)
+	_, err = store.Set(ctx, destination.URL+"/new")
+	require.NoError(t, err)
+
+	// Checked just before a restart.
+	require.NoError(t, store.SetHealth(ctx, fresh.ShortURL, storage.LinkHealth{Status: http.StatusOK, CheckedAt: time.Now()}))
+
+	checker := linkcheck.New(linkcheck.Options{HostDelay: time.Millisecond, Client: fetcher.Options{AllowPrivate: true}})
+	runs := newLinkCheckRuns(store, checker, time.Hour)
+
+	runs.run(context.Background())
+	mu.Lock()
+	assert.Equal(t, map[string]int{"/new": 1}, hits, "the first pass skips links checked within the interval")
+	mu.Unlock()
+
+	runs.run(context.Background())
+	mu.Lock()
+	assert.Equal(t, map[string]int{"/new": 2, "/fresh": 1}, hits, "the next pass checks every link again")
+	mu.Unlock()
+}
+
+func TestCheckLinks_Pages(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits[r.URL.Path]++
+	}))
+	defer destination.Close()
+
+	store := storage.NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+	for i := 0; i < linkCheckPageSize+1; i++ {
+		_, err := store.Set(ctx, fmt.Sprintf("%s/%d", destination.URL, i))
+		require.NoError(t, err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	_, err := store.SetWithAlias(ctx, destination.URL+"/expired", "", storage.LinkOptions{ExpiresAt: &expired})
+	require.NoError(t, err)
+
+	checker := linkcheck.New(linkcheck.Options{HostDelay: time.Microsecond, Client: fetcher.Options{AllowPrivate: true}})
+	checkLinks(context.Background(), store, checker, time.Now())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, hits, linkCheckPageSize+1, "links on every page are checked")
+	assert.NotContains(t, hits, "/expired", "expired links are not checked")
+}
@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/linkcheck"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartLinkChecker(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer destination.Close()
+
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+	userCtx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	alive, err := store.Set(userCtx, destination.URL+"/alive")
+	assert.NoError(t, err)
+	gone, err := store.Set(userCtx, destination.URL+"/gone")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	checker := linkcheck.New(linkcheck.Options{HostDelay: time.Millisecond, Client: fetcher.Options{AllowPrivate: true}})
+	go func() {
+		service.StartLinkChecker(ctx, store, checker, time.Hour)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	assert.Eventually(t, func() bool {
+		page, err := svc.FindUserURLs(userCtx, storage.URLQuery{Health: storage.HealthOK})
+		return err == nil && len(page.URLs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	page, err := svc.FindUserURLs(userCtx, storage.URLQuery{Health: storage.HealthOK})
+	assert.NoError(t, err)
+	assert.Equal(t, alive.ShortURL, page.URLs[0].ShortURL)
+
+	assert.Eventually(t, func() bool {
+		page, err := svc.FindUserURLs(userCtx, storage.URLQuery{Health: storage.HealthBroken})
+		return err == nil && len(page.URLs) == 1 && page.URLs[0].ShortURL == gone.ShortURL
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = svc.FindUserURLs(userCtx, storage.URLQuery{Health: "dead"})
+	assert.ErrorIs(t, err, service.ErrInvalidQuery)
+}
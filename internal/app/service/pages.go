@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// fetchRequest represents a request to fetch the destination page of a new link.
+//
+// Fields:
+//   - ShortURL: The short URL key of the link.
+//   - OriginalURL: The destination to fetch.
+type fetchRequest struct {
+	ShortURL    string
+	OriginalURL string
+}
+
+// fetchChan is a buffered channel used for queuing destination page fetches.
+var fetchChan = make(chan fetchRequest, 100)
+
+// fetchWorkers counts the running fetch workers; links are only queued while there are any.
+var fetchWorkers atomic.Int32
+
+// enqueuePageFetch queues fetching the destination page of a newly created link.
+// The request is dropped when no workers run or the queue is full, since the
+// metadata is a best-effort addition and must never slow down shortening.
+func enqueuePageFetch(url storage.URL) {
+	if fetchWorkers.Load() == 0 {
+		return
+	}
+
+	select {
+	case fetchChan <- fetchRequest{ShortURL: url.ShortURL, OriginalURL: url.OriginalURL}:
+	default:
+		logger.Default().Warn("page fetch queue is full", zap.String("short_url", url.ShortURL))
+	}
+}
+
+// StartFetchWorkers starts a pool of workers that fetch destination pages queued on link creation
+// and store the extracted title, Open Graph data and favicon with the link.
+//
+// Parameters:
+//   - ctx: Stops the workers when done.
+//   - store: The storage interface for managing URL persistence.
+//   - f: The fetcher used to download destination pages.
+//   - workers: The number of concurrent fetches.
+//
+// Usage:
+//
+//	This function blocks until ctx is done and is typically started as a goroutine.
+func StartFetchWorkers(ctx context.Context, store storage.Storage, f *fetcher.Fetcher, workers int) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		fetchWorkers.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer fetchWorkers.Add(-1)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case req := <-fetchChan:
+					fetchPage(ctx, store, f, req)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// fetchPage fetches a single destination page and stores its metadata.
+func fetchPage(ctx context.Context, store storage.Storage, f *fetcher.Fetcher, req fetchRequest) {
+	page, err := f.Fetch(ctx, req.OriginalURL)
+	if err != nil {
+		logger.Default().Warn("unable to fetch page", zap.String("short_url", req.ShortURL), zap.Error(err))
+		return
+	}
+
+	if err := store.SetPageInfo(ctx, req.ShortURL, page); err != nil {
+		logger.Default().Error("unable to store page info", zap.String("short_url", req.ShortURL), zap.Error(err))
+	}
+}
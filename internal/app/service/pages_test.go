@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartFetchWorkers(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Destination</title><meta property="og:description" content="Fetched"></head></html>`))
+	}))
+	defer destination.Close()
+
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.StartFetchWorkers(ctx, store, fetcher.New(fetcher.Options{AllowPrivate: true}), 2)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	userCtx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	// Workers register asynchronously and links created before that are not queued,
+	// so keep creating links until one of them gets its page fetched
+	var fetched storage.URL
+	for i := 0; fetched.Page == nil; i++ {
+		if !assert.Less(t, i, 250, "no page fetched") {
+			return
+		}
+
+		_, err := svc.ShortenURL(userCtx, destination.URL+"/page?n="+strconv.Itoa(i))
+		assert.NoError(t, err)
+
+		time.Sleep(20 * time.Millisecond)
+
+		page, err := svc.FindUserURLs(userCtx, storage.URLQuery{})
+		assert.NoError(t, err)
+		for _, link := range page.URLs {
+			if link.Page != nil {
+				fetched = link
+			}
+		}
+	}
+
+	assert.Equal(t, "Destination", fetched.Page.Title)
+	assert.Equal(t, "Fetched", fetched.Page.OGDescription)
+	assert.Equal(t, destination.URL+"/favicon.ico", fetched.Page.Favicon)
+}
@@ -2,24 +2,70 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/golangTroshin/shorturl/internal/app/broker"
 	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
 	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/qrcode"
+	"github.com/golangTroshin/shorturl/internal/app/redirect"
 	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// Errors returned by URLService.
+var (
+	// ErrURLExpired is returned when a link is past its expiry time.
+	ErrURLExpired = errors.New("url has expired")
+	// ErrInvalidOptions is returned when per-link settings fail validation.
+	ErrInvalidOptions = errors.New("invalid link options")
+	// ErrInvalidQuery is returned when link list parameters fail validation.
+	ErrInvalidQuery = errors.New("invalid link query")
+	// ErrInvalidQRCode is returned when the error correction level or image options of a QR code are not valid.
+	ErrInvalidQRCode = errors.New("invalid QR code options")
+	// ErrInvalidURL is returned when a URL to shorten is not an absolute http or https URL.
+	ErrInvalidURL = errors.New("invalid URL")
+	// ErrInvalidAlias is returned when a requested short URL has invalid characters or is reserved.
+	ErrInvalidAlias = errors.New("invalid alias")
+)
+
+// Limits on the metadata attached to a link.
+const (
+	MaxTitleLength = 256  // Longest title in bytes
+	MaxNoteLength  = 4096 // Longest note in bytes
+	MaxTags        = 20   // Most tags on a single link
+	MaxTagLength   = 64   // Longest tag in bytes
+	MaxPageSize    = 1000 // Largest page of the link list
+	MaxAliasLength = 64   // Longest requested short URL in bytes
 )
 
 // Service defines the interface for the URL service.
 type Service interface {
 	ShortenURL(ctx context.Context, originalURL string) (storage.URL, error)
 	GetOriginalURL(ctx context.Context, shortURL string) (string, error)
-	BatchShortenURLs(ctx context.Context, urls []storage.RequestBodyBanch) ([]storage.URL, error)
+	BatchShortenURLs(ctx context.Context, urls []storage.RequestBodyBanch) ([]BatchResult, error)
 	GetUserURLs(ctx context.Context) ([]storage.URL, error)
-	DeleteUserURLs(ctx context.Context, shortURLs []string) error
+	DeleteUserURLs(ctx context.Context, shortURLs []string) (deletes.Job, error)
+	GetDeletion(ctx context.Context, id string) (deletes.Job, error)
 	GetStats(ctx context.Context) (storage.Stats, error)
 	PingDatabase(ctx context.Context) error
+	ShortenURLWithOptions(ctx context.Context, req storage.RequestURL) (storage.URL, error)
+	ResolveURL(ctx context.Context, shortURL string) (storage.URL, error)
+	RecordClick(ctx context.Context, shortURL string) error
+	UpdateURLOptions(ctx context.Context, shortURL string, opts storage.LinkOptions) (storage.URL, error)
+	GetUserURL(ctx context.Context, shortURL string) (storage.URL, error)
+	SetURLRules(ctx context.Context, shortURL string, rules []storage.Rule) (storage.URL, error)
+	FindUserURLs(ctx context.Context, query storage.URLQuery) (storage.URLPage, error)
+	QRCode(ctx context.Context, shortURL string, level string, opts qrcode.ImageOptions) ([]byte, error)
+	ImportURL(ctx context.Context, req storage.RequestURL, alias string) (storage.URL, error)
+	ExportUserURLs(ctx context.Context, yield func(storage.URL) error) error
+	WatchLinks(ctx context.Context) (*broker.Subscription, error)
 }
 
 var _ Service = (*URLService)(nil) // Ensures URLService implements Service
@@ -27,40 +73,275 @@ var _ Service = (*URLService)(nil) // Ensures URLService implements Service
 // URLService is a struct that provides URL shortening and retrieval functionality.
 // It implements the Service interface, ensuring compliance with all defined methods.
 type URLService struct {
-	store storage.Storage
+	store   storage.Storage
+	deletes deletes.Queue
+	events  *broker.Broker
 }
 
-// NewURLService initializes the service with the provided storage.
+// NewURLService initializes the service with the provided storage. Deletions are queued in
+// memory until WithDeleteQueue selects another queue, and link events are published to a broker
+// of the service until WithBroker selects another one.
 func NewURLService(store storage.Storage) *URLService {
-	return &URLService{store: store}
+	return &URLService{
+		store:   store,
+		deletes: deletes.NewMemoryQueue(deletes.DefaultMemoryLimit),
+		events:  broker.New(broker.DefaultBuffer),
+	}
 }
 
-// ShortenURL shortens a single URL.
+// WithDeleteQueue makes the service queue deletions on q, which a deletes.Worker processes.
+func (s *URLService) WithDeleteQueue(q deletes.Queue) *URLService {
+	s.deletes = q
+	return s
+}
+
+// ShortenURL shortens a single URL. When the URL already exists, the existing link is
+// returned together with the conflict error. Returns ErrInvalidURL, like BatchShortenURLs for its
+// items, when the URL is not an absolute http or https URL.
 func (s *URLService) ShortenURL(ctx context.Context, originalURL string) (storage.URL, error) {
+	if err := validateURL(originalURL); err != nil {
+		return storage.URL{}, err
+	}
+
 	url, err := s.store.Set(ctx, originalURL)
 	if err != nil {
+		return url, err
+	}
+	enqueuePageFetch(url)
+	s.publish(broker.EventCreated, url)
+	return url, nil
+}
+
+// ShortenURLWithOptions shortens a single URL, storing the new link together with the per-link
+// settings from the request.
+//
+// Settings are only applied to newly created links: when the URL already exists,
+// the existing link is returned together with the conflict error unchanged.
+// Returns ErrInvalidURL when the URL is not an absolute http or https URL.
+func (s *URLService) ShortenURLWithOptions(ctx context.Context, req storage.RequestURL) (storage.URL, error) {
+	if err := validateURL(req.URL); err != nil {
+		return storage.URL{}, err
+	}
+	req.LinkOptions = normalizeOptions(req.LinkOptions)
+	if err := validateOptions(req.LinkOptions); err != nil {
+		return storage.URL{}, err
+	}
+
+	url, err := s.store.SetWithAlias(ctx, req.URL, "", req.LinkOptions)
+	if err != nil {
+		return url, err
+	}
+	enqueuePageFetch(url)
+	s.publish(broker.EventCreated, url)
+
+	return url, nil
+}
+
+// ResolveURL retrieves the full link record for a short URL that can be served to clients.
+// Returns ErrURLExpired if the link is past its expiry time.
+func (s *URLService) ResolveURL(ctx context.Context, shortURL string) (storage.URL, error) {
+	url, err := s.store.GetURL(ctx, shortURL)
+	if err != nil {
+		return url, err
+	}
+
+	if url.Expired(time.Now()) {
+		return url, ErrURLExpired
+	}
+
+	return url, nil
+}
+
+// QRCode renders a QR code of the public short link, `config.Options.FlagBaseURL + "/" + shortURL`.
+// The level is "L", "M", "Q" or "H", defaulting to "M". The link must be servable,
+// so unknown, deleted and expired links fail like ResolveURL.
+// Returns ErrInvalidQRCode if the level or the image options are not valid.
+func (s *URLService) QRCode(ctx context.Context, shortURL string, level string, opts qrcode.ImageOptions) ([]byte, error) {
+	ecLevel, err := qrcode.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQRCode, err)
+	}
+
+	opts, err = opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQRCode, err)
+	}
+
+	if _, err := s.ResolveURL(ctx, shortURL); err != nil {
+		return nil, err
+	}
+
+	code, err := qrcode.Encode([]byte(config.Options.FlagBaseURL+"/"+shortURL), ecLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	return code.Render(opts)
+}
+
+// RecordClick counts a redirect served for the short URL.
+func (s *URLService) RecordClick(ctx context.Context, shortURL string) error {
+	if err := s.store.RecordClick(ctx, shortURL); err != nil {
+		return err
+	}
+	s.publishClick(ctx, shortURL)
+	return nil
+}
+
+// UpdateURLOptions replaces the per-link settings of a URL owned by the user from the context.
+func (s *URLService) UpdateURLOptions(ctx context.Context, shortURL string, opts storage.LinkOptions) (storage.URL, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		logger.FromContext(ctx).Warn("request without user")
+		return storage.URL{}, errors.New("wrong userID")
+	}
+
+	opts = normalizeOptions(opts)
+	if err := validateOptions(opts); err != nil {
+		return storage.URL{}, err
+	}
+
+	url, err := s.store.UpdateOptions(ctx, userID, shortURL, opts)
+	if err != nil {
+		return url, err
+	}
+	s.publish(broker.EventUpdated, url)
+	return url, nil
+}
+
+// GetUserURL retrieves a link owned by the user from the context.
+// Returns storage.ErrURLNotFound if the link belongs to another user.
+func (s *URLService) GetUserURL(ctx context.Context, shortURL string) (storage.URL, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		logger.FromContext(ctx).Warn("request without user")
+		return storage.URL{}, errors.New("wrong userID")
+	}
+
+	url, err := s.store.GetURL(ctx, shortURL)
+	if err != nil {
+		return storage.URL{}, err
+	}
+
+	if url.UserID != userID {
+		return storage.URL{}, storage.ErrURLNotFound
+	}
+
+	return url, nil
+}
+
+// SetURLRules replaces the conditional redirect rules of a link owned by the user from the context,
+// keeping its other settings. Only the rules are written, so settings changed concurrently are
+// not lost.
+func (s *URLService) SetURLRules(ctx context.Context, shortURL string, rules []storage.Rule) (storage.URL, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		logger.FromContext(ctx).Warn("request without user")
+		return storage.URL{}, errors.New("wrong userID")
+	}
+
+	if err := validateOptions(storage.LinkOptions{Rules: rules}); err != nil {
 		return storage.URL{}, err
 	}
+
+	url, err := s.store.UpdateRules(ctx, userID, shortURL, rules)
+	if err != nil {
+		return url, err
+	}
+	s.publish(broker.EventUpdated, url)
 	return url, nil
 }
 
+// validateOptions checks per-link settings before they are stored.
+func validateOptions(opts storage.LinkOptions) error {
+	if len(opts.Title) > MaxTitleLength {
+		return fmt.Errorf("%w: title is longer than %d bytes", ErrInvalidOptions, MaxTitleLength)
+	}
+
+	if len(opts.Note) > MaxNoteLength {
+		return fmt.Errorf("%w: note is longer than %d bytes", ErrInvalidOptions, MaxNoteLength)
+	}
+
+	if len(opts.Tags) > MaxTags {
+		return fmt.Errorf("%w: more than %d tags", ErrInvalidOptions, MaxTags)
+	}
+
+	for _, tag := range opts.Tags {
+		if len(tag) > MaxTagLength {
+			return fmt.Errorf("%w: tag %q is longer than %d bytes", ErrInvalidOptions, tag, MaxTagLength)
+		}
+	}
+
+	if opts.RedirectType != 0 && !redirect.IsValidStatus(opts.RedirectType) {
+		return fmt.Errorf("%w: unsupported redirect type %d", ErrInvalidOptions, opts.RedirectType)
+	}
+
+	if err := redirect.ValidateRules(opts.Rules); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+
+	// Rules redirect like the link itself, so their destinations are held to the same rules.
+	for i, rule := range opts.Rules {
+		destinations := []string{rule.Destination}
+		if len(rule.Split) > 0 {
+			destinations = nil
+			for _, variant := range rule.Split {
+				destinations = append(destinations, variant.Destination)
+			}
+		}
+		for _, destination := range destinations {
+			if err := validateURL(destination); err != nil {
+				return fmt.Errorf("%w: rule %d: %v", ErrInvalidOptions, i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// normalizeOptions trims the link metadata and turns tags into a sorted set of lower-case labels,
+// so that tag filters match regardless of how the tag was spelled on input.
+func normalizeOptions(opts storage.LinkOptions) storage.LinkOptions {
+	opts.Title = strings.TrimSpace(opts.Title)
+	opts.Note = strings.TrimSpace(opts.Note)
+
+	if opts.Tags == nil {
+		return opts
+	}
+
+	tags := make([]string, 0, len(opts.Tags))
+	for _, tag := range opts.Tags {
+		if tag = NormalizeTag(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+
+	if len(tags) == 0 {
+		tags = nil
+	}
+	opts.Tags = tags
+
+	return opts
+}
+
+// NormalizeTag returns the canonical form of a tag as it is stored and matched.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
 // GetOriginalURL retrieves the original URL by its short URL.
 func (s *URLService) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
 	return s.store.Get(ctx, shortURL)
 }
 
-// BatchShortenURLs shortens multiple URLs in a batch.
-func (s *URLService) BatchShortenURLs(ctx context.Context, urls []storage.RequestBodyBanch) ([]storage.URL, error) {
-	return s.store.SetBatch(ctx, urls)
-}
-
 // GetUserURLs retrieves all URLs for a given user ID.
 func (s *URLService) GetUserURLs(ctx context.Context) ([]storage.URL, error) {
 	var urls []storage.URL
 
 	userID, ok := ctx.Value(middleware.UserIDKey).(string)
 	if !ok || userID == "" {
-		log.Printf("Wrong userID: %v", userID)
+		logger.FromContext(ctx).Warn("request without user")
 		return urls, errors.New("user ID is empty")
 	}
 
@@ -71,65 +352,82 @@ func (s *URLService) GetUserURLs(ctx context.Context) ([]storage.URL, error) {
 	}
 
 	if len(urls) == 0 {
-		log.Printf("GetURLsByUser: No URLs found for user %s", userID)
 		return urls, nil // Return empty response
 	}
 
-	log.Printf("GetURLsByUser: Found %d URLs for user %s", len(urls), userID)
+	logger.FromContext(ctx).Debug("found user urls", zap.Int("count", len(urls)))
 
 	return urls, nil
 }
 
-// deleteRequest represents a request to delete URLs for a user.
-//
-// Fields:
-//   - URLIDs: A slice of URL IDs to be deleted.
-//   - UserID: The ID of the user requesting the deletion.
-type deleteRequest struct {
-	URLIDs []string
-	UserID string
-}
+// FindUserURLs retrieves a page of the active URLs of the user from the context that match the query.
+// Returns ErrInvalidQuery if the sort key, health filter, page size or cursor are not valid.
+func (s *URLService) FindUserURLs(ctx context.Context, query storage.URLQuery) (storage.URLPage, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		logger.FromContext(ctx).Warn("request without user")
+		return storage.URLPage{}, errors.New("user ID is empty")
+	}
 
-// deleteChan is a buffered channel used for queuing URL deletion requests.
-var deleteChan = make(chan deleteRequest, 100)
+	switch query.Sort {
+	case "", storage.SortCreated, storage.SortClicks:
+	default:
+		return storage.URLPage{}, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, query.Sort)
+	}
+
+	switch query.Health {
+	case "", storage.HealthBroken, storage.HealthOK:
+	default:
+		return storage.URLPage{}, fmt.Errorf("%w: unknown health filter %q", ErrInvalidQuery, query.Health)
+	}
+
+	if query.Limit < 0 || query.Limit > MaxPageSize {
+		return storage.URLPage{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxPageSize)
+	}
+
+	query.Tag = NormalizeTag(query.Tag)
+	query.Search = strings.TrimSpace(query.Search)
+
+	page, err := s.store.QueryByUserID(ctx, userID, query)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		return page, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+
+	return page, err
+}
 
-// DeleteUserURLs deletes URLs for a user in a batch.
-func (s *URLService) DeleteUserURLs(ctx context.Context, shortURLs []string) error {
+// DeleteUserURLs queues a job deleting the URLs of the user, see package deletes.
+//
+// It returns deletes.ErrQueueFull or deletes.ErrClosed when the queue does not accept the job.
+func (s *URLService) DeleteUserURLs(ctx context.Context, shortURLs []string) (deletes.Job, error) {
 	if len(shortURLs) == 0 {
-		return errors.New("no URLs provided for deletion")
+		return deletes.Job{}, errors.New("no URLs provided for deletion")
 	}
 
 	userID, ok := ctx.Value(middleware.UserIDKey).(string)
 	if !ok || userID == "" {
-		log.Printf("Wrong userID: %v", userID)
-		return errors.New("wrong userID")
+		logger.FromContext(ctx).Warn("request without user")
+		return deletes.Job{}, errors.New("wrong userID")
 	}
-	log.Printf("urls: %v, user: %v", shortURLs, userID)
 
-	deleteChan <- deleteRequest{URLIDs: shortURLs, UserID: userID}
+	job, err := s.deletes.Enqueue(ctx, userID, shortURLs)
+	if err != nil {
+		return deletes.Job{}, err
+	}
+	logger.FromContext(ctx).Debug("queued urls for deletion", zap.String("job_id", job.ID), zap.Strings("urls", shortURLs))
 
-	return nil
+	return job, nil
 }
 
-// StartDeleteWorker starts a worker that processes URL deletion requests from the `deleteChan`.
-//
-// This worker listens for `deleteRequest` objects on the channel and performs batch URL deletions
-// using the provided storage interface.
-//
-// Parameters:
-//   - store: The storage interface for managing URL persistence.
-//
-// Usage:
-//
-//	This function is typically started as a goroutine.
-func StartDeleteWorker(store storage.Storage) {
-	for req := range deleteChan {
-		err := store.BatchDeleteURLs(req.UserID, req.URLIDs)
-
-		if err != nil {
-			log.Printf("Error deleting URLs for user %v: %v", req.UserID, err)
-		}
+// GetDeletion returns the deletion job of the user with the given ID.
+// Returns deletes.ErrJobNotFound for unknown jobs and jobs of other users.
+func (s *URLService) GetDeletion(ctx context.Context, id string) (deletes.Job, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		return deletes.Job{}, deletes.ErrJobNotFound
 	}
+
+	return s.deletes.Get(ctx, userID, id)
 }
 
 // GetStats retrieves URL and user statistics.
@@ -137,22 +435,12 @@ func (s *URLService) GetStats(ctx context.Context) (storage.Stats, error) {
 	return s.store.GetStats(ctx)
 }
 
-// PingDatabase checks the health of the database connection.
+// PingDatabase checks that the storage backend is reachable, reusing its connections.
 func (s *URLService) PingDatabase(ctx context.Context) error {
-	db, err := sql.Open("pgx", config.Options.DatabaseDsn)
-
-	if err != nil {
-		log.Printf("Database connection error: %v", err)
-		return errors.New("unable to connect to database")
-	}
-	defer db.Close()
-
-	if err := db.Ping(); err != nil {
-		log.Printf("Database ping error: %v", err)
-		return errors.New("database is unreachable")
+	if err := s.store.Ping(ctx); err != nil {
+		logger.FromContext(ctx).Warn("storage ping failed", zap.Error(err))
+		return errors.New("storage is unreachable")
 	}
 
-	log.Println("Database is healthy")
-
 	return nil
 }
@@ -3,14 +3,20 @@ package service_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
 	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/qrcode"
 	"github.com/golangTroshin/shorturl/internal/app/service"
 	"github.com/golangTroshin/shorturl/internal/app/storage"
 	"github.com/golangTroshin/shorturl/internal/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShortenURL(t *testing.T) {
@@ -40,6 +46,14 @@ func TestShortenURL(t *testing.T) {
 		assert.Error(t, err)
 		assert.Equal(t, storage.URL{}, result)
 	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := svc.ShortenURL(context.Background(), "example.com")
+		assert.ErrorIs(t, err, service.ErrInvalidURL)
+
+		_, err = svc.ShortenURLWithOptions(context.Background(), storage.RequestURL{URL: "ftp://example.com"})
+		assert.ErrorIs(t, err, service.ErrInvalidURL)
+	})
 }
 
 func TestGetOriginalURL(t *testing.T) {
@@ -74,30 +88,55 @@ func TestBatchShortenURLs(t *testing.T) {
 
 	mockStorage := mocks.NewMockStorage(ctrl)
 	svc := service.NewURLService(mockStorage)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
 
 	batch := []storage.RequestBodyBanch{
 		{CorrelationID: "id1", OriginalURL: "http://example.com/1"},
-		{CorrelationID: "id2", OriginalURL: "http://example.com/2"},
+		{CorrelationID: "id2", OriginalURL: "example.com/2"},
+		{CorrelationID: "id3", OriginalURL: "http://example.com/3"},
 	}
+	valid := []storage.RequestBodyBanch{batch[0], batch[2]}
 
 	t.Run("Batch shorten URLs successfully", func(t *testing.T) {
-		mockStorage.EXPECT().SetBatch(gomock.Any(), batch).Return([]storage.URL{
-			{UUID: "id1", ShortURL: "short1", OriginalURL: "http://example.com/1"},
-			{UUID: "id2", ShortURL: "short2", OriginalURL: "http://example.com/2"},
+		mockStorage.EXPECT().SetBatch(gomock.Any(), valid).Return([]storage.BatchURL{
+			{URL: storage.URL{UUID: "id1", ShortURL: "short1", OriginalURL: "http://example.com/1"}},
+			{URL: storage.URL{UUID: "uuid_short3", ShortURL: "short3", OriginalURL: "http://example.com/3"}, Existing: true},
 		}, nil)
 
-		result, err := svc.BatchShortenURLs(context.Background(), batch)
+		result, err := svc.BatchShortenURLs(ctx, batch)
 
 		assert.NoError(t, err)
-		assert.Len(t, result, 2)
-		assert.Equal(t, "short1", result[0].ShortURL)
-		assert.Equal(t, "http://example.com/1", result[0].OriginalURL)
+		assert.Len(t, result, 3)
+		assert.Equal(t, service.BatchCreated, result[0].Status)
+		assert.Equal(t, "short1", result[0].URL.ShortURL)
+		assert.Equal(t, "http://example.com/1", result[0].URL.OriginalURL)
+		assert.Equal(t, service.BatchInvalid, result[1].Status)
+		assert.ErrorIs(t, result[1].Err, service.ErrInvalidURL)
+		assert.Equal(t, "id3", result[2].CorrelationID)
+		assert.Equal(t, service.BatchExisting, result[2].Status)
+		assert.Equal(t, "short3", result[2].URL.ShortURL)
+	})
+
+	t.Run("Short URL held by another URL", func(t *testing.T) {
+		mockStorage.EXPECT().SetBatch(gomock.Any(), valid).Return([]storage.BatchURL{
+			{URL: storage.URL{UUID: "id1", ShortURL: "short1", OriginalURL: "http://example.com/1"}},
+			{URL: storage.URL{OriginalURL: "http://example.com/3"}, Err: storage.ErrAliasTaken},
+		}, nil)
+
+		result, err := svc.BatchShortenURLs(ctx, batch)
+
+		assert.NoError(t, err)
+		assert.Len(t, result, 3)
+		assert.Equal(t, service.BatchCreated, result[0].Status)
+		assert.Equal(t, service.BatchFailed, result[2].Status)
+		assert.ErrorIs(t, result[2].Err, storage.ErrAliasTaken)
+		assert.Equal(t, "http://example.com/3", result[2].URL.OriginalURL)
 	})
 
 	t.Run("Error in batch shortening URLs", func(t *testing.T) {
-		mockStorage.EXPECT().SetBatch(gomock.Any(), batch).Return(nil, errors.New("batch error"))
+		mockStorage.EXPECT().SetBatch(gomock.Any(), valid).Return(nil, errors.New("batch error"))
 
-		result, err := svc.BatchShortenURLs(context.Background(), batch)
+		result, err := svc.BatchShortenURLs(ctx, batch)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
@@ -145,14 +184,24 @@ func TestDeleteUserURLs(t *testing.T) {
 
 	t.Run("Delete user URLs successfully", func(t *testing.T) {
 		ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
-		err := svc.DeleteUserURLs(ctx, []string{"short1", "short2"})
+		job, err := svc.DeleteUserURLs(ctx, []string{"short1", "short2"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, deletes.StatusPending, job.Status)
 
+		// The job is only visible to its user.
+		found, err := svc.GetDeletion(ctx, job.ID)
 		assert.NoError(t, err)
+		assert.Equal(t, []string{"short1", "short2"}, found.URLs)
+
+		other := context.WithValue(context.Background(), middleware.UserIDKey, "user456")
+		_, err = svc.GetDeletion(other, job.ID)
+		assert.ErrorIs(t, err, deletes.ErrJobNotFound)
 	})
 
 	t.Run("Error deleting user URLs (empty list)", func(t *testing.T) {
 		ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
-		err := svc.DeleteUserURLs(ctx, []string{})
+		_, err := svc.DeleteUserURLs(ctx, []string{})
 
 		assert.Error(t, err)
 		assert.Equal(t, "no URLs provided for deletion", err.Error())
@@ -188,3 +237,216 @@ func TestGetStats(t *testing.T) {
 		assert.Equal(t, storage.Stats{}, result)
 	})
 }
+
+func TestResolveURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	svc := service.NewURLService(mockStorage)
+
+	t.Run("Active link", func(t *testing.T) {
+		mockStorage.EXPECT().GetURL(gomock.Any(), "short123").Return(
+			storage.URL{ShortURL: "short123", OriginalURL: "http://example.com"}, nil,
+		)
+
+		result, err := svc.ResolveURL(context.Background(), "short123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "http://example.com", result.OriginalURL)
+	})
+
+	t.Run("Expired link", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Hour)
+		mockStorage.EXPECT().GetURL(gomock.Any(), "short123").Return(
+			storage.URL{ShortURL: "short123", LinkOptions: storage.LinkOptions{ExpiresAt: &expiresAt}}, nil,
+		)
+
+		_, err := svc.ResolveURL(context.Background(), "short123")
+
+		assert.ErrorIs(t, err, service.ErrURLExpired)
+	})
+}
+
+func TestShortenURLWithOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	svc := service.NewURLService(mockStorage)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	t.Run("Options are applied to a new link", func(t *testing.T) {
+		opts := storage.LinkOptions{RedirectType: 301}
+		mockStorage.EXPECT().SetWithAlias(gomock.Any(), "http://example.com", "", opts).Return(
+			storage.URL{ShortURL: "short123", UserID: "user123", LinkOptions: opts}, nil,
+		)
+
+		result, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{URL: "http://example.com", LinkOptions: opts})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 301, result.RedirectType)
+	})
+
+	t.Run("Invalid redirect type", func(t *testing.T) {
+		_, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL:         "http://example.com",
+			LinkOptions: storage.LinkOptions{RedirectType: 200},
+		})
+
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+	})
+
+	t.Run("Metadata is normalized", func(t *testing.T) {
+		want := storage.LinkOptions{Title: "Example", Tags: []string{"docs", "work"}}
+		mockStorage.EXPECT().SetWithAlias(gomock.Any(), "http://example.com", "", want).Return(
+			storage.URL{ShortURL: "short123", UserID: "user123", LinkOptions: want}, nil,
+		)
+
+		_, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL:         "http://example.com",
+			LinkOptions: storage.LinkOptions{Title: " Example ", Tags: []string{"Work", " docs", "work", ""}},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Too many tags", func(t *testing.T) {
+		tags := make([]string, service.MaxTags+1)
+		for i := range tags {
+			tags[i] = fmt.Sprintf("tag%d", i)
+		}
+
+		_, err := svc.ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL:         "http://example.com",
+			LinkOptions: storage.LinkOptions{Tags: tags},
+		})
+
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+	})
+
+	t.Run("Nothing is published when the link is not created", func(t *testing.T) {
+		events := broker.New(broker.DefaultBuffer)
+		defer events.Close()
+		sub := events.Subscribe("user123")
+		defer sub.Close()
+
+		mockStorage.EXPECT().SetWithAlias(gomock.Any(), "http://example.com", "", storage.LinkOptions{Title: "Example"}).Return(
+			storage.URL{}, storage.ErrAliasTaken,
+		)
+
+		_, err := service.NewURLService(mockStorage).WithBroker(events).ShortenURLWithOptions(ctx, storage.RequestURL{
+			URL:         "http://example.com",
+			LinkOptions: storage.LinkOptions{Title: "Example"},
+		})
+
+		assert.ErrorIs(t, err, storage.ErrAliasTaken)
+		select {
+		case event := <-sub.Events():
+			t.Fatalf("unexpected event %+v", event)
+		default:
+		}
+	})
+}
+
+func TestFindUserURLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	svc := service.NewURLService(mockStorage)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	mockStorage.EXPECT().QueryByUserID(gomock.Any(), "user123", storage.URLQuery{Tag: "work", Search: "docs"}).Return(
+		storage.URLPage{URLs: []storage.URL{{ShortURL: "short1"}}}, nil,
+	)
+
+	page, err := svc.FindUserURLs(ctx, storage.URLQuery{Tag: " Work ", Search: " docs "})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.URLs, 1)
+
+	_, err = svc.FindUserURLs(context.Background(), storage.URLQuery{})
+	assert.Error(t, err)
+
+	_, err = svc.FindUserURLs(ctx, storage.URLQuery{Sort: "name"})
+	assert.ErrorIs(t, err, service.ErrInvalidQuery)
+
+	_, err = svc.FindUserURLs(ctx, storage.URLQuery{Limit: service.MaxPageSize + 1})
+	assert.ErrorIs(t, err, service.ErrInvalidQuery)
+
+	mockStorage.EXPECT().QueryByUserID(gomock.Any(), "user123", storage.URLQuery{Cursor: "bogus"}).Return(
+		storage.URLPage{}, storage.ErrInvalidCursor,
+	)
+	_, err = svc.FindUserURLs(ctx, storage.URLQuery{Cursor: "bogus"})
+	assert.ErrorIs(t, err, service.ErrInvalidQuery)
+}
+
+func TestQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorage(ctrl)
+	svc := service.NewURLService(mockStorage)
+
+	t.Run("Active link", func(t *testing.T) {
+		mockStorage.EXPECT().GetURL(gomock.Any(), "short123").Return(storage.URL{ShortURL: "short123"}, nil)
+
+		image, err := svc.QRCode(context.Background(), "short123", "H", qrcode.ImageOptions{Format: qrcode.FormatSVG, Margin: 1})
+
+		assert.NoError(t, err)
+		assert.Contains(t, string(image), "<svg")
+	})
+
+	t.Run("Deleted link", func(t *testing.T) {
+		mockStorage.EXPECT().GetURL(gomock.Any(), "short123").Return(storage.URL{}, storage.NewDeletedURLError())
+
+		_, err := svc.QRCode(context.Background(), "short123", "", qrcode.ImageOptions{})
+
+		var deleted *storage.DeletedURLError
+		assert.ErrorAs(t, err, &deleted)
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		_, err := svc.QRCode(context.Background(), "short123", "X", qrcode.ImageOptions{})
+		assert.ErrorIs(t, err, service.ErrInvalidQRCode)
+
+		_, err = svc.QRCode(context.Background(), "short123", "L", qrcode.ImageOptions{Format: "gif"})
+		assert.ErrorIs(t, err, service.ErrInvalidQRCode)
+	})
+}
+
+func TestSetURLRules(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	url, err := svc.ShortenURL(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, err = svc.UpdateURLOptions(ctx, url.ShortURL, storage.LinkOptions{Title: "Example", Tags: []string{"docs"}})
+	require.NoError(t, err)
+
+	updated, err := svc.SetURLRules(ctx, url.ShortURL, []storage.Rule{{Device: "mobile", Destination: "https://m.example.com"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Example", updated.Title, "only the rules are replaced")
+	assert.Equal(t, []string{"docs"}, updated.Tags)
+
+	tests := map[string]storage.Rule{
+		"Script destination":   {Device: "mobile", Destination: "javascript:alert(1)"},
+		"Relative destination": {Device: "mobile", Destination: "/mobile"},
+		"Script split variant": {Split: []storage.SplitVariant{
+			{Destination: "https://a.example.com", Weight: 1},
+			{Destination: "javascript:alert(1)", Weight: 1},
+		}},
+	}
+	for name, rule := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.SetURLRules(ctx, url.ShortURL, []storage.Rule{rule})
+			assert.ErrorIs(t, err, service.ErrInvalidOptions)
+		})
+	}
+
+	link, err := store.GetURL(ctx, url.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://m.example.com", link.Rules[0].Destination, "invalid rules are not stored")
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/deletes"
+	"github.com/golangTroshin/shorturl/internal/app/qrcode"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of service spans.
+const tracerName = "github.com/golangTroshin/shorturl/internal/app/service"
+
+var _ Service = (*TracedService)(nil) // Ensures TracedService implements Service
+
+// TracedService starts a span around every method of a Service, named after the method,
+// such as "Service.ResolveURL". Returned errors are recorded on the span.
+type TracedService struct {
+	svc Service
+}
+
+// NewTracedService wraps the service.
+func NewTracedService(svc Service) *TracedService {
+	return &TracedService{svc: svc}
+}
+
+// startSpan starts the span of a method.
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "Service."+method)
+}
+
+// endSpan ends the span of a method that returned err.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ShortenURL shortens a single URL.
+func (s *TracedService) ShortenURL(ctx context.Context, originalURL string) (storage.URL, error) {
+	ctx, span := startSpan(ctx, "ShortenURL")
+	url, err := s.svc.ShortenURL(ctx, originalURL)
+	endSpan(span, err)
+	return url, err
+}
+
+// GetOriginalURL retrieves the original URL of a short URL.
+func (s *TracedService) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
+	ctx, span := startSpan(ctx, "GetOriginalURL")
+	value, err := s.svc.GetOriginalURL(ctx, shortURL)
+	endSpan(span, err)
+	return value, err
+}
+
+// BatchShortenURLs shortens a batch of URLs.
+func (s *TracedService) BatchShortenURLs(ctx context.Context, urls []storage.RequestBodyBanch) ([]BatchResult, error) {
+	ctx, span := startSpan(ctx, "BatchShortenURLs")
+	results, err := s.svc.BatchShortenURLs(ctx, urls)
+	endSpan(span, err)
+	return results, err
+}
+
+// GetUserURLs retrieves the URLs of the user.
+func (s *TracedService) GetUserURLs(ctx context.Context) ([]storage.URL, error) {
+	ctx, span := startSpan(ctx, "GetUserURLs")
+	urls, err := s.svc.GetUserURLs(ctx)
+	endSpan(span, err)
+	return urls, err
+}
+
+// DeleteUserURLs queues a job deleting URLs of the user.
+func (s *TracedService) DeleteUserURLs(ctx context.Context, shortURLs []string) (deletes.Job, error) {
+	ctx, span := startSpan(ctx, "DeleteUserURLs")
+	job, err := s.svc.DeleteUserURLs(ctx, shortURLs)
+	endSpan(span, err)
+	return job, err
+}
+
+// GetDeletion returns the deletion job of the user with the given ID.
+func (s *TracedService) GetDeletion(ctx context.Context, id string) (deletes.Job, error) {
+	ctx, span := startSpan(ctx, "GetDeletion")
+	job, err := s.svc.GetDeletion(ctx, id)
+	endSpan(span, err)
+	return job, err
+}
+
+// GetStats retrieves URL and user statistics.
+func (s *TracedService) GetStats(ctx context.Context) (storage.Stats, error) {
+	ctx, span := startSpan(ctx, "GetStats")
+	stats, err := s.svc.GetStats(ctx)
+	endSpan(span, err)
+	return stats, err
+}
+
+// PingDatabase checks that the storage backend is reachable.
+func (s *TracedService) PingDatabase(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "PingDatabase")
+	err := s.svc.PingDatabase(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// ShortenURLWithOptions shortens a URL with per-link settings.
+func (s *TracedService) ShortenURLWithOptions(ctx context.Context, req storage.RequestURL) (storage.URL, error) {
+	ctx, span := startSpan(ctx, "ShortenURLWithOptions")
+	url, err := s.svc.ShortenURLWithOptions(ctx, req)
+	endSpan(span, err)
+	return url, err
+}
+
+// ResolveURL retrieves the link record of a short URL for redirecting.
+func (s *TracedService) ResolveURL(ctx context.Context, shortURL string) (storage.URL, error) {
+	ctx, span := startSpan(ctx, "ResolveURL")
+	url, err := s.svc.ResolveURL(ctx, shortURL)
+	endSpan(span, err)
+	return url, err
+}
+
+// RecordClick counts a redirect of the short URL.
+func (s *TracedService) RecordClick(ctx context.Context, shortURL string) error {
+	ctx, span := startSpan(ctx, "RecordClick")
+	err := s.svc.RecordClick(ctx, shortURL)
+	endSpan(span, err)
+	return err
+}
+
+// UpdateURLOptions replaces the settings of a URL of the user.
+func (s *TracedService) UpdateURLOptions(ctx context.Context, shortURL string, opts storage.LinkOptions) (storage.URL, error) {
+	ctx, span := startSpan(ctx, "UpdateURLOptions")
+	url, err := s.svc.UpdateURLOptions(ctx, shortURL, opts)
+	endSpan(span, err)
+	return url, err
+}
+
+// GetUserURL retrieves a URL of the user.
+func (s *TracedService) GetUserURL(ctx context.Context, shortURL string) (storage.URL, error) {
+	ctx, span := startSpan(ctx, "GetUserURL")
+	url, err := s.svc.GetUserURL(ctx, shortURL)
+	endSpan(span, err)
+	return url, err
+}
+
+// SetURLRules replaces the conditional redirect rules of a URL of the user.
+func (s *TracedService) SetURLRules(ctx context.Context, shortURL string, rules []storage.Rule) (storage.URL, error) {
+	ctx, span := startSpan(ctx, "SetURLRules")
+	url, err := s.svc.SetURLRules(ctx, shortURL, rules)
+	endSpan(span, err)
+	return url, err
+}
+
+// FindUserURLs retrieves a page of the user's URLs matching the query.
+func (s *TracedService) FindUserURLs(ctx context.Context, query storage.URLQuery) (storage.URLPage, error) {
+	ctx, span := startSpan(ctx, "FindUserURLs")
+	page, err := s.svc.FindUserURLs(ctx, query)
+	endSpan(span, err)
+	return page, err
+}
+
+// QRCode renders the QR code image of a short URL.
+func (s *TracedService) QRCode(ctx context.Context, shortURL string, level string, opts qrcode.ImageOptions) ([]byte, error) {
+	ctx, span := startSpan(ctx, "QRCode")
+	image, err := s.svc.QRCode(ctx, shortURL, level, opts)
+	endSpan(span, err)
+	return image, err
+}
+
+// ImportURL stores an imported link.
+func (s *TracedService) ImportURL(ctx context.Context, req storage.RequestURL, alias string) (storage.URL, error) {
+	ctx, span := startSpan(ctx, "ImportURL")
+	url, err := s.svc.ImportURL(ctx, req, alias)
+	endSpan(span, err)
+	return url, err
+}
+
+// ExportUserURLs passes every active URL of the user to yield.
+func (s *TracedService) ExportUserURLs(ctx context.Context, yield func(storage.URL) error) error {
+	ctx, span := startSpan(ctx, "ExportUserURLs")
+	err := s.svc.ExportUserURLs(ctx, yield)
+	endSpan(span, err)
+	return err
+}
+
+// WatchLinks subscribes to the events of the links of the user. The span covers the
+// subscription, not the lifetime of the stream.
+func (s *TracedService) WatchLinks(ctx context.Context) (*broker.Subscription, error) {
+	ctx, span := startSpan(ctx, "WatchLinks")
+	sub, err := s.svc.WatchLinks(ctx)
+	endSpan(span, err)
+	return sub, err
+}
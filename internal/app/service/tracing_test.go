@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/golangTroshin/shorturl/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracedService(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockService(ctrl)
+	svc := service.NewTracedService(mockService)
+
+	var spanContext trace.SpanContext
+	mockService.EXPECT().ResolveURL(gomock.Any(), "short123").DoAndReturn(
+		func(ctx context.Context, shortURL string) (storage.URL, error) {
+			spanContext = trace.SpanContextFromContext(ctx)
+			return storage.URL{ShortURL: shortURL}, nil
+		},
+	)
+	mockService.EXPECT().RecordClick(gomock.Any(), "short123").Return(errors.New("storage error"))
+
+	_, err := svc.ResolveURL(context.Background(), "short123")
+	require.NoError(t, err)
+	assert.Error(t, svc.RecordClick(context.Background(), "short123"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "Service.ResolveURL", spans[0].Name())
+	assert.Equal(t, spans[0].SpanContext().SpanID(), spanContext.SpanID(), "the wrapped service runs inside the span")
+	assert.Equal(t, "Service.RecordClick", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
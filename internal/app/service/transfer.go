@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+// exportPageSize is the number of links read from the storage at a time while exporting.
+const exportPageSize = 500
+
+// reservedAliases are path segments served by fixed routes, which cannot be used as short URLs.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"healthz": {},
+	"readyz":  {},
+}
+
+// ImportURL shortens a single imported URL for the user from the context, under the alias
+// when one is given, storing the new link together with the per-link settings from the request.
+//
+// Like ShortenURL, an existing link is never overwritten: when the URL is already stored,
+// the existing link is returned together with a storage.InsertConflictError.
+// Returns ErrInvalidURL, ErrInvalidAlias or ErrInvalidOptions if the row fails validation
+// and storage.ErrAliasTaken if the alias is used by another URL.
+func (s *URLService) ImportURL(ctx context.Context, req storage.RequestURL, alias string) (storage.URL, error) {
+	if userID, ok := ctx.Value(middleware.UserIDKey).(string); !ok || userID == "" {
+		return storage.URL{}, errors.New("user ID is empty")
+	}
+
+	if err := validateURL(req.URL); err != nil {
+		return storage.URL{}, err
+	}
+
+	if err := validateAlias(alias); err != nil {
+		return storage.URL{}, err
+	}
+
+	req.LinkOptions = normalizeOptions(req.LinkOptions)
+	if err := validateOptions(req.LinkOptions); err != nil {
+		return storage.URL{}, err
+	}
+
+	link, err := s.store.SetWithAlias(ctx, req.URL, alias, req.LinkOptions)
+	if err != nil {
+		return link, err
+	}
+	enqueuePageFetch(link)
+	s.publish(broker.EventCreated, link)
+
+	return link, nil
+}
+
+// ExportUserURLs calls yield for every active link of the user from the context, oldest first.
+//
+// Links are read from the storage page by page, so exporting does not hold the whole list
+// in memory on any backend. Iteration stops at the first error returned by yield.
+func (s *URLService) ExportUserURLs(ctx context.Context, yield func(storage.URL) error) error {
+	query := storage.URLQuery{Ascending: true, Limit: exportPageSize}
+
+	for {
+		page, err := s.FindUserURLs(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range page.URLs {
+			if err := yield(link); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// validateURL checks that a URL to shorten is an absolute http or https URL.
+func validateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: %q is not an absolute http or https URL", ErrInvalidURL, rawURL)
+	}
+
+	return nil
+}
+
+// validateAlias checks that a requested short URL consists of letters, digits, '-' and '_'
+// and does not shadow a fixed route. An empty alias is valid and selects a generated short URL.
+func validateAlias(alias string) error {
+	if len(alias) > MaxAliasLength {
+		return fmt.Errorf("%w: longer than %d bytes", ErrInvalidAlias, MaxAliasLength)
+	}
+
+	for _, r := range alias {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("%w: %q may only contain letters, digits, '-' and '_'", ErrInvalidAlias, alias)
+		}
+	}
+
+	if _, reserved := reservedAliases[alias]; reserved {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/service"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportURL(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+
+	t.Run("With alias and metadata", func(t *testing.T) {
+		link, err := svc.ImportURL(ctx, storage.RequestURL{
+			URL:         "https://example.com/docs",
+			LinkOptions: storage.LinkOptions{Title: " Docs ", Tags: []string{"Work", "work"}},
+		}, "docs")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "docs", link.ShortURL)
+		assert.Equal(t, "Docs", link.Title)
+		assert.Equal(t, []string{"work"}, link.Tags)
+	})
+
+	t.Run("Existing URL is left unchanged", func(t *testing.T) {
+		link, err := svc.ImportURL(ctx, storage.RequestURL{
+			URL:         "https://example.com/docs",
+			LinkOptions: storage.LinkOptions{Title: "Other"},
+		}, "")
+
+		var conflict *storage.InsertConflictError
+		assert.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "docs", link.ShortURL)
+
+		stored, err := store.GetURL(ctx, "docs")
+		assert.NoError(t, err)
+		assert.Equal(t, "Docs", stored.Title)
+	})
+
+	tests := []struct {
+		name  string
+		url   string
+		alias string
+		err   error
+	}{
+		{"Relative URL", "/docs", "", service.ErrInvalidURL},
+		{"Unsupported scheme", "ftp://example.com", "", service.ErrInvalidURL},
+		{"Alias with slash", "https://example.com/a", "a/b", service.ErrInvalidAlias},
+		{"Reserved alias", "https://example.com/a", "api", service.ErrInvalidAlias},
+		{"Liveness probe alias", "https://example.com/a", "healthz", service.ErrInvalidAlias},
+		{"Readiness probe alias", "https://example.com/a", "readyz", service.ErrInvalidAlias},
+		{"Taken alias", "https://example.com/a", "docs", storage.ErrAliasTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ImportURL(ctx, storage.RequestURL{URL: tt.url}, tt.alias)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestExportUserURLs(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewURLService(store)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+	otherCtx := context.WithValue(context.Background(), middleware.UserIDKey, "other")
+
+	// More links than fit into one storage page
+	const total = 1203
+	for i := 0; i < total; i++ {
+		_, err := store.Set(ctx, fmt.Sprintf("https://example.com/%d", i))
+		assert.NoError(t, err)
+	}
+	_, err := store.Set(otherCtx, "https://example.org")
+	assert.NoError(t, err)
+
+	seen := make(map[string]struct{})
+	err = svc.ExportUserURLs(ctx, func(link storage.URL) error {
+		seen[link.ShortURL] = struct{}{}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, seen, total)
+
+	t.Run("Stops at the first error", func(t *testing.T) {
+		stop := errors.New("stop")
+		count := 0
+		err := svc.ExportUserURLs(ctx, func(storage.URL) error {
+			count++
+			return stop
+		})
+
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, count)
+	})
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/golangTroshin/shorturl/internal/app/broker"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// WithBroker makes the service publish link events to b, which is shared with the transports
+// serving WatchLinks.
+func (s *URLService) WithBroker(b *broker.Broker) *URLService {
+	s.events = b
+	return s
+}
+
+// WatchLinks subscribes to the events of the links of the user from the context: creations,
+// updates of their settings, deletions and clicks. The caller must close the subscription.
+func (s *URLService) WatchLinks(ctx context.Context) (*broker.Subscription, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		logger.FromContext(ctx).Warn("request without user")
+		return nil, errors.New("wrong userID")
+	}
+
+	return s.events.Subscribe(userID), nil
+}
+
+// LinksDeleted publishes the deletion of the short URLs of the user. It is called by the
+// deletes.Worker once the storage deleted them, see deletes.Options.Deleted.
+func (s *URLService) LinksDeleted(userID string, shortURLs []string) {
+	for _, shortURL := range shortURLs {
+		s.publish(broker.EventDeleted, storage.URL{UserID: userID, ShortURL: shortURL})
+	}
+}
+
+// publish sends an event of the link to the watchers of its owner.
+func (s *URLService) publish(eventType string, url storage.URL) {
+	s.events.Publish(broker.Event{
+		Type:        eventType,
+		UserID:      url.UserID,
+		ShortURL:    url.ShortURL,
+		OriginalURL: url.OriginalURL,
+	})
+}
+
+// publishClick publishes a click on the short URL. The owner of the link is only looked up
+// while someone watches links, so redirects cost no extra storage read otherwise.
+func (s *URLService) publishClick(ctx context.Context, shortURL string) {
+	if s.events.Subscribers() == 0 {
+		return
+	}
+
+	url, err := s.store.GetURL(ctx, shortURL)
+	if err != nil {
+		logger.FromContext(ctx).Debug("unable to publish click", zap.String("short_url", shortURL), zap.Error(err))
+		return
+	}
+	s.publish(broker.EventClicked, url)
+}
@@ -0,0 +1,401 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"go.uber.org/zap"
+)
+
+// Defaults of CacheOptions.
+const (
+	DefaultCacheTTL         = 5 * time.Minute
+	DefaultCacheNegativeTTL = 30 * time.Second
+)
+
+// Reconnect delays of the invalidation listener.
+const (
+	minListenBackoff = time.Second
+	maxListenBackoff = 30 * time.Second
+)
+
+// CacheOptions configures a CachedStore.
+type CacheOptions struct {
+	Size        int           // Largest number of cached links; older entries are evicted first
+	TTL         time.Duration // Lifetime of a cached link; zero selects DefaultCacheTTL
+	NegativeTTL time.Duration // Lifetime of a cached miss; zero selects DefaultCacheNegativeTTL
+}
+
+// CacheStats holds the counters of a CachedStore.
+type CacheStats struct {
+	Hits    uint64 // Lookups served from the cache, including cached misses
+	Misses  uint64 // Lookups passed to the underlying storage
+	Entries int    // Links and misses currently cached
+}
+
+// HitRatio returns the share of lookups served from the cache, or zero before the first lookup.
+func (stats CacheStats) HitRatio() float64 {
+	if stats.Hits+stats.Misses == 0 {
+		return 0
+	}
+	return float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+}
+
+// InvalidationSource reports links changed in the storage, possibly by other instances.
+type InvalidationSource interface {
+	// ListenInvalidations blocks until ctx is done or the subscription fails. It calls subscribed
+	// once notifications are delivered and invalidate with the short URL of every changed link.
+	ListenInvalidations(ctx context.Context, subscribed func(), invalidate func(key string)) error
+}
+
+// CachedStore is a read-through cache of link records in front of any Storage.
+//
+// GetURL, which serves every redirect, is answered from a size-bounded LRU cache. Unknown and
+// deleted links are cached as well, unknown ones for the shorter NegativeTTL. Writes made through
+// the store invalidate the affected links; changes made by other instances are picked up by
+// StartInvalidationListener when the underlying storage is an InvalidationSource.
+// Click counts of cached links are incremented locally and may lag behind other instances
+// until the entry expires.
+//
+// All other methods, including ones added to Storage later, are passed through unchanged.
+type CachedStore struct {
+	Storage
+
+	cache     *lruCache
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	listening atomic.Bool
+}
+
+var _ Storage = (*CachedStore)(nil) // Ensures CachedStore implements Storage
+
+// NewCachedStore wraps the storage with a cache of the given size.
+func NewCachedStore(store Storage, opts CacheOptions) *CachedStore {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultCacheTTL
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = DefaultCacheNegativeTTL
+	}
+
+	return &CachedStore{
+		Storage: store,
+		cache:   newLRUCache(opts.Size, opts.TTL, opts.NegativeTTL),
+	}
+}
+
+// GetURL retrieves the full link record for the given short URL, from the cache when possible.
+func (store *CachedStore) GetURL(ctx context.Context, key string) (URL, error) {
+	if entry, ok := store.cache.get(key); ok {
+		store.hits.Add(1)
+		return entry.url, entry.err
+	}
+	store.misses.Add(1)
+
+	generation := store.cache.generation()
+	url, err := store.Storage.GetURL(ctx, key)
+
+	var deleted *DeletedURLError
+	if err == nil || errors.Is(err, ErrURLNotFound) || errors.As(err, &deleted) {
+		store.cache.add(key, url, err, generation)
+	}
+
+	return url, err
+}
+
+// Set stores the URL and invalidates its cached short URL.
+func (store *CachedStore) Set(ctx context.Context, value string) (URL, error) {
+	url, err := store.Storage.Set(ctx, value)
+	store.cache.remove(url.ShortURL)
+	return url, err
+}
+
+// SetBatch stores the URLs and invalidates the cached short URLs of the new links.
+func (store *CachedStore) SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]BatchURL, error) {
+	urls, err := store.Storage.SetBatch(ctx, batch)
+	for _, url := range urls {
+		if !url.Existing && url.Err == nil {
+			store.cache.remove(url.ShortURL)
+		}
+	}
+	return urls, err
+}
+
+// SetWithAlias stores the URL and invalidates its cached short URL.
+func (store *CachedStore) SetWithAlias(ctx context.Context, value string, alias string, opts LinkOptions) (URL, error) {
+	url, err := store.Storage.SetWithAlias(ctx, value, alias, opts)
+	store.cache.remove(url.ShortURL)
+	return url, err
+}
+
+// BatchDeleteURLs marks the URLs as deleted and invalidates them.
+func (store *CachedStore) BatchDeleteURLs(userID string, batch []string) ([]string, error) {
+	deleted, err := store.Storage.BatchDeleteURLs(userID, batch)
+	for _, key := range batch {
+		store.cache.remove(key)
+	}
+	return deleted, err
+}
+
+// UpdateOptions replaces the settings of the URL and invalidates it.
+func (store *CachedStore) UpdateOptions(ctx context.Context, userID string, key string, opts LinkOptions) (URL, error) {
+	url, err := store.Storage.UpdateOptions(ctx, userID, key, opts)
+	store.cache.remove(key)
+	return url, err
+}
+
+// UpdateRules replaces the redirect rules of the URL and invalidates it.
+func (store *CachedStore) UpdateRules(ctx context.Context, userID string, key string, rules []Rule) (URL, error) {
+	url, err := store.Storage.UpdateRules(ctx, userID, key, rules)
+	store.cache.remove(key)
+	return url, err
+}
+
+// RecordClick counts the click and increments the click counter of the cached link.
+func (store *CachedStore) RecordClick(ctx context.Context, key string) error {
+	if err := store.Storage.RecordClick(ctx, key); err != nil {
+		return err
+	}
+
+	store.cache.update(key, func(url *URL) { url.Clicks++ })
+	return nil
+}
+
+// SetPageInfo stores the destination page metadata and invalidates the link.
+func (store *CachedStore) SetPageInfo(ctx context.Context, key string, page PageInfo) error {
+	err := store.Storage.SetPageInfo(ctx, key, page)
+	store.cache.remove(key)
+	return err
+}
+
+// SetHealth records the health check result and invalidates the link.
+func (store *CachedStore) SetHealth(ctx context.Context, key string, health LinkHealth) error {
+	err := store.Storage.SetHealth(ctx, key, health)
+	store.cache.remove(key)
+	return err
+}
+
+// Invalidate removes the link with the given short URL from the cache.
+func (store *CachedStore) Invalidate(key string) {
+	store.cache.remove(key)
+}
+
+// Purge removes all links from the cache.
+func (store *CachedStore) Purge() {
+	store.cache.purge()
+}
+
+// Stats returns the hit and miss counters and the number of cached entries.
+func (store *CachedStore) Stats() CacheStats {
+	return CacheStats{
+		Hits:    store.hits.Load(),
+		Misses:  store.misses.Load(),
+		Entries: store.cache.len(),
+	}
+}
+
+// Unwrap returns the cached storage.
+func (store *CachedStore) Unwrap() Storage {
+	return store.Storage
+}
+
+// Close closes the underlying storage if it holds resources.
+func (store *CachedStore) Close() error {
+	if closer, ok := store.Storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// StartInvalidationListener invalidates cached links changed in the underlying storage, which
+// keeps the caches of several instances sharing a database coherent. It blocks until ctx is done
+// and returns immediately if neither the storage nor a storage wrapped by it reports changes.
+//
+// The subscription is renewed with increasing delays when it fails. Because changes may have
+// been missed meanwhile, the whole cache is purged whenever the subscription is established.
+func (store *CachedStore) StartInvalidationListener(ctx context.Context) {
+	source, ok := invalidationSource(store.Storage)
+	if !ok {
+		return
+	}
+
+	backoff := minListenBackoff
+	for {
+		err := source.ListenInvalidations(ctx,
+			func() {
+				backoff = minListenBackoff
+				store.Purge()
+				store.listening.Store(true)
+			},
+			store.Invalidate,
+		)
+		store.listening.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Default().Warn("cache invalidation listener failed", zap.Duration("retry_in", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxListenBackoff)
+	}
+}
+
+// CheckInvalidation reports an error while the underlying storage reports changes but the
+// invalidation listener is not subscribed to them, so that cached links may be stale.
+func (store *CachedStore) CheckInvalidation(_ context.Context) error {
+	if _, ok := invalidationSource(store.Storage); !ok {
+		return nil
+	}
+	if !store.listening.Load() {
+		return errors.New("cache invalidation listener is not subscribed")
+	}
+	return nil
+}
+
+// invalidationSource finds an InvalidationSource among the storage and the storages it wraps.
+func invalidationSource(store Storage) (InvalidationSource, bool) {
+	return find[InvalidationSource](store)
+}
+
+// lruCache is a size-bounded map of link lookups with per-entry expiry, evicting the least
+// recently used entry when full.
+type lruCache struct {
+	mu          sync.Mutex
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	items       map[string]*list.Element
+	order       *list.List // Front is the most recently used entry
+	gen         uint64     // Incremented by every invalidation
+	now         func() time.Time
+}
+
+// cacheEntry is the cached result of a lookup.
+type cacheEntry struct {
+	key     string
+	url     URL
+	err     error
+	expires time.Time
+}
+
+func newLRUCache(size int, ttl, negativeTTL time.Duration) *lruCache {
+	return &lruCache{
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		items:       make(map[string]*list.Element),
+		order:       list.New(),
+		now:         time.Now,
+	}
+}
+
+// get returns the cached result of a lookup and whether one was found.
+func (c *lruCache) get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.removeElement(element)
+		return cacheEntry{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return *entry, true
+}
+
+// generation returns the invalidation counter, to be passed to add after loading a value.
+func (c *lruCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// add caches the result of a lookup unless an invalidation happened since generation was read,
+// in which case the result may already be stale.
+func (c *lruCache) add(key string, url URL, err error, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 || generation != c.gen {
+		return
+	}
+
+	ttl := c.ttl
+	if errors.Is(err, ErrURLNotFound) {
+		ttl = c.negativeTTL
+	}
+	entry := &cacheEntry{key: key, url: url, err: err, expires: c.now().Add(ttl)}
+
+	if element, ok := c.items[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// update changes a cached link in place.
+func (c *lruCache) update(key string, change func(url *URL)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		if entry := element.Value.(*cacheEntry); entry.err == nil {
+			change(&entry.url)
+		}
+	}
+}
+
+// remove invalidates a key.
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// purge invalidates all keys.
+func (c *lruCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *lruCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*cacheEntry).key)
+}
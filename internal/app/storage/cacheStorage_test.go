@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the lookups reaching the underlying storage.
+type countingStore struct {
+	Storage
+	lookups atomic.Int64
+}
+
+func (store *countingStore) GetURL(ctx context.Context, key string) (URL, error) {
+	store.lookups.Add(1)
+	return store.Storage.GetURL(ctx, key)
+}
+
+// fakeInvalidationSource is a memory store reporting the keys sent to changes as changed.
+type fakeInvalidationSource struct {
+	*MemoryStore
+	changes chan string
+}
+
+func (store *fakeInvalidationSource) ListenInvalidations(ctx context.Context, subscribed func(), invalidate func(key string)) error {
+	subscribed()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case key := <-store.changes:
+			invalidate(key)
+		}
+	}
+}
+
+func newTestCachedStore(size int) (*CachedStore, *countingStore, context.Context) {
+	inner := &countingStore{Storage: NewMemoryStore()}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+	return NewCachedStore(inner, CacheOptions{Size: size}), inner, ctx
+}
+
+func TestCachedStore_GetURLHitsAndMisses(t *testing.T) {
+	store, inner, ctx := newTestCachedStore(10)
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		link, err := store.GetURL(ctx, url.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", link.OriginalURL)
+	}
+
+	assert.Equal(t, int64(1), inner.lookups.Load())
+	stats := store.Stats()
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Entries: 1}, stats)
+	assert.InDelta(t, 2.0/3.0, stats.HitRatio(), 0.001)
+}
+
+func TestCachedStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store, inner, ctx := newTestCachedStore(2)
+
+	var keys []string
+	for _, value := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"} {
+		url, err := store.Set(ctx, value)
+		require.NoError(t, err)
+		keys = append(keys, url.ShortURL)
+	}
+
+	_, _ = store.GetURL(ctx, keys[0])
+	_, _ = store.GetURL(ctx, keys[1])
+	_, _ = store.GetURL(ctx, keys[0]) // keys[1] becomes the least recently used entry
+	_, _ = store.GetURL(ctx, keys[2])
+	assert.Equal(t, 2, store.Stats().Entries)
+	assert.Equal(t, int64(3), inner.lookups.Load())
+
+	_, _ = store.GetURL(ctx, keys[0])
+	assert.Equal(t, int64(3), inner.lookups.Load())
+
+	_, _ = store.GetURL(ctx, keys[1])
+	assert.Equal(t, int64(4), inner.lookups.Load())
+}
+
+func TestCachedStore_TTL(t *testing.T) {
+	store, inner, ctx := newTestCachedStore(10)
+	now := time.Now()
+	store.cache.now = func() time.Time { return now }
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	_, _ = store.GetURL(ctx, url.ShortURL)
+	now = now.Add(DefaultCacheTTL - time.Second)
+	_, _ = store.GetURL(ctx, url.ShortURL)
+	assert.Equal(t, int64(1), inner.lookups.Load())
+
+	now = now.Add(time.Second)
+	_, _ = store.GetURL(ctx, url.ShortURL)
+	assert.Equal(t, int64(2), inner.lookups.Load())
+}
+
+func TestCachedStore_NegativeCaching(t *testing.T) {
+	store, inner, ctx := newTestCachedStore(10)
+	now := time.Now()
+	store.cache.now = func() time.Time { return now }
+
+	key := generateShortURL("https://example.com")
+	for i := 0; i < 2; i++ {
+		_, err := store.GetURL(ctx, key)
+		assert.ErrorIs(t, err, ErrURLNotFound)
+	}
+	assert.Equal(t, int64(1), inner.lookups.Load())
+
+	// A link created by another instance shows up once the miss expires
+	_, err := inner.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, err = store.GetURL(ctx, key)
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	now = now.Add(DefaultCacheNegativeTTL)
+	link, err := store.GetURL(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", link.OriginalURL)
+}
+
+func TestCachedStore_SetInvalidatesMiss(t *testing.T) {
+	store, _, ctx := newTestCachedStore(10)
+
+	_, err := store.GetURL(ctx, "custom")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	_, err = store.SetWithAlias(ctx, "https://example.org", "custom", LinkOptions{})
+	require.NoError(t, err)
+
+	link, err := store.GetURL(ctx, "custom")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.org", link.OriginalURL)
+}
+
+func TestCachedStore_InvalidatesOnUpdateAndDelete(t *testing.T) {
+	store, _, ctx := newTestCachedStore(10)
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, _ = store.GetURL(ctx, url.ShortURL)
+
+	_, err = store.UpdateOptions(ctx, "test-user", url.ShortURL, LinkOptions{RedirectType: 301})
+	require.NoError(t, err)
+	link, err := store.GetURL(ctx, url.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, 301, link.RedirectType)
+
+	_, err = store.BatchDeleteURLs("test-user", []string{url.ShortURL})
+	require.NoError(t, err)
+	_, err = store.GetURL(ctx, url.ShortURL)
+	var deleted *DeletedURLError
+	assert.ErrorAs(t, err, &deleted)
+
+	// Deleted links are served from the cache as well
+	_, err = store.GetURL(ctx, url.ShortURL)
+	assert.ErrorAs(t, err, &deleted)
+	assert.Equal(t, uint64(1), store.Stats().Hits)
+}
+
+func TestCachedStore_RecordClick(t *testing.T) {
+	store, inner, ctx := newTestCachedStore(10)
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, _ = store.GetURL(ctx, url.ShortURL)
+
+	require.NoError(t, store.RecordClick(ctx, url.ShortURL))
+	require.NoError(t, store.RecordClick(ctx, url.ShortURL))
+
+	link, err := store.GetURL(ctx, url.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), link.Clicks)
+	assert.Equal(t, int64(1), inner.lookups.Load())
+}
+
+func TestCachedStore_DisabledWithZeroSize(t *testing.T) {
+	store, inner, ctx := newTestCachedStore(0)
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	_, _ = store.GetURL(ctx, url.ShortURL)
+	_, _ = store.GetURL(ctx, url.ShortURL)
+	assert.Equal(t, int64(2), inner.lookups.Load())
+	assert.Equal(t, 0, store.Stats().Entries)
+}
+
+func TestCachedStore_StartInvalidationListener(t *testing.T) {
+	source := &fakeInvalidationSource{MemoryStore: NewMemoryStore(), changes: make(chan string)}
+	store := NewCachedStore(source, CacheOptions{Size: 10})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, _ = store.GetURL(ctx, url.ShortURL)
+	_, _ = store.GetURL(ctx, "unknown")
+	require.Equal(t, 2, store.Stats().Entries)
+	assert.Error(t, store.CheckInvalidation(ctx))
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		store.StartInvalidationListener(listenCtx)
+		close(done)
+	}()
+
+	// The cache is purged on subscribing; later changes invalidate single links
+	source.changes <- "unknown"
+	assert.Equal(t, 0, store.Stats().Entries)
+	assert.NoError(t, store.CheckInvalidation(ctx))
+	require.Eventually(t, func() bool {
+		_, _ = store.GetURL(ctx, url.ShortURL)
+		return store.Stats().Entries == 1
+	}, time.Second, 10*time.Millisecond)
+
+	source.changes <- url.ShortURL
+	source.changes <- "unknown" // Received once the previous change was handled
+	assert.Equal(t, 0, store.Stats().Entries)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener did not stop")
+	}
+	assert.Error(t, store.CheckInvalidation(ctx))
+}
+
+func TestCachedStore_StartInvalidationListenerWithoutSource(t *testing.T) {
+	store, _, _ := newTestCachedStore(10)
+
+	done := make(chan struct{})
+	go func() {
+		store.StartInvalidationListener(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener should return for storage without notifications")
+	}
+	assert.NoError(t, store.CheckInvalidation(context.Background()))
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// DefaultBulkInsertThreshold is the smallest batch written with COPY by default. Below it the
+// round trips of creating and filling the temporary table cost more than inserting row by row.
+const DefaultBulkInsertThreshold = 64
+
+// copyBatch stores a batch through pgx's native connection: the items are copied into a
+// temporary table, inserted into urls with a single INSERT ... ON CONFLICT DO NOTHING, and the
+// stored links are read back in batch order with one join.
+//
+// Items whose URL was inserted by this call are reported as created, items whose generated short
+// URL is held by another URL as failed with ErrAliasTaken, and all others as existing.
+func (store *DatabaseStore) copyBatch(ctx context.Context, batch []RequestBodyBanch, userID string) ([]BatchURL, error) {
+	conn, err := store.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var results []BatchURL
+	err = conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected database driver %T", driverConn)
+		}
+
+		var txErr error
+		results, txErr = copyBatchTx(ctx, stdConn.Conn(), batch, userID)
+		return txErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// copyBatchTx runs copyBatch in a transaction on the native connection.
+func copyBatchTx(ctx context.Context, conn *pgx.Conn, batch []RequestBodyBanch, userID string) ([]BatchURL, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The temporary table shares only origin_url with urls, so the join below can use USING
+	// and select urlColumns unqualified.
+	_, err = tx.Exec(ctx, `
+        CREATE TEMPORARY TABLE batch_urls (
+            ord        INTEGER NOT NULL,
+            origin_url TEXT    NOT NULL,
+            key        TEXT    NOT NULL
+        ) ON COMMIT DROP`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"batch_urls"}, []string{"ord", "origin_url", "key"},
+		pgx.CopyFromSlice(len(batch), func(i int) ([]any, error) {
+			return []any{i, batch[i].OriginalURL, generateShortURL(batch[i].OriginalURL)}, nil
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+        INSERT INTO urls (origin_url, short_url, user_id)
+        SELECT DISTINCT ON (origin_url) origin_url, key, $1
+        FROM batch_urls
+        ORDER BY origin_url, ord
+        ON CONFLICT DO NOTHING
+        RETURNING origin_url`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := make(map[string]struct{})
+	for rows.Next() {
+		var origin string
+		if err := rows.Scan(&origin); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		inserted[origin] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Items whose short URL is held by another URL were not inserted and join no link.
+	rows, err = tx.Query(ctx, `
+        SELECT ord, `+urlColumns+`
+        FROM batch_urls JOIN urls USING (origin_url)
+        ORDER BY ord`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]BatchURL, 0, len(batch))
+	for rows.Next() {
+		var ord int
+		url, err := scanURL(ordScanner{rows: rows, ord: &ord})
+		if err != nil {
+			return nil, err
+		}
+
+		for len(results) < ord {
+			results = append(results, failedBatchURL(batch[len(results)], ErrAliasTaken))
+		}
+
+		item := batch[ord]
+		if _, ok := inserted[url.OriginalURL]; ok {
+			delete(inserted, url.OriginalURL)
+			url.UUID = item.CorrelationID
+			results = append(results, BatchURL{URL: url})
+			continue
+		}
+
+		results = append(results, BatchURL{URL: url, Existing: true})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for len(results) < len(batch) {
+		results = append(results, failedBatchURL(batch[len(results)], ErrAliasTaken))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ordScanner reads the position of a batch item selected before urlColumns, so that the row can
+// be read with scanURL.
+type ordScanner struct {
+	rows pgx.Rows
+	ord  *int
+}
+
+// Scan reads the position into ord and the remaining columns into dest.
+func (s ordScanner) Scan(dest ...any) error {
+	return s.rows.Scan(append([]any{s.ord}, dest...)...)
+}
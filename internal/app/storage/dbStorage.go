@@ -3,21 +3,42 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
+	"strconv"
 	"time"
 
-	"github.com/golangTroshin/shorturl/internal/app/config"
 	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/lib/pq"
+	"go.uber.org/zap"
 )
 
 // DatabaseStore represents the structure for database operations.
 // It encapsulates methods to interact with the PostgreSQL database for URL shortening service.
-type DatabaseStore struct{}
+//
+// The store owns its connection pool, which is shared by all requests and closed with Close.
+type DatabaseStore struct {
+	db         *sql.DB
+	connConfig *pgx.ConnConfig
+
+	// BulkInsertThreshold is the smallest batch written by SetBatch with COPY; smaller batches are
+	// inserted row by row. Zero selects DefaultBulkInsertThreshold and a negative value disables COPY.
+	BulkInsertThreshold int
+}
 
-// DB represents the global database connection instance used by DatabaseStore.
-var DB *sql.DB
+// DatabaseConfig holds the connection settings of a DatabaseStore.
+type DatabaseConfig struct {
+	DSN                 string        // PostgreSQL connection string
+	MaxOpenConns        int           // Largest number of open connections; zero means unlimited
+	MaxIdleConns        int           // Largest number of idle connections kept in the pool
+	ConnMaxLifetime     time.Duration // Time after which a connection is closed; zero keeps connections forever
+	ConnMaxIdleTime     time.Duration // Time after which an idle connection is closed; zero keeps idle connections
+	StatementTimeout    time.Duration // Server-side limit for a single statement; zero disables the limit
+	BulkInsertThreshold int           // Smallest batch written with COPY, see DatabaseStore.BulkInsertThreshold
+}
 
 // InsertConflictError represents an error when a conflict occurs during an INSERT operation,
 // typically due to a unique constraint violation.
@@ -60,49 +81,78 @@ func NewDeletedURLError() error {
 	}
 }
 
-// initDB initializes the database connection using the configuration.
-// Returns an error if the connection cannot be established.
-func initDB() error {
-	var err error
-	DB, err = sql.Open("pgx", config.Options.DatabaseDsn)
+// NewDatabaseStore opens a connection pool with the given settings, checks that the database
+// is reachable and creates or migrates the urls table.
+func NewDatabaseStore(cfg DatabaseConfig) (*DatabaseStore, error) {
+	connConfig, err := pgx.ParseConfig(cfg.DSN)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := DB.Ping(); err != nil {
-		return err
+	if cfg.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
 	}
+	connConfig.Tracer = queryTracer{}
 
-	log.Println("Database connection established")
-	return nil
-}
+	db := stdlib.OpenDB(*connConfig)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-// CloseDB closes the active database connection.
-// Logs any error encountered during the closure.
-func CloseDB() {
-	if DB != nil {
-		if err := DB.Close(); err != nil {
-			log.Printf("Failed to close the database connection: %v", err)
-		} else {
-			log.Println("Database connection closed")
-		}
+	store := &DatabaseStore{db: db, connConfig: connConfig, BulkInsertThreshold: cfg.BulkInsertThreshold}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	logger.Default().Info("database connection established")
+
+	if err := store.createTableIfNotExists(); err != nil {
+		db.Close()
+		return nil, err
 	}
+
+	return store, nil
+}
+
+// Ping checks that the database is reachable through the connection pool of the store.
+func (store *DatabaseStore) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
 }
 
-// NewDatabaseStore creates a new DatabaseStore instance and initializes the database connection.
-// Ensures that the necessary database table exists.
-func NewDatabaseStore() (*DatabaseStore, error) {
-	store := &DatabaseStore{}
+// DB returns the connection pool of the store, for components keeping their own tables in the
+// same database.
+func (store *DatabaseStore) DB() *sql.DB {
+	return store.db
+}
+
+// Close closes the connection pool of the store.
+func (store *DatabaseStore) Close() error {
+	return store.db.Close()
+}
 
-	if err := initDB(); err != nil {
-		return store, err
+// ListenInvalidations reports the short URLs of links changed by any instance. It listens on a
+// dedicated connection outside the pool, to which a trigger on urls sends every change.
+func (store *DatabaseStore) ListenInvalidations(ctx context.Context, subscribed func(), invalidate func(key string)) error {
+	conn, err := pgx.ConnectConfig(ctx, store.connConfig.Copy())
+	if err != nil {
+		return err
 	}
+	defer conn.Close(context.Background())
 
-	if err := createTableIfNotExists(); err != nil {
-		return store, err
+	if _, err := conn.Exec(ctx, "LISTEN "+urlChangesChannel); err != nil {
+		return err
 	}
+	subscribed()
 
-	return store, nil
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		invalidate(notification.Payload)
+	}
 }
 
 // Get retrieves the original URL for a given short URL from the database.
@@ -112,20 +162,17 @@ func (store *DatabaseStore) Get(ctx context.Context, key string) (string, error)
 
 	var originalURL string
 	var isDeleted bool
-	err := DB.QueryRowContext(ctx, query, key).Scan(&originalURL, &isDeleted)
+	err := store.db.QueryRowContext(ctx, query, key).Scan(&originalURL, &isDeleted)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("there is no rows: %v", err)
 			return "", nil
 		}
 
-		log.Printf("error getting row: %v", err)
+		logger.FromContext(ctx).Error("unable to get url", zap.Error(err))
 		return "", err
 	}
 
-	log.Printf("url is found: %v %v", originalURL, isDeleted)
 	if isDeleted {
-		log.Printf("url was deleted: %v", originalURL)
 		return "", NewDeletedURLError()
 	}
 
@@ -137,12 +184,12 @@ func (store *DatabaseStore) Get(ctx context.Context, key string) (string, error)
 func (store *DatabaseStore) GetByUserID(ctx context.Context, userID string) ([]URL, error) {
 	var URLs []URL
 
-	query := `SELECT origin_url, short_url FROM urls WHERE user_id = $1;`
+	query := `SELECT origin_url, short_url FROM urls WHERE user_id = $1 ORDER BY created_at, short_url;`
 
-	rows, err := DB.QueryContext(ctx, query, userID)
+	rows, err := store.db.QueryContext(ctx, query, userID)
 
 	if rows.Err() != nil || err != nil {
-		log.Printf("error executing query: %v", err)
+		logger.FromContext(ctx).Error("unable to run query", zap.Error(err))
 		return nil, err
 	}
 	defer rows.Close()
@@ -151,7 +198,7 @@ func (store *DatabaseStore) GetByUserID(ctx context.Context, userID string) ([]U
 		var url URL
 		err := rows.Scan(&url.OriginalURL, &url.ShortURL)
 		if err != nil {
-			log.Printf("error scanning row: %v", err)
+			logger.FromContext(ctx).Error("unable to scan row", zap.Error(err))
 			return nil, err
 		}
 		URLs = append(URLs, url)
@@ -170,23 +217,22 @@ func (store *DatabaseStore) Set(ctx context.Context, value string) (URL, error)
 	}
 
 	userID := ctxValue.(string)
-	log.Printf("userID: %v", userID)
 	url := getURLObject(value, userID)
 
-	result, err := DB.ExecContext(ctx, `
+	result, err := store.db.ExecContext(ctx, `
         INSERT INTO urls (origin_url, short_url, user_id)
         VALUES ($1, $2, $3)
         ON CONFLICT (origin_url) DO NOTHING`, url.OriginalURL, url.ShortURL, userID)
 
 	if err != nil {
-		log.Printf("error %v", err)
+		logger.FromContext(ctx).Error("unable to insert url", zap.Error(err))
 
 		return url, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("error %v", err)
+		logger.FromContext(ctx).Error("unable to fetch rows affected", zap.Error(err))
 
 		return url, err
 	}
@@ -194,93 +240,144 @@ func (store *DatabaseStore) Set(ctx context.Context, value string) (URL, error)
 	if rowsAffected == 0 {
 		var existingShortURL string
 
-		queryErr := DB.QueryRowContext(ctx, `
+		queryErr := store.db.QueryRowContext(ctx, `
             SELECT short_url FROM urls WHERE origin_url = $1`, url.OriginalURL).Scan(&existingShortURL)
 
 		if queryErr != nil {
-			log.Printf("error %v", err)
+			logger.FromContext(ctx).Error("unable to get existing url", zap.Error(queryErr))
 
 			return url, queryErr
 		}
 
 		url.ShortURL = existingShortURL
-		log.Printf("conflict: originUrl %v already exists", url.OriginalURL)
 
 		return url, NewInsertConflictError()
 	}
 
-	log.Printf("url %v saved in db", url)
 	return url, nil
 }
 
-// SetBatch inserts multiple URLs into the database within a single transaction.
-// If any operation fails, the transaction is rolled back.
-func (store *DatabaseStore) SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]URL, error) {
-	URLs := make([]URL, 0, len(batch))
+// SetBatch stores multiple URLs in a single transaction and returns one entry per item in
+// batch order. URLs that were already shortened, or appear earlier in the batch, are returned
+// as existing links, and items whose generated short URL is held by another URL as failed with
+// ErrAliasTaken, instead of failing the batch.
+//
+// Batches of at least BulkInsertThreshold items are copied into the database with copyBatch;
+// smaller ones are inserted row by row with insertBatch. Both return the same results.
+func (store *DatabaseStore) SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]BatchURL, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok {
+		return nil, fmt.Errorf("user ID is missing in context")
+	}
+
+	threshold := store.BulkInsertThreshold
+	if threshold == 0 {
+		threshold = DefaultBulkInsertThreshold
+	}
+
+	if threshold > 0 && len(batch) >= threshold {
+		return store.copyBatch(ctx, batch, userID)
+	}
+
+	return store.insertBatch(ctx, batch, userID)
+}
 
-	tx, err := DB.Begin()
+// insertBatch stores a batch with one prepared INSERT per item.
+func (store *DatabaseStore) insertBatch(ctx context.Context, batch []RequestBodyBanch, userID string) ([]BatchURL, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Printf("error start transaction: %v", err)
-		return URLs, err
+		logger.FromContext(ctx).Error("unable to begin transaction", zap.Error(err))
+		return nil, err
 	}
 
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx,
-		"INSERT INTO urls (origin_url, short_url, user_id) "+
-			"VALUES ($1, $2, $3);")
+	insert, err := tx.PrepareContext(ctx, `
+        INSERT INTO urls (origin_url, short_url, user_id)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING
+        RETURNING `+urlColumns)
+	if err != nil {
+		logger.FromContext(ctx).Error("unable to prepare statement", zap.Error(err))
+		return nil, err
+	}
+	defer insert.Close()
 
+	existing, err := tx.PrepareContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE origin_url = $1`)
 	if err != nil {
-		log.Printf("error preparing context: %v", err)
-		return URLs, err
+		logger.FromContext(ctx).Error("unable to prepare statement", zap.Error(err))
+		return nil, err
 	}
-	defer stmt.Close()
+	defer existing.Close()
 
-	userID := ctx.Value(middleware.UserIDKey).(string)
-	for _, url := range batch {
-		urlObj := getURLObjectWithID(url.CorrelationID, url.OriginalURL, userID)
-		_, err = stmt.ExecContext(ctx, urlObj.OriginalURL, urlObj.ShortURL, userID)
+	results := make([]BatchURL, 0, len(batch))
+	for _, item := range batch {
+		urlObj := getURLObjectWithID(item.CorrelationID, item.OriginalURL, userID)
+
+		url, err := scanURL(insert.QueryRowContext(ctx, urlObj.OriginalURL, urlObj.ShortURL, userID))
+		if err == nil {
+			url.UUID = urlObj.UUID
+			results = append(results, BatchURL{URL: url})
+			continue
+		}
+		if err != sql.ErrNoRows {
+			logger.FromContext(ctx).Error("unable to insert url", zap.Error(err))
+			return nil, err
+		}
+
+		url, err = scanURL(existing.QueryRowContext(ctx, urlObj.OriginalURL))
+		if err == sql.ErrNoRows {
+			results = append(results, failedBatchURL(item, ErrAliasTaken))
+			continue
+		}
 		if err != nil {
-			log.Printf("error inserting row: %v", err)
-			return URLs, err
+			return nil, err
 		}
 
-		URLs = append(URLs, urlObj)
+		results = append(results, BatchURL{URL: url, Existing: true})
 	}
 
 	if err = tx.Commit(); err != nil {
-		log.Printf("error commit transaction: %v", err)
-		return URLs, err
+		logger.FromContext(ctx).Error("unable to commit transaction", zap.Error(err))
+		return nil, err
 	}
 
-	return URLs, nil
+	return results, nil
 }
 
-// BatchDeleteURLs marks multiple URLs as deleted for a specific user ID.
+// BatchDeleteURLs marks multiple URLs as deleted for a specific user ID and returns the short URLs
+// it marked, leaving out unknown, foreign and already deleted ones.
 // Returns an error if the operation fails.
-func (store *DatabaseStore) BatchDeleteURLs(userID string, urlIDs []string) error {
-	log.Printf("Start delete: %v %v", urlIDs, userID)
-	query := `UPDATE urls SET is_deleted = TRUE WHERE short_url = ANY($1) AND user_id = $2`
-
-	result, err := DB.Exec(query, pq.Array(urlIDs), userID)
+func (store *DatabaseStore) BatchDeleteURLs(userID string, urlIDs []string) ([]string, error) {
+	query := `UPDATE urls SET is_deleted = TRUE WHERE short_url = ANY($1) AND user_id = $2 AND NOT is_deleted
+		RETURNING short_url`
 
+	rows, err := store.db.Query(query, pq.Array(urlIDs), userID)
 	if err != nil {
-		log.Printf("Error deleting URLs: %v", err)
-		return err
+		logger.Default().Error("unable to delete urls", zap.Error(err))
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("Error fetching rows affected: %v", err)
-		return err
+	var deleted []string
+	for rows.Next() {
+		var shortURL string
+		if err := rows.Scan(&shortURL); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, shortURL)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Default().Error("unable to delete urls", zap.Error(err))
+		return nil, err
 	}
 
-	log.Printf("Rows affected: %d", rowsAffected)
+	logger.Default().Debug("deleted urls", zap.Int("rows", len(deleted)))
 
-	return nil
+	return deleted, nil
 }
 
-func createTableIfNotExists() error {
+func (store *DatabaseStore) createTableIfNotExists() error {
 	createTableSQL := "CREATE TABLE IF NOT EXISTS urls (" +
 		" id SERIAL PRIMARY KEY," +
 		" origin_url VARCHAR(250) NOT NULL UNIQUE," +
@@ -289,15 +386,412 @@ func createTableIfNotExists() error {
 		" is_deleted BOOL NOT NULL DEFAULT FALSE," +
 		" created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP)"
 
-	if _, err := DB.ExecContext(context.Background(), createTableSQL); err != nil {
-		log.Printf("error creating table: %v", err)
+	if _, err := store.db.ExecContext(context.Background(), createTableSQL); err != nil {
+		logger.Default().Error("unable to create table", zap.Error(err))
 		return err
 	}
 
-	log.Println("table created or already exists.")
+	for _, migration := range migrations {
+		if _, err := store.db.ExecContext(context.Background(), migration); err != nil {
+			logger.Default().Error("unable to apply migration", zap.String("migration", migration), zap.Error(err))
+			return err
+		}
+	}
+
 	return nil
 }
 
+// urlChangesChannel is the notification channel on which the short URLs of changed links are sent.
+const urlChangesChannel = "url_changes"
+
+// migrations holds schema changes applied on top of the initial urls table.
+// Every statement must be idempotent because it runs on each start.
+var migrations = []string{
+	"ALTER TABLE urls ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0",
+	"ALTER TABLE urls ADD COLUMN IF NOT EXISTS options JSONB NOT NULL DEFAULT '{}'",
+	"CREATE INDEX IF NOT EXISTS urls_tags_idx ON urls USING GIN ((options->'tags'))",
+	"ALTER TABLE urls ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now()",
+	// The initial table already has a nullable created_at, which the statement above keeps.
+	"UPDATE urls SET created_at = now() WHERE created_at IS NULL",
+	"ALTER TABLE urls ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL",
+	"CREATE INDEX IF NOT EXISTS urls_user_created_idx ON urls (user_id, created_at, short_url)",
+	"ALTER TABLE urls ADD COLUMN IF NOT EXISTS page JSONB",
+	"ALTER TABLE urls ADD COLUMN IF NOT EXISTS health JSONB",
+	// Older versions could store a short URL twice. Such rows must be resolved by hand, see the
+	// upgrade notes of the README, so the index is not created over them and startup fails clearly.
+	`DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE tablename = 'urls' AND indexname = 'urls_short_url_key') THEN
+        IF EXISTS (SELECT 1 FROM urls GROUP BY short_url HAVING count(*) > 1) THEN
+            RAISE EXCEPTION 'urls holds duplicate short_url values, so urls_short_url_key cannot be created'
+                USING HINT = 'Keep one row per short_url before upgrading; see the upgrade notes in README.md.';
+        END IF;
+    END IF;
+END
+$$`,
+	"CREATE UNIQUE INDEX IF NOT EXISTS urls_short_url_key ON urls (short_url)",
+	`CREATE OR REPLACE FUNCTION notify_url_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('` + urlChangesChannel + `', OLD.short_url);
+    ELSE
+        PERFORM pg_notify('` + urlChangesChannel + `', NEW.short_url);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`,
+	// Clicks are left out so that redirects do not invalidate the caches of other instances.
+	// The trigger is replaced in place: dropping it first would let changes made by other
+	// instances meanwhile go unnotified.
+	"CREATE OR REPLACE TRIGGER urls_notify_change" +
+		" AFTER INSERT OR DELETE OR UPDATE OF origin_url, short_url, user_id, is_deleted, options, page, health ON urls" +
+		" FOR EACH ROW EXECUTE FUNCTION notify_url_change()",
+	// The outbox is filled by a trigger, so that events are committed with the change of the link.
+	"CREATE TABLE IF NOT EXISTS outbox_events (" +
+		" id UUID PRIMARY KEY DEFAULT gen_random_uuid()," +
+		" type VARCHAR(32) NOT NULL," +
+		" user_id VARCHAR(250) NOT NULL," +
+		" short_url VARCHAR(250) NOT NULL," +
+		" original_url TEXT NOT NULL," +
+		" occurred_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp())",
+	"CREATE INDEX IF NOT EXISTS outbox_events_occurred_idx ON outbox_events (occurred_at)",
+	`CREATE OR REPLACE FUNCTION record_url_event() RETURNS trigger AS $$
+DECLARE
+    event_type VARCHAR(32);
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event_type := '` + EventLinkCreated + `';
+    ELSIF NEW.is_deleted AND NOT OLD.is_deleted THEN
+        event_type := '` + EventLinkDeleted + `';
+    ELSIF NEW.clicks > 0 AND OLD.clicks = 0 THEN
+        event_type := '` + EventLinkClicked + `';
+    ELSE
+        RETURN NULL;
+    END IF;
+    INSERT INTO outbox_events (type, user_id, short_url, original_url)
+        VALUES (event_type, NEW.user_id, NEW.short_url, NEW.origin_url);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`,
+	// Replaced in place like urls_notify_change, so no change of another instance misses its event.
+	"CREATE OR REPLACE TRIGGER urls_record_event" +
+		" AFTER INSERT OR UPDATE OF is_deleted, clicks ON urls" +
+		" FOR EACH ROW EXECUTE FUNCTION record_url_event()",
+}
+
+// GetURL retrieves the full link record for the given short URL.
+// Returns ErrURLNotFound if there is no such URL and a DeletedURLError if it was deleted.
+func (store *DatabaseStore) GetURL(ctx context.Context, key string) (URL, error) {
+	query := `SELECT ` + urlColumns + ` FROM urls WHERE short_url = $1;`
+
+	url, err := scanURL(store.db.QueryRowContext(ctx, query, key))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return URL{}, ErrURLNotFound
+		}
+
+		logger.FromContext(ctx).Error("unable to get url", zap.Error(err))
+		return URL{}, err
+	}
+
+	if url.DeletedFlag {
+		return url, NewDeletedURLError()
+	}
+
+	return url, nil
+}
+
+// UpdateOptions replaces the per-link settings of a URL owned by the given user.
+func (store *DatabaseStore) UpdateOptions(ctx context.Context, userID string, key string, opts LinkOptions) (URL, error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return URL{}, err
+	}
+
+	query := `UPDATE urls SET options = $1 WHERE short_url = $2 AND user_id = $3 AND NOT is_deleted
+		RETURNING ` + urlColumns + `;`
+
+	url, err := scanURL(store.db.QueryRowContext(ctx, query, data, key, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return URL{}, ErrURLNotFound
+		}
+
+		logger.FromContext(ctx).Error("unable to update options", zap.Error(err))
+		return URL{}, err
+	}
+
+	return url, nil
+}
+
+// UpdateRules replaces the redirect rules of a URL owned by the given user in a single statement,
+// so that settings changed concurrently by UpdateOptions are kept.
+func (store *DatabaseStore) UpdateRules(ctx context.Context, userID string, key string, rules []Rule) (URL, error) {
+	data, err := json.Marshal(LinkOptions{Rules: rules})
+	if err != nil {
+		return URL{}, err
+	}
+
+	// The marshaled settings hold only the rules, or nothing when there are none, which removes them.
+	query := `UPDATE urls SET options = (options - 'rules') || $1::jsonb
+		WHERE short_url = $2 AND user_id = $3 AND NOT is_deleted
+		RETURNING ` + urlColumns + `;`
+
+	url, err := scanURL(store.db.QueryRowContext(ctx, query, data, key, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return URL{}, ErrURLNotFound
+		}
+
+		logger.FromContext(ctx).Error("unable to update rules", zap.Error(err))
+		return URL{}, err
+	}
+
+	return url, nil
+}
+
+// PendingEvents returns up to limit unacknowledged link events, oldest first.
+func (store *DatabaseStore) PendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	query := `SELECT id, type, user_id, short_url, original_url, occurred_at FROM outbox_events
+		ORDER BY occurred_at LIMIT $1`
+
+	rows, err := store.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.ID, &event.Type, &event.UserID, &event.ShortURL, &event.OriginalURL, &event.OccurredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// AckEvents removes the link events from the outbox_events table.
+func (store *DatabaseStore) AckEvents(ctx context.Context, ids []string) error {
+	_, err := store.db.ExecContext(ctx, `DELETE FROM outbox_events WHERE id = ANY($1)`, pq.Array(ids))
+	return err
+}
+
+// RecordClick increments the click counter of the given short URL.
+func (store *DatabaseStore) RecordClick(ctx context.Context, key string) error {
+	result, err := store.db.ExecContext(ctx, `UPDATE urls SET clicks = clicks + 1 WHERE short_url = $1`, key)
+	if err != nil {
+		logger.FromContext(ctx).Error("unable to record click", zap.Error(err))
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	return nil
+}
+
+// QueryByUserID retrieves a page of the user's active URLs matching the query.
+// Tag filters use the GIN index on the tags of the link options, and pages are
+// selected with a keyset condition on the sort column and the short URL.
+func (store *DatabaseStore) QueryByUserID(ctx context.Context, userID string, query URLQuery) (URLPage, error) {
+	after, err := query.decodeCursor()
+	if err != nil {
+		return URLPage{}, err
+	}
+
+	column, direction, operator := query.sqlOrder()
+
+	statement := `SELECT ` + urlColumns + ` FROM urls WHERE user_id = $1 AND NOT is_deleted`
+	args := []any{userID}
+
+	if query.Tag != "" {
+		args = append(args, query.Tag)
+		statement += fmt.Sprintf(" AND options->'tags' ? $%d", len(args))
+	}
+
+	switch query.Health {
+	case HealthBroken:
+		statement += " AND health IS NOT NULL AND NOT (COALESCE((health->>'status')::int, 0) BETWEEN 1 AND 399)"
+	case HealthOK:
+		statement += " AND (health->>'status')::int BETWEEN 1 AND 399"
+	}
+
+	if query.Search != "" {
+		args = append(args, query.searchPattern())
+		statement += fmt.Sprintf(" AND (origin_url ILIKE $%[1]d OR options->>'title' ILIKE $%[1]d)", len(args))
+	}
+
+	if after != nil {
+		var value any = after.value
+		if column == "created_at" {
+			value = time.UnixMicro(after.value).UTC()
+		}
+
+		args = append(args, value, after.shortURL)
+		statement += fmt.Sprintf(" AND (%s, short_url) %s ($%d, $%d)", column, operator, len(args)-1, len(args))
+	}
+
+	statement += fmt.Sprintf(" ORDER BY %[1]s %[2]s, short_url %[2]s", column, direction)
+
+	if query.Limit > 0 {
+		// One extra row tells whether there is a next page
+		args = append(args, query.Limit+1)
+		statement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := store.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		logger.FromContext(ctx).Error("unable to run query", zap.Error(err))
+		return URLPage{}, err
+	}
+	defer rows.Close()
+
+	var urls []URL
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			logger.FromContext(ctx).Error("unable to scan row", zap.Error(err))
+			return URLPage{}, err
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		return URLPage{}, err
+	}
+
+	if query.Limit <= 0 || len(urls) <= query.Limit {
+		return URLPage{URLs: urls}, nil
+	}
+
+	urls = urls[:query.Limit]
+
+	return URLPage{URLs: urls, NextCursor: query.encodeCursor(urls[len(urls)-1])}, nil
+}
+
+// SetPageInfo stores metadata fetched from the destination page of the given short URL.
+func (store *DatabaseStore) SetPageInfo(ctx context.Context, key string, page PageInfo) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+
+	result, err := store.db.ExecContext(ctx, `UPDATE urls SET page = $1 WHERE short_url = $2`, data, key)
+	if err != nil {
+		logger.FromContext(ctx).Error("unable to store page info", zap.Error(err))
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	return nil
+}
+
+// ActiveURLs retrieves up to limit URLs that are neither deleted nor expired, ordered by short
+// URL and starting after the given one. Pages are read through the unique index on short_url.
+func (store *DatabaseStore) ActiveURLs(ctx context.Context, after string, limit int) ([]URL, error) {
+	rows, err := store.db.QueryContext(ctx, `SELECT `+urlColumns+` FROM urls
+        WHERE short_url > $1 AND NOT is_deleted
+        AND (options->>'expires_at' IS NULL OR (options->>'expires_at')::timestamptz > now())
+        ORDER BY short_url LIMIT $2`, after, limit)
+	if err != nil {
+		logger.FromContext(ctx).Error("unable to run query", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []URL
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			logger.FromContext(ctx).Error("unable to scan row", zap.Error(err))
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, rows.Err()
+}
+
+// SetHealth records the result of a destination health check of the given short URL.
+func (store *DatabaseStore) SetHealth(ctx context.Context, key string, health LinkHealth) error {
+	data, err := json.Marshal(health)
+	if err != nil {
+		return err
+	}
+
+	result, err := store.db.ExecContext(ctx, `UPDATE urls SET health = $1 WHERE short_url = $2`, data, key)
+	if err != nil {
+		logger.FromContext(ctx).Error("unable to store health", zap.Error(err))
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// urlColumns lists the columns of a full URL record in the order expected by scanURL.
+const urlColumns = "origin_url, short_url, user_id, is_deleted, clicks, options, created_at, page, health"
+
+// scanURL reads a single URL record selected as urlColumns.
+func scanURL(row rowScanner) (URL, error) {
+	var url URL
+	var options, page, health []byte
+
+	if err := row.Scan(&url.OriginalURL, &url.ShortURL, &url.UserID, &url.DeletedFlag, &url.Clicks, &options, &url.CreatedAt, &page, &health); err != nil {
+		return URL{}, err
+	}
+
+	if err := json.Unmarshal(options, &url.LinkOptions); err != nil {
+		return URL{}, err
+	}
+
+	if page != nil {
+		url.Page = &PageInfo{}
+		if err := json.Unmarshal(page, url.Page); err != nil {
+			return URL{}, err
+		}
+	}
+
+	if health != nil {
+		url.Health = &LinkHealth{}
+		if err := json.Unmarshal(health, url.Health); err != nil {
+			return URL{}, err
+		}
+	}
+
+	return url, nil
+}
+
 // GetStats retrieves service statistic
 func (store *DatabaseStore) GetStats(ctx context.Context) (Stats, error) {
 	query := `
@@ -308,16 +802,66 @@ func (store *DatabaseStore) GetStats(ctx context.Context) (Stats, error) {
 
 	var totalURLs int
 	var uniqueUsers int
-	err := DB.QueryRowContext(ctx, query).Scan(&totalURLs, &uniqueUsers)
+	err := store.db.QueryRowContext(ctx, query).Scan(&totalURLs, &uniqueUsers)
 	if err != nil {
-		log.Printf("error getting stats: %v", err)
+		logger.FromContext(ctx).Error("unable to get stats", zap.Error(err))
 		return Stats{}, err
 	}
 
-	log.Printf("Total URLs: %d, Unique Users: %d", totalURLs, uniqueUsers)
-
 	return Stats{
 		Urls:  totalURLs,
 		Users: uniqueUsers,
 	}, nil
 }
+
+// SetWithAlias inserts a new URL with its per-link settings under the alias, or under a generated
+// short URL when the alias is empty. If the original URL already exists, it returns the existing
+// short URL with an InsertConflictError; if the short URL is used by another URL, it returns ErrAliasTaken.
+func (store *DatabaseStore) SetWithAlias(ctx context.Context, value string, alias string, opts LinkOptions) (URL, error) {
+	userID, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok {
+		return URL{}, fmt.Errorf("user ID is missing in context")
+	}
+
+	url := getURLObject(value, userID)
+	if alias != "" {
+		url.UUID = "uuid_" + alias
+		url.ShortURL = alias
+	}
+
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return URL{}, err
+	}
+
+	result, err := store.db.ExecContext(ctx, `
+        INSERT INTO urls (origin_url, short_url, user_id, options)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT DO NOTHING`, url.OriginalURL, url.ShortURL, userID, options)
+	if err != nil {
+		return url, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return url, err
+	}
+
+	if rowsAffected > 0 {
+		url.LinkOptions = opts
+		return url, nil
+	}
+
+	var existingShortURL string
+	err = store.db.QueryRowContext(ctx, `SELECT short_url FROM urls WHERE origin_url = $1`, url.OriginalURL).Scan(&existingShortURL)
+	if err == sql.ErrNoRows {
+		return URL{}, ErrAliasTaken
+	}
+	if err != nil {
+		return url, err
+	}
+
+	url.ShortURL = existingShortURL
+
+	return url, NewInsertConflictError()
+}
@@ -13,6 +13,9 @@
 //   - FileStore: A file-based storage that persists URLs to a file on disk.
 //   - DatabaseStore: A database-backed storage for scalable and reliable use cases.
 //
+// Any of them can be wrapped by a CachedStore, a read-through LRU cache of link lookups,
+// and by an InstrumentedStore, which reports the latency of every call.
+//
 // The storage backend can be selected at runtime based on configuration values.
 //
 // # Core Types
@@ -45,7 +48,7 @@
 //
 //	func main() {
 //	    // Initialize storage based on configuration
-//	    store, err := storage.GetStorageByConfig()
+//	    store, err := storage.GetStorageByConfig(nil)
 //	    if err != nil {
 //	        log.Fatalf("Failed to initialize storage: %v", err)
 //	    }
@@ -17,6 +17,10 @@ import (
 type FileStore struct {
 	mu      sync.RWMutex
 	urlList map[string]URL
+	tags    tagIndex
+	origins map[string]string
+	outbox  *eventLog           // Records the changes of links in the file next to the storage file, see Outbox
+	clicked map[string]struct{} // Links whose click counter is newer than their record in the file
 }
 
 // NewFileStore initializes and returns a new FileStore instance.
@@ -24,6 +28,9 @@ type FileStore struct {
 func NewFileStore() (*FileStore, error) {
 	store := &FileStore{
 		urlList: make(map[string]URL),
+		tags:    make(tagIndex),
+		origins: make(map[string]string),
+		clicked: make(map[string]struct{}),
 	}
 
 	err := store.loadFromFile()
@@ -31,6 +38,11 @@ func NewFileStore() (*FileStore, error) {
 		return nil, err
 	}
 
+	store.outbox, err = loadEventLog(outboxPath(config.Options.StoragePath))
+	if err != nil {
+		return nil, err
+	}
+
 	return store, nil
 }
 
@@ -57,14 +69,20 @@ func (store *FileStore) GetByUserID(_ context.Context, userID string) ([]URL, er
 }
 
 // Set adds a new URL to the store, generating a unique short URL for it.
-// The URL is written to the file for persistence.
+// The URL is written to the file for persistence. A URL that is already stored is left
+// unchanged and returned with an InsertConflictError.
 func (store *FileStore) Set(ctx context.Context, value string) (URL, error) {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
 	userID := ctx.Value(middleware.UserIDKey).(string)
-	url := getURLObject(value, userID)
+	url, err := newAliasedURL(store.urlList, store.origins, value, "", userID)
+	if err != nil {
+		return url, err
+	}
+
 	store.urlList[url.ShortURL] = url
+	store.origins[url.OriginalURL] = url.ShortURL
 
 	Producer, err := NewProducer(config.Options.StoragePath)
 	if err != nil {
@@ -76,46 +94,52 @@ func (store *FileStore) Set(ctx context.Context, value string) (URL, error) {
 		return url, err
 	}
 
-	return url, nil
+	return url, store.outbox.record(newEvent(EventLinkCreated, url))
 }
 
 // SetBatch adds multiple URLs to the store in a single operation.
-// Each URL is persisted to the file.
-func (store *FileStore) SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]URL, error) {
-	URLs := make([]URL, 0, len(batch))
-
+// Each new URL is persisted to the file; URLs that were already shortened are returned
+// as existing and left unchanged.
+func (store *FileStore) SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]BatchURL, error) {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	userID := ctx.Value(middleware.UserIDKey).(string)
-	for _, url := range batch {
-		urlObj := getURLObjectWithID(url.CorrelationID, url.OriginalURL, userID)
-		store.urlList[urlObj.ShortURL] = urlObj
+	userID, _ := ctx.Value(middleware.UserIDKey).(string)
+	results := newBatchURLs(store.urlList, store.origins, batch, userID)
 
-		Producer, err := NewProducer(config.Options.StoragePath)
-		if err != nil {
-			return URLs, err
-		}
-		defer Producer.Close()
+	producer, err := NewProducer(config.Options.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	defer producer.Close()
 
-		if err := Producer.WriteURL(&urlObj); err != nil {
-			return URLs, err
+	var events []Event
+	for _, result := range results {
+		if result.Existing || result.Err != nil {
+			continue
 		}
 
-		URLs = append(URLs, urlObj)
+		url := result.URL
+		store.urlList[url.ShortURL] = url
+		store.origins[url.OriginalURL] = url.ShortURL
+
+		if err := producer.WriteURL(&url); err != nil {
+			return nil, err
+		}
+		events = append(events, newEvent(EventLinkCreated, url))
 	}
 
-	return URLs, nil
+	return results, store.outbox.record(events...)
 }
 
-// BatchDeleteURLs marks multiple URLs as deleted for a specific user ID.
-// Updates are made in memory; persistence is not yet implemented.
-func (store *FileStore) BatchDeleteURLs(userID string, batch []string) error {
+// BatchDeleteURLs marks multiple URLs as deleted for a specific user ID, appends the deleted
+// records to the file and returns their short URLs.
+func (store *FileStore) BatchDeleteURLs(userID string, batch []string) ([]string, error) {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
 	if len(store.urlList) == 0 {
-		return errors.New("no URLs in the store")
+		return nil, errors.New("no URLs in the store")
 	}
 
 	batchMap := make(map[string]struct{})
@@ -123,18 +147,221 @@ func (store *FileStore) BatchDeleteURLs(userID string, batch []string) error {
 		batchMap[shortURL] = struct{}{}
 	}
 
+	var deleted []URL
 	for key, url := range store.urlList {
 		if url.UserID == userID {
-			if _, found := batchMap[url.ShortURL]; found {
+			if _, found := batchMap[url.ShortURL]; found && !url.DeletedFlag {
 				url.DeletedFlag = true
 				store.urlList[key] = url
+				deleted = append(deleted, url)
 			}
 		}
 	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	producer, err := NewProducer(config.Options.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	defer producer.Close()
+
+	events := make([]Event, 0, len(deleted))
+	keys := make([]string, 0, len(deleted))
+	for i := range deleted {
+		if err := producer.WriteURL(&deleted[i]); err != nil {
+			return nil, err
+		}
+		events = append(events, newEvent(EventLinkDeleted, deleted[i]))
+		keys = append(keys, deleted[i].ShortURL)
+	}
+
+	return keys, store.outbox.record(events...)
+}
+
+// GetURL retrieves the full link record for the given short URL.
+// Returns ErrURLNotFound if the key is unknown and a DeletedURLError if the URL was deleted.
+func (store *FileStore) GetURL(_ context.Context, key string) (URL, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return URL{}, ErrURLNotFound
+	}
+
+	if url.DeletedFlag {
+		return url, NewDeletedURLError()
+	}
+
+	return url, nil
+}
+
+// UpdateOptions replaces the per-link settings of a URL owned by the given user.
+// The updated record is appended to the file and takes precedence on the next load.
+func (store *FileStore) UpdateOptions(_ context.Context, userID string, key string, opts LinkOptions) (URL, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok || url.UserID != userID || url.DeletedFlag {
+		return URL{}, ErrURLNotFound
+	}
+
+	store.tags.remove(url)
+	url.LinkOptions = opts
+	store.urlList[key] = url
+	store.tags.add(url)
+
+	if err := store.writeToFile(&url); err != nil {
+		return url, err
+	}
+
+	return url, nil
+}
+
+// UpdateRules replaces the redirect rules of a URL owned by the given user, keeping its other
+// settings. The updated record is appended to the file like in UpdateOptions.
+func (store *FileStore) UpdateRules(_ context.Context, userID string, key string, rules []Rule) (URL, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok || url.UserID != userID || url.DeletedFlag {
+		return URL{}, ErrURLNotFound
+	}
+
+	url.Rules = rules
+	store.urlList[key] = url
+
+	if err := store.writeToFile(&url); err != nil {
+		return url, err
+	}
+
+	return url, nil
+}
+
+// RecordClick increments the click counter of the given short URL.
+//
+// Only the first click is written to the file right away, before it is recorded in the outbox,
+// so that it is not reported again after a restart. Later counts are written by Flush, which
+// keeps redirects from appending a record each.
+func (store *FileStore) RecordClick(_ context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return ErrURLNotFound
+	}
+
+	url.Clicks++
+	store.urlList[key] = url
+
+	if url.Clicks > 1 {
+		store.clicked[key] = struct{}{}
+		return nil
+	}
+
+	if err := store.writeToFile(&url); err != nil {
+		return err
+	}
+	return store.outbox.record(newEvent(EventLinkClicked, url))
+}
+
+// QueryByUserID retrieves a page of the user's active URLs matching the query.
+// Tag filters are served from the tag index.
+func (store *FileStore) QueryByUserID(_ context.Context, userID string, query URLQuery) (URLPage, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return queryURLs(store.urlList, store.tags, userID, query)
+}
+
+// writeToFile appends a single URL record to the storage file.
+func (store *FileStore) writeToFile(url *URL) error {
+	producer, err := NewProducer(config.Options.StoragePath)
+	if err != nil {
+		return err
+	}
+	defer producer.Close()
+
+	delete(store.clicked, url.ShortURL)
+	return producer.WriteURL(url)
+}
+
+// Flush writes the pending click counters, then commits the storage file and its outbox file
+// to disk.
+func (store *FileStore) Flush(_ context.Context) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.writeClicks(); err != nil {
+		return err
+	}
+
+	for _, path := range []string{config.Options.StoragePath, store.outbox.path} {
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = file.Sync()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeClicks appends the records of the links clicked since their last write.
+func (store *FileStore) writeClicks() error {
+	if len(store.clicked) == 0 {
+		return nil
+	}
+
+	producer, err := NewProducer(config.Options.StoragePath)
+	if err != nil {
+		return err
+	}
+	defer producer.Close()
+
+	for key := range store.clicked {
+		url := store.urlList[key]
+		if err := producer.WriteURL(&url); err != nil {
+			return err
+		}
+		delete(store.clicked, key)
+	}
 
 	return nil
 }
 
+// PendingEvents returns up to limit unacknowledged link events, oldest first.
+func (store *FileStore) PendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	return store.outbox.PendingEvents(ctx, limit)
+}
+
+// AckEvents removes the link events from the outbox file.
+func (store *FileStore) AckEvents(ctx context.Context, ids []string) error {
+	return store.outbox.AckEvents(ctx, ids)
+}
+
+// outboxPath returns the path of the outbox file of the storage file at path.
+func outboxPath(path string) string {
+	return path + ".outbox"
+}
+
+// Close commits the storage file and its outbox file to disk.
+func (store *FileStore) Close() error {
+	return store.Flush(context.Background())
+}
+
 // loadFromFile loads URL data from the file into the in-memory store.
 func (store *FileStore) loadFromFile() error {
 	consumer, err := NewConsumer(config.Options.StoragePath)
@@ -152,9 +379,11 @@ func (store *FileStore) loadFromFile() error {
 			return err
 		}
 
-		if _, ok := store.urlList[url.ShortURL]; !ok {
-			store.urlList[url.ShortURL] = *url
-		}
+		// Later records override earlier ones, so updates appended to the file win on reload.
+		store.tags.remove(store.urlList[url.ShortURL])
+		store.urlList[url.ShortURL] = *url
+		store.origins[url.OriginalURL] = url.ShortURL
+		store.tags.add(*url)
 	}
 	return nil
 }
@@ -260,3 +489,85 @@ func (store *FileStore) GetStats(_ context.Context) (Stats, error) {
 
 	return stats, nil
 }
+
+// SetPageInfo stores metadata fetched from the destination page of the given short URL.
+func (store *FileStore) SetPageInfo(_ context.Context, key string, page PageInfo) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return ErrURLNotFound
+	}
+
+	url.Page = &page
+	store.urlList[key] = url
+
+	if err := store.writeToFile(&url); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ActiveURLs retrieves up to limit URLs that are neither deleted nor expired, ordered by short
+// URL and starting after the given one.
+func (store *FileStore) ActiveURLs(_ context.Context, after string, limit int) ([]URL, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return activeURLs(store.urlList, after, limit), nil
+}
+
+// SetHealth records the result of a destination health check of the given short URL.
+func (store *FileStore) SetHealth(_ context.Context, key string, health LinkHealth) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return ErrURLNotFound
+	}
+
+	url.Health = &health
+	store.urlList[key] = url
+
+	if err := store.writeToFile(&url); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetWithAlias adds a new URL with its per-link settings to the store under the alias, or under
+// a generated short URL when the alias is empty, and persists it to the file. Existing links are
+// never overwritten.
+func (store *FileStore) SetWithAlias(ctx context.Context, value string, alias string, opts LinkOptions) (URL, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	userID, _ := ctx.Value(middleware.UserIDKey).(string)
+	url, err := newAliasedURL(store.urlList, store.origins, value, alias, userID)
+	if err != nil {
+		return url, err
+	}
+	url.LinkOptions = opts
+
+	store.urlList[url.ShortURL] = url
+	store.origins[url.OriginalURL] = url.ShortURL
+
+	if err := store.writeToFile(&url); err != nil {
+		return url, err
+	}
+	return url, store.outbox.record(newEvent(EventLinkCreated, url))
+}
+
+// Ping checks that the storage file can still be opened for appending.
+func (store *FileStore) Ping(_ context.Context) error {
+	producer, err := NewProducer(config.Options.StoragePath)
+	if err != nil {
+		return err
+	}
+
+	return producer.Close()
+}
@@ -15,6 +15,7 @@ func TestFileStore_SetAndGet(t *testing.T) {
 	tmpFile, err := os.CreateTemp("", "test_store_*.json")
 	assert.NoError(t, err)
 	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
 
 	config.Options.StoragePath = tmpFile.Name()
 
@@ -36,11 +37,44 @@ func TestFileStore_SetAndGet(t *testing.T) {
 	assert.Equal(t, originalURL, retrievedURL)
 }
 
+func TestFileStore_SetExisting(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_store_*.json")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
+
+	config.Options.StoragePath = tmpFile.Name()
+
+	store, err := NewFileStore()
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+	url, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+	_, err = store.UpdateOptions(ctx, "test-user", url.ShortURL, LinkOptions{RedirectType: 301})
+	assert.NoError(t, err)
+
+	// Shortening the URL again, even by another user, leaves the link and the file unchanged.
+	other := context.WithValue(context.Background(), middleware.UserIDKey, "other-user")
+	existing, err := store.Set(other, "https://example.com")
+	var conflict *InsertConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, url.ShortURL, existing.ShortURL)
+
+	reloaded, err := NewFileStore()
+	assert.NoError(t, err)
+	link, err := reloaded.GetURL(ctx, url.ShortURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-user", link.UserID)
+	assert.Equal(t, 301, link.RedirectType)
+}
+
 func TestFileStore_BatchDeleteURLs(t *testing.T) {
 	// Setup temporary file for testing
 	tmpFile, err := os.CreateTemp("", "test_store_*.json")
 	assert.NoError(t, err)
 	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
 
 	config.Options.StoragePath = tmpFile.Name()
 
@@ -55,19 +89,48 @@ func TestFileStore_BatchDeleteURLs(t *testing.T) {
 	url2, _ := store.Set(ctx, "https://example2.com")
 
 	// Batch delete URLs
-	err = store.BatchDeleteURLs("test-user", []string{url1.ShortURL, url2.ShortURL})
+	deleted, err := store.BatchDeleteURLs("test-user", []string{url1.ShortURL, url2.ShortURL})
 	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{url1.ShortURL, url2.ShortURL}, deleted)
 
 	// Verify deletion
 	assert.True(t, store.urlList[url1.ShortURL].DeletedFlag)
 	assert.True(t, store.urlList[url2.ShortURL].DeletedFlag)
 }
 
+func TestFileStore_Flush(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_store_*.json")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
+
+	config.Options.StoragePath = tmpFile.Name()
+
+	store, err := NewFileStore()
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+	deleted, _ := store.Set(ctx, "https://example1.com")
+	kept, _ := store.Set(ctx, "https://example2.com")
+	_, err = store.BatchDeleteURLs("test-user", []string{deleted.ShortURL})
+	assert.NoError(t, err)
+
+	// The storage is flushed through the storage wrappers.
+	wrapped := NewCachedStore(NewInstrumentedStore(store, "file", nil), CacheOptions{})
+	assert.NoError(t, Flush(ctx, wrapped))
+
+	reloaded, err := NewFileStore()
+	assert.NoError(t, err)
+	assert.True(t, reloaded.urlList[deleted.ShortURL].DeletedFlag)
+	assert.False(t, reloaded.urlList[kept.ShortURL].DeletedFlag)
+}
+
 func TestFileStore_loadFromFile(t *testing.T) {
 	// Setup temporary file for testing
 	tmpFile, err := os.CreateTemp("", "test_store_*.json")
 	assert.NoError(t, err)
 	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
 
 	config.Options.StoragePath = tmpFile.Name()
 
@@ -100,6 +163,7 @@ func TestFileStore_SetBatch(t *testing.T) {
 	tmpFile, err := os.CreateTemp("", "test_store_*.json")
 	assert.NoError(t, err)
 	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
 
 	config.Options.StoragePath = tmpFile.Name()
 
@@ -121,4 +185,124 @@ func TestFileStore_SetBatch(t *testing.T) {
 	for i, url := range urls {
 		assert.Equal(t, batch[i].OriginalURL, url.OriginalURL)
 	}
+
+	// Existing URLs are kept, and the new ones are reloaded from the file
+	urls, err = store.SetBatch(ctx, append(batch, RequestBodyBanch{CorrelationID: "id3", OriginalURL: "https://example3.com"}))
+	assert.NoError(t, err)
+	assert.True(t, urls[0].Existing)
+	assert.True(t, urls[1].Existing)
+	assert.False(t, urls[2].Existing)
+
+	reloaded, err := NewFileStore()
+	assert.NoError(t, err)
+	original, err := reloaded.Get(ctx, urls[2].ShortURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example3.com", original)
+}
+
+func TestFileStore_UpdateOptionsPersisted(t *testing.T) {
+	// Setup temporary file for testing
+	tmpFile, err := os.CreateTemp("", "test_store_*.json")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
+
+	config.Options.StoragePath = tmpFile.Name()
+
+	store, err := NewFileStore()
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	url, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+
+	_, err = store.UpdateOptions(ctx, "test-user", url.ShortURL, LinkOptions{RedirectType: 308, Tags: []string{"old"}})
+	assert.NoError(t, err)
+	_, err = store.UpdateOptions(ctx, "test-user", url.ShortURL, LinkOptions{RedirectType: 308, Tags: []string{"work"}})
+	assert.NoError(t, err)
+	_, err = store.UpdateRules(ctx, "test-user", url.ShortURL, []Rule{{Device: "mobile", Destination: "https://m.example.com"}})
+	assert.NoError(t, err)
+
+	// Reload the store from the file: the latest record must win
+	reloaded, err := NewFileStore()
+	assert.NoError(t, err)
+
+	link, err := reloaded.GetURL(ctx, url.ShortURL)
+	assert.NoError(t, err)
+	assert.Equal(t, 308, link.RedirectType)
+	assert.Equal(t, []Rule{{Device: "mobile", Destination: "https://m.example.com"}}, link.Rules)
+
+	// The tag index is rebuilt from the latest records
+	page, err := reloaded.QueryByUserID(ctx, "test-user", URLQuery{Tag: "work"})
+	assert.NoError(t, err)
+	assert.Len(t, page.URLs, 1)
+	assert.False(t, page.URLs[0].CreatedAt.IsZero())
+
+	page, err = reloaded.QueryByUserID(ctx, "test-user", URLQuery{Tag: "old"})
+	assert.NoError(t, err)
+	assert.Empty(t, page.URLs)
+}
+
+func TestFileStore_SetPageInfoPersisted(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_store_*.json")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
+
+	config.Options.StoragePath = tmpFile.Name()
+
+	store, err := NewFileStore()
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	url, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.SetPageInfo(ctx, url.ShortURL, PageInfo{Title: "Example Domain"}))
+	assert.ErrorIs(t, store.SetPageInfo(ctx, "nonexistent", PageInfo{}), ErrURLNotFound)
+
+	reloaded, err := NewFileStore()
+	assert.NoError(t, err)
+
+	link, err := reloaded.GetURL(ctx, url.ShortURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Domain", link.Page.Title)
+}
+
+func TestFileStore_SetWithAliasPersisted(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_store_*.json")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
+
+	config.Options.StoragePath = tmpFile.Name()
+
+	store, err := NewFileStore()
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	created, err := store.SetWithAlias(ctx, "https://example.com", "home", LinkOptions{Title: "Home"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Home", created.Title)
+
+	_, err = store.SetWithAlias(ctx, "https://example.org", "home", LinkOptions{})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	reloaded, err := NewFileStore()
+	assert.NoError(t, err)
+
+	link, err := reloaded.GetURL(ctx, "home")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", link.OriginalURL)
+	assert.Equal(t, "Home", link.Title, "the settings are stored with the link")
+
+	// Only the creation is recorded, without a separate update of the settings
+	events, err := reloaded.PendingEvents(ctx, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, EventLinkCreated, events[0].Type)
+	}
 }
@@ -11,14 +11,20 @@ import (
 // MemoryStore represents an in-memory storage for URLs.
 // It uses a thread-safe map to store and manage URL data.
 type MemoryStore struct {
-	mu      sync.RWMutex   // Ensures thread-safe access to the urlList map.
-	urlList map[string]URL // Stores mapping of short URLs to full URL objects.
+	mu      sync.RWMutex      // Ensures thread-safe access to the urlList map.
+	urlList map[string]URL    // Stores mapping of short URLs to full URL objects.
+	tags    tagIndex          // Indexes short URLs by user and tag.
+	origins map[string]string // Maps original URLs to their latest short URL.
+	outbox  *eventLog         // Records the changes of links, see Outbox.
 }
 
 // NewMemoryStore initializes and returns a new MemoryStore instance.
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		urlList: make(map[string]URL),
+		tags:    make(tagIndex),
+		origins: make(map[string]string),
+		outbox:  &eventLog{},
 	}
 }
 
@@ -53,6 +59,7 @@ func (store *MemoryStore) GetByUserID(_ context.Context, userID string) ([]URL,
 
 // Set adds a new URL to the store, generating a unique short URL for it.
 // If the user ID is present in the context, it associates the URL with the user.
+// A URL that is already stored is left unchanged and returned with an InsertConflictError.
 func (store *MemoryStore) Set(ctx context.Context, value string) (URL, error) {
 	store.mu.Lock()
 	defer store.mu.Unlock()
@@ -63,35 +70,48 @@ func (store *MemoryStore) Set(ctx context.Context, value string) (URL, error) {
 		userID = ctxValue.(string)
 	}
 
-	url := getURLObject(value, userID)
+	url, err := newAliasedURL(store.urlList, store.origins, value, "", userID)
+	if err != nil {
+		return url, err
+	}
+
 	store.urlList[url.ShortURL] = url
+	store.origins[url.OriginalURL] = url.ShortURL
+	_ = store.outbox.record(newEvent(EventLinkCreated, url))
 	return url, nil
 }
 
 // SetBatch adds multiple URLs to the store in a single operation.
-// Each URL is associated with a user ID derived from the context.
-func (store *MemoryStore) SetBatch(ctx context.Context, urls []RequestBodyBanch) ([]URL, error) {
-	URLs := make([]URL, 0, len(urls))
+// Each URL is associated with a user ID derived from the context. URLs that were already
+// shortened are returned as existing and left unchanged.
+func (store *MemoryStore) SetBatch(ctx context.Context, urls []RequestBodyBanch) ([]BatchURL, error) {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	userID := ctx.Value(middleware.UserIDKey).(string)
-	for _, url := range urls {
-		url := getURLObjectWithID(url.CorrelationID, url.OriginalURL, userID)
-		store.urlList[url.ShortURL] = url
-		URLs = append(URLs, url)
+	userID, _ := ctx.Value(middleware.UserIDKey).(string)
+	results := newBatchURLs(store.urlList, store.origins, urls, userID)
+
+	var events []Event
+	for _, result := range results {
+		if !result.Existing && result.Err == nil {
+			store.urlList[result.ShortURL] = result.URL
+			store.origins[result.OriginalURL] = result.ShortURL
+			events = append(events, newEvent(EventLinkCreated, result.URL))
+		}
 	}
+	_ = store.outbox.record(events...)
 
-	return URLs, nil
+	return results, nil
 }
 
-// BatchDeleteURLs marks multiple URLs as deleted for a specific user ID.
-func (store *MemoryStore) BatchDeleteURLs(userID string, batch []string) error {
+// BatchDeleteURLs marks multiple URLs as deleted for a specific user ID and returns the short
+// URLs it marked, leaving out unknown, foreign and already deleted ones.
+func (store *MemoryStore) BatchDeleteURLs(userID string, batch []string) ([]string, error) {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
 	if len(store.urlList) == 0 {
-		return errors.New("no URLs in the store")
+		return nil, errors.New("no URLs in the store")
 	}
 
 	batchMap := make(map[string]struct{})
@@ -99,16 +119,23 @@ func (store *MemoryStore) BatchDeleteURLs(userID string, batch []string) error {
 		batchMap[shortURL] = struct{}{}
 	}
 
+	var (
+		events  []Event
+		deleted []string
+	)
 	for key, url := range store.urlList {
 		if url.UserID == userID {
-			if _, found := batchMap[url.ShortURL]; found {
+			if _, found := batchMap[url.ShortURL]; found && !url.DeletedFlag {
 				url.DeletedFlag = true
 				store.urlList[key] = url
+				events = append(events, newEvent(EventLinkDeleted, url))
+				deleted = append(deleted, url.ShortURL)
 			}
 		}
 	}
+	_ = store.outbox.record(events...)
 
-	return nil
+	return deleted, nil
 }
 
 // GetStats retrieves service statistic
@@ -130,3 +157,160 @@ func (store *MemoryStore) GetStats(_ context.Context) (Stats, error) {
 
 	return stats, nil
 }
+
+// GetURL retrieves the full link record for the given short URL.
+// Returns ErrURLNotFound if the key is unknown and a DeletedURLError if the URL was deleted.
+func (store *MemoryStore) GetURL(_ context.Context, key string) (URL, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return URL{}, ErrURLNotFound
+	}
+
+	if url.DeletedFlag {
+		return url, NewDeletedURLError()
+	}
+
+	return url, nil
+}
+
+// UpdateOptions replaces the per-link settings of a URL owned by the given user.
+func (store *MemoryStore) UpdateOptions(_ context.Context, userID string, key string, opts LinkOptions) (URL, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok || url.UserID != userID || url.DeletedFlag {
+		return URL{}, ErrURLNotFound
+	}
+
+	store.tags.remove(url)
+	url.LinkOptions = opts
+	store.urlList[key] = url
+	store.tags.add(url)
+
+	return url, nil
+}
+
+// UpdateRules replaces the redirect rules of a URL owned by the given user, keeping its other
+// settings.
+func (store *MemoryStore) UpdateRules(_ context.Context, userID string, key string, rules []Rule) (URL, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok || url.UserID != userID || url.DeletedFlag {
+		return URL{}, ErrURLNotFound
+	}
+
+	url.Rules = rules
+	store.urlList[key] = url
+
+	return url, nil
+}
+
+// RecordClick increments the click counter of the given short URL.
+func (store *MemoryStore) RecordClick(_ context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return ErrURLNotFound
+	}
+
+	url.Clicks++
+	store.urlList[key] = url
+	if url.Clicks == 1 {
+		_ = store.outbox.record(newEvent(EventLinkClicked, url))
+	}
+
+	return nil
+}
+
+// QueryByUserID retrieves a page of the user's active URLs matching the query.
+// Tag filters are served from the tag index.
+func (store *MemoryStore) QueryByUserID(_ context.Context, userID string, query URLQuery) (URLPage, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return queryURLs(store.urlList, store.tags, userID, query)
+}
+
+// SetPageInfo stores metadata fetched from the destination page of the given short URL.
+func (store *MemoryStore) SetPageInfo(_ context.Context, key string, page PageInfo) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return ErrURLNotFound
+	}
+
+	url.Page = &page
+	store.urlList[key] = url
+
+	return nil
+}
+
+// ActiveURLs retrieves up to limit URLs that are neither deleted nor expired, ordered by short
+// URL and starting after the given one.
+func (store *MemoryStore) ActiveURLs(_ context.Context, after string, limit int) ([]URL, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return activeURLs(store.urlList, after, limit), nil
+}
+
+// SetHealth records the result of a destination health check of the given short URL.
+func (store *MemoryStore) SetHealth(_ context.Context, key string, health LinkHealth) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, ok := store.urlList[key]
+	if !ok {
+		return ErrURLNotFound
+	}
+
+	url.Health = &health
+	store.urlList[key] = url
+
+	return nil
+}
+
+// SetWithAlias adds a new URL with its per-link settings to the store under the alias, or under
+// a generated short URL when the alias is empty. Existing links are never overwritten.
+func (store *MemoryStore) SetWithAlias(ctx context.Context, value string, alias string, opts LinkOptions) (URL, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	userID, _ := ctx.Value(middleware.UserIDKey).(string)
+	url, err := newAliasedURL(store.urlList, store.origins, value, alias, userID)
+	if err != nil {
+		return url, err
+	}
+	url.LinkOptions = opts
+
+	store.urlList[url.ShortURL] = url
+	store.origins[url.OriginalURL] = url.ShortURL
+	_ = store.outbox.record(newEvent(EventLinkCreated, url))
+
+	return url, nil
+}
+
+// PendingEvents returns up to limit unacknowledged link events, oldest first.
+func (store *MemoryStore) PendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	return store.outbox.PendingEvents(ctx, limit)
+}
+
+// AckEvents removes the link events from the outbox.
+func (store *MemoryStore) AckEvents(ctx context.Context, ids []string) error {
+	return store.outbox.AckEvents(ctx, ids)
+}
+
+// Ping always succeeds because the store lives in memory.
+func (store *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
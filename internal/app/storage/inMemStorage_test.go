@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
 	"github.com/stretchr/testify/assert"
@@ -59,9 +60,14 @@ func TestMemoryStore_BatchDeleteURLs(t *testing.T) {
 	url1, _ := store.Set(ctx, "https://example1.com")
 	url2, _ := store.Set(ctx, "https://example2.com")
 
-	// Batch delete URLs
-	err := store.BatchDeleteURLs("test-user", []string{url1.ShortURL, url2.ShortURL})
+	// Batch delete URLs; unknown and already deleted ones are not reported
+	deleted, err := store.BatchDeleteURLs("test-user", []string{url1.ShortURL, url2.ShortURL, "unknown"})
 	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{url1.ShortURL, url2.ShortURL}, deleted)
+
+	deleted, err = store.BatchDeleteURLs("test-user", []string{url1.ShortURL})
+	assert.NoError(t, err)
+	assert.Empty(t, deleted)
 
 	// Verify deletion
 	assert.True(t, store.urlList[url1.ShortURL].DeletedFlag)
@@ -98,3 +104,294 @@ func TestMemoryStore_SetAndGetByUserID(t *testing.T) {
 		assert.True(t, urlMap[expectedURL], "Expected URL not found: %s", expectedURL)
 	}
 }
+
+func TestMemoryStore_UpdateOptionsAndRecordClick(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	url, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+
+	// Only the owner can change the settings
+	_, err = store.UpdateOptions(ctx, "other-user", url.ShortURL, LinkOptions{RedirectType: 301})
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	updated, err := store.UpdateOptions(ctx, "test-user", url.ShortURL, LinkOptions{RedirectType: 301})
+	assert.NoError(t, err)
+	assert.Equal(t, 301, updated.RedirectType)
+
+	assert.NoError(t, store.RecordClick(ctx, url.ShortURL))
+	assert.ErrorIs(t, store.RecordClick(ctx, "nonexistent"), ErrURLNotFound)
+
+	link, err := store.GetURL(ctx, url.ShortURL)
+	assert.NoError(t, err)
+	assert.Equal(t, 301, link.RedirectType)
+	assert.Equal(t, int64(1), link.Clicks)
+}
+
+func TestMemoryStore_SetExisting(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	url, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+	_, err = store.UpdateOptions(ctx, "test-user", url.ShortURL, LinkOptions{RedirectType: 301, Tags: []string{"docs"}})
+	assert.NoError(t, err)
+	assert.NoError(t, store.RecordClick(ctx, url.ShortURL))
+
+	// Shortening the URL again, even by another user, returns the link unchanged.
+	other := context.WithValue(context.Background(), middleware.UserIDKey, "other-user")
+	existing, err := store.Set(other, "https://example.com")
+	var conflict *InsertConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, url.ShortURL, existing.ShortURL)
+	assert.Equal(t, "test-user", existing.UserID)
+
+	link, err := store.GetURL(ctx, url.ShortURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-user", link.UserID)
+	assert.Equal(t, 301, link.RedirectType)
+	assert.Equal(t, []string{"docs"}, link.Tags)
+	assert.Equal(t, int64(1), link.Clicks)
+	assert.Equal(t, url.CreatedAt, link.CreatedAt)
+}
+
+func TestMemoryStore_QueryByUserIDTag(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	first, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+	second, err := store.Set(ctx, "https://example.org")
+	assert.NoError(t, err)
+
+	_, err = store.UpdateOptions(ctx, "test-user", first.ShortURL, LinkOptions{Tags: []string{"work", "docs"}})
+	assert.NoError(t, err)
+	_, err = store.UpdateOptions(ctx, "test-user", second.ShortURL, LinkOptions{Tags: []string{"work"}})
+	assert.NoError(t, err)
+
+	page, err := store.QueryByUserID(ctx, "test-user", URLQuery{Tag: "work"})
+	assert.NoError(t, err)
+	assert.Len(t, page.URLs, 2)
+
+	// Retagging moves the link out of the old tag
+	_, err = store.UpdateOptions(ctx, "test-user", first.ShortURL, LinkOptions{Tags: []string{"docs"}})
+	assert.NoError(t, err)
+
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{Tag: "work"})
+	assert.NoError(t, err)
+	assert.Len(t, page.URLs, 1)
+	assert.Equal(t, second.ShortURL, page.URLs[0].ShortURL)
+
+	// Tags are per user and deleted links are skipped
+	page, err = store.QueryByUserID(ctx, "other-user", URLQuery{Tag: "docs"})
+	assert.NoError(t, err)
+	assert.Empty(t, page.URLs)
+
+	_, err = store.BatchDeleteURLs("test-user", []string{first.ShortURL})
+	assert.NoError(t, err)
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.URLs, 1)
+}
+
+func TestMemoryStore_QueryByUserIDPages(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	var keys []string
+	for _, u := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.org"} {
+		url, err := store.Set(ctx, u)
+		assert.NoError(t, err)
+		keys = append(keys, url.ShortURL)
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, store.RecordClick(ctx, keys[0]))
+
+	// Newest first, two pages
+	page, err := store.QueryByUserID(ctx, "test-user", URLQuery{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{keys[2], keys[1]}, shortURLs(page.URLs))
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{keys[0]}, shortURLs(page.URLs))
+	assert.Empty(t, page.NextCursor)
+
+	// Most clicked first
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{Sort: SortClicks, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{keys[0]}, shortURLs(page.URLs))
+
+	// A cursor is bound to its sort order
+	_, err = store.QueryByUserID(ctx, "test-user", URLQuery{Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	// Search over the original URL, as substring and as prefix
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{Search: "EXAMPLE.COM", Ascending: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{keys[0], keys[1]}, shortURLs(page.URLs))
+
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{Search: "example", Prefix: true})
+	assert.NoError(t, err)
+	assert.Empty(t, page.URLs)
+
+	// Search over the title
+	_, err = store.UpdateOptions(ctx, "test-user", keys[2], LinkOptions{Title: "Quarterly report"})
+	assert.NoError(t, err)
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{Search: "quarterly", Prefix: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{keys[2]}, shortURLs(page.URLs))
+}
+
+func shortURLs(urls []URL) []string {
+	keys := make([]string, 0, len(urls))
+	for _, url := range urls {
+		keys = append(keys, url.ShortURL)
+	}
+	return keys
+}
+
+func TestMemoryStore_SetHealthAndQuery(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	alive, err := store.Set(ctx, "https://example.com")
+	assert.NoError(t, err)
+	gone, err := store.Set(ctx, "https://example.org")
+	assert.NoError(t, err)
+	_, err = store.Set(ctx, "https://example.net")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.SetHealth(ctx, alive.ShortURL, LinkHealth{Status: 200, CheckedAt: time.Now()}))
+	assert.NoError(t, store.SetHealth(ctx, gone.ShortURL, LinkHealth{Error: "connection refused", CheckedAt: time.Now()}))
+	assert.ErrorIs(t, store.SetHealth(ctx, "nonexistent", LinkHealth{}), ErrURLNotFound)
+
+	// Unchecked links match neither filter
+	page, err := store.QueryByUserID(ctx, "test-user", URLQuery{Health: HealthBroken})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{gone.ShortURL}, shortURLs(page.URLs))
+
+	page, err = store.QueryByUserID(ctx, "test-user", URLQuery{Health: HealthOK})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{alive.ShortURL}, shortURLs(page.URLs))
+
+	urls, err := store.ActiveURLs(ctx, "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, urls, 3)
+}
+
+func TestMemoryStore_ActiveURLs(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	for alias, opts := range map[string]LinkOptions{
+		"a": {},
+		"b": {ExpiresAt: &past},
+		"c": {ExpiresAt: &future},
+		"d": {},
+		"e": {},
+	} {
+		_, err := store.SetWithAlias(ctx, "https://example.com/"+alias, alias, opts)
+		assert.NoError(t, err)
+	}
+	_, err := store.BatchDeleteURLs("test-user", []string{"d"})
+	assert.NoError(t, err)
+
+	// Deleted and expired links are left out, and pages continue after the last short URL
+	urls, err := store.ActiveURLs(ctx, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, shortURLs(urls))
+
+	urls, err = store.ActiveURLs(ctx, "c", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e"}, shortURLs(urls))
+
+	urls, err = store.ActiveURLs(ctx, "e", 2)
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func TestMemoryStore_SetWithAlias(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	generated, err := store.SetWithAlias(ctx, "https://example.com", "", LinkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, generateShortURL("https://example.com"), generated.ShortURL)
+
+	aliased, err := store.SetWithAlias(ctx, "https://example.org", "docs", LinkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "docs", aliased.ShortURL)
+	assert.Equal(t, "test-user", aliased.UserID)
+
+	// Existing URLs are reported with their link, with or without an alias
+	var conflict *InsertConflictError
+	existing, err := store.SetWithAlias(ctx, "https://example.com", "other", LinkOptions{})
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, generated.ShortURL, existing.ShortURL)
+
+	existing, err = store.SetWithAlias(ctx, "https://example.org", "docs", LinkOptions{})
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "docs", existing.ShortURL)
+
+	_, err = store.SetWithAlias(ctx, "https://example.net", "docs", LinkOptions{})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	original, err := store.Get(ctx, "docs")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.org", original)
+}
+
+func TestMemoryStore_SetBatchExisting(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	first, err := store.Set(ctx, "https://example1.com")
+	assert.NoError(t, err)
+
+	urls, err := store.SetBatch(ctx, []RequestBodyBanch{
+		{CorrelationID: "id1", OriginalURL: "https://example1.com"},
+		{CorrelationID: "id2", OriginalURL: "https://example2.com"},
+		{CorrelationID: "id3", OriginalURL: "https://example2.com"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 3)
+
+	assert.True(t, urls[0].Existing)
+	assert.Equal(t, first.ShortURL, urls[0].ShortURL)
+	assert.Equal(t, first.UUID, urls[0].UUID)
+
+	assert.False(t, urls[1].Existing)
+	assert.Equal(t, "id2", urls[1].UUID)
+
+	// Repeated URLs resolve to the link created earlier in the batch
+	assert.True(t, urls[2].Existing)
+	assert.Equal(t, urls[1].ShortURL, urls[2].ShortURL)
+
+	t.Run("Generated short URL held by another URL", func(t *testing.T) {
+		taken, err := store.SetWithAlias(ctx, "https://example.org", generateShortURL("https://example3.com"), LinkOptions{})
+		assert.NoError(t, err)
+
+		urls, err := store.SetBatch(ctx, []RequestBodyBanch{
+			{OriginalURL: "https://example4.com"},
+			{OriginalURL: "https://example3.com"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, urls, 2)
+		assert.NoError(t, urls[0].Err)
+		assert.ErrorIs(t, urls[1].Err, ErrAliasTaken)
+		assert.Equal(t, "https://example3.com", urls[1].OriginalURL)
+
+		// The other items of the batch are stored, and the alias keeps its URL
+		original, err := store.Get(ctx, generateShortURL("https://example4.com"))
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example4.com", original)
+		original, err = store.Get(ctx, taken.ShortURL)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.org", original)
+	})
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// OperationObserver receives the outcome of every call made through an InstrumentedStore.
+// Failed is false for expected results such as unknown, deleted or already shortened URLs.
+type OperationObserver func(backend, operation string, duration time.Duration, failed bool)
+
+// InstrumentedStore reports the latency of every call to a storage backend to an OperationObserver.
+type InstrumentedStore struct {
+	store   Storage
+	backend string
+	observe OperationObserver
+}
+
+var _ Storage = (*InstrumentedStore)(nil) // Ensures InstrumentedStore implements Storage
+
+// NewInstrumentedStore wraps the storage, reporting its calls under the given backend name.
+func NewInstrumentedStore(store Storage, backend string, observe OperationObserver) *InstrumentedStore {
+	return &InstrumentedStore{store: store, backend: backend, observe: observe}
+}
+
+// Unwrap returns the instrumented storage.
+func (store *InstrumentedStore) Unwrap() Storage {
+	return store.store
+}
+
+// done reports a call started at start that returned err.
+func (store *InstrumentedStore) done(operation string, start time.Time, err error) {
+	store.observe(store.backend, operation, time.Since(start), failed(err))
+}
+
+// failed reports whether err is an actual storage failure rather than an expected result.
+func failed(err error) bool {
+	var deleted *DeletedURLError
+	var conflict *InsertConflictError
+	switch {
+	case err == nil,
+		errors.Is(err, ErrURLNotFound),
+		errors.Is(err, ErrAliasTaken),
+		errors.As(err, &deleted),
+		errors.As(err, &conflict):
+		return false
+	default:
+		return true
+	}
+}
+
+// Get retrieves the original URL for the given short URL.
+func (store *InstrumentedStore) Get(ctx context.Context, key string) (string, error) {
+	start := time.Now()
+	value, err := store.store.Get(ctx, key)
+	store.done("get", start, err)
+	return value, err
+}
+
+// GetByUserID retrieves all URLs associated with the user.
+func (store *InstrumentedStore) GetByUserID(ctx context.Context, userID string) ([]URL, error) {
+	start := time.Now()
+	urls, err := store.store.GetByUserID(ctx, userID)
+	store.done("get_by_user_id", start, err)
+	return urls, err
+}
+
+// Set stores a new URL.
+func (store *InstrumentedStore) Set(ctx context.Context, value string) (URL, error) {
+	start := time.Now()
+	url, err := store.store.Set(ctx, value)
+	store.done("set", start, err)
+	return url, err
+}
+
+// SetBatch stores multiple URLs in a single operation.
+func (store *InstrumentedStore) SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]BatchURL, error) {
+	start := time.Now()
+	urls, err := store.store.SetBatch(ctx, batch)
+	store.done("set_batch", start, err)
+	return urls, err
+}
+
+// BatchDeleteURLs marks multiple URLs of the user as deleted.
+func (store *InstrumentedStore) BatchDeleteURLs(userID string, batch []string) ([]string, error) {
+	start := time.Now()
+	deleted, err := store.store.BatchDeleteURLs(userID, batch)
+	store.done("batch_delete", start, err)
+	return deleted, err
+}
+
+// GetStats retrieves service statistics.
+func (store *InstrumentedStore) GetStats(ctx context.Context) (Stats, error) {
+	start := time.Now()
+	stats, err := store.store.GetStats(ctx)
+	store.done("get_stats", start, err)
+	return stats, err
+}
+
+// GetURL retrieves the full link record for the given short URL.
+func (store *InstrumentedStore) GetURL(ctx context.Context, key string) (URL, error) {
+	start := time.Now()
+	url, err := store.store.GetURL(ctx, key)
+	store.done("get_url", start, err)
+	return url, err
+}
+
+// UpdateOptions replaces the settings of a URL owned by the user.
+func (store *InstrumentedStore) UpdateOptions(ctx context.Context, userID string, key string, opts LinkOptions) (URL, error) {
+	start := time.Now()
+	url, err := store.store.UpdateOptions(ctx, userID, key, opts)
+	store.done("update_options", start, err)
+	return url, err
+}
+
+// UpdateRules replaces the redirect rules of a URL owned by the user.
+func (store *InstrumentedStore) UpdateRules(ctx context.Context, userID string, key string, rules []Rule) (URL, error) {
+	start := time.Now()
+	url, err := store.store.UpdateRules(ctx, userID, key, rules)
+	store.done("update_rules", start, err)
+	return url, err
+}
+
+// RecordClick increments the click counter of the given short URL.
+func (store *InstrumentedStore) RecordClick(ctx context.Context, key string) error {
+	start := time.Now()
+	err := store.store.RecordClick(ctx, key)
+	store.done("record_click", start, err)
+	return err
+}
+
+// QueryByUserID retrieves a page of the user's active URLs matching the query.
+func (store *InstrumentedStore) QueryByUserID(ctx context.Context, userID string, query URLQuery) (URLPage, error) {
+	start := time.Now()
+	page, err := store.store.QueryByUserID(ctx, userID, query)
+	store.done("query_by_user_id", start, err)
+	return page, err
+}
+
+// SetPageInfo stores the destination page metadata of the given short URL.
+func (store *InstrumentedStore) SetPageInfo(ctx context.Context, key string, page PageInfo) error {
+	start := time.Now()
+	err := store.store.SetPageInfo(ctx, key, page)
+	store.done("set_page_info", start, err)
+	return err
+}
+
+// ActiveURLs retrieves a page of URLs that are neither deleted nor expired.
+func (store *InstrumentedStore) ActiveURLs(ctx context.Context, after string, limit int) ([]URL, error) {
+	start := time.Now()
+	urls, err := store.store.ActiveURLs(ctx, after, limit)
+	store.done("active_urls", start, err)
+	return urls, err
+}
+
+// SetHealth records the health check result of the given short URL.
+func (store *InstrumentedStore) SetHealth(ctx context.Context, key string, health LinkHealth) error {
+	start := time.Now()
+	err := store.store.SetHealth(ctx, key, health)
+	store.done("set_health", start, err)
+	return err
+}
+
+// SetWithAlias stores a new URL with its settings under the alias.
+func (store *InstrumentedStore) SetWithAlias(ctx context.Context, value string, alias string, opts LinkOptions) (URL, error) {
+	start := time.Now()
+	url, err := store.store.SetWithAlias(ctx, value, alias, opts)
+	store.done("set_with_alias", start, err)
+	return url, err
+}
+
+// Ping checks that the storage backend is reachable.
+func (store *InstrumentedStore) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := store.store.Ping(ctx)
+	store.done("ping", start, err)
+	return err
+}
+
+// Close closes the instrumented storage if it holds resources.
+func (store *InstrumentedStore) Close() error {
+	if closer, ok := store.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type observedOperation struct {
+	backend   string
+	operation string
+	failed    bool
+}
+
+func TestInstrumentedStore(t *testing.T) {
+	var observed []observedOperation
+	store := NewInstrumentedStore(NewMemoryStore(), "memory", func(backend, operation string, _ time.Duration, failed bool) {
+		observed = append(observed, observedOperation{backend, operation, failed})
+	})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	_, err := store.BatchDeleteURLs("test-user", nil)
+	assert.Error(t, err, "the store is empty")
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, err = store.GetURL(ctx, url.ShortURL)
+	require.NoError(t, err)
+	_, err = store.GetURL(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	assert.Equal(t, []observedOperation{
+		{"memory", "batch_delete", true},
+		{"memory", "set", false},
+		{"memory", "get_url", false},
+		{"memory", "get_url", false}, // Unknown URLs are an expected result
+	}, observed)
+}
+
+func TestInstrumentedStore_Unwrap(t *testing.T) {
+	store := NewInstrumentedStore(NewMemoryStore(), "memory", func(string, string, time.Duration, bool) {})
+
+	_, ok := invalidationSource(NewCachedStore(store, CacheOptions{}))
+	assert.False(t, ok)
+
+	source := &fakeInvalidationSource{MemoryStore: NewMemoryStore()}
+	wrapped := NewInstrumentedStore(source, "memory", func(string, string, time.Duration, bool) {})
+	found, ok := invalidationSource(NewCachedStore(wrapped, CacheOptions{}))
+	assert.True(t, ok)
+	assert.Same(t, source, found)
+}
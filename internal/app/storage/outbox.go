@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of link events.
+const (
+	EventLinkCreated = "link.created" // A link was shortened
+	EventLinkDeleted = "link.deleted" // A link was deleted by its user
+	EventLinkClicked = "link.clicked" // A link was followed for the first time
+)
+
+// EventTypes lists all types of link events.
+var EventTypes = []string{EventLinkCreated, EventLinkDeleted, EventLinkClicked}
+
+// Event is a change of a link, recorded in the outbox of the storage in the same operation as
+// the change itself: no change is stored without its event and no event without its change.
+type Event struct {
+	ID          string    `json:"id"`           // Unique ID of the event
+	Type        string    `json:"type"`         // One of EventTypes
+	UserID      string    `json:"user_id"`      // Owner of the link
+	ShortURL    string    `json:"short_url"`    // Short URL key of the link
+	OriginalURL string    `json:"original_url"` // Destination of the link
+	OccurredAt  time.Time `json:"occurred_at"`  // Moment of the change
+}
+
+// Outbox is implemented by storages recording an Event for every link change. Events are
+// returned until they are acknowledged, so each one is handed out at least once.
+type Outbox interface {
+	PendingEvents(ctx context.Context, limit int) ([]Event, error) // PendingEvents returns up to limit unacknowledged events, oldest first.
+	AckEvents(ctx context.Context, ids []string) error             // AckEvents removes the events from the outbox.
+}
+
+var (
+	_ Outbox = (*MemoryStore)(nil)   // Ensures MemoryStore implements Outbox
+	_ Outbox = (*FileStore)(nil)     // Ensures FileStore implements Outbox
+	_ Outbox = (*DatabaseStore)(nil) // Ensures DatabaseStore implements Outbox
+)
+
+// FindOutbox finds an Outbox among the storage and the storages it wraps.
+func FindOutbox(store Storage) (Outbox, bool) {
+	return find[Outbox](store)
+}
+
+// newEvent creates an event of the given type for the link.
+func newEvent(eventType string, url URL) Event {
+	return Event{
+		ID:          uuid.NewString(),
+		Type:        eventType,
+		UserID:      url.UserID,
+		ShortURL:    url.ShortURL,
+		OriginalURL: url.OriginalURL,
+		OccurredAt:  time.Now().UTC(),
+	}
+}
+
+// eventLog is the outbox of MemoryStore and FileStore. Stores record events while holding
+// their own lock, so that events are added together with the change. With a path, events are
+// also appended to that file and survive restarts.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+	path   string
+}
+
+// loadEventLog reads the unacknowledged events of the file at path. A missing file holds no
+// events and is only created by the first recorded event.
+func loadEventLog(path string) (*eventLog, error) {
+	outbox := &eventLog{path: path}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return outbox, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		data, err := reader.ReadBytes('\n')
+		if len(data) > 0 {
+			var event Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				return nil, err
+			}
+			outbox.events = append(outbox.events, event)
+		}
+		if err == io.EOF {
+			return outbox, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// record adds the events to the outbox.
+func (outbox *eventLog) record(events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	if outbox.path != "" {
+		file, err := os.OpenFile(outbox.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := writeEvents(file, events); err != nil {
+			return err
+		}
+	}
+
+	outbox.events = append(outbox.events, events...)
+	return nil
+}
+
+// PendingEvents returns up to limit unacknowledged events, oldest first.
+func (outbox *eventLog) PendingEvents(_ context.Context, limit int) ([]Event, error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	return slices.Clone(outbox.events[:min(limit, len(outbox.events))]), nil
+}
+
+// AckEvents removes the events from the outbox. With a path, the remaining events replace the file.
+func (outbox *eventLog) AckEvents(_ context.Context, ids []string) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	remaining := slices.DeleteFunc(slices.Clone(outbox.events), func(event Event) bool {
+		return slices.Contains(ids, event.ID)
+	})
+
+	if outbox.path != "" {
+		if err := replaceEvents(outbox.path, remaining); err != nil {
+			return err
+		}
+	}
+
+	outbox.events = remaining
+	return nil
+}
+
+// writeEvents writes the events as JSON lines.
+func writeEvents(w io.Writer, events []Event) error {
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceEvents atomically replaces the file at path with the events.
+func replaceEvents(path string, events []Event) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".outbox-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeEvents(tmp, events); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
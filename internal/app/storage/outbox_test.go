@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/config"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventTypes returns the types of the events, in order.
+func eventTypes(events []Event) []string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestMemoryStore_Outbox(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, err = store.Set(ctx, "https://example.com")
+	require.Error(t, err)
+	require.NoError(t, store.RecordClick(ctx, url.ShortURL))
+	require.NoError(t, store.RecordClick(ctx, url.ShortURL))
+	_, err = store.BatchDeleteURLs("test-user", []string{url.ShortURL})
+	require.NoError(t, err)
+	deleted, err := store.BatchDeleteURLs("test-user", []string{url.ShortURL})
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+
+	// Only the insertion, the first click and the first deletion are events, found through the
+	// wrappers.
+	outbox, ok := FindOutbox(NewCachedStore(NewInstrumentedStore(store, "memory", nil), CacheOptions{}))
+	require.True(t, ok)
+	events, err := outbox.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{EventLinkCreated, EventLinkClicked, EventLinkDeleted}, eventTypes(events))
+	assert.Equal(t, "test-user", events[0].UserID)
+	assert.Equal(t, url.ShortURL, events[0].ShortURL)
+	assert.Equal(t, "https://example.com", events[0].OriginalURL)
+
+	require.NoError(t, outbox.AckEvents(ctx, []string{events[0].ID}))
+	events, err = outbox.PendingEvents(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{EventLinkClicked}, eventTypes(events))
+}
+
+func TestFileStore_Outbox(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_store_*.json")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
+
+	config.Options.StoragePath = tmpFile.Name()
+
+	store, err := NewFileStore()
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, err = store.Set(ctx, "https://example.com")
+	require.Error(t, err, "shortening the URL again records no event")
+	_, err = store.BatchDeleteURLs("test-user", []string{url.ShortURL})
+	require.NoError(t, err)
+
+	events, err := store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{EventLinkCreated, EventLinkDeleted}, eventTypes(events))
+	require.NoError(t, store.AckEvents(ctx, []string{events[0].ID}))
+
+	// Unacknowledged events and deletions survive a restart.
+	reloaded, err := NewFileStore()
+	require.NoError(t, err)
+	assert.True(t, reloaded.urlList[url.ShortURL].DeletedFlag)
+	pending, err := reloaded.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, events[1:], pending)
+}
+
+func TestFileStore_ClicksSurviveRestart(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_store_*.json")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(outboxPath(tmpFile.Name()))
+
+	config.Options.StoragePath = tmpFile.Name()
+
+	store, err := NewFileStore()
+	require.NoError(t, err)
+
+	lines := func() int {
+		data, err := os.ReadFile(tmpFile.Name())
+		require.NoError(t, err)
+		return bytes.Count(data, []byte("\n"))
+	}
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	for range 5 {
+		require.NoError(t, store.RecordClick(ctx, url.ShortURL))
+	}
+
+	// Only the first click is written right away; the count follows on flush.
+	assert.Equal(t, 2, lines())
+	require.NoError(t, store.Flush(ctx))
+	assert.Equal(t, 3, lines())
+
+	// After a restart the count goes on and the first click is not an event again.
+	reloaded, err := NewFileStore()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), reloaded.urlList[url.ShortURL].Clicks)
+	require.NoError(t, reloaded.RecordClick(ctx, url.ShortURL))
+	assert.Equal(t, int64(6), reloaded.urlList[url.ShortURL].Clicks)
+
+	events, err := reloaded.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{EventLinkCreated, EventLinkClicked}, eventTypes(events))
+}
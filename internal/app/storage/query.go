@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort keys of a user's link list.
+const (
+	SortCreated = "created" // By creation time
+	SortClicks  = "clicks"  // By number of redirects served
+)
+
+// Health filters of a user's link list.
+const (
+	HealthBroken = "broken" // Links whose last check failed
+	HealthOK     = "ok"     // Links whose last check succeeded
+)
+
+// ErrInvalidCursor is returned when a page cursor is malformed or was issued for another sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// URLQuery selects links of a user. Deleted links are never returned.
+type URLQuery struct {
+	Tag       string // Only links carrying the tag
+	Health    string // HealthBroken or HealthOK selects checked links by their last result
+	Search    string // Case-insensitive text matched against the original URL and the title
+	Prefix    bool   // Match Search as a prefix instead of a substring
+	Sort      string // SortCreated or SortClicks; empty means SortCreated
+	Ascending bool   // Sort in ascending order; the default is newest or most clicked first
+	Limit     int    // Maximum number of links in the page; zero returns all links
+	Cursor    string // Position after which the page starts, taken from URLPage.NextCursor
+}
+
+// URLPage is a page of a user's link list.
+type URLPage struct {
+	URLs       []URL  // Links in the requested order
+	NextCursor string // Cursor of the next page; empty on the last page
+}
+
+// cursor is the decoded position of the last link of a page.
+// Links are ordered by the sort value and then by the short URL, which makes the order total.
+type cursor struct {
+	value    int64  // Creation time in microseconds or click count of the last link
+	shortURL string // Short URL of the last link
+}
+
+// sortKey returns the sort key of the query with its default applied.
+func (query URLQuery) sortKey() string {
+	if query.Sort == "" {
+		return SortCreated
+	}
+
+	return query.Sort
+}
+
+// order returns the order prefix embedded into cursors, binding them to the sort order.
+func (query URLQuery) order() string {
+	direction := "desc"
+	if query.Ascending {
+		direction = "asc"
+	}
+
+	return query.sortKey() + "." + direction
+}
+
+// sortValue returns the value the URL is ordered by under the query.
+func (query URLQuery) sortValue(url URL) int64 {
+	if query.sortKey() == SortClicks {
+		return url.Clicks
+	}
+
+	return url.CreatedAt.UnixMicro()
+}
+
+// encodeCursor returns an opaque cursor pointing after the URL.
+func (query URLQuery) encodeCursor(url URL) string {
+	raw := query.order() + ":" + strconv.FormatInt(query.sortValue(url), 10) + ":" + url.ShortURL
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses the cursor of the query. A query without a cursor yields nil.
+func (query URLQuery) decodeCursor() (*cursor, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || parts[0] != query.order() || parts[2] == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	value, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor{value: value, shortURL: parts[2]}, nil
+}
+
+// matches reports whether the URL satisfies the tag, health and search filters of the query.
+func (query URLQuery) matches(url URL) bool {
+	if query.Tag != "" && !slices.Contains(url.Tags, query.Tag) {
+		return false
+	}
+
+	switch query.Health {
+	case HealthBroken:
+		if url.Health == nil || !url.Health.Broken() {
+			return false
+		}
+	case HealthOK:
+		if url.Health == nil || url.Health.Broken() {
+			return false
+		}
+	}
+
+	if query.Search == "" {
+		return true
+	}
+
+	search := strings.ToLower(query.Search)
+	for _, field := range []string{url.OriginalURL, url.Title} {
+		field = strings.ToLower(field)
+		if (query.Prefix && strings.HasPrefix(field, search)) || (!query.Prefix && strings.Contains(field, search)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// before reports whether a is listed before b.
+func (query URLQuery) before(a, b URL) bool {
+	return query.beforePosition(query.sortValue(a), a.ShortURL, query.sortValue(b), b.ShortURL)
+}
+
+// beforePosition compares two positions given by sort value and short URL.
+func (query URLQuery) beforePosition(valueA int64, keyA string, valueB int64, keyB string) bool {
+	if valueA != valueB {
+		return (valueA < valueB) == query.Ascending
+	}
+
+	if keyA == keyB {
+		return false
+	}
+
+	return (keyA < keyB) == query.Ascending
+}
+
+// paginate sorts the URLs, skips those up to the cursor and cuts the page to the limit.
+func (query URLQuery) paginate(urls []URL) (URLPage, error) {
+	after, err := query.decodeCursor()
+	if err != nil {
+		return URLPage{}, err
+	}
+
+	sort.Slice(urls, func(i, j int) bool {
+		return query.before(urls[i], urls[j])
+	})
+
+	if after != nil {
+		start := sort.Search(len(urls), func(i int) bool {
+			return query.beforePosition(after.value, after.shortURL, query.sortValue(urls[i]), urls[i].ShortURL)
+		})
+		urls = urls[start:]
+	}
+
+	if query.Limit <= 0 || len(urls) <= query.Limit {
+		return URLPage{URLs: urls}, nil
+	}
+
+	urls = urls[:query.Limit]
+
+	return URLPage{URLs: urls, NextCursor: query.encodeCursor(urls[len(urls)-1])}, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// searchPattern returns the ILIKE pattern for the search text of the query.
+func (query URLQuery) searchPattern() string {
+	if query.Prefix {
+		return escapeLike(query.Search) + "%"
+	}
+
+	return "%" + escapeLike(query.Search) + "%"
+}
+
+// sqlOrder returns the column the query is sorted by and the comparison operator selecting rows after a cursor.
+func (query URLQuery) sqlOrder() (column string, direction string, operator string) {
+	column = "created_at"
+	if query.sortKey() == SortClicks {
+		column = "clicks"
+	}
+
+	if query.Ascending {
+		return column, "ASC", ">"
+	}
+
+	return column, "DESC", "<"
+}
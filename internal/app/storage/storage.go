@@ -4,6 +4,11 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
 
 	"github.com/golangTroshin/shorturl/internal/app/config"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -12,22 +17,159 @@ import (
 // Storage defines the interface for a URL storage system. It supports CRUD
 // operations for URLs and batch operations for managing multiple URLs.
 type Storage interface {
-	Get(ctx context.Context, key string) (string, error)                   // Get retrieves the original URL corresponding to the given short URL.
-	GetByUserID(ctx context.Context, userID string) ([]URL, error)         // GetByUserID retrieves all URLs associated with the specified user ID.
-	Set(ctx context.Context, value string) (URL, error)                    // Set creates and stores a new short URL for the given original URL.
-	SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]URL, error) // SetBatch stores multiple URLs in a single operation.
-	BatchDeleteURLs(userID string, batch []string) error                   // BatchDeleteURLs marks multiple URLs as deleted for a specific user.
-	GetStats(ctx context.Context) (Stats, error)                           // GetStats retrieves service statistic
+	Get(ctx context.Context, key string) (string, error)                                         // Get retrieves the original URL corresponding to the given short URL.
+	GetByUserID(ctx context.Context, userID string) ([]URL, error)                               // GetByUserID retrieves all URLs associated with the specified user ID.
+	Set(ctx context.Context, value string) (URL, error)                                          // Set creates and stores a new short URL for the given original URL.
+	SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]BatchURL, error)                  // SetBatch stores multiple URLs in a single operation, keeping existing ones.
+	BatchDeleteURLs(userID string, batch []string) ([]string, error)                             // BatchDeleteURLs marks multiple URLs as deleted for a specific user and returns those it marked.
+	GetStats(ctx context.Context) (Stats, error)                                                 // GetStats retrieves service statistic
+	GetURL(ctx context.Context, key string) (URL, error)                                         // GetURL retrieves the full link record for the given short URL.
+	UpdateOptions(ctx context.Context, userID string, key string, opts LinkOptions) (URL, error) // UpdateOptions replaces the per-link settings of a URL owned by the user.
+	UpdateRules(ctx context.Context, userID string, key string, rules []Rule) (URL, error)       // UpdateRules replaces only the redirect rules of a URL owned by the user, keeping its other settings.
+	RecordClick(ctx context.Context, key string) error                                           // RecordClick increments the click counter of the given short URL.
+	QueryByUserID(ctx context.Context, userID string, query URLQuery) (URLPage, error)           // QueryByUserID retrieves a page of the user's active URLs matching the query.
+	SetPageInfo(ctx context.Context, key string, page PageInfo) error                            // SetPageInfo stores metadata fetched from the destination page of the given short URL.
+	ActiveURLs(ctx context.Context, after string, limit int) ([]URL, error)                      // ActiveURLs retrieves a page of URLs that are neither deleted nor expired, ordered by short URL and starting after the given one.
+	SetHealth(ctx context.Context, key string, health LinkHealth) error                          // SetHealth records the result of a destination health check of the given short URL.
+	SetWithAlias(ctx context.Context, value string, alias string, opts LinkOptions) (URL, error) // SetWithAlias stores a new URL with its settings under the alias, or a generated short URL when it is empty, never overwriting a link.
+	Ping(ctx context.Context) error                                                              // Ping checks that the storage backend is reachable.
 }
 
+// Flusher is implemented by storages buffering changes before they reach the disk.
+type Flusher interface {
+	Flush(ctx context.Context) error // Flush persists the buffered changes.
+}
+
+// Flush persists the changes buffered by the storage or a storage wrapped by it. It does
+// nothing when no storage implements Flusher.
+func Flush(ctx context.Context, store Storage) error {
+	if flusher, ok := find[Flusher](store); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// Database finds a DatabaseStore among the storage and the storages it wraps.
+func Database(store Storage) (*DatabaseStore, bool) {
+	return find[*DatabaseStore](store)
+}
+
+// find returns the first of the storage and the storages it wraps that is a T.
+func find[T any](store Storage) (T, bool) {
+	for {
+		if found, ok := store.(T); ok {
+			return found, true
+		}
+
+		wrapper, ok := store.(interface{ Unwrap() Storage })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		store = wrapper.Unwrap()
+	}
+}
+
+// ErrURLNotFound is returned when the requested short URL does not exist
+// or is not owned by the requesting user.
+var ErrURLNotFound = errors.New("url not found")
+
+// ErrAliasTaken is returned when a requested short URL is already used by a link to another URL.
+var ErrAliasTaken = errors.New("alias is already taken")
+
 // URL represents a mapping between a short URL and its original URL.
 // It includes metadata such as user ownership and deletion status.
 type URL struct {
-	UUID        string `json:"uuid"`         // Unique identifier for the URL
-	ShortURL    string `json:"short_url"`    // Shortened URL key
-	OriginalURL string `json:"original_url"` // Original URL
-	UserID      string // User who owns the URL
-	DeletedFlag bool   `db:"is_deleted"` // Indicates if the URL has been deleted
+	UUID        string      `json:"uuid"`         // Unique identifier for the URL
+	ShortURL    string      `json:"short_url"`    // Shortened URL key
+	OriginalURL string      `json:"original_url"` // Original URL
+	UserID      string      // User who owns the URL
+	DeletedFlag bool        `db:"is_deleted"`         // Indicates if the URL has been deleted
+	Clicks      int64       `json:"clicks"`           // Number of redirects served for the URL
+	CreatedAt   time.Time   `json:"created_at"`       // Moment the URL was shortened
+	Page        *PageInfo   `json:"page,omitempty"`   // Metadata fetched from the destination page
+	Health      *LinkHealth `json:"health,omitempty"` // Result of the last destination health check
+	LinkOptions             // Per-link settings
+}
+
+// LinkHealth is the result of checking whether the destination of a link is reachable.
+type LinkHealth struct {
+	Status    int       `json:"status,omitempty"` // Final HTTP status of the destination; zero if the request failed
+	Error     string    `json:"error,omitempty"`  // Reason the request failed
+	CheckedAt time.Time `json:"checked_at"`       // Moment of the check
+}
+
+// Broken reports whether the destination failed to respond or returned an error status.
+func (h LinkHealth) Broken() bool {
+	return h.Status == 0 || h.Status >= 400
+}
+
+// PageInfo holds metadata extracted from the destination page of a link.
+type PageInfo struct {
+	Title         string     `json:"title,omitempty"`          // Text of the <title> element
+	OGTitle       string     `json:"og_title,omitempty"`       // Open Graph title
+	OGDescription string     `json:"og_description,omitempty"` // Open Graph description
+	Favicon       string     `json:"favicon,omitempty"`        // Absolute URL of the favicon
+	FetchedAt     *time.Time `json:"fetched_at,omitempty"`     // Moment the page was fetched
+}
+
+// LinkOptions holds per-link settings that control how a short URL is served.
+// Zero values mean "use the service default".
+type LinkOptions struct {
+	RedirectType int        `json:"redirect_type,omitempty"` // HTTP status used for the redirect: 301, 302, 307 or 308
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // Moment after which the link stops redirecting
+	Rules        []Rule     `json:"rules,omitempty"`         // Ordered conditional redirect rules; the first match wins
+	UTM          *UTM       `json:"utm,omitempty"`           // UTM parameters added to the destination at redirect time
+	PassQuery    bool       `json:"pass_query,omitempty"`    // Forward query parameters of the short URL to the destination
+	Title        string     `json:"title,omitempty"`         // Owner-supplied title shown on the preview page
+	Description  string     `json:"description,omitempty"`   // Owner-supplied description shown on the preview page
+	Note         string     `json:"note,omitempty"`          // Free-text note visible to the owner only
+	Tags         []string   `json:"tags,omitempty"`          // Owner-defined labels used to filter the link list
+}
+
+// UTM is a template of campaign tracking parameters merged into the destination query string.
+// Empty fields are not added.
+type UTM struct {
+	Source   string `json:"source,omitempty"`   // utm_source
+	Medium   string `json:"medium,omitempty"`   // utm_medium
+	Campaign string `json:"campaign,omitempty"` // utm_campaign
+	Term     string `json:"term,omitempty"`     // utm_term
+	Content  string `json:"content,omitempty"`  // utm_content
+}
+
+// Rule routes requests matching all of its conditions to an alternative destination.
+// A rule without conditions matches every request.
+type Rule struct {
+	Device      string            `json:"device,omitempty"`      // Device class from User-Agent: mobile, tablet, desktop or bot
+	Language    string            `json:"language,omitempty"`    // Preferred language from Accept-Language, e.g. "de" or "en-US"
+	Query       map[string]string `json:"query,omitempty"`       // Query parameters that must be present; an empty value matches any value
+	TimeWindow  *TimeWindow       `json:"time_window,omitempty"` // Time of day when the rule is active
+	Destination string            `json:"destination,omitempty"` // Target URL when the rule matches
+	Split       []SplitVariant    `json:"split,omitempty"`       // Weighted A/B variants used instead of Destination
+}
+
+// TimeWindow is a daily time range in "HH:MM" format. The range wraps around
+// midnight when Start is later than End.
+type TimeWindow struct {
+	Start    string `json:"start"`              // Inclusive start time, e.g. "09:00"
+	End      string `json:"end"`                // Exclusive end time, e.g. "17:30"
+	Location string `json:"location,omitempty"` // IANA time zone name; UTC when empty
+}
+
+// SplitVariant is a single destination of a weighted A/B split.
+type SplitVariant struct {
+	Destination string `json:"destination"` // Target URL of the variant
+	Weight      int    `json:"weight"`      // Relative weight of the variant
+}
+
+// IsZero reports whether no per-link settings are set.
+func (o LinkOptions) IsZero() bool {
+	return reflect.ValueOf(o).IsZero()
+}
+
+// Expired reports whether the link has an expiry that is already in the past.
+func (o LinkOptions) Expired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
 }
 
 // Stats holds statistical information about saved URLs and users.
@@ -38,7 +180,8 @@ type Stats struct {
 
 // RequestURL represents the structure for incoming API requests to shorten a URL.
 type RequestURL struct {
-	URL string `json:"url"` // The original URL to be shortened
+	URL         string `json:"url"` // The original URL to be shortened
+	LinkOptions        // Optional per-link settings
 }
 
 // ResponseShortURL represents the structure of the API response for a shortened URL.
@@ -52,31 +195,176 @@ type RequestBodyBanch struct {
 	OriginalURL   string `json:"original_url"`   // The original URL to be shortened
 }
 
+// BatchURL is the link of a batch item returned by SetBatch.
+type BatchURL struct {
+	URL
+	Existing bool  // The URL was already shortened, and URL is the existing link
+	Err      error // ErrAliasTaken when the generated short URL is held by another URL; the item was not stored and only OriginalURL is set
+}
+
 // GetStorageByConfig initializes and returns the appropriate storage system
 // based on the application configuration (e.g., database, file, or memory storage).
-func GetStorageByConfig() (Storage, error) {
+// Unless disabled, the storage is wrapped in a CachedStore. When observe is not nil, the calls
+// reaching the backend are reported to it, and when tracing is enabled they are traced.
+func GetStorageByConfig(observe OperationObserver) (Storage, error) {
 	var store Storage
+	var backend string
 	var err error
 
-	if config.Options.DatabaseDsn != "" {
-		store, err = NewDatabaseStore()
-		if err != nil {
-			return store, err
+	switch {
+	case config.Options.DatabaseDsn != "":
+		backend = "postgres"
+		store, err = NewDatabaseStore(DatabaseConfig{
+			DSN:                 config.Options.DatabaseDsn,
+			MaxOpenConns:        config.Options.DBMaxOpenConns,
+			MaxIdleConns:        config.Options.DBMaxIdleConns,
+			ConnMaxLifetime:     config.Options.DBConnMaxLifetime,
+			ConnMaxIdleTime:     config.Options.DBConnMaxIdleTime,
+			StatementTimeout:    config.Options.DBStatementTimeout,
+			BulkInsertThreshold: DefaultBulkInsertThreshold,
+		})
+	case config.Options.StoragePath != "":
+		backend = "file"
+		store, err = NewFileStore()
+	default:
+		backend = "memory"
+		store = NewMemoryStore()
+	}
+	if err != nil {
+		return store, err
+	}
+
+	if observe != nil {
+		store = NewInstrumentedStore(store, backend, observe)
+	}
+
+	if config.Options.TracingExporter != "" {
+		store = NewTracedStore(store, backend)
+	}
+
+	if config.Options.CacheSize > 0 {
+		store = NewCachedStore(store, CacheOptions{
+			Size:        config.Options.CacheSize,
+			TTL:         config.Options.CacheTTL,
+			NegativeTTL: config.Options.CacheNegativeTTL,
+		})
+	}
+
+	return store, nil
+}
+
+// queryURLs selects a page of the user's active URLs matching the query from an in-memory URL map.
+func queryURLs(urlList map[string]URL, tags tagIndex, userID string, query URLQuery) (URLPage, error) {
+	var urls []URL
+
+	if query.Tag != "" {
+		for key := range tags.keys(userID, query.Tag) {
+			if url, ok := urlList[key]; ok && !url.DeletedFlag && query.matches(url) {
+				urls = append(urls, url)
+			}
+		}
+	} else {
+		for _, url := range urlList {
+			if url.UserID == userID && !url.DeletedFlag && query.matches(url) {
+				urls = append(urls, url)
+			}
 		}
+	}
+
+	return query.paginate(urls)
+}
 
-		return store, nil
+// activeURLs selects up to limit URLs that are neither deleted nor expired from an in-memory URL
+// map, ordered by short URL and starting after the given one.
+func activeURLs(urlList map[string]URL, after string, limit int) []URL {
+	now := time.Now()
+
+	var urls []URL
+	for key, url := range urlList {
+		if key > after && !url.DeletedFlag && !url.Expired(now) {
+			urls = append(urls, url)
+		}
 	}
 
-	if config.Options.StoragePath != "" {
-		store, err = NewFileStore()
-		if err != nil {
-			return store, err
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ShortURL < urls[j].ShortURL })
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls
+}
+
+// newAliasedURL prepares a link for SetWithAlias against an in-memory URL map and its index
+// of original URLs. It returns the existing link with an InsertConflictError when the URL is
+// already stored, and ErrAliasTaken when the alias holds another URL.
+func newAliasedURL(urlList map[string]URL, origins map[string]string, value string, alias string, userID string) (URL, error) {
+	if existing, ok := urlList[origins[value]]; ok && existing.OriginalURL == value {
+		return existing, NewInsertConflictError()
+	}
+
+	url := getURLObject(value, userID)
+	if alias != "" {
+		url.UUID = "uuid_" + alias
+		url.ShortURL = alias
+	}
+
+	if existing, ok := urlList[url.ShortURL]; ok {
+		if existing.OriginalURL == value {
+			return existing, NewInsertConflictError()
 		}
+		return URL{}, ErrAliasTaken
+	}
+
+	return url, nil
+}
 
-		return store, nil
+// newBatchURLs prepares the links of a batch for the in-memory indexes without modifying them,
+// returning one entry per item in batch order. Items whose URL is already stored, or appears
+// earlier in the batch, resolve to that link and are marked as existing. Items whose generated
+// short URL is held by another URL fail with ErrAliasTaken without affecting the others.
+func newBatchURLs(urlList map[string]URL, origins map[string]string, batch []RequestBodyBanch, userID string) []BatchURL {
+	results := make([]BatchURL, 0, len(batch))
+	added := make(map[string]URL)
+	keys := make(map[string]struct{})
+
+	for _, item := range batch {
+		if existing, ok := added[item.OriginalURL]; ok {
+			results = append(results, BatchURL{URL: existing, Existing: true})
+			continue
+		}
+
+		url, err := newAliasedURL(urlList, origins, item.OriginalURL, "", userID)
+		var conflict *InsertConflictError
+		switch {
+		case errors.As(err, &conflict):
+			results = append(results, BatchURL{URL: url, Existing: true})
+			continue
+		case err != nil:
+			results = append(results, failedBatchURL(item, err))
+			continue
+		}
+
+		if _, ok := keys[url.ShortURL]; ok {
+			results = append(results, failedBatchURL(item, ErrAliasTaken))
+			continue
+		}
+
+		if item.CorrelationID != "" {
+			url.UUID = item.CorrelationID
+		}
+		added[url.OriginalURL] = url
+		keys[url.ShortURL] = struct{}{}
+		results = append(results, BatchURL{URL: url})
 	}
 
-	return NewMemoryStore(), nil
+	return results
+}
+
+// failedBatchURL returns the entry of a batch item that could not be stored because of err.
+func failedBatchURL(item RequestBodyBanch, err error) BatchURL {
+	return BatchURL{
+		URL: URL{OriginalURL: item.OriginalURL},
+		Err: fmt.Errorf("short URL of %s: %w", item.OriginalURL, err),
+	}
 }
 
 func getURLObject(url string, userID string) URL {
@@ -86,6 +374,7 @@ func getURLObject(url string, userID string) URL {
 		ShortURL:    key,
 		OriginalURL: url,
 		UserID:      userID,
+		CreatedAt:   time.Now().UTC(),
 	}
 }
 
@@ -97,6 +386,7 @@ func getURLObjectWithID(uuid string, url string, userID string) URL {
 		ShortURL:    key,
 		OriginalURL: url,
 		UserID:      userID,
+		CreatedAt:   time.Now().UTC(),
 	}
 }
 
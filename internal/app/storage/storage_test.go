@@ -11,7 +11,7 @@ func TestGetStorageByConfig_MemoryStore(t *testing.T) {
 	config.Options.DatabaseDsn = ""
 	config.Options.StoragePath = ""
 
-	store, err := GetStorageByConfig()
+	store, err := GetStorageByConfig(nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, store)
@@ -21,7 +21,7 @@ func TestGetStorageByConfig_FileStore(t *testing.T) {
 	config.Options.DatabaseDsn = ""
 	config.Options.StoragePath = "test_storage.json"
 
-	store, err := GetStorageByConfig()
+	store, err := GetStorageByConfig(nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, store)
@@ -61,3 +61,15 @@ func TestGetURLObjectWithID(t *testing.T) {
 	assert.Equal(t, url, urlObject.OriginalURL)
 	assert.Equal(t, userID, urlObject.UserID)
 }
+
+func TestGetStorageByConfig_CachedStore(t *testing.T) {
+	config.Options.DatabaseDsn = ""
+	config.Options.StoragePath = ""
+	config.Options.CacheSize = 100
+	defer func() { config.Options.CacheSize = 0 }()
+
+	store, err := GetStorageByConfig(nil)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &CachedStore{}, store)
+}
@@ -0,0 +1,47 @@
+package storage
+
+// tagIndex maps a user and a tag to the short URLs carrying that tag.
+// It backs tag filtering in the memory and file stores; callers synchronise access.
+type tagIndex map[string]map[string]map[string]struct{}
+
+// add indexes the tags of the URL.
+func (idx tagIndex) add(url URL) {
+	if len(url.Tags) == 0 {
+		return
+	}
+
+	byTag, ok := idx[url.UserID]
+	if !ok {
+		byTag = make(map[string]map[string]struct{})
+		idx[url.UserID] = byTag
+	}
+
+	for _, tag := range url.Tags {
+		keys, ok := byTag[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			byTag[tag] = keys
+		}
+		keys[url.ShortURL] = struct{}{}
+	}
+}
+
+// remove drops the tags of the URL from the index.
+func (idx tagIndex) remove(url URL) {
+	byTag := idx[url.UserID]
+	for _, tag := range url.Tags {
+		delete(byTag[tag], url.ShortURL)
+		if len(byTag[tag]) == 0 {
+			delete(byTag, tag)
+		}
+	}
+
+	if len(byTag) == 0 {
+		delete(idx, url.UserID)
+	}
+}
+
+// keys returns the short URLs of the user carrying the tag.
+func (idx tagIndex) keys(userID string, tag string) map[string]struct{} {
+	return idx[userID][tag]
+}
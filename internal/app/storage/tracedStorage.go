@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of storage spans.
+const tracerName = "github.com/golangTroshin/shorturl/internal/app/storage"
+
+// TracedStore starts a span around every call to a storage backend, named after the
+// operation, such as "Storage.GetURL". Expected results such as unknown or deleted URLs
+// are not marked as errors.
+type TracedStore struct {
+	store   Storage
+	backend string
+}
+
+var _ Storage = (*TracedStore)(nil) // Ensures TracedStore implements Storage
+
+// NewTracedStore wraps the storage, labelling its spans with the given backend name.
+func NewTracedStore(store Storage, backend string) *TracedStore {
+	return &TracedStore{store: store, backend: backend}
+}
+
+// Unwrap returns the traced storage.
+func (store *TracedStore) Unwrap() Storage {
+	return store.store
+}
+
+// start starts the span of an operation.
+func (store *TracedStore) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "Storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("storage.backend", store.backend)),
+	)
+}
+
+// endSpan ends the span of an operation that returned err.
+func endSpan(span trace.Span, err error) {
+	if failed(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Get retrieves the original URL for the given short URL.
+func (store *TracedStore) Get(ctx context.Context, key string) (string, error) {
+	ctx, span := store.start(ctx, "Get")
+	value, err := store.store.Get(ctx, key)
+	endSpan(span, err)
+	return value, err
+}
+
+// GetByUserID retrieves all URLs associated with the user.
+func (store *TracedStore) GetByUserID(ctx context.Context, userID string) ([]URL, error) {
+	ctx, span := store.start(ctx, "GetByUserID")
+	urls, err := store.store.GetByUserID(ctx, userID)
+	endSpan(span, err)
+	return urls, err
+}
+
+// Set stores a new URL.
+func (store *TracedStore) Set(ctx context.Context, value string) (URL, error) {
+	ctx, span := store.start(ctx, "Set")
+	url, err := store.store.Set(ctx, value)
+	endSpan(span, err)
+	return url, err
+}
+
+// SetBatch stores multiple URLs in a single operation.
+func (store *TracedStore) SetBatch(ctx context.Context, batch []RequestBodyBanch) ([]BatchURL, error) {
+	ctx, span := store.start(ctx, "SetBatch")
+	urls, err := store.store.SetBatch(ctx, batch)
+	endSpan(span, err)
+	return urls, err
+}
+
+// BatchDeleteURLs marks multiple URLs of the user as deleted. The call carries no context,
+// so its span starts a new trace.
+func (store *TracedStore) BatchDeleteURLs(userID string, batch []string) ([]string, error) {
+	_, span := store.start(context.Background(), "BatchDeleteURLs")
+	deleted, err := store.store.BatchDeleteURLs(userID, batch)
+	endSpan(span, err)
+	return deleted, err
+}
+
+// GetStats retrieves service statistics.
+func (store *TracedStore) GetStats(ctx context.Context) (Stats, error) {
+	ctx, span := store.start(ctx, "GetStats")
+	stats, err := store.store.GetStats(ctx)
+	endSpan(span, err)
+	return stats, err
+}
+
+// GetURL retrieves the full link record for the given short URL.
+func (store *TracedStore) GetURL(ctx context.Context, key string) (URL, error) {
+	ctx, span := store.start(ctx, "GetURL")
+	url, err := store.store.GetURL(ctx, key)
+	endSpan(span, err)
+	return url, err
+}
+
+// UpdateOptions replaces the settings of a URL owned by the user.
+func (store *TracedStore) UpdateOptions(ctx context.Context, userID string, key string, opts LinkOptions) (URL, error) {
+	ctx, span := store.start(ctx, "UpdateOptions")
+	url, err := store.store.UpdateOptions(ctx, userID, key, opts)
+	endSpan(span, err)
+	return url, err
+}
+
+// UpdateRules replaces the redirect rules of a URL owned by the user.
+func (store *TracedStore) UpdateRules(ctx context.Context, userID string, key string, rules []Rule) (URL, error) {
+	ctx, span := store.start(ctx, "UpdateRules")
+	url, err := store.store.UpdateRules(ctx, userID, key, rules)
+	endSpan(span, err)
+	return url, err
+}
+
+// RecordClick increments the click counter of the given short URL.
+func (store *TracedStore) RecordClick(ctx context.Context, key string) error {
+	ctx, span := store.start(ctx, "RecordClick")
+	err := store.store.RecordClick(ctx, key)
+	endSpan(span, err)
+	return err
+}
+
+// QueryByUserID retrieves a page of the user's active URLs matching the query.
+func (store *TracedStore) QueryByUserID(ctx context.Context, userID string, query URLQuery) (URLPage, error) {
+	ctx, span := store.start(ctx, "QueryByUserID")
+	page, err := store.store.QueryByUserID(ctx, userID, query)
+	endSpan(span, err)
+	return page, err
+}
+
+// SetPageInfo stores the destination page metadata of the given short URL.
+func (store *TracedStore) SetPageInfo(ctx context.Context, key string, page PageInfo) error {
+	ctx, span := store.start(ctx, "SetPageInfo")
+	err := store.store.SetPageInfo(ctx, key, page)
+	endSpan(span, err)
+	return err
+}
+
+// ActiveURLs retrieves a page of URLs that are neither deleted nor expired.
+func (store *TracedStore) ActiveURLs(ctx context.Context, after string, limit int) ([]URL, error) {
+	ctx, span := store.start(ctx, "ActiveURLs")
+	urls, err := store.store.ActiveURLs(ctx, after, limit)
+	endSpan(span, err)
+	return urls, err
+}
+
+// SetHealth records the health check result of the given short URL.
+func (store *TracedStore) SetHealth(ctx context.Context, key string, health LinkHealth) error {
+	ctx, span := store.start(ctx, "SetHealth")
+	err := store.store.SetHealth(ctx, key, health)
+	endSpan(span, err)
+	return err
+}
+
+// SetWithAlias stores a new URL with its settings under the alias.
+func (store *TracedStore) SetWithAlias(ctx context.Context, value string, alias string, opts LinkOptions) (URL, error) {
+	ctx, span := store.start(ctx, "SetWithAlias")
+	url, err := store.store.SetWithAlias(ctx, value, alias, opts)
+	endSpan(span, err)
+	return url, err
+}
+
+// Ping checks that the storage backend is reachable.
+func (store *TracedStore) Ping(ctx context.Context) error {
+	ctx, span := store.start(ctx, "Ping")
+	err := store.store.Ping(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// Close closes the traced storage if it holds resources.
+func (store *TracedStore) Close() error {
+	if closer, ok := store.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
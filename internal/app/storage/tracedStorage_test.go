@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedStore(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	store := NewTracedStore(NewMemoryStore(), "memory")
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "test-user")
+
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	_, err = store.GetURL(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	_, err = NewTracedStore(NewMemoryStore(), "memory").BatchDeleteURLs("test-user", []string{url.ShortURL})
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "Storage.Set", spans[0].Name())
+	assert.Equal(t, "Storage.GetURL", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code, "unknown URLs are an expected result")
+	assert.Equal(t, "Storage.BatchDeleteURLs", spans[2].Name())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		sql       string
+		operation string
+		table     string
+	}{
+		{"SELECT origin_url, is_deleted FROM urls WHERE short_url = $1;", "SELECT", "urls"},
+		{"\n        INSERT INTO urls (origin_url, short_url, user_id)\n        VALUES ($1, $2, $3)", "INSERT", "urls"},
+		{"UPDATE urls SET clicks = clicks + 1 WHERE short_url = $1", "UPDATE", "urls"},
+		{"delete from urls where user_id = $1", "DELETE", "urls"},
+		{"CREATE TEMPORARY TABLE batch_urls (ord INTEGER)", "CREATE", ""},
+		{"select 1", "SELECT", ""},
+		{"", "SQL", ""},
+	}
+
+	for _, tt := range tests {
+		operation, table := statementName(tt.sql)
+		assert.Equal(t, tt.operation, operation, tt.sql)
+		assert.Equal(t, tt.table, table, tt.sql)
+	}
+}
@@ -0,0 +1,92 @@
+// Package tracing sets up distributed tracing of the URL shortener service with OpenTelemetry.
+//
+// Incoming W3C `traceparent` headers and gRPC metadata are continued by HTTPMiddleware and the
+// gRPC interceptors, so a request keeps its trace across services. Spans around service methods
+// and storage calls are created by service.TracedService, storage.TracedStore and the SQL query
+// tracer of storage.DatabaseStore, all of which use the global tracer provider set up by Setup.
+//
+// # Exporters
+//
+//   - ExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP.
+//   - ExporterStdout writes spans as JSON lines, which is meant for tests and local debugging.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Supported values of Options.Exporter.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// ServiceName identifies the service in exported spans.
+const ServiceName = "shorturl"
+
+// instrumentationName names the tracer of the HTTP and gRPC spans.
+const instrumentationName = "github.com/golangTroshin/shorturl/internal/app/tracing"
+
+// Options configures Setup.
+type Options struct {
+	Exporter string    // ExporterOTLP, ExporterStdout or empty to export nothing
+	Endpoint string    // URL of the OTLP/HTTP collector, e.g. "http://localhost:4318"
+	Writer   io.Writer // Destination of ExporterStdout; os.Stdout when nil
+}
+
+// Setup installs the W3C trace context propagator and, unless opts.Exporter is empty, a global
+// tracer provider exporting every span. The returned function flushes pending spans and must
+// be called before the process exits.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch opts.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		writer := opts.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
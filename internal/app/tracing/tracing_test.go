@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceparent = "00-" + testTraceID + "-" + testParentID + "-01"
+)
+
+// exportedSpan holds the fields of a span written by the stdout exporter used by the tests.
+type exportedSpan struct {
+	Name        string
+	SpanContext struct{ TraceID string }
+	Parent      struct{ SpanID string }
+	Attributes  []struct {
+		Key   string
+		Value struct{ Value any }
+	}
+	Status struct{ Code string }
+}
+
+// attribute returns the value of the span attribute with the given key.
+func (s exportedSpan) attribute(key string) any {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			return attr.Value.Value
+		}
+	}
+	return nil
+}
+
+// setupStdout installs a stdout exporter and returns a function flushing and decoding the spans.
+func setupStdout(t *testing.T) func() []exportedSpan {
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterStdout, Writer: &buf})
+	require.NoError(t, err)
+
+	return func() []exportedSpan {
+		require.NoError(t, shutdown(context.Background()))
+
+		var spans []exportedSpan
+		decoder := json.NewDecoder(&buf)
+		for decoder.More() {
+			var span exportedSpan
+			require.NoError(t, decoder.Decode(&span))
+			spans = append(spans, span)
+		}
+		return spans
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	spans := setupStdout(t)
+
+	r := chi.NewRouter()
+	r.Use(HTTPMiddleware)
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, testTraceID, trace.SpanContextFromContext(r.Context()).TraceID().String())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	exported := spans()
+	require.Len(t, exported, 1)
+	assert.Equal(t, "GET /{id}", exported[0].Name)
+	assert.Equal(t, testTraceID, exported[0].SpanContext.TraceID)
+	assert.Equal(t, testParentID, exported[0].Parent.SpanID)
+	assert.Equal(t, "/{id}", exported[0].attribute("http.route"))
+	assert.Equal(t, float64(http.StatusInternalServerError), exported[0].attribute("http.response.status_code"))
+	assert.Equal(t, "Error", exported[0].Status.Code)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	spans := setupStdout(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", testTraceparent))
+	info := &grpc.UnaryServerInfo{FullMethod: "/shortener.Shortener/GetOriginalURL"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		assert.Equal(t, testTraceID, trace.SpanContextFromContext(ctx).TraceID().String())
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+
+	_, err := UnaryServerInterceptor(ctx, nil, info, handler)
+	require.Error(t, err)
+
+	exported := spans()
+	require.Len(t, exported, 1)
+	assert.Equal(t, "shortener.Shortener/GetOriginalURL", exported[0].Name)
+	assert.Equal(t, testParentID, exported[0].Parent.SpanID)
+	assert.Equal(t, "GetOriginalURL", exported[0].attribute("rpc.method"))
+	assert.Equal(t, "Unset", exported[0].Status.Code, "client errors do not fail the span")
+}
+
+// contextStream is a server stream with only a context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	spans := setupStdout(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", testTraceparent))
+	info := &grpc.StreamServerInfo{FullMethod: "/shortener.Shortener/ShortenURLs"}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		assert.Equal(t, testTraceID, trace.SpanContextFromContext(stream.Context()).TraceID().String())
+		return status.Error(codes.Internal, "failed")
+	}
+
+	require.Error(t, StreamServerInterceptor(nil, contextStream{ctx: ctx}, info, handler))
+
+	exported := spans()
+	require.Len(t, exported, 1)
+	assert.Equal(t, testTraceID, exported[0].SpanContext.TraceID)
+	assert.Equal(t, "Error", exported[0].Status.Code)
+}
@@ -0,0 +1,181 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+var _ Store = (*FileStore)(nil) // Ensures FileStore implements Store
+
+// FileStore keeps subscriptions and deliveries in memory like MemoryStore, and saves them to a
+// JSON file after every change, so that they survive restarts like the links of the file storage.
+// The file is replaced atomically: a crash leaves either the previous or the new state.
+type FileStore struct {
+	*MemoryStore
+	path   string
+	saveMu sync.Mutex // Serializes the writes of the file
+}
+
+// fileState is the content of the file of a FileStore.
+type fileState struct {
+	Subscriptions []fileSubscription `json:"subscriptions"`
+	Deliveries    []*Delivery        `json:"deliveries"`
+}
+
+// fileSubscription is a subscription as saved in the file, with its owner.
+type fileSubscription struct {
+	Subscription
+	UserID string `json:"user_id"`
+}
+
+// NewFileStore creates a store saved to the file at path, loading the subscriptions and deliveries
+// saved there by a previous process, if any.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook file: %w", err)
+	}
+
+	var state fileState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook file: %w", err)
+	}
+	for _, sub := range state.Subscriptions {
+		sub.Subscription.UserID = sub.UserID
+		s.subscriptions = append(s.subscriptions, &sub.Subscription)
+	}
+	for _, d := range state.Deliveries {
+		s.deliveries = append(s.deliveries, d)
+		s.dispatched[dispatchKey(d.SubscriptionID, d.EventID)] = true
+	}
+	return s, nil
+}
+
+// CreateSubscription stores a new subscription with a new ID.
+func (s *FileStore) CreateSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	created, err := s.MemoryStore.CreateSubscription(ctx, sub)
+	if err != nil {
+		return Subscription{}, err
+	}
+	return created, s.save()
+}
+
+// DeleteSubscription removes the subscription of the user and its deliveries.
+func (s *FileStore) DeleteSubscription(ctx context.Context, userID string, id string) error {
+	if err := s.MemoryStore.DeleteSubscription(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Dispatch adds a pending delivery of the event for each matching subscription of its user,
+// once per subscription. The deliveries are saved before returning, so that the event may be
+// acknowledged.
+func (s *FileStore) Dispatch(ctx context.Context, event storage.Event, payload []byte) (int, error) {
+	added, err := s.MemoryStore.Dispatch(ctx, event, payload)
+	if err != nil || added == 0 {
+		return added, err
+	}
+	return added, s.save()
+}
+
+// Claim leases up to limit ready deliveries, oldest first.
+func (s *FileStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	claimed, err := s.MemoryStore.Claim(ctx, limit, lease)
+	if err != nil || len(claimed) == 0 {
+		return claimed, err
+	}
+	return claimed, s.save()
+}
+
+// Delivered marks the delivery as delivered.
+func (s *FileStore) Delivered(ctx context.Context, id string, responseStatus int) error {
+	if err := s.MemoryStore.Delivered(ctx, id, responseStatus); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Retry records the failed attempt and makes the delivery ready again at the given time.
+func (s *FileStore) Retry(ctx context.Context, id string, responseStatus int, cause error, at time.Time) error {
+	if err := s.MemoryStore.Retry(ctx, id, responseStatus, cause, at); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Fail records the failed attempt and gives up on the delivery.
+func (s *FileStore) Fail(ctx context.Context, id string, responseStatus int, cause error) error {
+	if err := s.MemoryStore.Fail(ctx, id, responseStatus, cause); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Purge removes finished deliveries last updated before the given time.
+func (s *FileStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	purged, err := s.MemoryStore.Purge(ctx, before)
+	if err != nil || purged == 0 {
+		return purged, err
+	}
+	return purged, s.save()
+}
+
+// save replaces the file with the current subscriptions and deliveries.
+func (s *FileStore) save() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	state := fileState{
+		Subscriptions: make([]fileSubscription, 0, len(s.subscriptions)),
+		Deliveries:    make([]*Delivery, 0, len(s.deliveries)),
+	}
+	for _, sub := range s.subscriptions {
+		state.Subscriptions = append(state.Subscriptions, fileSubscription{Subscription: *sub, UserID: sub.UserID})
+	}
+	for _, d := range s.deliveries {
+		c := *d
+		state.Deliveries = append(state.Deliveries, &c)
+	}
+	data, err := json.Marshal(state)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save webhook file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save webhook file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save webhook file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save webhook file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save webhook file: %w", err)
+	}
+	return nil
+}
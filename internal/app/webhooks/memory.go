@@ -0,0 +1,260 @@
+package webhooks
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/google/uuid"
+)
+
+var _ Store = (*MemoryStore)(nil) // Ensures MemoryStore implements Store
+
+// MemoryStore keeps subscriptions and deliveries in memory. They are lost when the process
+// stops, like the links of the memory storage.
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions []*Subscription // In the order they were created
+	deliveries    []*Delivery     // In the order they were dispatched
+	dispatched    map[string]bool // Subscription and event IDs of the deliveries, see dispatchKey
+	ready         chan struct{}
+	now           func() time.Time
+}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		dispatched: make(map[string]bool),
+		ready:      make(chan struct{}, 1),
+		now:        time.Now,
+	}
+}
+
+// CreateSubscription stores a new subscription with a new ID.
+func (s *MemoryStore) CreateSubscription(_ context.Context, sub Subscription) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub.ID = uuid.NewString()
+	sub.Events = slices.Clone(sub.Events)
+	sub.CreatedAt = s.now()
+	s.subscriptions = append(s.subscriptions, &sub)
+	return sub, nil
+}
+
+// ListSubscriptions returns the subscriptions of the user, oldest first.
+func (s *MemoryStore) ListSubscriptions(_ context.Context, userID string) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := []Subscription{}
+	for _, sub := range s.subscriptions {
+		if sub.UserID == userID {
+			c := *sub
+			c.Events = slices.Clone(sub.Events)
+			subs = append(subs, c)
+		}
+	}
+	return subs, nil
+}
+
+// DeleteSubscription removes the subscription of the user and its deliveries.
+func (s *MemoryStore) DeleteSubscription(_ context.Context, userID string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscription(userID, id) == nil {
+		return ErrSubscriptionNotFound
+	}
+	s.subscriptions = slices.DeleteFunc(s.subscriptions, func(sub *Subscription) bool { return sub.ID == id })
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d *Delivery) bool { return d.SubscriptionID == id })
+	return nil
+}
+
+// Dispatch adds a pending delivery of the event for each matching subscription of its user,
+// once per subscription.
+func (s *MemoryStore) Dispatch(_ context.Context, event storage.Event, payload []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	added := 0
+	for _, sub := range s.subscriptions {
+		key := dispatchKey(sub.ID, event.ID)
+		if sub.UserID != event.UserID || !slices.Contains(sub.Events, event.Type) || s.dispatched[key] {
+			continue
+		}
+		s.dispatched[key] = true
+		s.deliveries = append(s.deliveries, &Delivery{
+			ID:             uuid.NewString(),
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        slices.Clone(payload),
+			Status:         StatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+		added++
+	}
+
+	if added > 0 {
+		select {
+		case s.ready <- struct{}{}:
+		default:
+		}
+	}
+	return added, nil
+}
+
+// Claim leases up to limit ready deliveries, oldest first.
+func (s *MemoryStore) Claim(_ context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var claimed []Delivery
+	for _, d := range s.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if d.Status != StatusPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+
+		d.Attempts++
+		d.NextAttemptAt = now.Add(lease)
+		d.UpdatedAt = now
+
+		c := *d
+		sub := s.subscriptionByID(d.SubscriptionID)
+		c.URL, c.Secret = sub.URL, sub.Secret
+		claimed = append(claimed, c)
+	}
+	return claimed, nil
+}
+
+// Delivered marks the delivery as delivered.
+func (s *MemoryStore) Delivered(_ context.Context, id string, responseStatus int) error {
+	return s.finish(id, func(d *Delivery) {
+		d.Status = StatusDelivered
+		d.ResponseStatus = responseStatus
+		d.LastError = ""
+	})
+}
+
+// Retry records the failed attempt and makes the delivery ready again at the given time.
+func (s *MemoryStore) Retry(_ context.Context, id string, responseStatus int, cause error, at time.Time) error {
+	return s.finish(id, func(d *Delivery) {
+		d.ResponseStatus = responseStatus
+		d.LastError = cause.Error()
+		d.NextAttemptAt = at
+	})
+}
+
+// Fail records the failed attempt and gives up on the delivery.
+func (s *MemoryStore) Fail(_ context.Context, id string, responseStatus int, cause error) error {
+	return s.finish(id, func(d *Delivery) {
+		d.Status = StatusFailed
+		d.ResponseStatus = responseStatus
+		d.LastError = cause.Error()
+	})
+}
+
+// Deliveries returns up to limit deliveries of the subscription of the user, newest first.
+func (s *MemoryStore) Deliveries(_ context.Context, userID string, subscriptionID string, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscription(userID, subscriptionID) == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	deliveries := []Delivery{}
+	for i := len(s.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if d := s.deliveries[i]; d.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, *d)
+		}
+	}
+	return deliveries, nil
+}
+
+// Purge removes finished deliveries last updated before the given time.
+func (s *MemoryStore) Purge(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d *Delivery) bool {
+		if d.Status == StatusPending || !d.UpdatedAt.Before(before) {
+			return false
+		}
+		delete(s.dispatched, dispatchKey(d.SubscriptionID, d.EventID))
+		purged++
+		return true
+	})
+	return purged, nil
+}
+
+// Stats counts pending and failed deliveries.
+func (s *MemoryStore) Stats(_ context.Context) (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats Stats
+	for _, d := range s.deliveries {
+		switch d.Status {
+		case StatusPending:
+			stats.Pending++
+		case StatusFailed:
+			stats.Failed++
+		}
+	}
+	return stats, nil
+}
+
+// Ready is signalled when a delivery is dispatched, so that a waiting Worker claims it immediately.
+func (s *MemoryStore) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// finish applies the outcome of an attempt to a pending delivery. Deliveries no longer pending
+// and deliveries removed with their subscription are left unchanged.
+func (s *MemoryStore) finish(id string, apply func(d *Delivery)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.deliveries {
+		if d.ID == id && d.Status == StatusPending {
+			apply(d)
+			d.UpdatedAt = s.now()
+		}
+	}
+	return nil
+}
+
+// subscription returns the subscription of the user with the given ID, or nil.
+func (s *MemoryStore) subscription(userID string, id string) *Subscription {
+	if sub := s.subscriptionByID(id); sub != nil && sub.UserID == userID {
+		return sub
+	}
+	return nil
+}
+
+// subscriptionByID returns the subscription with the given ID, or nil.
+func (s *MemoryStore) subscriptionByID(id string) *Subscription {
+	for _, sub := range s.subscriptions {
+		if sub.ID == id {
+			return sub
+		}
+	}
+	return nil
+}
+
+// dispatchKey identifies the delivery of an event to a subscription.
+func dispatchKey(subscriptionID string, eventID string) string {
+	return subscriptionID + "/" + eventID
+}
@@ -0,0 +1,271 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var _ Store = (*PostgresStore)(nil) // Ensures PostgresStore implements Store
+
+// PostgresStore keeps subscriptions and deliveries in tables shared by all instances using the
+// database. Workers of several instances claim deliveries concurrently with
+// SELECT ... FOR UPDATE SKIP LOCKED, as deletes.PostgresQueue does for deletion jobs.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// webhooksSchema creates the webhook tables. Every statement is idempotent because it runs on
+// each start.
+var webhooksSchema = []string{
+	"CREATE TABLE IF NOT EXISTS webhook_subscriptions (" +
+		" id UUID PRIMARY KEY," +
+		" user_id VARCHAR(250) NOT NULL," +
+		" url TEXT NOT NULL," +
+		" events TEXT[] NOT NULL," +
+		" secret TEXT NOT NULL," +
+		" created_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+	"CREATE INDEX IF NOT EXISTS webhook_subscriptions_user_idx ON webhook_subscriptions (user_id, created_at)",
+	"CREATE TABLE IF NOT EXISTS webhook_deliveries (" +
+		" id UUID PRIMARY KEY," +
+		" subscription_id UUID NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE," +
+		" event_id UUID NOT NULL," +
+		" event_type VARCHAR(32) NOT NULL," +
+		" payload JSONB NOT NULL," +
+		" status VARCHAR(16) NOT NULL DEFAULT 'pending'," +
+		" attempts INT NOT NULL DEFAULT 0," +
+		" response_status INT NOT NULL DEFAULT 0," +
+		" last_error TEXT NOT NULL DEFAULT ''," +
+		" next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now()," +
+		" created_at TIMESTAMPTZ NOT NULL DEFAULT now()," +
+		" updated_at TIMESTAMPTZ NOT NULL DEFAULT now()," +
+		" UNIQUE (subscription_id, event_id))",
+	"CREATE INDEX IF NOT EXISTS webhook_deliveries_ready_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'",
+	"CREATE INDEX IF NOT EXISTS webhook_deliveries_log_idx ON webhook_deliveries (subscription_id, created_at)",
+}
+
+// deliveryColumns lists the columns of webhook_deliveries read by scanDelivery, in order.
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.response_status, d.last_error, d.next_attempt_at, d.created_at, d.updated_at`
+
+// NewPostgresStore creates the webhook tables if needed and returns a store using them. The
+// connection pool is owned by the caller.
+func NewPostgresStore(ctx context.Context, db *sql.DB) (*PostgresStore, error) {
+	for _, statement := range webhooksSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, err
+		}
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// CreateSubscription stores a new subscription with a new ID.
+func (s *PostgresStore) CreateSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	sub.ID = uuid.NewString()
+	query := `INSERT INTO webhook_subscriptions (id, user_id, url, events, secret) VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+	err := s.db.QueryRowContext(ctx, query, sub.ID, sub.UserID, sub.URL, pq.Array(sub.Events), sub.Secret).Scan(&sub.CreatedAt)
+	return sub, err
+}
+
+// ListSubscriptions returns the subscriptions of the user, oldest first.
+func (s *PostgresStore) ListSubscriptions(ctx context.Context, userID string) ([]Subscription, error) {
+	query := `SELECT id, user_id, url, events, secret, created_at FROM webhook_subscriptions
+		WHERE user_id = $1 ORDER BY created_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.URL, pq.Array(&sub.Events), &sub.Secret, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// DeleteSubscription removes the subscription of the user; its deliveries are removed by the
+// foreign key.
+func (s *PostgresStore) DeleteSubscription(ctx context.Context, userID string, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrSubscriptionNotFound
+	}
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrSubscriptionNotFound)
+}
+
+// Dispatch adds a pending delivery of the event for each matching subscription of its user,
+// once per subscription: deliveries already dispatched are kept by the unique constraint.
+func (s *PostgresStore) Dispatch(ctx context.Context, event storage.Event, payload []byte) (int, error) {
+	query := `INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload)
+		SELECT gen_random_uuid(), id, $1, $2, $3 FROM webhook_subscriptions
+		WHERE user_id = $4 AND $2 = ANY(events)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	result, err := s.db.ExecContext(ctx, query, event.ID, event.Type, string(payload), event.UserID)
+	if err != nil {
+		return 0, err
+	}
+	added, err := result.RowsAffected()
+	return int(added), err
+}
+
+// Claim leases up to limit ready deliveries, oldest first, skipping deliveries locked by other
+// workers.
+func (s *PostgresStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	query := `UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING ` + deliveryColumns + `, s.url, s.secret`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var url, secret string
+		delivery, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		delivery.URL, delivery.Secret = url, secret
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Delivered marks the delivery as delivered.
+func (s *PostgresStore) Delivered(ctx context.Context, id string, responseStatus int) error {
+	query := `UPDATE webhook_deliveries SET status = 'delivered', response_status = $2, last_error = '', updated_at = now()
+		WHERE id = $1 AND status = 'pending'`
+	return s.exec(ctx, query, id, responseStatus)
+}
+
+// Retry records the failed attempt and makes the delivery ready again at the given time.
+func (s *PostgresStore) Retry(ctx context.Context, id string, responseStatus int, cause error, at time.Time) error {
+	query := `UPDATE webhook_deliveries SET response_status = $2, last_error = $3, next_attempt_at = $4, updated_at = now()
+		WHERE id = $1 AND status = 'pending'`
+	return s.exec(ctx, query, id, responseStatus, cause.Error(), at)
+}
+
+// Fail records the failed attempt and gives up on the delivery.
+func (s *PostgresStore) Fail(ctx context.Context, id string, responseStatus int, cause error) error {
+	query := `UPDATE webhook_deliveries SET status = 'failed', response_status = $2, last_error = $3, updated_at = now()
+		WHERE id = $1 AND status = 'pending'`
+	return s.exec(ctx, query, id, responseStatus, cause.Error())
+}
+
+// Deliveries returns up to limit deliveries of the subscription of the user, newest first.
+func (s *PostgresStore) Deliveries(ctx context.Context, userID string, subscriptionID string, limit int) ([]Delivery, error) {
+	if _, err := uuid.Parse(subscriptionID); err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	var found bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE id = $1 AND user_id = $2)`,
+		subscriptionID, userID).Scan(&found)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d
+		WHERE d.subscription_id = $1 ORDER BY d.created_at DESC, d.id LIMIT $2`
+	rows, err := s.db.QueryContext(ctx, query, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Purge removes finished deliveries last updated before the given time.
+func (s *PostgresStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE status <> 'pending' AND updated_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// Stats counts pending and failed deliveries.
+func (s *PostgresStore) Stats(ctx context.Context) (Stats, error) {
+	query := `SELECT count(*) FILTER (WHERE status = 'pending'), count(*) FILTER (WHERE status = 'failed') FROM webhook_deliveries`
+
+	var stats Stats
+	err := s.db.QueryRowContext(ctx, query).Scan(&stats.Pending, &stats.Failed)
+	return stats, err
+}
+
+// exec runs a statement recording an attempt of a delivery. Deliveries no longer pending, for
+// instance finished by a worker whose lease expired, are left unchanged.
+func (s *PostgresStore) exec(ctx context.Context, query string, args ...any) error {
+	_, err := s.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// affected returns notFound when the statement changed no row.
+func affected(result sql.Result, notFound error) error {
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// scanDelivery reads a delivery from a row of deliveryColumns followed by the extra columns.
+func scanDelivery(row interface{ Scan(dest ...any) error }, extra ...any) (Delivery, error) {
+	var (
+		delivery Delivery
+		payload  []byte
+		status   string
+	)
+	dest := append([]any{&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType, &payload,
+		&status, &delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &delivery.NextAttemptAt,
+		&delivery.CreatedAt, &delivery.UpdatedAt}, extra...)
+	err := row.Scan(dest...)
+	delivery.Payload = payload
+	delivery.Status = Status(status)
+	return delivery, err
+}
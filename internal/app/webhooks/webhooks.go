@@ -0,0 +1,164 @@
+// Package webhooks notifies downstream systems of link events.
+//
+// Users subscribe a URL to some of the event types of package storage. Storages record every
+// change of a link in their outbox in the same operation as the change, and a Worker turns the
+// outbox events into a Delivery per matching Subscription before acknowledging them. Deliveries
+// are JSON payloads POSTed to the subscribed URL and signed with HMAC-SHA256 using the secret of
+// the subscription, see Sign. Failed deliveries are retried with exponential backoff and every
+// attempt is kept in the delivery log of the subscription. Deliveries are sent at least once and
+// possibly out of order: receivers deduplicate them by event ID and order them by occurred_at.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+)
+
+// Status is the state of a delivery.
+type Status string
+
+// Delivery statuses.
+const (
+	StatusPending   Status = "pending"   // Waiting for a worker, possibly for a retry
+	StatusDelivered Status = "delivered" // The receiver answered with a 2xx status
+	StatusFailed    Status = "failed"    // Failed too often and gave up; see LastError
+)
+
+// Headers of delivery requests.
+const (
+	HeaderID        = "X-Webhook-ID"        // ID of the event, the same for every retry
+	HeaderEvent     = "X-Webhook-Event"     // Type of the event
+	HeaderTimestamp = "X-Webhook-Timestamp" // Unix time of the attempt, part of the signature
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" followed by the hex HMAC, see Sign
+)
+
+// Errors returned by stores.
+var (
+	// ErrSubscriptionNotFound is returned for unknown subscriptions and subscriptions of other users.
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	// ErrInvalidSubscription is wrapped by the errors of Subscription.Validate.
+	ErrInvalidSubscription = errors.New("invalid webhook subscription")
+)
+
+// Subscription is the request of a user to be notified of some events of their links.
+type Subscription struct {
+	ID        string    `json:"id"`               // ID of the subscription
+	UserID    string    `json:"-"`                // Owner of the subscription and of the links
+	URL       string    `json:"url"`              // HTTP(S) URL deliveries are POSTed to
+	Events    []string  `json:"events"`           // Event types delivered, among storage.EventTypes
+	Secret    string    `json:"secret,omitempty"` // Key of the signatures; only shown when created
+	CreatedAt time.Time `json:"created_at"`       // Moment the subscription was created
+}
+
+// Validate checks that the URL is an absolute HTTP(S) URL, that every event type is known and
+// that there is a secret.
+//
+// The URL check only rejects localhost and IP literals of private addresses: hostnames are not
+// resolved here, and the addresses they resolve to are checked when deliveries are dialed, by the
+// guard of the fetcher.NewHTTPClient client of the Worker.
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	if ip := net.ParseIP(u.Hostname()); u.Hostname() == "localhost" || (ip != nil && !fetcher.IsPublicIP(ip)) {
+		return fmt.Errorf("%w: url must not point to a private address", ErrInvalidSubscription)
+	}
+	if len(s.Events) == 0 {
+		return fmt.Errorf("%w: events must not be empty", ErrInvalidSubscription)
+	}
+	for _, event := range s.Events {
+		if !slices.Contains(storage.EventTypes, event) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidSubscription, event)
+		}
+	}
+	if s.Secret == "" {
+		return fmt.Errorf("%w: secret must not be empty", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+// Delivery is the notification of an event to a subscription, with the outcome of its last attempt.
+type Delivery struct {
+	ID             string          `json:"id"`                        // ID of the delivery
+	SubscriptionID string          `json:"subscription_id"`           // Subscription notified
+	EventID        string          `json:"event_id"`                  // ID of the outbox event
+	EventType      string          `json:"event_type"`                // Type of the event
+	Payload        json.RawMessage `json:"payload"`                   // Body POSTed to the subscription
+	Status         Status          `json:"status"`                    // pending, delivered or failed
+	Attempts       int             `json:"attempts"`                  // Number of times the payload was sent
+	ResponseStatus int             `json:"response_status,omitempty"` // HTTP status of the last attempt, if any
+	LastError      string          `json:"last_error,omitempty"`      // Error of the last failed attempt
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`           // Earliest time a worker may send the payload
+	CreatedAt      time.Time       `json:"created_at"`                // Moment the event was dispatched
+	UpdatedAt      time.Time       `json:"updated_at"`                // Moment of the last attempt
+	URL            string          `json:"-"`                         // URL of the subscription, set by Claim
+	Secret         string          `json:"-"`                         // Secret of the subscription, set by Claim
+}
+
+// Stats counts the deliveries of a store per status.
+type Stats struct {
+	Pending int // Deliveries waiting for a worker
+	Failed  int // Deliveries that gave up
+}
+
+// Store keeps subscriptions and their deliveries.
+type Store interface {
+	CreateSubscription(ctx context.Context, sub Subscription) (Subscription, error)                      // CreateSubscription stores a new subscription with a new ID.
+	ListSubscriptions(ctx context.Context, userID string) ([]Subscription, error)                        // ListSubscriptions returns the subscriptions of the user, oldest first.
+	DeleteSubscription(ctx context.Context, userID string, id string) error                              // DeleteSubscription removes the subscription of the user and its deliveries.
+	Dispatch(ctx context.Context, event storage.Event, payload []byte) (int, error)                      // Dispatch adds a pending delivery of the event for each matching subscription of its user, once per subscription.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)                       // Claim leases up to limit ready deliveries, counting an attempt; deliveries not finished within lease are claimed again.
+	Delivered(ctx context.Context, id string, responseStatus int) error                                  // Delivered marks the delivery as delivered.
+	Retry(ctx context.Context, id string, responseStatus int, cause error, at time.Time) error           // Retry records the failed attempt and makes the delivery ready again at the given time.
+	Fail(ctx context.Context, id string, responseStatus int, cause error) error                          // Fail records the failed attempt and gives up on the delivery.
+	Deliveries(ctx context.Context, userID string, subscriptionID string, limit int) ([]Delivery, error) // Deliveries returns up to limit deliveries of the subscription of the user, newest first.
+	Purge(ctx context.Context, before time.Time) (int, error)                                            // Purge removes finished deliveries last updated before the given time.
+	Stats(ctx context.Context) (Stats, error)                                                            // Stats counts pending and failed deliveries.
+}
+
+// NewSecret returns a random secret for signing the deliveries of a subscription.
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+// Sign returns the X-Webhook-Signature header of a payload sent at the given time:
+// "sha256=" followed by the hex HMAC-SHA256, keyed with the secret, of the Unix timestamp,
+// a dot and the payload.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of the payload sent at the Unix timestamp
+// of the X-Webhook-Timestamp header. Receivers should also reject old timestamps to prevent
+// replays.
+func Verify(secret string, timestamp string, signature string, payload []byte) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	expected := Sign(secret, time.Unix(seconds, 0), payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,357 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/http/middleware"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"github.com/golangTroshin/shorturl/internal/app/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastOptions makes workers poll and retry quickly.
+var fastOptions = webhooks.Options{
+	BaseURL:      "http://short.test",
+	PollInterval: time.Millisecond,
+	MinBackoff:   time.Millisecond,
+	MaxBackoff:   2 * time.Millisecond,
+	Client:       fetcher.NewHTTPClient(fetcher.Options{AllowPrivate: true}), // Receivers listen on loopback
+}
+
+// received is a request accepted by a receiver.
+type received struct {
+	header  http.Header
+	body    []byte
+	payload webhooks.Payload
+}
+
+// receiver is an httptest server answering deliveries with the given statuses in turn, and
+// with 204 once they are used up.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []received
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rcv := &receiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload webhooks.Payload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.requests = append(rcv.requests, received{header: r.Header.Clone(), body: body, payload: payload})
+		status := http.StatusNoContent
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+// received returns the requests accepted so far.
+func (rcv *receiver) received() []received {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]received(nil), rcv.requests...)
+}
+
+// setup creates a memory storage and a subscription of user1 to the given events at the receiver.
+func setup(t *testing.T, rcv *receiver, events ...string) (*storage.MemoryStore, *webhooks.MemoryStore, webhooks.Subscription) {
+	hooks := webhooks.NewMemoryStore()
+	sub, err := hooks.CreateSubscription(context.Background(), webhooks.Subscription{
+		UserID: "user1",
+		URL:    rcv.URL,
+		Events: events,
+		Secret: "secret",
+	})
+	require.NoError(t, err)
+	return storage.NewMemoryStore(), hooks, sub
+}
+
+// run starts the worker until the test ends.
+func run(t *testing.T, worker *webhooks.Worker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go worker.Run(ctx)
+}
+
+// waitForLog waits until the delivery log of the subscription holds a delivery with the given
+// status and returns the log.
+func waitForLog(t *testing.T, hooks webhooks.Store, sub webhooks.Subscription, status webhooks.Status) []webhooks.Delivery {
+	var log []webhooks.Delivery
+	require.Eventually(t, func() bool {
+		var err error
+		log, err = hooks.Deliveries(context.Background(), sub.UserID, sub.ID, 10)
+		return err == nil && len(log) > 0 && log[0].Status == status
+	}, 2*time.Second, time.Millisecond)
+	return log
+}
+
+func TestWorker_DeliversSignedPayloads(t *testing.T) {
+	rcv := newReceiver(t)
+	store, hooks, sub := setup(t, rcv, storage.EventLinkCreated, storage.EventLinkClicked)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user1")
+	url, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.NoError(t, store.RecordClick(ctx, url.ShortURL))
+	_, err = store.BatchDeleteURLs("user1", []string{url.ShortURL})
+	require.NoError(t, err)
+	other := context.WithValue(context.Background(), middleware.UserIDKey, "user2")
+	_, err = store.Set(other, "https://example.org")
+	require.NoError(t, err)
+
+	run(t, webhooks.NewWorker(store, hooks, fastOptions))
+
+	// The deletion and the links of other users are not subscribed.
+	waitForLog(t, hooks, sub, webhooks.StatusDelivered)
+	require.Eventually(t, func() bool { return len(rcv.received()) == 2 }, 2*time.Second, time.Millisecond)
+	byType := make(map[string]received)
+	for _, request := range rcv.received() {
+		byType[request.payload.Type] = request
+	}
+	require.Contains(t, byType, storage.EventLinkCreated)
+	require.Contains(t, byType, storage.EventLinkClicked)
+
+	created := byType[storage.EventLinkCreated]
+	assert.Equal(t, storage.EventLinkCreated, created.payload.Type)
+	assert.Equal(t, "http://short.test/"+url.ShortURL, created.payload.Data.ShortURL)
+	assert.Equal(t, "https://example.com", created.payload.Data.OriginalURL)
+	assert.Equal(t, created.payload.ID, created.header.Get(webhooks.HeaderID))
+	assert.Equal(t, storage.EventLinkCreated, created.header.Get(webhooks.HeaderEvent))
+	assert.Equal(t, "application/json", created.header.Get("Content-Type"))
+	assert.Equal(t, byType[storage.EventLinkClicked].payload.ID, byType[storage.EventLinkClicked].header.Get(webhooks.HeaderID))
+	assert.True(t, webhooks.Verify("secret", created.header.Get(webhooks.HeaderTimestamp),
+		created.header.Get(webhooks.HeaderSignature), created.body), "signature is valid")
+	assert.False(t, webhooks.Verify("other", created.header.Get(webhooks.HeaderTimestamp),
+		created.header.Get(webhooks.HeaderSignature), created.body), "signature depends on the secret")
+
+	// Dispatched events leave the outbox.
+	pending, err := store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestWorker_RetriesFailedDeliveries(t *testing.T) {
+	rcv := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	store, hooks, sub := setup(t, rcv, storage.EventLinkCreated)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user1")
+	_, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	run(t, webhooks.NewWorker(store, hooks, fastOptions))
+
+	log := waitForLog(t, hooks, sub, webhooks.StatusDelivered)
+	require.Len(t, log, 1)
+	assert.Equal(t, 3, log[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, log[0].ResponseStatus)
+	assert.Empty(t, log[0].LastError)
+
+	// Every attempt carries the same event, signed anew.
+	requests := rcv.received()
+	require.Len(t, requests, 3)
+	for _, request := range requests {
+		assert.Equal(t, requests[0].payload.ID, request.header.Get(webhooks.HeaderID))
+		assert.True(t, webhooks.Verify("secret", request.header.Get(webhooks.HeaderTimestamp),
+			request.header.Get(webhooks.HeaderSignature), request.body))
+	}
+}
+
+func TestWorker_GivesUp(t *testing.T) {
+	rcv := newReceiver(t, http.StatusGone, http.StatusGone, http.StatusGone)
+	store, hooks, sub := setup(t, rcv, storage.EventLinkCreated)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user1")
+	_, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	opts := fastOptions
+	opts.MaxAttempts = 3
+	run(t, webhooks.NewWorker(store, hooks, opts))
+
+	log := waitForLog(t, hooks, sub, webhooks.StatusFailed)
+	assert.Equal(t, 3, log[0].Attempts)
+	assert.Equal(t, http.StatusGone, log[0].ResponseStatus)
+	assert.Equal(t, "receiver answered 410 Gone", log[0].LastError)
+
+	stats, err := hooks.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, webhooks.Stats{Failed: 1}, stats)
+}
+
+func TestWorker_RefusesPrivateAddresses(t *testing.T) {
+	rcv := newReceiver(t)
+	store, hooks, sub := setup(t, rcv, storage.EventLinkCreated)
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user1")
+	_, err := store.Set(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	// The default client refuses the loopback receiver.
+	opts := fastOptions
+	opts.Client = nil
+	opts.MaxAttempts = 1
+	run(t, webhooks.NewWorker(store, hooks, opts))
+
+	log := waitForLog(t, hooks, sub, webhooks.StatusFailed)
+	assert.Contains(t, log[0].LastError, fetcher.ErrForbiddenAddress.Error())
+	assert.Zero(t, log[0].ResponseStatus)
+	assert.Empty(t, rcv.received())
+}
+
+func TestWorker_Shutdown(t *testing.T) {
+	worker := webhooks.NewWorker(storage.NewMemoryStore(), webhooks.NewMemoryStore(), fastOptions)
+	run(t, worker)
+	require.Eventually(t, func() bool { return worker.Check(context.Background()) == nil }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, worker.Shutdown(ctx))
+	assert.Error(t, worker.Check(context.Background()), "worker stopped")
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	hooks := webhooks.NewMemoryStore()
+	sub, err := hooks.CreateSubscription(ctx, webhooks.Subscription{
+		UserID: "user1", URL: "http://receiver.test", Events: []string{storage.EventLinkDeleted}, Secret: "secret",
+	})
+	require.NoError(t, err)
+
+	event := storage.Event{ID: "event1", Type: storage.EventLinkDeleted, UserID: "user1"}
+
+	t.Run("events are dispatched once", func(t *testing.T) {
+		added, err := hooks.Dispatch(ctx, event, []byte(`{}`))
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+
+		added, err = hooks.Dispatch(ctx, event, []byte(`{}`))
+		require.NoError(t, err)
+		assert.Equal(t, 0, added)
+	})
+
+	t.Run("claimed deliveries carry the subscription", func(t *testing.T) {
+		claimed, err := hooks.Claim(ctx, 10, time.Hour)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, "http://receiver.test", claimed[0].URL)
+		assert.Equal(t, "secret", claimed[0].Secret)
+
+		claimed, err = hooks.Claim(ctx, 10, time.Hour)
+		require.NoError(t, err)
+		assert.Empty(t, claimed, "the leased delivery is not claimed again")
+	})
+
+	t.Run("subscriptions of other users are not found", func(t *testing.T) {
+		_, err := hooks.Deliveries(ctx, "user2", sub.ID, 10)
+		assert.ErrorIs(t, err, webhooks.ErrSubscriptionNotFound)
+		assert.ErrorIs(t, hooks.DeleteSubscription(ctx, "user2", sub.ID), webhooks.ErrSubscriptionNotFound)
+	})
+
+	t.Run("deleting a subscription deletes its deliveries", func(t *testing.T) {
+		require.NoError(t, hooks.DeleteSubscription(ctx, "user1", sub.ID))
+		subs, err := hooks.ListSubscriptions(ctx, "user1")
+		require.NoError(t, err)
+		assert.Empty(t, subs)
+
+		stats, err := hooks.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, webhooks.Stats{}, stats)
+	})
+}
+
+func TestFileStore_SurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	hooks, err := webhooks.NewFileStore(path)
+	require.NoError(t, err)
+
+	sub, err := hooks.CreateSubscription(ctx, webhooks.Subscription{
+		UserID: "user1", URL: "http://receiver.test", Events: []string{storage.EventLinkDeleted}, Secret: "secret",
+	})
+	require.NoError(t, err)
+	event := storage.Event{ID: "event1", Type: storage.EventLinkDeleted, UserID: "user1"}
+	added, err := hooks.Dispatch(ctx, event, []byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, 1, added)
+
+	reopened, err := webhooks.NewFileStore(path)
+	require.NoError(t, err)
+
+	subs, err := reopened.ListSubscriptions(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, sub.ID, subs[0].ID)
+	assert.Equal(t, "secret", subs[0].Secret)
+
+	added, err = reopened.Dispatch(ctx, event, []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, 0, added, "the event was dispatched before the restart")
+
+	claimed, err := reopened.Claim(ctx, 10, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "http://receiver.test", claimed[0].URL)
+}
+
+func TestSubscription_Validate(t *testing.T) {
+	valid := webhooks.Subscription{URL: "https://receiver.test/hook", Events: []string{storage.EventLinkCreated}, Secret: "secret"}
+	assert.NoError(t, valid.Validate())
+
+	tests := map[string]func(s *webhooks.Subscription){
+		"relative url":  func(s *webhooks.Subscription) { s.URL = "/hook" },
+		"other scheme":  func(s *webhooks.Subscription) { s.URL = "ftp://receiver.test" },
+		"localhost":     func(s *webhooks.Subscription) { s.URL = "http://localhost:8080/hook" },
+		"loopback":      func(s *webhooks.Subscription) { s.URL = "http://127.0.0.1/hook" },
+		"link-local":    func(s *webhooks.Subscription) { s.URL = "http://169.254.169.254/latest/meta-data" },
+		"private":       func(s *webhooks.Subscription) { s.URL = "https://[fd00::1]/hook" },
+		"no events":     func(s *webhooks.Subscription) { s.Events = nil },
+		"unknown event": func(s *webhooks.Subscription) { s.Events = []string{"link.renamed"} },
+		"no secret":     func(s *webhooks.Subscription) { s.Secret = "" },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			sub := valid
+			change(&sub)
+			assert.ErrorIs(t, sub.Validate(), webhooks.ErrInvalidSubscription)
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	signature := webhooks.Sign("secret", at, []byte(`{"id":"1"}`))
+
+	// echo -n '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54", signature)
+	assert.True(t, webhooks.Verify("secret", "1700000000", signature, []byte(`{"id":"1"}`)))
+	assert.False(t, webhooks.Verify("secret", "1700000001", signature, []byte(`{"id":"1"}`)))
+	assert.False(t, webhooks.Verify("secret", "1700000000", signature, []byte(`{"id":"2"}`)))
+	assert.False(t, webhooks.Verify("secret", "not a time", signature, []byte(`{"id":"1"}`)))
+}
+
+func TestOptions_Backoff(t *testing.T) {
+	opts := webhooks.Options{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, opts.Backoff(1))
+	assert.Equal(t, 4*time.Second, opts.Backoff(3))
+	assert.Equal(t, 10*time.Second, opts.Backoff(5))
+}
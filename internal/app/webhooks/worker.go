@@ -0,0 +1,330 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golangTroshin/shorturl/internal/app/fetcher"
+	"github.com/golangTroshin/shorturl/internal/app/logger"
+	"github.com/golangTroshin/shorturl/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// Options tune a Worker. Zero fields select the defaults.
+type Options struct {
+	BaseURL      string        // Prefix of the short URLs in payloads, e.g. http://localhost:8080
+	BatchSize    int           // Most events and deliveries handled at once; 100 by default
+	PollInterval time.Duration // Time between polls while there is nothing to do; 1s by default
+	Lease        time.Duration // Time after which a claimed delivery not finished is claimed again; 1m by default
+	Timeout      time.Duration // Time a receiver has to answer a delivery; 10s by default
+	MaxAttempts  int           // Attempts before a delivery is given up as failed; 8 by default
+	MinBackoff   time.Duration // Delay before the first retry, doubled on every further one; 10s by default
+	MaxBackoff   time.Duration // Longest delay between retries; 1h by default
+	Retention    time.Duration // Time finished deliveries are kept in the delivery log; 7 days by default
+	Client       *http.Client  // Client sending the deliveries; a fetcher.NewHTTPClient with Timeout by default
+}
+
+// withDefaults returns the options with zero fields replaced by the defaults.
+func (o Options) withDefaults() Options {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.Lease <= 0 {
+		o.Lease = time.Minute
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 8
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 10 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Hour
+	}
+	if o.Retention <= 0 {
+		o.Retention = 7 * 24 * time.Hour
+	}
+	if o.Client == nil {
+		// Subscribed URLs are chosen by users, so deliveries must not reach internal addresses,
+		// neither directly nor through redirects.
+		o.Client = fetcher.NewHTTPClient(fetcher.Options{Timeout: o.Timeout})
+	}
+	return o
+}
+
+// Backoff returns the delay before retrying a delivery that failed its given attempt.
+func (o Options) Backoff(attempt int) time.Duration {
+	o = o.withDefaults()
+
+	delay := o.MinBackoff
+	for i := 1; i < attempt && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, o.MaxBackoff)
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID         string      `json:"id"`          // ID of the event, the same as the X-Webhook-ID header
+	Type       string      `json:"type"`        // Type of the event, see storage.EventTypes
+	OccurredAt time.Time   `json:"occurred_at"` // Moment of the change
+	Data       PayloadLink `json:"data"`        // The changed link
+}
+
+// PayloadLink describes the link of a Payload.
+type PayloadLink struct {
+	ShortURL    string `json:"short_url"`    // Full short URL of the link
+	OriginalURL string `json:"original_url"` // Destination of the link
+}
+
+// Worker turns the events of an outbox into deliveries and sends them.
+type Worker struct {
+	outbox storage.Outbox
+	store  Store
+	opts   Options
+
+	running  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{} // Closed by Shutdown
+	stopped  chan struct{} // Closed when Run returns
+}
+
+// NewWorker creates a worker dispatching the events of outbox to the subscriptions of store.
+func NewWorker(outbox storage.Outbox, store Store, opts Options) *Worker {
+	return &Worker{
+		outbox:  outbox,
+		store:   store,
+		opts:    opts.withDefaults(),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Run dispatches events and sends deliveries until ctx is done or Shutdown is called. It must be
+// called once, typically as a goroutine.
+func (w *Worker) Run(ctx context.Context) {
+	w.running.Store(true)
+	defer func() {
+		w.running.Store(false)
+		close(w.stopped)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var ready <-chan struct{}
+	if notifier, ok := w.store.(interface{ Ready() <-chan struct{} }); ok {
+		ready = notifier.Ready()
+	}
+
+	poll := time.NewTicker(w.opts.PollInterval)
+	defer poll.Stop()
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+
+	for {
+		dispatched, err := w.dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Default().Error("unable to dispatch link events", zap.Error(err))
+		}
+		claimed, err := w.deliverBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Default().Error("unable to claim webhook deliveries", zap.Error(err))
+		}
+		if (dispatched == w.opts.BatchSize || claimed == w.opts.BatchSize) && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ready:
+		case <-poll.C:
+		case <-purge.C:
+			w.purge(ctx)
+		}
+	}
+}
+
+// Shutdown stops Run and waits until it returns. Deliveries in flight are cancelled and, like
+// events not dispatched yet, sent again after the next start when the store is durable.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	select {
+	case <-w.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook worker not stopped: %w", ctx.Err())
+	}
+}
+
+// Check reports an error when the worker is not running. It is meant to be registered as a
+// liveness check.
+func (w *Worker) Check(_ context.Context) error {
+	if !w.running.Load() {
+		return errors.New("webhook worker is not running")
+	}
+	return nil
+}
+
+// dispatch turns a batch of outbox events into deliveries and acknowledges them. It returns the
+// number of dispatched events. Events are acknowledged only once dispatched, so a failure leaves them
+// for the next attempt; Store.Dispatch ignores the events dispatched twice.
+func (w *Worker) dispatch(ctx context.Context) (int, error) {
+	events, err := w.outbox.PendingEvents(ctx, w.opts.BatchSize)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	var (
+		acked       = make([]string, 0, len(events))
+		dispatchErr error
+	)
+	for _, event := range events {
+		var payload []byte
+		payload, dispatchErr = json.Marshal(w.payload(event))
+		if dispatchErr == nil {
+			_, dispatchErr = w.store.Dispatch(ctx, event, payload)
+		}
+		if dispatchErr != nil {
+			break
+		}
+		acked = append(acked, event.ID)
+	}
+
+	if len(acked) > 0 {
+		if err := w.outbox.AckEvents(ctx, acked); err != nil {
+			return 0, err
+		}
+	}
+	return len(acked), dispatchErr
+}
+
+// payload returns the body of the deliveries of the event.
+func (w *Worker) payload(event storage.Event) Payload {
+	return Payload{
+		ID:         event.ID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		Data: PayloadLink{
+			ShortURL:    w.opts.BaseURL + "/" + event.ShortURL,
+			OriginalURL: event.OriginalURL,
+		},
+	}
+}
+
+// deliverBatch claims a batch of deliveries and sends them concurrently. It returns the number
+// of claimed deliveries.
+func (w *Worker) deliverBatch(ctx context.Context) (int, error) {
+	deliveries, err := w.store.Claim(ctx, w.opts.BatchSize, w.opts.Lease)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// deliver sends a delivery and records the outcome, retrying failures with backoff.
+func (w *Worker) deliver(ctx context.Context, delivery Delivery) {
+	log := logger.Default().With(zap.String("delivery_id", delivery.ID), zap.String("event_id", delivery.EventID),
+		zap.Int("attempts", delivery.Attempts))
+
+	status, err := w.send(ctx, delivery)
+	if ctx.Err() != nil {
+		return // The lease expires and the delivery is claimed again
+	}
+	if err == nil {
+		if err := w.store.Delivered(ctx, delivery.ID, status); err != nil {
+			log.Error("unable to record webhook delivery", zap.Error(err))
+		}
+		return
+	}
+
+	if delivery.Attempts >= w.opts.MaxAttempts {
+		log.Error("webhook delivery gave up", zap.Error(err))
+		if err := w.store.Fail(ctx, delivery.ID, status, err); err != nil {
+			log.Error("unable to record failed webhook delivery", zap.Error(err))
+		}
+		return
+	}
+
+	delay := w.opts.Backoff(delivery.Attempts)
+	log.Warn("webhook delivery failed", zap.Duration("retry_in", delay), zap.Error(err))
+	if err := w.store.Retry(ctx, delivery.ID, status, err, time.Now().Add(delay)); err != nil {
+		log.Error("unable to retry webhook delivery", zap.Error(err))
+	}
+}
+
+// send POSTs the signed payload of the delivery. It returns the response status, if any, and an
+// error unless the status is 2xx.
+func (w *Worker) send(ctx context.Context, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "shorturl-webhooks")
+	req.Header.Set(HeaderID, delivery.EventID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, now, delivery.Payload))
+
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// purge removes finished deliveries older than the retention.
+func (w *Worker) purge(ctx context.Context) {
+	purged, err := w.store.Purge(ctx, time.Now().Add(-w.opts.Retention))
+	if err != nil {
+		logger.Default().Error("unable to purge webhook deliveries", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		logger.Default().Debug("purged webhook deliveries", zap.Int("count", purged))
+	}
+}
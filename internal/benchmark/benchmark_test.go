@@ -71,9 +71,9 @@ func BenchmarkAPIPostBatchHandler(b *testing.B) {
 		{CorrelationID: "1", OriginalURL: "https://example.com"},
 		{CorrelationID: "2", OriginalURL: "https://another.com"},
 	}
-	urlObjs := []storage.URL{
-		{UUID: "1", ShortURL: "EAaArVRs"},
-		{UUID: "2", ShortURL: "BMOSbMDJ"},
+	urlObjs := []storage.BatchURL{
+		{URL: storage.URL{UUID: "1", ShortURL: "EAaArVRs"}},
+		{URL: storage.URL{UUID: "2", ShortURL: "BMOSbMDJ"}},
 	}
 
 	mockStore.EXPECT().
@@ -104,7 +104,7 @@ func BenchmarkAPIDeleteUrlsHandler(b *testing.B) {
 
 	mockStore.EXPECT().
 		BatchDeleteURLs(userID, urlIDs).
-		Return(nil).
+		Return(urlIDs, nil).
 		AnyTimes()
 
 	ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
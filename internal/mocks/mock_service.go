@@ -9,6 +9,10 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	broker "github.com/golangTroshin/shorturl/internal/app/broker"
+	deletes "github.com/golangTroshin/shorturl/internal/app/deletes"
+	qrcode "github.com/golangTroshin/shorturl/internal/app/qrcode"
+	service "github.com/golangTroshin/shorturl/internal/app/service"
 	storage "github.com/golangTroshin/shorturl/internal/app/storage"
 )
 
@@ -36,10 +40,10 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 }
 
 // BatchShortenURLs mocks base method.
-func (m *MockService) BatchShortenURLs(ctx context.Context, urls []storage.RequestBodyBanch) ([]storage.URL, error) {
+func (m *MockService) BatchShortenURLs(ctx context.Context, urls []storage.RequestBodyBanch) ([]service.BatchResult, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "BatchShortenURLs", ctx, urls)
-	ret0, _ := ret[0].([]storage.URL)
+	ret0, _ := ret[0].([]service.BatchResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -51,11 +55,12 @@ func (mr *MockServiceMockRecorder) BatchShortenURLs(ctx, urls interface{}) *gomo
 }
 
 // DeleteUserURLs mocks base method.
-func (m *MockService) DeleteUserURLs(ctx context.Context, shortURLs []string) error {
+func (m *MockService) DeleteUserURLs(ctx context.Context, shortURLs []string) (deletes.Job, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DeleteUserURLs", ctx, shortURLs)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(deletes.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // DeleteUserURLs indicates an expected call of DeleteUserURLs.
@@ -64,6 +69,50 @@ func (mr *MockServiceMockRecorder) DeleteUserURLs(ctx, shortURLs interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserURLs", reflect.TypeOf((*MockService)(nil).DeleteUserURLs), ctx, shortURLs)
 }
 
+// ExportUserURLs mocks base method.
+func (m *MockService) ExportUserURLs(ctx context.Context, yield func(storage.URL) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUserURLs", ctx, yield)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportUserURLs indicates an expected call of ExportUserURLs.
+func (mr *MockServiceMockRecorder) ExportUserURLs(ctx, yield interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUserURLs", reflect.TypeOf((*MockService)(nil).ExportUserURLs), ctx, yield)
+}
+
+// FindUserURLs mocks base method.
+func (m *MockService) FindUserURLs(ctx context.Context, query storage.URLQuery) (storage.URLPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUserURLs", ctx, query)
+	ret0, _ := ret[0].(storage.URLPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUserURLs indicates an expected call of FindUserURLs.
+func (mr *MockServiceMockRecorder) FindUserURLs(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserURLs", reflect.TypeOf((*MockService)(nil).FindUserURLs), ctx, query)
+}
+
+// GetDeletion mocks base method.
+func (m *MockService) GetDeletion(ctx context.Context, id string) (deletes.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletion", ctx, id)
+	ret0, _ := ret[0].(deletes.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletion indicates an expected call of GetDeletion.
+func (mr *MockServiceMockRecorder) GetDeletion(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletion", reflect.TypeOf((*MockService)(nil).GetDeletion), ctx, id)
+}
+
 // GetOriginalURL mocks base method.
 func (m *MockService) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
 	m.ctrl.T.Helper()
@@ -94,6 +143,21 @@ func (mr *MockServiceMockRecorder) GetStats(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockService)(nil).GetStats), ctx)
 }
 
+// GetUserURL mocks base method.
+func (m *MockService) GetUserURL(ctx context.Context, shortURL string) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserURL", ctx, shortURL)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserURL indicates an expected call of GetUserURL.
+func (mr *MockServiceMockRecorder) GetUserURL(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURL", reflect.TypeOf((*MockService)(nil).GetUserURL), ctx, shortURL)
+}
+
 // GetUserURLs mocks base method.
 func (m *MockService) GetUserURLs(ctx context.Context) ([]storage.URL, error) {
 	m.ctrl.T.Helper()
@@ -109,6 +173,21 @@ func (mr *MockServiceMockRecorder) GetUserURLs(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURLs", reflect.TypeOf((*MockService)(nil).GetUserURLs), ctx)
 }
 
+// ImportURL mocks base method.
+func (m *MockService) ImportURL(ctx context.Context, req storage.RequestURL, alias string) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportURL", ctx, req, alias)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportURL indicates an expected call of ImportURL.
+func (mr *MockServiceMockRecorder) ImportURL(ctx, req, alias interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportURL", reflect.TypeOf((*MockService)(nil).ImportURL), ctx, req, alias)
+}
+
 // PingDatabase mocks base method.
 func (m *MockService) PingDatabase(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -123,6 +202,65 @@ func (mr *MockServiceMockRecorder) PingDatabase(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingDatabase", reflect.TypeOf((*MockService)(nil).PingDatabase), ctx)
 }
 
+// QRCode mocks base method.
+func (m *MockService) QRCode(ctx context.Context, shortURL, level string, opts qrcode.ImageOptions) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QRCode", ctx, shortURL, level, opts)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QRCode indicates an expected call of QRCode.
+func (mr *MockServiceMockRecorder) QRCode(ctx, shortURL, level, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QRCode", reflect.TypeOf((*MockService)(nil).QRCode), ctx, shortURL, level, opts)
+}
+
+// RecordClick mocks base method.
+func (m *MockService) RecordClick(ctx context.Context, shortURL string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordClick", ctx, shortURL)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordClick indicates an expected call of RecordClick.
+func (mr *MockServiceMockRecorder) RecordClick(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordClick", reflect.TypeOf((*MockService)(nil).RecordClick), ctx, shortURL)
+}
+
+// ResolveURL mocks base method.
+func (m *MockService) ResolveURL(ctx context.Context, shortURL string) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveURL", ctx, shortURL)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveURL indicates an expected call of ResolveURL.
+func (mr *MockServiceMockRecorder) ResolveURL(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveURL", reflect.TypeOf((*MockService)(nil).ResolveURL), ctx, shortURL)
+}
+
+// SetURLRules mocks base method.
+func (m *MockService) SetURLRules(ctx context.Context, shortURL string, rules []storage.Rule) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLRules", ctx, shortURL, rules)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetURLRules indicates an expected call of SetURLRules.
+func (mr *MockServiceMockRecorder) SetURLRules(ctx, shortURL, rules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLRules", reflect.TypeOf((*MockService)(nil).SetURLRules), ctx, shortURL, rules)
+}
+
 // ShortenURL mocks base method.
 func (m *MockService) ShortenURL(ctx context.Context, originalURL string) (storage.URL, error) {
 	m.ctrl.T.Helper()
@@ -137,3 +275,48 @@ func (mr *MockServiceMockRecorder) ShortenURL(ctx, originalURL interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenURL", reflect.TypeOf((*MockService)(nil).ShortenURL), ctx, originalURL)
 }
+
+// ShortenURLWithOptions mocks base method.
+func (m *MockService) ShortenURLWithOptions(ctx context.Context, req storage.RequestURL) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShortenURLWithOptions", ctx, req)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShortenURLWithOptions indicates an expected call of ShortenURLWithOptions.
+func (mr *MockServiceMockRecorder) ShortenURLWithOptions(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenURLWithOptions", reflect.TypeOf((*MockService)(nil).ShortenURLWithOptions), ctx, req)
+}
+
+// UpdateURLOptions mocks base method.
+func (m *MockService) UpdateURLOptions(ctx context.Context, shortURL string, opts storage.LinkOptions) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURLOptions", ctx, shortURL, opts)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateURLOptions indicates an expected call of UpdateURLOptions.
+func (mr *MockServiceMockRecorder) UpdateURLOptions(ctx, shortURL, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURLOptions", reflect.TypeOf((*MockService)(nil).UpdateURLOptions), ctx, shortURL, opts)
+}
+
+// WatchLinks mocks base method.
+func (m *MockService) WatchLinks(ctx context.Context) (*broker.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchLinks", ctx)
+	ret0, _ := ret[0].(*broker.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchLinks indicates an expected call of WatchLinks.
+func (mr *MockServiceMockRecorder) WatchLinks(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchLinks", reflect.TypeOf((*MockService)(nil).WatchLinks), ctx)
+}
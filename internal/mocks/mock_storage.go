@@ -35,12 +35,28 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// ActiveURLs mocks base method.
+func (m *MockStorage) ActiveURLs(ctx context.Context, after string, limit int) ([]storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActiveURLs", ctx, after, limit)
+	ret0, _ := ret[0].([]storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActiveURLs indicates an expected call of ActiveURLs.
+func (mr *MockStorageMockRecorder) ActiveURLs(ctx, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActiveURLs", reflect.TypeOf((*MockStorage)(nil).ActiveURLs), ctx, after, limit)
+}
+
 // BatchDeleteURLs mocks base method.
-func (m *MockStorage) BatchDeleteURLs(userID string, batch []string) error {
+func (m *MockStorage) BatchDeleteURLs(userID string, batch []string) ([]string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "BatchDeleteURLs", userID, batch)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // BatchDeleteURLs indicates an expected call of BatchDeleteURLs.
@@ -94,6 +110,64 @@ func (mr *MockStorageMockRecorder) GetStats(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockStorage)(nil).GetStats), ctx)
 }
 
+// GetURL mocks base method.
+func (m *MockStorage) GetURL(ctx context.Context, key string) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURL", ctx, key)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURL indicates an expected call of GetURL.
+func (mr *MockStorageMockRecorder) GetURL(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURL", reflect.TypeOf((*MockStorage)(nil).GetURL), ctx, key)
+}
+
+// Ping mocks base method.
+func (m *MockStorage) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStorageMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), ctx)
+}
+
+// QueryByUserID mocks base method.
+func (m *MockStorage) QueryByUserID(ctx context.Context, userID string, query storage.URLQuery) (storage.URLPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryByUserID", ctx, userID, query)
+	ret0, _ := ret[0].(storage.URLPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryByUserID indicates an expected call of QueryByUserID.
+func (mr *MockStorageMockRecorder) QueryByUserID(ctx, userID, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryByUserID", reflect.TypeOf((*MockStorage)(nil).QueryByUserID), ctx, userID, query)
+}
+
+// RecordClick mocks base method.
+func (m *MockStorage) RecordClick(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordClick", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordClick indicates an expected call of RecordClick.
+func (mr *MockStorageMockRecorder) RecordClick(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordClick", reflect.TypeOf((*MockStorage)(nil).RecordClick), ctx, key)
+}
+
 // Set mocks base method.
 func (m *MockStorage) Set(ctx context.Context, value string) (storage.URL, error) {
 	m.ctrl.T.Helper()
@@ -110,10 +184,10 @@ func (mr *MockStorageMockRecorder) Set(ctx, value interface{}) *gomock.Call {
 }
 
 // SetBatch mocks base method.
-func (m *MockStorage) SetBatch(ctx context.Context, batch []storage.RequestBodyBanch) ([]storage.URL, error) {
+func (m *MockStorage) SetBatch(ctx context.Context, batch []storage.RequestBodyBanch) ([]storage.BatchURL, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SetBatch", ctx, batch)
-	ret0, _ := ret[0].([]storage.URL)
+	ret0, _ := ret[0].([]storage.BatchURL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -123,3 +197,113 @@ func (mr *MockStorageMockRecorder) SetBatch(ctx, batch interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBatch", reflect.TypeOf((*MockStorage)(nil).SetBatch), ctx, batch)
 }
+
+// SetHealth mocks base method.
+func (m *MockStorage) SetHealth(ctx context.Context, key string, health storage.LinkHealth) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetHealth", ctx, key, health)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetHealth indicates an expected call of SetHealth.
+func (mr *MockStorageMockRecorder) SetHealth(ctx, key, health interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHealth", reflect.TypeOf((*MockStorage)(nil).SetHealth), ctx, key, health)
+}
+
+// SetPageInfo mocks base method.
+func (m *MockStorage) SetPageInfo(ctx context.Context, key string, page storage.PageInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPageInfo", ctx, key, page)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPageInfo indicates an expected call of SetPageInfo.
+func (mr *MockStorageMockRecorder) SetPageInfo(ctx, key, page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPageInfo", reflect.TypeOf((*MockStorage)(nil).SetPageInfo), ctx, key, page)
+}
+
+// SetWithAlias mocks base method.
+func (m *MockStorage) SetWithAlias(ctx context.Context, value, alias string, opts storage.LinkOptions) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWithAlias", ctx, value, alias, opts)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetWithAlias indicates an expected call of SetWithAlias.
+func (mr *MockStorageMockRecorder) SetWithAlias(ctx, value, alias, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithAlias", reflect.TypeOf((*MockStorage)(nil).SetWithAlias), ctx, value, alias, opts)
+}
+
+// UpdateOptions mocks base method.
+func (m *MockStorage) UpdateOptions(ctx context.Context, userID, key string, opts storage.LinkOptions) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOptions", ctx, userID, key, opts)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOptions indicates an expected call of UpdateOptions.
+func (mr *MockStorageMockRecorder) UpdateOptions(ctx, userID, key, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOptions", reflect.TypeOf((*MockStorage)(nil).UpdateOptions), ctx, userID, key, opts)
+}
+
+// UpdateRules mocks base method.
+func (m *MockStorage) UpdateRules(ctx context.Context, userID, key string, rules []storage.Rule) (storage.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRules", ctx, userID, key, rules)
+	ret0, _ := ret[0].(storage.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRules indicates an expected call of UpdateRules.
+func (mr *MockStorageMockRecorder) UpdateRules(ctx, userID, key, rules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRules", reflect.TypeOf((*MockStorage)(nil).UpdateRules), ctx, userID, key, rules)
+}
+
+// MockFlusher is a mock of Flusher interface.
+type MockFlusher struct {
+	ctrl     *gomock.Controller
+	recorder *MockFlusherMockRecorder
+}
+
+// MockFlusherMockRecorder is the mock recorder for MockFlusher.
+type MockFlusherMockRecorder struct {
+	mock *MockFlusher
+}
+
+// NewMockFlusher creates a new mock instance.
+func NewMockFlusher(ctrl *gomock.Controller) *MockFlusher {
+	mock := &MockFlusher{ctrl: ctrl}
+	mock.recorder = &MockFlusherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFlusher) EXPECT() *MockFlusherMockRecorder {
+	return m.recorder
+}
+
+// Flush mocks base method.
+func (m *MockFlusher) Flush(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Flush", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Flush indicates an expected call of Flush.
+func (mr *MockFlusherMockRecorder) Flush(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Flush", reflect.TypeOf((*MockFlusher)(nil).Flush), ctx)
+}